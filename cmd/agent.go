@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"vssh/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+// agentCmd manages the certificates vssh adds to ssh-agent
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Manage vssh certificates in ssh-agent",
+	Long: `Manage the certificates vssh adds to ssh-agent with --agent,
+ssh.use_agent or ssh.agent_only.
+
+Expired certificates and certificates replaced by a newer one for the same
+user, role and engine are removed automatically whenever vssh adds a
+certificate. vssh agent clean removes them on demand.
+
+Examples:
+  vssh agent clean`,
+}
+
+// agentCleanCmd removes stale vssh certificates from ssh-agent
+var agentCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove expired and superseded vssh certificates from ssh-agent",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		keyring, closeAgent, err := ssh.ConnectAgent()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer closeAgent()
+
+		removed, err := ssh.PruneAgent(keyring)
+		for _, identity := range removed {
+			fmt.Printf("Removed %s\n", identity)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(removed) == 0 {
+			fmt.Println("No stale vssh certificates in ssh-agent")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+	agentCmd.AddCommand(agentCleanCmd)
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"vssh/internal/agentd"
+	"vssh/internal/config"
+	"vssh/internal/metrics"
+	"vssh/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+// agentdCmd runs the certificate refresh daemon
+var agentdCmd = &cobra.Command{
+	Use:   "agentd [target...]",
+	Short: "Keep certificates fresh in the background",
+	Long: `Run a daemon that keeps the certificates of the given targets, or of
+agentd.targets, fresh so connections never wait for signing.
+
+Every agentd.check_interval the daemon re-signs each certificate within
+ssh.renew_before of expiring, including those of jump hosts, and renews the
+Vault token before it expires. With ssh.use_agent or ssh.agent_only the
+certificates are kept in ssh-agent as well.
+
+The daemon answers requests on a control socket, agentd.sock in the state
+directory: vssh agentd status and vssh agentd cert use it, and other tools can
+too (see CONFIG.md). Targets requested through the socket are kept fresh from
+then on.
+
+With agentd.metrics_file the metrics shown by vssh stats are written in the
+Prometheus text format every agentd.check_interval, for node_exporter's
+textfile collector; with agentd.metrics_listen they are served at /metrics.
+
+The daemon runs in the foreground until interrupted; run it from a service
+manager (systemd, launchd) or a terminal multiplexer to keep it running.
+
+Examples:
+  vssh agentd alice@db01 @prod
+  vssh agentd status
+  vssh agentd cert alice@db02`,
+	ValidArgsFunction: completeTarget,
+	Run: func(cmd *cobra.Command, args []string) {
+		statusPath := agentd.DefaultStatusPath(config.GetStateDir())
+		if status, _ := agentd.ReadStatus(statusPath); status != nil && !agentdStale(status) {
+			fmt.Fprintf(os.Stderr, "Error: vssh agentd is already running (pid %d)\n", status.PID)
+			os.Exit(1)
+		}
+
+		s := newSession(cmd)
+		logger := s.logger
+
+		rawTargets := args
+		if len(rawTargets) == 0 {
+			rawTargets = s.config.Agentd.Targets
+		}
+		if len(rawTargets) == 0 {
+			logger.Fatalf("No targets to keep certificates for: give targets or set agentd.targets")
+		}
+
+		expanded, err := s.expandTargets(rawTargets)
+		if err != nil {
+			logger.Fatalf("Invalid SSH target: %v", err)
+		}
+		var targets []*ssh.SSHTarget
+		for _, rawTarget := range expanded {
+			target, err := ssh.ResolveTarget(s.config, rawTarget)
+			if err != nil {
+				logger.Fatalf("Invalid SSH target %s: %v", rawTarget, err)
+			}
+			// The daemon renews the token of the vault section only
+			if name, _ := s.config.VaultFor(s.config.ResolveHost(target.Hostname)); name != "" {
+				logger.Fatalf("%s is signed by Vault cluster %s, vssh agentd only keeps certificates from the vault section fresh", rawTarget, name)
+			}
+			targets = append(targets, target)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		logger.Infof("vssh agentd keeping %d certificate(s) fresh, checking every %v", len(targets), s.config.Agentd.CheckInterval)
+		daemon := agentd.New(s.config, s.vaultClient, s.signer, logger, targets)
+		go func() {
+			if err := daemon.Serve(ctx, agentd.DefaultSocketPath(config.GetStateDir())); err != nil {
+				logger.Warnf("Control socket unavailable: %v", err)
+			}
+		}()
+		go exportMetrics(ctx, s)
+		if err := daemon.Run(ctx, statusPath); err != nil {
+			logger.Fatalf("%v", err)
+		}
+		logger.Infof("vssh agentd stopped")
+	},
+}
+
+// agentdStatusCmd shows the state of the running daemon
+var agentdStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the certificates the daemon keeps fresh",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := outputFormat(cmd)
+		state := "running"
+		status, err := agentd.NewClient(agentd.DefaultSocketPath(config.GetStateDir())).Status()
+		if err != nil {
+			// Fall back to the status file of a daemon without a control socket
+			status, err = agentd.ReadStatus(agentd.DefaultStatusPath(config.GetStateDir()))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if status == nil {
+				if format != outputTable {
+					printStructured(format, agentdStatusOutput{State: "not running"})
+				} else {
+					fmt.Println("vssh agentd is not running")
+				}
+				os.Exit(1)
+			}
+			if agentdStale(status) {
+				state = "not responding"
+			}
+		}
+
+		if format != outputTable {
+			printStructured(format, agentdStatusOutput{State: state, Status: status})
+			return
+		}
+		fmt.Printf("vssh agentd %s (pid %d, started %s)\n", state, status.PID, status.Started.Local().Format("2006-01-02 15:04"))
+		fmt.Printf("Last refresh: %s, next: %s\n", status.LastRefresh.Local().Format("15:04:05"), status.NextRefresh.Local().Format("15:04:05"))
+
+		switch {
+		case status.TokenError != "":
+			fmt.Printf("Vault token:  %s\n", status.TokenError)
+		case status.TokenExpires.IsZero():
+			fmt.Printf("Vault token:  valid, never expires\n")
+		default:
+			fmt.Printf("Vault token:  valid until %s\n", status.TokenExpires.Local().Format("2006-01-02 15:04"))
+		}
+
+		for _, cert := range status.Certificates {
+			switch {
+			case cert.Error != "":
+				fmt.Printf("  %-30s  error: %s\n", cert.Target, cert.Error)
+			case cert.Path == "":
+				fmt.Printf("  %-30s  in ssh-agent\n", cert.Target)
+			case cert.Expires.IsZero():
+				fmt.Printf("  %-30s  valid, never expires  %s\n", cert.Target, cert.Path)
+			default:
+				fmt.Printf("  %-30s  valid until %s  %s\n", cert.Target, cert.Expires.Local().Format("15:04"), cert.Path)
+			}
+		}
+	},
+}
+
+// agentdCertCmd asks the running daemon for a valid certificate
+var agentdCertCmd = &cobra.Command{
+	Use:   "cert <target>",
+	Short: "Get a valid certificate from the daemon",
+	Long: `Ask the running daemon for a valid certificate for target, signing one if
+needed, and print its path. The daemon keeps it fresh from then on.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTarget,
+	Run: func(cmd *cobra.Command, args []string) {
+		cert, err := agentd.NewClient(agentd.DefaultSocketPath(config.GetStateDir())).Certificate(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if cert.Path == "" {
+			fmt.Printf("Certificate for %s is in ssh-agent\n", cert.Target)
+			return
+		}
+		fmt.Println(cert.Path)
+	},
+}
+
+// agentdStatusOutput is the state of the daemon as printed with --output:
+// running, not responding or not running, with its status unless not running
+type agentdStatusOutput struct {
+	State string `json:"state"`
+	*agentd.Status
+}
+
+// agentdStale reports whether a daemon missed its last refresh, so it has
+// stopped without removing its status file or is hung
+func agentdStale(status *agentd.Status) bool {
+	return time.Now().After(status.NextRefresh.Add(time.Minute))
+}
+
+// exportMetrics writes the metrics to agentd.metrics_file every
+// agentd.check_interval and serves them on agentd.metrics_listen, if set,
+// until ctx is cancelled
+func exportMetrics(ctx context.Context, s *session) {
+	store := metricsStore()
+	if listen := s.config.Agentd.MetricsListen; listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler(store))
+		server := &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+		go func() {
+			s.logger.Infof("Serving metrics on http://%s/metrics", listen)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Warnf("Metrics unavailable: %v", err)
+			}
+		}()
+	}
+
+	path := s.config.Agentd.MetricsFile
+	if path == "" {
+		return
+	}
+	ticker := time.NewTicker(s.config.Agentd.CheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := metrics.WriteTextfile(path, store); err != nil {
+			s.logger.Warnf("Failed to write metrics file: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(agentdCmd)
+	agentdCmd.AddCommand(agentdStatusCmd)
+	agentdCmd.AddCommand(agentdCertCmd)
+}
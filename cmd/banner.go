@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"strings"
+
+	"vssh/internal/ssh"
+	"vssh/internal/utils"
+)
+
+// showBanner prints the banner configured for a target's host, such as a
+// change freeze notice, before connecting. It is written to stderr rather than
+// logged, so --quiet and the log level and format don't hide it, and each
+// banner is shown only once per invocation.
+func (s *session) showBanner(target *ssh.SSHTarget) {
+	banner := strings.TrimSpace(s.config.ResolveHost(target.Hostname).Banner)
+	if banner == "" {
+		return
+	}
+
+	s.bannerMu.Lock()
+	defer s.bannerMu.Unlock()
+	if s.bannersShown[banner] {
+		return
+	}
+	if s.bannersShown == nil {
+		s.bannersShown = make(map[string]bool)
+	}
+	s.bannersShown[banner] = true
+	utils.PrintNotice(banner)
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"vssh/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+// bookmarkCmd manages named shortcuts for targets
+var bookmarkCmd = &cobra.Command{
+	Use:   "bookmark",
+	Short: "Manage bookmarked targets",
+	Long: `Manage short names for frequently used targets.
+
+Bookmarks are stored in the bookmarks section of the configuration file and can
+be used anywhere a target is accepted:
+
+  vssh bookmark add db admin@db01.prod.example.com:2222
+  vssh db
+
+Bookmark names are case-insensitive.`,
+}
+
+// bookmarkAddCmd adds or replaces a bookmark
+var bookmarkAddCmd = &cobra.Command{
+	Use:   "add <name> <target>",
+	Short: "Add or replace a bookmark",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, target := strings.ToLower(args[0]), args[1]
+		if err := validateBookmarkName(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		configPath := config.GetActiveConfigPath()
+		if err := config.SetValue(configPath, "bookmarks."+name, target); err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Bookmarked %s as %s\n", target, name)
+	},
+}
+
+// bookmarkRmCmd removes a bookmark
+var bookmarkRmCmd = &cobra.Command{
+	Use:     "rm <name>",
+	Aliases: []string{"remove"},
+	Short:   "Remove a bookmark",
+	Args:    cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		loaded, err := config.LoadConfig()
+		if err != nil || len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var names []string
+		for name := range loaded.Bookmarks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		name := strings.ToLower(args[0])
+
+		configPath := config.GetActiveConfigPath()
+		if err := config.UnsetValue(configPath, "bookmarks."+name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing bookmark: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Removed bookmark %s\n", name)
+	},
+}
+
+// bookmarkListCmd lists bookmarks
+var bookmarkListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List bookmarks",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		loaded := mustLoadConfig()
+
+		if len(loaded.Bookmarks) == 0 {
+			fmt.Println("No bookmarks configured")
+			return
+		}
+
+		names := make([]string, 0, len(loaded.Bookmarks))
+		for name := range loaded.Bookmarks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("%s\t%s\n", name, loaded.Bookmarks[name])
+		}
+	},
+}
+
+// validateBookmarkName rejects names that can't be stored as a config key or
+// would be mistaken for another kind of target
+func validateBookmarkName(name string) error {
+	if name == "" || strings.ContainsAny(name, ".@:[] \t") || strings.HasPrefix(name, "!") {
+		return fmt.Errorf("invalid bookmark name %q: names cannot contain '.', '@', ':', brackets or spaces, or start with '!'", name)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(bookmarkCmd)
+	bookmarkCmd.AddCommand(bookmarkAddCmd)
+	bookmarkCmd.AddCommand(bookmarkRmCmd)
+	bookmarkCmd.AddCommand(bookmarkListCmd)
+}
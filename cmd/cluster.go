@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"vssh/internal/ssh"
+	"vssh/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// clusterCmd broadcasts typed input to shells on many hosts
+var clusterCmd = &cobra.Command{
+	Use:   "cluster (--hosts <host,...> | --group <name> | --tag <key=value>)",
+	Short: "Type commands into shells on many hosts at once",
+	Long: `Open a shell on each host with the built-in SSH client and send every line
+typed to all of them, like clusterssh. Output is printed as it arrives, each
+line prefixed with its host.
+
+The shells have no pseudo-terminal, so they show no prompt and programs that
+need one, such as editors or sudo asking for a password, don't work. End the
+session with Ctrl-D; Ctrl-C interrupts the commands running on every host.
+
+vssh cluster exits with the highest exit code returned by any host's shell.
+
+Examples:
+  vssh cluster --hosts web1,web2,web3
+  vssh cluster --group web
+  vssh cluster --tag Environment=staging`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		hosts, _ := cmd.Flags().GetStringSlice("hosts")
+		if group, _ := cmd.Flags().GetString("group"); group != "" {
+			hosts = append(hosts, "@"+group)
+		}
+		if tag, _ := cmd.Flags().GetString("tag"); tag != "" {
+			hosts = append(hosts, "@tag:"+tag)
+		}
+		if len(hosts) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: --hosts, --group or --tag is required\n")
+			os.Exit(1)
+		}
+
+		s := newSession(cmd)
+		// Broadcast sessions never stop to ask for a role per host
+		s.selectRole = false
+
+		targets, err := s.expandTargets(hosts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		results := runCluster(s, targets, os.Stdin)
+
+		exitCode := 0
+		for _, result := range results {
+			if result.code == 0 {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%s: %v\n", result.host, result.err)
+			exitCode = max(exitCode, result.code)
+		}
+		os.Exit(exitCode)
+	},
+}
+
+// clusterHost is the shell of one host in a broadcast session
+type clusterHost struct {
+	input *io.PipeWriter
+	lines chan []byte
+}
+
+// runCluster signs certificates for every target, opens a shell on each and
+// sends every line read from input to all shells that are still open, until
+// input ends or every shell has exited. Results are returned in target order.
+func runCluster(s *session, targets []string, input io.Reader) []runResult {
+	results := make([]runResult, len(targets))
+	options := &ssh.SSHOptions{}
+
+	var outputMu sync.Mutex
+	var sessions sync.WaitGroup
+	var hosts []*clusterHost
+	for i, rawTarget := range targets {
+		results[i].host = rawTarget
+		// Sign sequentially so each username is signed once and reused from disk
+		target, certPath, targetOptions, err := s.prepareTarget(rawTarget, options)
+		var conn *connection
+		if err == nil {
+			conn = newConnection(s, rawTarget, target, certPath)
+			err = beforeConnect(s, conn)
+		}
+		if err != nil {
+			results[i].code, results[i].err = exitCode(err), err
+			continue
+		}
+
+		reader, writer := io.Pipe()
+		host := &clusterHost{input: writer, lines: make(chan []byte, 64)}
+		hosts = append(hosts, host)
+
+		sessions.Add(1)
+		go func() {
+			defer sessions.Done()
+			started := time.Now()
+			prefix := rawTarget + ": "
+			stdout := utils.NewPrefixWriter(os.Stdout, prefix, &outputMu)
+			stderr := utils.NewPrefixWriter(os.Stderr, prefix, &outputMu)
+			err := s.sshClient.ExecuteNative(target, certPath, targetOptions, nil, reader, stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+			// Input for a shell that exited is dropped
+			reader.Close()
+			recordConnection(s, err)
+			afterDisconnect(s, conn, started, err)
+			if err != nil {
+				results[i].err = err
+				results[i].code = exitCode(err)
+			}
+		}()
+		go host.write()
+	}
+	if len(hosts) == 0 {
+		return results
+	}
+
+	done := make(chan struct{})
+	go func() {
+		sessions.Wait()
+		close(done)
+	}()
+
+	s.logger.Infof("Connected to %d hosts: input is sent to all of them, Ctrl-D ends the session", len(hosts))
+	lines := make(chan []byte)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			lines <- append(scanner.Bytes(), '\n')
+		}
+	}()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if ok {
+				for _, host := range hosts {
+					host.lines <- line
+				}
+				continue
+			}
+			for _, host := range hosts {
+				close(host.lines)
+			}
+			<-done
+			return results
+		case <-done:
+			return results
+		}
+	}
+}
+
+// write sends the host's lines to its shell, so a slow host doesn't hold up
+// the others, and ends the shell's input once there are no more
+func (h *clusterHost) write() {
+	for line := range h.lines {
+		h.input.Write(line)
+	}
+	h.input.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(clusterCmd)
+	clusterCmd.Flags().StringSlice("hosts", nil, "Comma-separated hosts to open shells on ([user@]host[:port] or @group)")
+	clusterCmd.Flags().String("group", "", "Open shells on the hosts of this group")
+	clusterCmd.Flags().String("tag", "", "Open shells on inventory hosts with these tags (key=value[,key=value])")
+	clusterCmd.RegisterFlagCompletionFunc("hosts", completeHostList)
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"vssh/internal/config"
+	"vssh/internal/inventory"
+	"vssh/internal/ssh"
+	"vssh/internal/vault"
+	"vssh/pkg/types"
+
+	"github.com/spf13/cobra"
+)
+
+// completionCmd generates shell completion scripts
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generate shell completion scripts",
+	Long: `Generate a shell completion script for vssh.
+
+Completion covers commands and flags as well as targets: hosts from the
+configuration, groups (@name), bookmarks, connection history, ssh_config
+aliases and the Vault inventory, plus usernames from the configuration.
+
+Bash (requires bash-completion):
+  source <(vssh completion bash)
+  vssh completion bash > /etc/bash_completion.d/vssh
+
+Zsh:
+  vssh completion zsh > "${fpath[1]}/_vssh"
+
+Fish:
+  vssh completion fish > ~/.config/fish/completions/vssh.fish
+
+PowerShell:
+  vssh completion powershell | Out-String | Invoke-Expression`,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		switch args[0] {
+		case "bash":
+			err = rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			err = rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			err = rootCmd.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			err = rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		default:
+			cmd.PrintErrf("Error: unsupported shell %q (use bash, zsh, fish or powershell)\n", args[0])
+			os.Exit(1)
+		}
+		if err != nil {
+			cmd.PrintErrf("Error generating completion: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// completeTarget completes the destination with known targets. Flag parsing
+// is disabled for the root command, so args still hold any ssh options.
+func completeTarget(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	_, positional, err := ssh.ParseSSHArgs(args)
+	if err != nil {
+		// The word is the value of an option such as -i, which may be a file
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	if len(positional) > 0 || strings.HasPrefix(toComplete, "-") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return targetCompletions(toComplete)
+}
+
+// completeHostList completes the last entry of a comma-separated host list
+func completeHostList(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := ""
+	if index := strings.LastIndex(toComplete, ","); index >= 0 {
+		prefix, toComplete = toComplete[:index+1], toComplete[index+1:]
+	}
+
+	candidates, directive := targetCompletions(toComplete)
+	for i := range candidates {
+		candidates[i] = prefix + candidates[i]
+	}
+	return candidates, directive | cobra.ShellCompDirectiveNoSpace
+}
+
+// targetCompletions returns the known targets starting with toComplete. When
+// toComplete has a user@ prefix the host part is completed and the prefix kept.
+func targetCompletions(toComplete string) ([]string, cobra.ShellCompDirective) {
+	loaded, err := config.LoadConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	user, host := "", toComplete
+	if index := strings.LastIndex(toComplete, "@"); index > 0 {
+		user, host = toComplete[:index+1], toComplete[index+1:]
+	}
+
+	var candidates []string
+	seen := map[string]bool{}
+	for _, name := range knownTargets(loaded) {
+		if seen[name] || !strings.HasPrefix(name, host) {
+			continue
+		}
+		// Groups and bookmarks name whole targets and can't take a user
+		if user != "" && (strings.HasPrefix(name, "@") || loaded.Bookmarks[name] != "") {
+			continue
+		}
+		seen[name] = true
+		candidates = append(candidates, user+name)
+	}
+
+	// Offer usernames when nothing has been typed after a user yet
+	if user == "" {
+		for _, name := range knownUsers(loaded) {
+			if strings.HasPrefix(name+"@", toComplete) && !seen[name+"@"] {
+				seen[name+"@"] = true
+				candidates = append(candidates, name+"@")
+			}
+		}
+	}
+
+	sort.Strings(candidates)
+
+	// A bare user@ needs a host after it, so don't add a space
+	directive := cobra.ShellCompDirectiveNoFileComp
+	if len(candidates) > 0 && allHaveSuffix(candidates, "@") {
+		directive |= cobra.ShellCompDirectiveNoSpace
+	}
+	return candidates, directive
+}
+
+// knownTargets collects hosts, groups, bookmarks, history targets, ssh_config
+// aliases and Vault inventory hosts. Sources that fail to load are skipped.
+func knownTargets(cfg *types.Config) []string {
+	var targets []string
+
+	for _, host := range cfg.Hosts {
+		for _, pattern := range strings.Split(host.Pattern, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" && !strings.ContainsAny(pattern, "*?!") {
+				targets = append(targets, pattern)
+			}
+		}
+	}
+
+	for _, name := range cfg.GroupNames() {
+		targets = append(targets, "@"+name)
+		for _, host := range cfg.Groups[name].Hosts {
+			if !strings.ContainsAny(host, "*?!") {
+				targets = append(targets, host)
+			}
+		}
+	}
+
+	for name := range cfg.Bookmarks {
+		targets = append(targets, name)
+	}
+
+	if entries, err := historyStore(cfg).Load(); err == nil {
+		// Most recent first so duplicates keep the latest spelling
+		for i := len(entries) - 1; i >= 0; i-- {
+			targets = append(targets, entries[i].Target)
+		}
+	}
+
+	if sshConfig, err := ssh.LoadUserSSHConfig(cfg); err == nil && sshConfig != nil {
+		targets = append(targets, sshConfig.Hosts()...)
+	}
+
+	for _, host := range inventoryCompletions(cfg) {
+		targets = append(targets, host.Name)
+	}
+
+	return targets
+}
+
+// inventoryCompletions reads the Vault inventory with a saved token, without
+// prompting for authentication. EC2 is not queried since it is too slow for
+// interactive completion.
+func inventoryCompletions(cfg *types.Config) []inventory.Host {
+	if cfg.Inventory.VaultPath == "" {
+		return nil
+	}
+
+	vaultClient, err := vault.NewClient(&cfg.Vault)
+	if err != nil {
+		return nil
+	}
+	vaultClient.GetClient().SetClientTimeout(2 * time.Second)
+	if err := vaultClient.LoadTokenFromFile(); err != nil {
+		return nil
+	}
+
+	hosts, err := inventory.LoadVaultKV(vaultClient, cfg.Inventory.VaultPath)
+	if err != nil {
+		return nil
+	}
+	return hosts
+}
+
+// knownUsers returns the usernames configured for keys and default host users
+func knownUsers(cfg *types.Config) []string {
+	var users []string
+	for name := range cfg.Users {
+		users = append(users, name)
+	}
+	for _, host := range cfg.Hosts {
+		if host.User != "" {
+			users = append(users, host.User)
+		}
+	}
+	for _, group := range cfg.Groups {
+		if group.User != "" {
+			users = append(users, group.User)
+		}
+	}
+	return users
+}
+
+// allHaveSuffix reports whether every string in values ends with suffix
+func allHaveSuffix(values []string, suffix string) bool {
+	for _, value := range values {
+		if !strings.HasSuffix(value, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.ValidArgsFunction = completeTarget
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"vssh/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "View and modify vssh configuration",
+	Long: `View and modify the vssh configuration file without editing YAML by hand.
+
+Keys use dotted notation matching the configuration file structure.
+
+Examples:
+  vssh config get vault.address
+  vssh config set vault.address https://vault.example.com:8200
+  vssh config set ssh.certificate_ttl 2h
+  vssh config set users.alice.private_key ~/.ssh/alice_ed25519
+  vssh config edit`,
+}
+
+// configGetCmd prints a single configuration value
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a configuration key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		loaded := mustLoadConfig()
+
+		value, err := config.GetValue(loaded, args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(config.FormatValue(value))
+	},
+}
+
+// configSetCmd writes a single configuration value
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a configuration key in the config file",
+	Long: `Set a configuration key in the config file.
+
+The value is checked against the key's type (string, boolean, integer,
+duration or comma-separated list) and the resulting configuration is
+validated before the file is written. Comments in the file are preserved.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, raw := args[0], args[1]
+
+		value, err := config.ParseValue(key, raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		configPath := config.GetActiveConfigPath()
+		if err := config.SetValue(configPath, key, value); err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Set %s = %s in %s\n", key, config.FormatValue(value), configPath)
+	},
+}
+
+// configEditCmd opens the config file in the user's editor
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the configuration file in $EDITOR",
+	Long: `Open the active configuration file in $VISUAL or $EDITOR.
+
+The file is edited as a temporary copy. When the editor exits the result is
+validated; if it is invalid the editor is reopened with the error shown at the
+top of the file. Exiting without making changes aborts the edit and the
+original file is left untouched.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := config.GetActiveConfigPath()
+
+		original, err := os.ReadFile(configPath)
+		if os.IsNotExist(err) {
+			// Start from the default template when no config exists yet
+			original, err = defaultConfigTemplate()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		tmpFile, err := os.CreateTemp("", "vssh-config-*.yaml")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating temporary file: %v\n", err)
+			os.Exit(1)
+		}
+		tmpPath := tmpFile.Name()
+		tmpFile.Close()
+		defer os.Remove(tmpPath)
+
+		contents := original
+		for {
+			if err := os.WriteFile(tmpPath, contents, 0600); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing temporary file: %v\n", err)
+				os.Exit(1)
+			}
+
+			if err := runEditor(tmpPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error running editor: %v\n", err)
+				os.Exit(1)
+			}
+
+			edited, err := os.ReadFile(tmpPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading edited file: %v\n", err)
+				os.Exit(1)
+			}
+			edited = stripEditErrors(edited)
+
+			if bytes.Equal(edited, stripEditErrors(contents)) {
+				fmt.Println("Edit cancelled, no changes made.")
+				return
+			}
+
+			if _, err := config.ValidateData(edited); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+				contents = withEditError(edited, err)
+				continue
+			}
+
+			if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating config directory: %v\n", err)
+				os.Exit(1)
+			}
+			if err := os.WriteFile(configPath, edited, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing configuration: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Configuration saved to %s\n", configPath)
+			return
+		}
+	},
+}
+
+// editErrorPrefix marks the error comments added to the top of a file being edited
+const editErrorPrefix = "# vssh: "
+
+// runEditor opens path in the user's preferred editor and waits for it to exit
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		} else {
+			editor = "vi"
+		}
+	}
+
+	// EDITOR may contain arguments, e.g. "code --wait"
+	parts := strings.Fields(editor)
+	editorCmd := exec.Command(parts[0], append(parts[1:], path)...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+	return editorCmd.Run()
+}
+
+// withEditError prepends a validation error as comments so the user sees it when the editor reopens
+func withEditError(data []byte, editErr error) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(editErrorPrefix + "The edited configuration is invalid and was not saved:\n")
+	for _, line := range strings.Split(editErr.Error(), "\n") {
+		buf.WriteString(editErrorPrefix + line + "\n")
+	}
+	buf.WriteString(editErrorPrefix + "Fix the problem, or exit without changes to abort.\n")
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// stripEditErrors removes error comments previously added by withEditError
+func stripEditErrors(data []byte) []byte {
+	for bytes.HasPrefix(data, []byte(editErrorPrefix)) {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			return nil
+		}
+		data = data[end+1:]
+	}
+	return data
+}
+
+// defaultConfigTemplate returns the contents of a freshly initialized config file
+func defaultConfigTemplate() ([]byte, error) {
+	dir, err := os.MkdirTemp("", "vssh-config")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := config.CreateDefaultConfig(path); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configEditCmd)
+}
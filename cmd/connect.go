@@ -0,0 +1,593 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"vssh/internal/config"
+	"vssh/internal/inventory"
+	"vssh/internal/ssh"
+	"vssh/internal/utils"
+	"vssh/internal/vault"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// session holds the clients shared by every connection in one vssh invocation
+type session struct {
+	config      *types.Config
+	logger      *logrus.Logger
+	vaultClient *vault.Client
+	signer      *ssh.Signer
+	sshClient   *ssh.Client
+	inventory   *inventory.Inventory
+
+	// dryRun prints the certificates and ssh commands instead of signing and connecting
+	dryRun bool
+
+	// useAgent loads certificates into the ssh-agent and connects through it
+	useAgent bool
+
+	// keyType selects which of the user's keys is signed for the targets
+	keyType string
+
+	// ttl requests certificates for the targets valid this long, signed afresh
+	ttl time.Duration
+
+	// principals are requested in the targets' certificates in place of the configured ones
+	principals []string
+
+	// strict fails when a new certificate isn't valid for the login user
+	strict bool
+
+	// selectRole asks which Vault role to use for targets without a mapped role
+	selectRole bool
+
+	// mode is the authentication mode for the targets from --mode, if any
+	mode string
+
+	// otp allows logging in to targets with one-time passwords, which only
+	// commands running ssh themselves can pass on
+	otp bool
+
+	// signers sign for the targets of each additional Vault cluster, by name
+	signers map[string]*ssh.Signer
+
+	// record is where to record sessions from --record, if given
+	record string
+
+	// confirm asks for confirmation before connecting to protected hosts
+	confirm bool
+
+	// reason for connecting to protected hosts, from --reason
+	reason string
+
+	// confirmed holds the reason given for each protected host confirmed
+	confirmed map[string]string
+	confirmMu sync.Mutex
+
+	// bannersShown holds the host banners already shown
+	bannersShown map[string]bool
+	bannerMu     sync.Mutex
+
+	// wait retries connecting until the targets' SSH servers answer, every
+	// retryInterval for up to waitTimeout, with --wait
+	wait          bool
+	retryInterval time.Duration
+	waitTimeout   time.Duration
+
+	// reconnects is how many times in a row to reconnect when a connection
+	// drops, from --reconnect
+	reconnects int
+}
+
+// errOTPUnsupported is returned for targets logged in to with one-time
+// passwords by commands that don't run ssh themselves
+var errOTPUnsupported = errors.New("mode otp, which this command doesn't support")
+
+// newSession initializes logging, loads the configuration and ensures a valid
+// Vault token. Failures are fatal since nothing can be done without them.
+func newSession(cmd *cobra.Command) *session {
+	// Initialize logger
+	debug, _ := cmd.Flags().GetBool("debug")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	noColor, _ := cmd.Flags().GetBool("no-color")
+	level := logrus.InfoLevel
+	if debug || verbose {
+		level = logrus.DebugLevel
+	} else if quiet {
+		level = logrus.WarnLevel
+	}
+	utils.InitLogger(level, !noColor && utils.UseColor(os.Stderr))
+
+	logger := utils.GetLogger()
+	logger.Debug("Starting vssh")
+
+	// Load configuration
+	var err error
+	cfg, err = config.LoadConfig()
+	if err != nil {
+		fatalf(logger, exitConfig, "Failed to load configuration: %v", err)
+	}
+
+	if cfg.Logging.Format == "json" {
+		utils.SetJSONFormat()
+	}
+
+	// Keep a log file, usually at debug level, whatever the console shows
+	if cfg.Logging.File != "" {
+		level, _ := logrus.ParseLevel(cfg.Logging.Level)
+		if err := utils.AddLogFile(cfg.Logging.File, level, int64(cfg.Logging.MaxSize)<<20, cfg.Logging.MaxBackups); err != nil {
+			logger.Warnf("Failed to open log file: %v", err)
+		} else {
+			logger.Debugf("Starting vssh %s", strings.Join(os.Args[1:], " "))
+		}
+	}
+
+	// Send connection and signing events to syslog
+	if syslog := cfg.Logging.Syslog; syslog.Enabled {
+		if err := utils.AddSyslog(syslog.Network, syslog.Address, syslog.Tag, syslog.Facility); err != nil {
+			logger.Warnf("Failed to send events to syslog: %v", err)
+		}
+	}
+
+	logger.Debugf("Configuration loaded successfully")
+	logger.Debugf("Vault address: %s", cfg.Vault.Address)
+	logger.Debugf("Auth method: %s", cfg.Vault.AuthMethod)
+
+	// --auto-keygen enables ssh.auto_generate_key for this invocation
+	if autoKeygen, _ := cmd.Flags().GetBool("auto-keygen"); autoKeygen {
+		cfg.SSH.AutoGenerateKey = true
+	}
+
+	keyType, _ := cmd.Flags().GetString("key-type")
+	if keyType != "" && !slices.Contains(types.KeyTypes, keyType) {
+		fatalf(logger, 1, "Invalid --key-type %s, must be one of %s", keyType, strings.Join(types.KeyTypes, ", "))
+	}
+
+	ttl, _ := cmd.Flags().GetDuration("ttl")
+	if ttl < 0 {
+		fatalf(logger, 1, "Invalid --ttl %s, must be positive", ttl)
+	}
+
+	principals, _ := cmd.Flags().GetStringSlice("principal")
+	if slices.Contains(principals, "") {
+		fatalf(logger, 1, "Invalid --principal, must not be empty")
+	}
+
+	mode, _ := cmd.Flags().GetString("mode")
+	if mode != "" && !slices.Contains(types.Modes, mode) {
+		fatalf(logger, 1, "Invalid --mode %s, must be one of %s", mode, strings.Join(types.Modes, ", "))
+	}
+
+	// Create Vault client
+	vaultClient, err := vault.NewClient(&cfg.Vault)
+	if err != nil {
+		fatalf(logger, exitConfig, "Failed to create Vault client: %v", err)
+	}
+
+	// Create authenticator and ensure we have a valid token. Nothing is signed
+	// in a dry run, so an existing token is used without prompting for a login.
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if dryRun {
+		if err := vaultClient.LoadTokenFromFile(); err != nil {
+			logger.Debugf("Could not load token from file: %v", err)
+		}
+	} else {
+		authenticator := newAuthenticator(cfg, vaultClient, &cfg.Vault, logger)
+		if err := authenticator.EnsureAuthenticated(); err != nil {
+			fatalf(logger, exitAuth, "Authentication failed: %v", err)
+		}
+	}
+
+	// Create SSH client and validate the SSH binary is available
+	sshClient := ssh.NewClient(cfg, logger)
+	if err := sshClient.ValidateSSHBinary(); err != nil {
+		fatalf(logger, exitLaunch, "SSH validation failed: %v", err)
+	}
+
+	logger.Debugf("SSH binary validation passed")
+
+	if hostCAConfig := cfg.SSH.HostCA; hostCAConfig.Verify != "" && !dryRun {
+		hostCA, err := loadHostCA(vaultClient, &hostCAConfig)
+		switch {
+		case err == nil:
+			sshClient.SetHostCA(hostCA)
+		case hostCAConfig.Verify == "enforce":
+			fatalf(logger, exitSigning, "Failed to load the host CA: %v", err)
+		default:
+			logger.Warnf("Failed to load the host CA, host certificates won't be verified: %v", err)
+		}
+	}
+
+	s := &session{
+		config:      cfg,
+		logger:      logger,
+		vaultClient: vaultClient,
+		signer:      ssh.NewSigner(vaultClient, cfg, logger),
+		sshClient:   sshClient,
+		dryRun:      dryRun,
+		keyType:     keyType,
+		ttl:         ttl,
+		principals:  principals,
+		mode:        mode,
+	}
+	if strict, _ := cmd.Flags().GetBool("strict"); strict {
+		s.strict = true
+	}
+	s.configureSigner(s.signer)
+	s.selectRole = cfg.Vault.SelectRole && !dryRun
+	s.confirm = true
+	s.reason, _ = cmd.Flags().GetString("reason")
+	if useAgent, _ := cmd.Flags().GetBool("agent"); useAgent || cfg.SSH.UseAgent || cfg.SSH.AgentOnly {
+		s.useAgent = true
+	}
+
+	if err := s.loadInventory(); err != nil {
+		if !dryRun {
+			fatalf(logger, exitConfig, "Failed to load host inventory: %v", err)
+		}
+		logger.Warnf("Failed to load host inventory: %v", err)
+	}
+
+	return s
+}
+
+// configureSigner records the certificates a signer issues and its signing
+// latency, if configured, and makes it remember the roles chosen for targets
+func (s *session) configureSigner(signer *ssh.Signer) {
+	if s.config.History.RecordCertificates {
+		recordCertificates(s, signer)
+	}
+	recordSigning(s, signer)
+	signer.UseRoleChoices(ssh.NewRoleChoices(ssh.DefaultRoleChoicesPath(config.GetStateDir())))
+}
+
+// signerFor returns the signer for the Vault cluster a target's host is mapped
+// to, logging in to an additional cluster the first time one of its hosts is
+// used. Each cluster keeps its own token and caches its own certificates.
+func (s *session) signerFor(target *ssh.SSHTarget) (*ssh.Signer, error) {
+	name, vaultConfig := s.config.VaultFor(s.config.ResolveHost(target.Hostname))
+	if name == "" {
+		return s.signer, nil
+	}
+	if signer, ok := s.signers[name]; ok {
+		return signer, nil
+	}
+
+	// The cluster's signer sees the configuration with its own vault section
+	clusterConfig := *s.config
+	clusterConfig.Vault = vaultConfig
+
+	vaultClient, err := vault.NewClient(&clusterConfig.Vault)
+	if err != nil {
+		return nil, classify(exitConfig, fmt.Errorf("failed to create client for Vault cluster %s: %w", name, err))
+	}
+	if s.dryRun {
+		if err := vaultClient.LoadTokenFromFile(); err != nil {
+			s.logger.Debugf("Could not load token for Vault cluster %s from file: %v", name, err)
+		}
+	} else {
+		s.logger.Debugf("Using Vault cluster %s at %s for %s", name, vaultConfig.Address, target.Hostname)
+		authenticator := newAuthenticator(s.config, vaultClient, &clusterConfig.Vault, s.logger)
+		if err := authenticator.EnsureAuthenticated(); err != nil {
+			return nil, classify(exitAuth, fmt.Errorf("authentication to Vault cluster %s failed: %w", name, err))
+		}
+	}
+
+	signer := ssh.NewSigner(vaultClient, &clusterConfig, s.logger)
+	s.configureSigner(signer)
+	if s.signers == nil {
+		s.signers = make(map[string]*ssh.Signer)
+	}
+	s.signers[name] = signer
+	return signer, nil
+}
+
+// loadHostCA fetches the public key of the Vault host CA
+func loadHostCA(vaultClient *vault.Client, config *types.HostCAConfig) (*ssh.HostCA, error) {
+	caKey, err := vaultClient.SSHCAPublicKey(config.SigningEngine)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewHostCA(config, caKey)
+}
+
+// prepareTarget resolves a target and ensures certificates for it and any jump
+// hosts. It returns the target, its certificate path and a copy of options with
+// the identity and jump hosts filled in.
+func (s *session) prepareTarget(rawTarget string, options *ssh.SSHOptions) (*ssh.SSHTarget, string, *ssh.SSHOptions, error) {
+	// A login name given with -l applies unless the target names its own user
+	if options.LoginName != "" && !strings.Contains(rawTarget, "@") {
+		rawTarget = options.LoginName + "@" + rawTarget
+	}
+
+	target, err := ssh.ResolveTarget(s.config, rawTarget)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("invalid SSH target: %w", err)
+	}
+
+	// --key-type takes precedence over the key_type mapped to the host
+	if s.keyType != "" {
+		target.KeyType = s.keyType
+	}
+
+	// --ttl and --principal apply to the target's certificate, not the jump hosts
+	target.TTL = s.ttl
+	target.Principals = s.principals
+	target.Strict = s.strict
+	target.Mode = s.mode
+
+	// A key given with -i is signed and used in place of the ssh_config IdentityFile
+	if options.IdentityFile != "" {
+		target.IdentityFile = options.IdentityFile
+	}
+
+	// Jump hosts given with -J replace the configured ProxyJump; "none" disables it
+	switch options.ProxyJump {
+	case "":
+	case "none":
+		target.ProxyJump = ""
+	default:
+		target.ProxyJump = options.ProxyJump
+	}
+
+	s.logger.Debugf("Parsed SSH target - Username: %s, Hostname: %s, Alias: %s", target.Username, target.Hostname, target.Alias)
+
+	s.showBanner(target)
+	if err := s.confirmProtected(target); err != nil {
+		return nil, "", nil, classify(1, err)
+	}
+
+	if s.selectRole {
+		signer, err := s.signerFor(target)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if err := signer.SelectRole(target); err != nil {
+			return nil, "", nil, classify(exitSigning, err)
+		}
+	}
+
+	// Each target gets its own identity and jump hosts
+	targetOptions := *options
+	targetOptions.JumpHosts = nil
+
+	var certPath string
+	if s.signer.ResolveMode(target) == "otp" {
+		// The target is logged in to with a one-time password instead of a
+		// certificate; jump hosts still use certificates
+		if !s.otp {
+			return nil, "", nil, fmt.Errorf("%s uses %w", target.Hostname, errOTPUnsupported)
+		}
+		password, err := s.oneTimePassword(target, options)
+		if err != nil {
+			return nil, "", nil, classify(exitSigning, err)
+		}
+		targetOptions.Password = password
+		targetOptions.IdentityFile = ""
+	} else {
+		certPath, err = s.ensureCertificate(target)
+		if err != nil {
+			return nil, "", nil, classify(exitSigning, fmt.Errorf("failed to ensure SSH certificate: %w", err))
+		}
+
+		// Get private key path for identity
+		privateKeyPath, err := s.signer.GetPrivateKeyPath(target)
+		if err != nil {
+			return nil, "", nil, classify(exitSigning, fmt.Errorf("failed to get private key path: %w", err))
+		}
+		targetOptions.IdentityFile = privateKeyPath
+
+		s.logger.Debugf("Private key path: %s", privateKeyPath)
+
+		// In agent mode ssh authenticates with the certificate in the agent
+		if s.useAgent {
+			if err := s.addToAgent(privateKeyPath, certPath); err != nil {
+				return nil, "", nil, classify(exitSigning, err)
+			}
+			certPath = ""
+			targetOptions.IdentityFile = ""
+		}
+	}
+
+	// Ensure certificates for any jump hosts so every hop uses cert auth
+	if target.ProxyJump != "" {
+		hops, err := ssh.ParseProxyJump(s.config, target.ProxyJump)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("invalid ProxyJump: %w", err)
+		}
+
+		for _, hop := range hops {
+			hop.Strict = s.strict
+			hopCert, err := s.ensureCertificate(hop)
+			if err != nil {
+				return nil, "", nil, classify(exitSigning, fmt.Errorf("failed to ensure SSH certificate for jump host %s: %w", hop.Hostname, err))
+			}
+			hopKey, err := s.signer.GetPrivateKeyPath(hop)
+			if err != nil {
+				return nil, "", nil, classify(exitSigning, fmt.Errorf("failed to get private key path for jump host %s: %w", hop.Hostname, err))
+			}
+
+			s.logger.Debugf("Jump host %s@%s using certificate %s", hop.Username, hop.Hostname, hopCert)
+			if s.useAgent {
+				if err := s.addToAgent(hopKey, hopCert); err != nil {
+					return nil, "", nil, classify(exitSigning, err)
+				}
+				hopCert, hopKey = "", ""
+			}
+			targetOptions.JumpHosts = append(targetOptions.JumpHosts, ssh.JumpHost{
+				Target:          hop,
+				CertificateFile: hopCert,
+				IdentityFile:    hopKey,
+				KeyOptions:      ssh.KeyProviderArgs(s.config),
+			})
+		}
+	}
+
+	return target, certPath, &targetOptions, nil
+}
+
+// oneTimePassword generates a one-time password for a target from its Vault
+// OTP role. In a dry run it only prints how it would be generated and returns
+// a placeholder.
+func (s *session) oneTimePassword(target *ssh.SSHTarget, options *ssh.SSHOptions) (string, error) {
+	signer, err := s.signerFor(target)
+	if err != nil {
+		return "", err
+	}
+	if s.dryRun {
+		fmt.Printf("One-time password for %s@%s: would be generated\n", target.Username, target.Hostname)
+		fmt.Printf("  Vault creds path: %s/creds/%s\n", signer.ResolveSigningEngine(target), signer.ResolveRole(target))
+		return "one-time password", nil
+	}
+
+	network := "ip"
+	if options.IPv4 {
+		network = "ip4"
+	} else if options.IPv6 {
+		network = "ip6"
+	}
+	password, err := signer.GenerateOTP(target, network)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate a one-time password: %w", err)
+	}
+	return password, nil
+}
+
+// addToAgent loads a certificate and its private key into the ssh-agent. In a
+// dry run it only prints that it would.
+func (s *session) addToAgent(keyPath, certPath string) error {
+	if s.config.SSH.AgentOnly {
+		// ensureCertificate already put the certificate in the agent
+		return nil
+	}
+	if s.dryRun {
+		fmt.Printf("  ssh-agent:       would be added with %s\n", keyPath)
+		return nil
+	}
+	if err := ssh.AddCertificateToAgent(keyPath, certPath); err != nil {
+		return err
+	}
+	s.logger.Debugf("Certificate %s is in the ssh-agent", certPath)
+	return nil
+}
+
+// expandTargets replaces bookmark names with their targets, @group references
+// with the hosts of the named group, @tag:key=value selectors with matching
+// inventory hosts, inventory host names with their addresses and !N references
+// with targets from the connection history. Group members that are glob
+// patterns cannot be connected to and are skipped.
+func (s *session) expandTargets(targets []string) ([]string, error) {
+	var expanded []string
+	for _, target := range targets {
+		target, err := expandHistoryRef(s.config, target)
+		if err != nil {
+			return nil, err
+		}
+
+		if bookmark, exists := s.config.Bookmarks[strings.ToLower(target)]; exists {
+			s.logger.Debugf("Bookmark %s resolves to %s", target, bookmark)
+			target = bookmark
+		}
+
+		if selector, ok := strings.CutPrefix(target, "@tag:"); ok {
+			hosts, err := s.selectInventory(selector)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, hosts...)
+			continue
+		}
+
+		if !strings.HasPrefix(target, "@") {
+			expanded = append(expanded, s.inventoryTarget(target))
+			continue
+		}
+
+		name := target[1:]
+		group, exists := s.config.Groups[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown host group: %s", name)
+		}
+
+		for _, host := range group.Hosts {
+			if strings.ContainsAny(host, "*?!") {
+				s.logger.Warnf("Skipping pattern %s in group %s", host, name)
+				continue
+			}
+			expanded = append(expanded, s.inventoryTarget(host))
+		}
+	}
+	return expanded, nil
+}
+
+// loadInventory loads the host inventory from the configured sources and
+// applies each host's user and role through the hosts configuration.
+// Explicit hosts entries take precedence since they are matched first.
+func (s *session) loadInventory() error {
+	s.inventory = &inventory.Inventory{}
+
+	if path := s.config.Inventory.VaultPath; path != "" {
+		hosts, err := inventory.LoadVaultKV(s.vaultClient, path)
+		if err != nil {
+			return err
+		}
+		s.logger.Debugf("Loaded %d hosts from Vault inventory %s", len(hosts), path)
+		s.inventory.Hosts = append(s.inventory.Hosts, hosts...)
+	}
+
+	if s.config.Inventory.EC2.Enabled {
+		hosts, err := inventory.LoadEC2(s.config.Inventory.EC2)
+		if err != nil {
+			return err
+		}
+		s.logger.Debugf("Loaded %d hosts from EC2", len(hosts))
+		s.inventory.Hosts = append(s.inventory.Hosts, hosts...)
+	}
+
+	s.config.Hosts = append(s.config.Hosts, s.inventory.HostConfigs()...)
+	return nil
+}
+
+// selectInventory returns the addresses of inventory hosts matching a tag selector
+func (s *session) selectInventory(selector string) ([]string, error) {
+	tags, err := inventory.ParseTagSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := s.inventory.Select(tags)
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no inventory hosts match tag %s", selector)
+	}
+
+	targets := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		targets = append(targets, host.Address)
+	}
+	return targets, nil
+}
+
+// inventoryTarget replaces an inventory host name in target with its address,
+// keeping any user@ prefix
+func (s *session) inventoryTarget(target string) string {
+	user, name := "", target
+	if index := strings.LastIndex(target, "@"); index >= 0 {
+		user, name = target[:index+1], target[index+1:]
+	}
+
+	if host := s.inventory.Find(name); host != nil {
+		s.logger.Debugf("Inventory host %s resolves to %s", name, host.Address)
+		return user + host.Address
+	}
+	return target
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// docsCmd generates reference documentation
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate reference documentation",
+	Long: `Generate reference documentation for every vssh command.
+
+The output is generated from the command definitions, so it always matches
+the installed version. Output contains no generation date, so it is
+reproducible for packaging.`,
+}
+
+// docsManCmd generates man pages
+var docsManCmd = &cobra.Command{
+	Use:   "man",
+	Short: "Generate man pages",
+	Long: `Generate a section 1 man page for vssh and each of its subcommands.
+
+Example:
+  vssh docs man --dir /usr/local/share/man/man1`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("dir")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+			os.Exit(1)
+		}
+
+		header := &doc.GenManHeader{
+			Title:   "VSSH",
+			Section: "1",
+			Source:  fmt.Sprintf("vssh %s", version),
+			Manual:  "vssh manual",
+		}
+
+		rootCmd.DisableAutoGenTag = true
+		if err := doc.GenManTree(rootCmd, header, dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating man pages: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Man pages written to %s\n", dir)
+	},
+}
+
+// docsMarkdownCmd generates Markdown command reference
+var docsMarkdownCmd = &cobra.Command{
+	Use:   "markdown",
+	Short: "Generate Markdown command reference",
+	Long: `Generate one Markdown file per command, linked together, for publishing
+the command reference on a documentation site.
+
+Example:
+  vssh docs markdown --dir docs/cli`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("dir")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+			os.Exit(1)
+		}
+
+		rootCmd.DisableAutoGenTag = true
+		if err := doc.GenMarkdownTree(rootCmd, dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating Markdown: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Markdown reference written to %s\n", dir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(docsCmd)
+	docsCmd.AddCommand(docsManCmd)
+	docsCmd.AddCommand(docsMarkdownCmd)
+
+	docsManCmd.Flags().String("dir", "man", "Directory to write man pages to")
+	docsMarkdownCmd.Flags().String("dir", "docs/cli", "Directory to write Markdown files to")
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"vssh/internal/ssh"
+)
+
+// ensureCertificate returns the certificate for a target, signing it if it is
+// missing or expired. With ssh.agent_only the certificate is only put in the
+// ssh-agent and the returned path is empty. In a dry run it prints the
+// certificate and how it would be signed instead.
+func (s *session) ensureCertificate(target *ssh.SSHTarget) (string, error) {
+	signer, err := s.signerFor(target)
+	if err != nil {
+		return "", err
+	}
+	if !s.dryRun && s.config.SSH.AgentOnly {
+		return "", signer.EnsureAgentCertificate(target)
+	}
+	if !s.dryRun {
+		return signer.EnsureSSHCertificate(target)
+	}
+
+	plan, err := signer.PlanCertificate(target)
+	if err != nil {
+		return "", err
+	}
+
+	if s.config.SSH.AgentOnly {
+		fmt.Printf("Certificate for %s@%s: ssh-agent only (reused if valid, otherwise signed)\n", target.Username, target.Hostname)
+		fmt.Printf("  Vault sign path: %s\n", plan.SignPath)
+		fmt.Printf("  Vault role:      %s\n", plan.Role)
+		printVaultCluster(s, target)
+		if s.config.SSH.EphemeralKey {
+			fmt.Printf("  Public key:      ephemeral ed25519 key\n")
+		} else {
+			fmt.Printf("  Public key:      %s\n", plan.PublicKeyPath)
+		}
+		return "", nil
+	}
+
+	state := "would be signed"
+	if plan.Valid {
+		state = "valid, would be reused"
+	}
+	fmt.Printf("Certificate for %s@%s: %s (%s)\n", target.Username, target.Hostname, plan.Path, state)
+	fmt.Printf("  Vault sign path: %s\n", plan.SignPath)
+	fmt.Printf("  Vault role:      %s\n", plan.Role)
+	printVaultCluster(s, target)
+	if !plan.Valid {
+		fmt.Printf("  Public key:      %s\n", plan.PublicKeyPath)
+		fmt.Printf("  TTL:             %s\n", plan.TTL)
+		if len(plan.Principals) > 0 {
+			fmt.Printf("  Principals:      %s\n", strings.Join(plan.Principals, ", "))
+		}
+		if len(plan.Extensions) > 0 {
+			fmt.Printf("  Extensions:      %s\n", formatOptions(plan.Extensions))
+		}
+		if len(plan.CriticalOptions) > 0 {
+			fmt.Printf("  Critical opts:   %s\n", formatOptions(plan.CriticalOptions))
+		}
+		if keyID, err := signer.KeyID(target, plan); err == nil && keyID != "" {
+			fmt.Printf("  Key ID:          %s\n", keyID)
+		}
+	}
+	return plan.Path, nil
+}
+
+// printVaultCluster prints the additional Vault cluster that signs for a
+// target, if any
+func printVaultCluster(s *session, target *ssh.SSHTarget) {
+	if name, vault := s.config.VaultFor(s.config.ResolveHost(target.Hostname)); name != "" {
+		fmt.Printf("  Vault cluster:   %s (%s)\n", name, vault.Address)
+	}
+}
+
+// formatOptions formats certificate extensions or critical options as sorted
+// name or name=value entries
+func formatOptions(options map[string]string) string {
+	var entries []string
+	for _, name := range slices.Sorted(maps.Keys(options)) {
+		if options[name] == "" {
+			entries = append(entries, name)
+		} else {
+			entries = append(entries, name+"="+options[name])
+		}
+	}
+	return strings.Join(entries, ", ")
+}
+
+// printDryRun prints the certificates each target would use and the exact ssh
+// command line vssh would run, without signing anything or connecting
+func printDryRun(s *session, targets []string, options *ssh.SSHOptions, command []string) {
+	failed := false
+	for i, rawTarget := range targets {
+		if len(targets) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("==> %s <==\n", rawTarget)
+		}
+
+		target, certPath, targetOptions, err := s.prepareTarget(rawTarget, options)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", rawTarget, err)
+			failed = true
+			continue
+		}
+
+		args := s.sshClient.Command(target, certPath, targetOptions, command).Args
+		for j, arg := range args {
+			args[j] = ssh.ShellQuote(arg)
+		}
+		fmt.Printf("Command: %s\n", strings.Join(args, " "))
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
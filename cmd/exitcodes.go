@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"vssh/internal/config"
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Exit codes for failures before the remote command runs, so scripts can tell
+// them apart. Like ssh, vssh otherwise exits with the remote command's exit
+// status, or 255 when ssh can't connect. Usage and other errors exit with 1.
+const (
+	exitConfig  = 2 // the configuration or inventory couldn't be loaded
+	exitAuth    = 3 // logging in to Vault failed
+	exitSigning = 4 // no certificate or one-time password could be obtained
+	exitLaunch  = 5 // ssh, or the tool running it, couldn't be started
+)
+
+// failure is an error with the exit code of its class
+type failure struct {
+	code int
+	err  error
+}
+
+func (f *failure) Error() string {
+	return f.err.Error()
+}
+
+func (f *failure) Unwrap() error {
+	return f.err
+}
+
+// classify gives err the exit code of a failure class, unless it already has
+// one from where it happened
+func classify(code int, err error) error {
+	var f *failure
+	if err == nil || errors.As(err, &f) {
+		return err
+	}
+	return &failure{code: code, err: err}
+}
+
+// exitCode returns the exit code for an error from preparing a target or
+// connecting to it: the code of its class, the remote command's exit status,
+// 5 if ssh couldn't be started, or 255 like ssh
+func exitCode(err error) int {
+	var f *failure
+	if errors.As(err, &f) {
+		return f.code
+	}
+	var launchErr *ssh.LaunchError
+	if errors.As(err, &launchErr) {
+		return exitLaunch
+	}
+	return ssh.ExitCode(err)
+}
+
+// fatalf logs an error and exits with code
+func fatalf(logger *logrus.Logger, code int, format string, args ...interface{}) {
+	logger.Errorf(format, args...)
+	os.Exit(code)
+}
+
+// mustLoadConfig loads the configuration, exiting with exitConfig if it can't
+// be loaded
+func mustLoadConfig() *types.Config {
+	loaded, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(exitConfig)
+	}
+	return loaded
+}
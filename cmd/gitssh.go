@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"vssh/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+// gitSSHCmd runs ssh for git with a Vault-signed certificate
+var gitSSHCmd = &cobra.Command{
+	Use:   "git-ssh [ssh options] [user@]host command",
+	Short: "Run ssh for git with a Vault-signed certificate",
+	Long: `Connect to a git server with a Vault-signed certificate, for use as git's
+ssh command. git passes the server and the git command to run, and vssh
+signs a certificate for the mapped user if needed before running ssh, so
+there is no need to run vssh before each push or fetch.
+
+vssh's own messages are left out unless --verbose or --debug is given, so
+only git's output is shown.
+
+Setup:
+  git config --global core.sshCommand "vssh git-ssh"
+  git config --global ssh.variant ssh
+
+or, for a single command:
+  GIT_SSH_COMMAND="vssh git-ssh" GIT_SSH_VARIANT=ssh git push`,
+	// Flags follow ssh's syntax and are parsed by ssh.ParseSSHArgs
+	DisableFlagParsing: true,
+	Args:               cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		args, err := parseRootFlags(cmd, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if help, _ := cmd.Flags().GetBool("help"); help || (len(args) > 0 && args[0] == "-h") {
+			cmd.Help()
+			return
+		}
+
+		options, positional, err := ssh.ParseSSHArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// git runs "<command> -G <host>" to find out whether its ssh command
+		// takes OpenSSH's options, which it does
+		if slices.Contains(options.ExtraArgs, "-G") {
+			return
+		}
+		if len(positional) < 2 {
+			fmt.Fprintf(os.Stderr, "Error: a destination and the git command to run are required\n")
+			os.Exit(1)
+		}
+
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		debug, _ := cmd.Flags().GetBool("debug")
+		if !verbose && !debug && options.Verbose == 0 && !options.Debug {
+			cmd.Flags().Set("quiet", "true")
+		}
+
+		s := newSession(cmd)
+		s.otp = true
+		targets, err := s.expandTargets(positional[:1])
+		if err != nil {
+			s.logger.Errorf("Invalid SSH target: %v", err)
+			os.Exit(255)
+		}
+		if len(targets) != 1 {
+			s.logger.Errorf("%s expands to %d targets, git needs exactly one", positional[0], len(targets))
+			os.Exit(255)
+		}
+
+		target, certPath, targetOptions, err := s.prepareTarget(targets[0], options)
+		if err != nil {
+			s.logger.Errorf("%v", err)
+			os.Exit(exitCode(err))
+		}
+
+		// git asks ssh to pass on the protocol version it wants. The built-in
+		// client can't, and git falls back to the original protocol.
+		if s.sshClient.UsesNative() {
+			targetOptions.ExtraArgs = withoutOption(targetOptions.ExtraArgs, "SendEnv=GIT_PROTOCOL")
+		}
+
+		conns := []*connection{newConnection(s, targets[0], target, certPath)}
+		err = withHooks(s, conns, func() error {
+			return s.sshClient.Execute(target, certPath, targetOptions, positional[1:], os.Stdin, os.Stdout, os.Stderr)
+		})
+		if err != nil {
+			// ssh has already reported why it failed
+			var exitErr *ssh.ExitError
+			if !errors.As(err, &exitErr) {
+				s.logger.Errorf("SSH connection failed: %v", err)
+			}
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gitSSHCmd)
+}
+
+// withoutOption returns args without the -o options setting option
+func withoutOption(args []string, option string) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-o" && i+1 < len(args) && args[i+1] == option {
+			i++
+			continue
+		}
+		kept = append(kept, args[i])
+	}
+	return kept
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"vssh/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+// groupCmd represents the group command
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Inspect configured host groups",
+	Long: `Inspect the host groups defined in the groups section of the configuration.
+
+Groups share settings such as the Vault role and signing engine across their
+hosts, and can be used as targets for batch commands.`,
+}
+
+// groupListCmd lists the configured groups
+var groupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List host groups",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		loaded := mustLoadConfig()
+
+		if len(loaded.Groups) == 0 {
+			fmt.Println("No host groups configured")
+			return
+		}
+
+		for _, name := range loaded.GroupNames() {
+			fmt.Printf("%s\t%d hosts\n", name, len(loaded.Groups[name].Hosts))
+		}
+	},
+}
+
+// groupShowCmd prints a single group's hosts and settings
+var groupShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show the hosts and settings of a group",
+	Args:  cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		loaded, err := config.LoadConfig()
+		if err != nil || len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return loaded.GroupNames(), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		loaded := mustLoadConfig()
+
+		group, exists := loaded.Groups[args[0]]
+		if !exists {
+			fmt.Fprintf(os.Stderr, "Error: unknown group: %s\n", args[0])
+			os.Exit(1)
+		}
+
+		fmt.Printf("Group: %s\n", args[0])
+		fmt.Printf("Hosts: %s\n", strings.Join(group.Hosts, ", "))
+		if group.Role != "" {
+			fmt.Printf("Role: %s\n", group.Role)
+		}
+		if group.SigningEngine != "" {
+			fmt.Printf("Signing engine: %s\n", group.SigningEngine)
+		}
+		if group.ProxyJump != "" {
+			fmt.Printf("Jump host: %s\n", group.ProxyJump)
+		}
+		if group.User != "" {
+			fmt.Printf("Default user: %s\n", group.User)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(groupCmd)
+	groupCmd.AddCommand(groupListCmd)
+	groupCmd.AddCommand(groupShowCmd)
+}
@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"vssh/internal/config"
+	"vssh/internal/history"
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// historyCmd lists recent connections
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recent connections",
+	Long: `Show recent successful connections, oldest first.
+
+Reconnect to an entry by passing its number prefixed with ! as the target.
+Quote it so the shell does not perform its own history expansion:
+
+  vssh '!3'
+  vssh '!!'      # the most recent connection
+
+With history.record_certificates enabled, the serial number and key ID of
+every issued certificate are recorded too. --certificates lists them, and
+--serial finds the certificate a server logged:
+
+  vssh history --certificates --serial 4223709817364522380`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		last, _ := cmd.Flags().GetInt("last")
+		format := outputFormat(cmd)
+
+		loaded := mustLoadConfig()
+
+		if certificates, _ := cmd.Flags().GetBool("certificates"); certificates {
+			serial, _ := cmd.Flags().GetUint64("serial")
+			listCertificates(loaded, last, serial, format)
+			return
+		}
+
+		entries, err := historyStore(loaded).Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(entries) == 0 && format == outputTable {
+			fmt.Println("No connection history")
+			return
+		}
+
+		start := 0
+		if last > 0 && len(entries) > last {
+			start = len(entries) - last
+		}
+
+		if format != outputTable {
+			output := []historyEntryOutput{}
+			for i := start; i < len(entries); i++ {
+				entry := entries[i]
+				output = append(output, historyEntryOutput{
+					Number:     i + 1,
+					Time:       entry.Time,
+					Target:     entry.Target,
+					User:       entry.User,
+					Host:       entry.Host,
+					Port:       entry.Port,
+					Duration:   entry.Duration.Round(time.Second).String(),
+					ExitCode:   entry.ExitCode,
+					CertSerial: entry.CertSerial,
+					Protected:  entry.Protected,
+					Reason:     entry.Reason,
+				})
+			}
+			printStructured(format, output)
+			return
+		}
+
+		for i := start; i < len(entries); i++ {
+			entry := entries[i]
+			fmt.Printf("%5d  %s  %-40s  %s\n",
+				i+1,
+				entry.Time.Local().Format("2006-01-02 15:04"),
+				entry.Target,
+				entry.Duration.Round(time.Second))
+		}
+	},
+}
+
+// historyEntryOutput is a connection history entry as printed with --output,
+// numbered for reconnecting with !N
+type historyEntryOutput struct {
+	Number     int       `json:"number"`
+	Time       time.Time `json:"time"`
+	Target     string    `json:"target"`
+	User       string    `json:"user"`
+	Host       string    `json:"host"`
+	Port       string    `json:"port,omitempty"`
+	Duration   string    `json:"duration"`
+	ExitCode   int       `json:"exit_code"`
+	CertSerial string    `json:"cert_serial,omitempty"`
+	Protected  bool      `json:"protected,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// historyStore returns the connection history store for the configuration
+func historyStore(cfg *types.Config) *history.Store {
+	return history.NewStore(history.DefaultPath(config.GetStateDir()), cfg.History.MaxEntries)
+}
+
+// certificateStore returns the issued certificates store for the configuration
+func certificateStore(cfg *types.Config) *history.CertificateStore {
+	return history.NewCertificateStore(history.DefaultCertificatesPath(config.GetStateDir()), cfg.History.MaxEntries)
+}
+
+// listCertificates prints the last recorded certificates, or those with serial,
+// in format
+func listCertificates(cfg *types.Config, last int, serial uint64, format string) {
+	certs, err := certificateStore(cfg).Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if serial != 0 {
+		certs = slices.DeleteFunc(certs, func(cert history.Certificate) bool {
+			return cert.Serial != serial
+		})
+	} else if last > 0 && len(certs) > last {
+		certs = certs[len(certs)-last:]
+	}
+
+	if format != outputTable {
+		if certs == nil {
+			certs = []history.Certificate{}
+		}
+		printStructured(format, certs)
+		return
+	}
+
+	if len(certs) == 0 {
+		if !cfg.History.RecordCertificates {
+			fmt.Println("No certificates recorded; enable history.record_certificates to record them")
+		} else {
+			fmt.Println("No certificates recorded")
+		}
+		return
+	}
+
+	for _, cert := range certs {
+		target := cert.User
+		if cert.Host != "" {
+			target += "@" + cert.Host
+		}
+		fmt.Printf("%20d  %s  %-30s  %-20s  %s\n",
+			cert.Serial,
+			cert.Issued.Local().Format("2006-01-02 15:04"),
+			target,
+			cert.Role,
+			cert.KeyID)
+	}
+}
+
+// recordCertificates stores the serial number and key ID of every certificate
+// signer issues
+func recordCertificates(s *session, signer *ssh.Signer) {
+	store := certificateStore(s.config)
+	signer.OnIssued(func(target *ssh.SSHTarget, plan *ssh.CertificatePlan, cert *gossh.Certificate) {
+		record := history.Certificate{
+			Serial:     cert.Serial,
+			KeyID:      cert.KeyId,
+			Principals: cert.ValidPrincipals,
+			Role:       plan.Role,
+			Engine:     plan.Engine,
+			Issued:     time.Now(),
+		}
+		if target != nil {
+			record.User = target.Username
+			record.Host = target.Hostname
+		}
+		if cert.ValidBefore != gossh.CertTimeInfinity {
+			record.Expires = time.Unix(int64(cert.ValidBefore), 0)
+		}
+		if err := store.Add(record); err != nil {
+			s.logger.Warnf("Failed to record issued certificate: %v", err)
+		}
+	})
+}
+
+// expandHistoryRef replaces a !N or !! history reference with the recorded target
+func expandHistoryRef(cfg *types.Config, target string) (string, error) {
+	if !strings.HasPrefix(target, "!") {
+		return target, nil
+	}
+
+	store := historyStore(cfg)
+	ref := target[1:]
+	if ref == "!" {
+		entries, err := store.Load()
+		if err != nil {
+			return "", err
+		}
+		if len(entries) == 0 {
+			return "", fmt.Errorf("no connection history")
+		}
+		return entries[len(entries)-1].Target, nil
+	}
+
+	n, err := strconv.Atoi(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid history reference: %s", target)
+	}
+	entry, err := store.Get(n)
+	if err != nil {
+		return "", err
+	}
+	return entry.Target, nil
+}
+
+// recordHistory stores a finished connection. Connections that failed to
+// establish (ssh exit code 255) are not recorded, unless to a protected host
+// that was confirmed.
+func recordHistory(s *session, c *connection, started time.Time, connectErr error) {
+	c.recorded = true
+	if !s.config.History.Enabled {
+		return
+	}
+	if ssh.ExitCode(connectErr) == 255 && !c.confirmed {
+		return
+	}
+
+	entry := history.Entry{
+		Target:     c.rawTarget,
+		User:       c.target.Username,
+		Host:       c.target.Hostname,
+		Port:       c.target.Port,
+		Time:       started,
+		Duration:   time.Since(started),
+		ExitCode:   exitCode(connectErr),
+		CertSerial: c.serial,
+		Protected:  c.confirmed,
+		Reason:     c.reason,
+	}
+	if err := historyStore(s.config).Add(entry); err != nil {
+		s.logger.Warnf("Failed to record connection history: %v", err)
+	}
+}
+
+// printSessionSummary prints a line summing up a finished session on stderr,
+// with ui.session_summary set
+func printSessionSummary(s *session, c *connection, started time.Time, connectErr error) {
+	if !s.config.UI.SessionSummary {
+		return
+	}
+	summary := fmt.Sprintf("Session with %s@%s ended after %s, exit code %d",
+		c.target.Username, c.target.Hostname, time.Since(started).Round(time.Second), exitCode(connectErr))
+	if c.serial != "" {
+		summary += ", certificate serial " + c.serial
+	}
+	fmt.Fprintln(os.Stderr, summary)
+}
+
+// logConnection logs a finished connection at debug level, with the target,
+// role, duration in seconds and exit code as fields for log files and JSON
+// output
+func logConnection(s *session, target *ssh.SSHTarget, started time.Time, connectErr error) {
+	duration := time.Since(started)
+	s.logger.WithFields(logrus.Fields{
+		"event":     "connection_closed",
+		"user":      target.Username,
+		"host":      target.Hostname,
+		"role":      s.signer.ResolveRole(target),
+		"duration":  duration.Seconds(),
+		"exit_code": exitCode(connectErr),
+	}).Debugf("Connection to %s@%s closed after %s", target.Username, target.Hostname, duration.Round(time.Second))
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().Int("last", 20, "Number of most recent entries to show (0 for all)")
+	historyCmd.Flags().Bool("certificates", false, "Show issued certificates instead of connections (history.record_certificates)")
+	historyCmd.Flags().Uint64("serial", 0, "With --certificates, show only the certificate with this serial number")
+}
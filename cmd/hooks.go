@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"time"
+
+	"vssh/internal/hooks"
+	"vssh/internal/ssh"
+	"vssh/internal/webhook"
+)
+
+// connection describes a connection to a target for hooks and the webhook
+type connection struct {
+	rawTarget string
+	target    *ssh.SSHTarget
+	role      string
+	certPath  string
+	serial    string
+
+	// confirmed is set when the target is a protected host confirmed with
+	// reason
+	confirmed bool
+	reason    string
+
+	// recorded is set once the connection is in the history
+	recorded bool
+}
+
+// newConnection describes a connection to target, given as rawTarget, with
+// the certificate at certPath if any
+func newConnection(s *session, rawTarget string, target *ssh.SSHTarget, certPath string) *connection {
+	c := &connection{
+		rawTarget: rawTarget,
+		target:    target,
+		role:      s.signer.ResolveRole(target),
+		certPath:  certPath,
+	}
+	c.reason, c.confirmed = s.confirmedReason(target.Hostname)
+	if certPath != "" {
+		if serial, err := ssh.CertificateSerial(certPath); err == nil {
+			c.serial = fmt.Sprint(serial)
+		}
+	}
+	return c
+}
+
+// env returns the environment variables describing the connection for hook
+// commands
+func (c *connection) env() []string {
+	return []string{
+		"VSSH_TARGET=" + c.rawTarget,
+		"VSSH_HOST=" + c.target.Hostname,
+		"VSSH_USER=" + c.target.Username,
+		"VSSH_PORT=" + cmp.Or(c.target.Port, "22"),
+		"VSSH_ROLE=" + c.role,
+		"VSSH_CERT_PATH=" + c.certPath,
+		"VSSH_CERT_SERIAL=" + c.serial,
+		"VSSH_REASON=" + c.reason,
+	}
+}
+
+// event returns a webhook event of the given type for the connection
+func (c *connection) event(eventType string) *webhook.Event {
+	clientHost, _ := os.Hostname()
+	return &webhook.Event{
+		Event:      eventType,
+		Time:       time.Now(),
+		Target:     c.rawTarget,
+		Host:       c.target.Hostname,
+		User:       c.target.Username,
+		Port:       cmp.Or(c.target.Port, "22"),
+		Role:       c.role,
+		CertSerial: c.serial,
+		Reason:     c.reason,
+		ClientHost: clientHost,
+		ClientUser: os.Getenv("USER"),
+	}
+}
+
+// beforeConnect runs the hooks.pre_connect commands, any of which can stop
+// the connection by failing, and then posts session_start to the webhook.
+// Every command connecting to a host calls it, directly or through withHooks,
+// so the hooks can't be bypassed by using another one.
+func beforeConnect(s *session, c *connection) error {
+	if preConnect := s.config.Hooks.PreConnect; len(preConnect) > 0 {
+		s.logger.Debugf("Running %d pre_connect hook(s)", len(preConnect))
+		if err := hooks.Run(preConnect, c.env(), os.Stderr); err != nil {
+			return classify(1, fmt.Errorf("not connecting, pre_connect %w", err))
+		}
+	}
+	sendWebhook(s, c.event(webhook.SessionStart))
+	return nil
+}
+
+// afterDisconnect posts session_end to the webhook and runs the
+// hooks.post_disconnect commands with the session's exit code and duration in
+// seconds. Failures are only reported.
+func afterDisconnect(s *session, c *connection, started time.Time, connectErr error) {
+	code := exitCode(connectErr)
+	duration := time.Since(started)
+
+	// Sessions with protected hosts are kept in the history whatever command
+	// connected to them, for the confirmation and its reason
+	if c.confirmed && !c.recorded {
+		recordHistory(s, c, started, connectErr)
+	}
+
+	event := c.event(webhook.SessionEnd)
+	event.ExitCode = &code
+	event.Duration = duration.Seconds()
+	sendWebhook(s, event)
+
+	if postDisconnect := s.config.Hooks.PostDisconnect; len(postDisconnect) > 0 {
+		env := append(c.env(),
+			fmt.Sprintf("VSSH_EXIT_CODE=%d", code),
+			fmt.Sprintf("VSSH_DURATION=%d", int(duration.Seconds())),
+		)
+		s.logger.Debugf("Running %d post_disconnect hook(s)", len(postDisconnect))
+		if err := hooks.Run(postDisconnect, env, os.Stderr); err != nil {
+			s.logger.Warnf("post_disconnect %v", err)
+		}
+	}
+}
+
+// withHooks runs connect as a session with the connections' targets, for
+// commands handing the connections to ssh or another tool at once: every
+// pre_connect hook runs and session_start is posted first, and session_end is
+// posted and the post_disconnect hooks run once connect returns. If a
+// pre_connect hook fails connect isn't called, and the sessions started end.
+func withHooks(s *session, conns []*connection, connect func() error) error {
+	for i, c := range conns {
+		if err := beforeConnect(s, c); err != nil {
+			for _, started := range conns[:i] {
+				afterDisconnect(s, started, time.Now(), err)
+			}
+			return err
+		}
+	}
+
+	started := time.Now()
+	err := connect()
+	for _, c := range conns {
+		afterDisconnect(s, c, started, err)
+	}
+	return err
+}
+
+// sendWebhook posts event to webhook.url, if configured, warning if it fails
+func sendWebhook(s *session, event *webhook.Event) {
+	config := s.config.Webhook
+	if config.URL == "" {
+		return
+	}
+	s.logger.Debugf("Posting %s for %s@%s to %s", event.Event, event.User, event.Host, config.URL)
+	if err := webhook.Send(config.URL, config.Secret, config.Timeout, event); err != nil {
+		s.logger.Warnf("Failed to post %s to webhook: %v", event.Event, err)
+	}
+}
@@ -1,22 +1,39 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
 
 	"vssh/internal/config"
+	"vssh/internal/utils"
+	"vssh/internal/vault"
+	"vssh/pkg/types"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 // initCmd represents the init command
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize vssh configuration",
-	Long: `Initialize vssh by creating a default configuration file.
+	Long: `Initialize vssh by creating a configuration file.
 
-This command creates a default configuration file at ~/.config/vssh/config.yaml
-with example settings that you can customize for your environment.`,
+When run in a terminal, init walks through an interactive wizard that asks for
+your Vault address, authentication method, SSH signing engine and private key,
+checks that Vault is reachable, and writes a working configuration to
+~/.config/vssh/config.yaml.
+
+Use --non-interactive (or run without a terminal) to write the commented
+default template instead.
+
+Use --from-vault to discover auth methods, SSH secrets engines and roles
+from a Vault server (using VAULT_ADDR/VAULT_TOKEN or --address/--token)
+and pre-populate the configuration from what is found.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		configPath := config.GetConfigPath()
 
@@ -30,24 +47,396 @@ with example settings that you can customize for your environment.`,
 			}
 		}
 
-		// Create the default configuration
-		if err := config.CreateDefaultConfig(configPath); err != nil {
+		nonInteractive, _ := cmd.Flags().GetBool("non-interactive")
+		interactive := !nonInteractive && term.IsTerminal(int(os.Stdin.Fd()))
+
+		var settings []config.Setting
+		var err error
+		if fromVault, _ := cmd.Flags().GetBool("from-vault"); fromVault {
+			address, _ := cmd.Flags().GetString("address")
+			token, _ := cmd.Flags().GetString("token")
+			settings, err = discoverSettings(address, token, interactive)
+		} else if interactive {
+			settings, err = runInitWizard()
+		} else {
+			writeDefaultConfig(configPath)
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := writeConfigWithSettings(configPath, settings); err != nil {
 			fmt.Fprintf(os.Stderr, "Error creating configuration file: %v\n", err)
 			os.Exit(1)
 		}
 
-		fmt.Printf("Configuration file created at %s\n", configPath)
-		fmt.Println("\nPlease edit the configuration file to match your Vault setup:")
-		fmt.Printf("  - Set vault.address to your Vault server URL\n")
-		fmt.Printf("  - Configure your preferred authentication method\n")
-		fmt.Printf("  - Add user configurations as needed\n")
-		fmt.Printf("\nFor more information, see: https://github.com/ncecere/vssh\n")
+		fmt.Printf("\nConfiguration file created at %s\n", configPath)
+		fmt.Println("You can now connect with: vssh user@hostname")
 	},
 }
 
+// writeDefaultConfig writes the commented default template
+func writeDefaultConfig(configPath string) {
+	if err := config.CreateDefaultConfig(configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating configuration file: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Configuration file created at %s\n", configPath)
+	fmt.Println("\nPlease edit the configuration file to match your Vault setup:")
+	fmt.Printf("  - Set vault.address to your Vault server URL\n")
+	fmt.Printf("  - Configure your preferred authentication method\n")
+	fmt.Printf("  - Add user configurations as needed\n")
+	fmt.Printf("\nFor more information, see: https://github.com/ncecere/vssh\n")
+}
+
+// writeConfigWithSettings writes the default template with the given settings applied,
+// only replacing configPath once the result has been validated
+func writeConfigWithSettings(configPath string, settings []config.Setting) error {
+	tmpPath := configPath + ".new"
+	defer os.Remove(tmpPath)
+
+	if err := config.CreateDefaultConfig(tmpPath); err != nil {
+		return err
+	}
+	if err := config.SetValues(tmpPath, settings...); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, configPath)
+}
+
+// runInitWizard interactively collects the settings for a new configuration
+func runInitWizard() ([]config.Setting, error) {
+	reader := bufio.NewReader(os.Stdin)
+	home, _ := os.UserHomeDir()
+
+	fmt.Println("Welcome to vssh! Let's create your configuration.")
+	fmt.Println()
+
+	address, err := utils.Prompt(reader, "Vault address", os.Getenv("VAULT_ADDR"))
+	if err != nil {
+		return nil, err
+	}
+	if address == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+
+	namespace, err := utils.Prompt(reader, "Vault namespace (optional)", os.Getenv("VAULT_NAMESPACE"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify connectivity before asking anything else
+	if err := checkVaultHealth(address, namespace); err != nil {
+		fmt.Printf("Warning: could not reach Vault at %s: %v\n", address, err)
+		proceed, err := utils.Confirm(reader, "Continue anyway?", false)
+		if err != nil {
+			return nil, err
+		}
+		if !proceed {
+			return nil, fmt.Errorf("initialization aborted")
+		}
+	}
+
+	settings := []config.Setting{
+		{Key: "vault.address", Value: address},
+		{Key: "vault.namespace", Value: namespace},
+	}
+
+	fmt.Println()
+	authMethod, err := utils.PromptChoice(reader, "Authentication method:", []string{
+		string(types.AuthMethodToken),
+		string(types.AuthMethodUserPass),
+		string(types.AuthMethodLDAP),
+		string(types.AuthMethodOIDC),
+	}, string(types.AuthMethodToken))
+	if err != nil {
+		return nil, err
+	}
+	settings = append(settings, config.Setting{Key: "vault.auth_method", Value: authMethod})
+
+	switch types.AuthMethod(authMethod) {
+	case types.AuthMethodUserPass, types.AuthMethodLDAP:
+		username, err := utils.Prompt(reader, "Username (leave empty to be prompted at login)", "")
+		if err != nil {
+			return nil, err
+		}
+		mount, err := utils.Prompt(reader, "Auth mount path", authMethod)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings,
+			config.Setting{Key: fmt.Sprintf("vault.%s.username", authMethod), Value: username},
+			config.Setting{Key: fmt.Sprintf("vault.%s.mount", authMethod), Value: mount},
+		)
+	case types.AuthMethodOIDC:
+		role, err := utils.Prompt(reader, "OIDC role", "")
+		if err != nil {
+			return nil, err
+		}
+		if role == "" {
+			return nil, fmt.Errorf("an OIDC role is required for oidc authentication")
+		}
+		mount, err := utils.Prompt(reader, "OIDC mount path", "oidc")
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings,
+			config.Setting{Key: "vault.oidc.role", Value: role},
+			config.Setting{Key: "vault.oidc.mount", Value: mount},
+		)
+	}
+
+	fmt.Println()
+	engine, err := utils.Prompt(reader, "SSH signing engine mount", "ssh-client-signer")
+	if err != nil {
+		return nil, err
+	}
+	role, err := utils.Prompt(reader, "Vault SSH role (leave empty to use your username)", "")
+	if err != nil {
+		return nil, err
+	}
+	settings = append(settings,
+		config.Setting{Key: "ssh.signing_engine", Value: engine},
+		config.Setting{Key: "vault.role", Value: role},
+	)
+
+	keyPath, err := utils.Prompt(reader, "SSH private key", defaultPrivateKeyPath(home))
+	if err != nil {
+		return nil, err
+	}
+	keyPath = expandHome(keyPath, home)
+
+	if _, err := os.Stat(keyPath + ".pub"); err != nil {
+		fmt.Printf("Warning: public key %s.pub not found; generate a key pair before connecting\n", keyPath)
+	}
+
+	settings = append(settings, config.Setting{Key: "ssh.key_directory", Value: filepath.Dir(keyPath)})
+	// Dotted usernames can't be expressed as config keys; they fall back to key_directory
+	if current, err := user.Current(); err == nil && current.Username != "" && !strings.Contains(current.Username, ".") {
+		settings = append(settings, config.Setting{
+			Key:   fmt.Sprintf("users.%s.private_key", current.Username),
+			Value: keyPath,
+		})
+	}
+
+	return settings, nil
+}
+
+// discoverSettings builds configuration settings from the auth methods,
+// SSH engines and roles found on a Vault server
+func discoverSettings(address, token string, interactive bool) ([]config.Setting, error) {
+	reader := bufio.NewReader(os.Stdin)
+
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" && interactive {
+		var err error
+		if address, err = utils.Prompt(reader, "Vault address", ""); err != nil {
+			return nil, err
+		}
+	}
+	if address == "" {
+		return nil, fmt.Errorf("vault address is required (use --address or VAULT_ADDR)")
+	}
+	namespace := os.Getenv("VAULT_NAMESPACE")
+
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				token = strings.TrimSpace(string(data))
+			}
+		}
+	}
+	if token == "" && interactive {
+		fmt.Print("Vault token: ")
+		tty := &utils.Terminal{In: os.Stdin, Out: os.Stdout}
+		tokenBytes, err := tty.ReadPassword()
+		if err != nil {
+			return nil, fmt.Errorf("error reading token: %w", err)
+		}
+		token = strings.TrimSpace(string(tokenBytes))
+	}
+	if token == "" {
+		return nil, fmt.Errorf("a Vault token is required for discovery (use --token or VAULT_TOKEN)")
+	}
+
+	client, err := vault.NewClient(&types.VaultConfig{Address: address, Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(token)
+
+	fmt.Printf("Discovering configuration from %s...\n", address)
+	discovery, err := client.Discover()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := []config.Setting{
+		{Key: "vault.address", Value: address},
+		{Key: "vault.namespace", Value: namespace},
+	}
+
+	// Auth method: prefer interactive human login methods over raw tokens
+	var candidates []vault.AuthMount
+	for _, preferred := range []types.AuthMethod{types.AuthMethodOIDC, types.AuthMethodLDAP, types.AuthMethodUserPass} {
+		for _, mount := range discovery.AuthMounts {
+			if mount.Type == string(preferred) {
+				candidates = append(candidates, mount)
+			}
+		}
+	}
+
+	authMethod := string(types.AuthMethodToken)
+	if len(candidates) > 0 {
+		chosen := candidates[0]
+		if len(candidates) > 1 && interactive {
+			options := make([]string, len(candidates))
+			for i, mount := range candidates {
+				options[i] = fmt.Sprintf("%s (%s)", mount.Path, mount.Type)
+			}
+			answer, err := utils.PromptChoice(reader, "Discovered auth methods:", options, options[0])
+			if err != nil {
+				return nil, err
+			}
+			for i, option := range options {
+				if option == answer {
+					chosen = candidates[i]
+				}
+			}
+		}
+
+		authMethod = chosen.Type
+		settings = append(settings, config.Setting{Key: fmt.Sprintf("vault.%s.mount", chosen.Type), Value: chosen.Path})
+
+		if chosen.Type == string(types.AuthMethodOIDC) {
+			oidcRole := ""
+			if roles, err := client.ListKeys(fmt.Sprintf("auth/%s/role", chosen.Path)); err == nil && len(roles) == 1 {
+				oidcRole = roles[0]
+			} else if err == nil && len(roles) > 1 && interactive {
+				if oidcRole, err = utils.PromptChoice(reader, "OIDC roles:", roles, roles[0]); err != nil {
+					return nil, err
+				}
+			} else if interactive {
+				if oidcRole, err = utils.Prompt(reader, "OIDC role", ""); err != nil {
+					return nil, err
+				}
+			}
+
+			if oidcRole == "" {
+				fmt.Println("Warning: could not determine an OIDC role, falling back to token authentication")
+				authMethod = string(types.AuthMethodToken)
+			} else {
+				settings = append(settings, config.Setting{Key: "vault.oidc.role", Value: oidcRole})
+			}
+		}
+	}
+	settings = append(settings, config.Setting{Key: "vault.auth_method", Value: authMethod})
+	fmt.Printf("  Auth method: %s\n", authMethod)
+
+	// SSH signing engine
+	if len(discovery.SSHEngines) == 0 {
+		return nil, fmt.Errorf("no SSH secrets engines found on %s", address)
+	}
+	engine := discovery.SSHEngines[0]
+	if len(discovery.SSHEngines) > 1 && interactive {
+		options := make([]string, len(discovery.SSHEngines))
+		for i, e := range discovery.SSHEngines {
+			options[i] = e.Path
+		}
+		answer, err := utils.PromptChoice(reader, "Discovered SSH secrets engines:", options, options[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range discovery.SSHEngines {
+			if e.Path == answer {
+				engine = e
+			}
+		}
+	}
+	settings = append(settings, config.Setting{Key: "ssh.signing_engine", Value: engine.Path})
+	fmt.Printf("  Signing engine: %s\n", engine.Path)
+
+	// Role: leave unset when a role matching the username exists, since that is the default
+	role := ""
+	username := os.Getenv("USER")
+	hasUserRole := false
+	for _, r := range engine.Roles {
+		if r == username {
+			hasUserRole = true
+		}
+	}
+	if !hasUserRole {
+		if len(engine.Roles) == 1 {
+			role = engine.Roles[0]
+		} else if len(engine.Roles) > 1 && interactive {
+			if role, err = utils.PromptChoice(reader, "Discovered SSH roles:", engine.Roles, engine.Roles[0]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	settings = append(settings, config.Setting{Key: "vault.role", Value: role})
+	if role != "" {
+		fmt.Printf("  Role: %s\n", role)
+	} else {
+		fmt.Println("  Role: <username>")
+	}
+
+	return settings, nil
+}
+
+// checkVaultHealth verifies that a Vault server is reachable and unsealed
+func checkVaultHealth(address, namespace string) error {
+	client, err := vault.NewClient(&types.VaultConfig{Address: address, Namespace: namespace})
+	if err != nil {
+		return err
+	}
+
+	health, err := client.GetClient().Sys().Health()
+	if err != nil {
+		return err
+	}
+	if health.Sealed {
+		return fmt.Errorf("vault is sealed")
+	}
+
+	fmt.Printf("Connected to Vault %s\n", health.Version)
+	return nil
+}
+
+// defaultPrivateKeyPath suggests an existing private key, preferring ed25519
+func defaultPrivateKeyPath(home string) string {
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		path := filepath.Join(home, ".ssh", name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(home, ".ssh", "id_ed25519")
+}
+
+// expandHome expands a leading tilde in path
+func expandHome(path, home string) string {
+	if len(path) > 0 && path[0] == '~' {
+		return filepath.Join(home, path[1:])
+	}
+	return path
+}
+
 func init() {
 	rootCmd.AddCommand(initCmd)
 
 	// Add force flag to overwrite existing config
 	initCmd.Flags().BoolP("force", "f", false, "overwrite existing configuration file")
+	initCmd.Flags().Bool("non-interactive", false, "write the default template without prompting")
+	initCmd.Flags().Bool("from-vault", false, "discover auth methods, SSH engines and roles from Vault")
+	initCmd.Flags().String("address", "", "Vault address used with --from-vault (default $VAULT_ADDR)")
+	initCmd.Flags().String("token", "", "Vault token used with --from-vault (default $VAULT_TOKEN)")
 }
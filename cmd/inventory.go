@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"vssh/internal/inventory"
+	"vssh/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+// inventoryHost is a host known to vssh from the configuration or an inventory source
+type inventoryHost struct {
+	name    string
+	address string
+	tags    map[string]string
+}
+
+// inventoryCmd prints the hosts vssh knows about
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Print the hosts known to vssh",
+	Long: `Print the hosts known to vssh: literal hosts from the hosts and groups
+configuration plus hosts from the Vault and EC2 inventory sources.
+
+With --format ansible the output is an Ansible dynamic inventory. Every host
+gets a freshly checked Vault-signed certificate and the variables Ansible's
+ssh connection needs to use it (ansible_user, ansible_port,
+ansible_ssh_private_key_file and ansible_ssh_common_args with the certificate
+and any jump hosts). vssh groups and inventory tags (tag_<key>_<value>) become
+Ansible groups.
+
+To use it, create an executable inventory script:
+
+  #!/bin/sh
+  exec vssh inventory --format ansible "$@"
+
+and run ansible-playbook -i that script. Authenticate to Vault before running
+Ansible, since the inventory cannot prompt for credentials.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		hostName, _ := cmd.Flags().GetString("host")
+
+		if format != "text" && format != "ansible" {
+			fmt.Fprintf(os.Stderr, "Error: invalid format %q (use text or ansible)\n", format)
+			os.Exit(1)
+		}
+
+		s := newSession(cmd)
+		// Inventories are generated unattended, so no role or confirmation
+		// for protected hosts is asked for
+		s.selectRole = false
+		s.confirm = false
+		hosts := s.inventoryHosts()
+
+		if format == "text" {
+			for _, host := range hosts {
+				target, err := ssh.ResolveTarget(s.config, host.address)
+				if err != nil {
+					s.logger.Warnf("Skipping %s: %v", host.name, err)
+					continue
+				}
+				fmt.Printf("%s\t%s\t%s\t%s\t%s\n", host.name, host.address, target.Username, s.signer.ResolveRole(target), formatTags(host.tags))
+			}
+			return
+		}
+
+		var ansibleHosts []inventory.AnsibleHost
+		for _, host := range hosts {
+			if hostName != "" && host.name != hostName {
+				continue
+			}
+
+			vars, err := s.ansibleVars(host)
+			if err != nil {
+				s.logger.Warnf("Skipping %s: %v", host.name, err)
+				continue
+			}
+
+			groups := append(s.config.GroupsForHost(host.name), s.config.GroupsForHost(host.address)...)
+			groups = append(groups, inventory.TagGroups(host.tags)...)
+			ansibleHosts = append(ansibleHosts, inventory.AnsibleHost{Name: host.name, Groups: groups, Vars: vars})
+		}
+
+		// --host prints a single host's variables, as the Ansible inventory protocol expects
+		var output interface{} = inventory.Ansible(ansibleHosts)
+		if hostName != "" {
+			output = map[string]interface{}{}
+			if len(ansibleHosts) > 0 {
+				output = ansibleHosts[0].Vars
+			}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding inventory: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// inventoryHosts lists inventory source hosts followed by literal hosts from
+// the hosts and groups configuration, without duplicates
+func (s *session) inventoryHosts() []inventoryHost {
+	var hosts []inventoryHost
+	seen := map[string]bool{}
+
+	for _, host := range s.inventory.Hosts {
+		if seen[host.Name] {
+			continue
+		}
+		// Inventory addresses were added to the hosts configuration when loaded
+		seen[host.Name], seen[host.Address] = true, true
+		hosts = append(hosts, inventoryHost{name: host.Name, address: host.Address, tags: host.Tags})
+	}
+
+	var literals []string
+	for _, host := range s.config.Hosts {
+		literals = append(literals, strings.Split(host.Pattern, ",")...)
+	}
+	for _, name := range s.config.GroupNames() {
+		literals = append(literals, s.config.Groups[name].Hosts...)
+	}
+
+	for _, name := range literals {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] || strings.ContainsAny(name, "*?!") {
+			continue
+		}
+		seen[name] = true
+		hosts = append(hosts, inventoryHost{name: name, address: name})
+	}
+
+	return hosts
+}
+
+// ansibleVars ensures a certificate for host and returns the Ansible
+// connection variables that use it
+func (s *session) ansibleVars(host inventoryHost) (map[string]interface{}, error) {
+	target, certPath, options, err := s.prepareTarget(host.address, &ssh.SSHOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// In agent mode the certificate is only in ssh-agent
+	var sshArgs []string
+	if certPath != "" {
+		sshArgs = append(sshArgs, "-o", ssh.ShellQuote("CertificateFile="+certPath))
+	}
+	for _, arg := range ssh.KeyProviderArgs(s.config) {
+		sshArgs = append(sshArgs, ssh.ShellQuote(arg))
+	}
+	if len(options.JumpHosts) > 0 {
+		sshArgs = append(sshArgs, "-o", ssh.ShellQuote("ProxyCommand="+ssh.ProxyCommand(options.JumpHosts)))
+	}
+
+	vars := map[string]interface{}{
+		"ansible_host":            target.SSHHost(),
+		"ansible_user":            target.Username,
+		"ansible_ssh_common_args": strings.Join(sshArgs, " "),
+		"vssh_role":               s.signer.ResolveRole(target),
+	}
+	if options.IdentityFile != "" {
+		vars["ansible_ssh_private_key_file"] = options.IdentityFile
+	}
+	if target.Port != "" {
+		vars["ansible_port"] = target.Port
+	}
+	return vars, nil
+}
+
+// formatTags renders tags as sorted key=value pairs
+func formatTags(tags map[string]string) string {
+	var pairs []string
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func init() {
+	rootCmd.AddCommand(inventoryCmd)
+	inventoryCmd.Flags().String("format", "text", "Output format: text or ansible")
+	inventoryCmd.Flags().Bool("list", false, "List all hosts (Ansible inventory protocol; the default)")
+	inventoryCmd.Flags().String("host", "", "Print variables for a single host (Ansible inventory protocol)")
+}
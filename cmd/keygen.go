@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"vssh/internal/config"
+	"vssh/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+// keygenCmd generates a key pair for vssh to sign
+var keygenCmd = &cobra.Command{
+	Use:   "keygen [--type ed25519|ecdsa|rsa] [--user name] [--sign]",
+	Short: "Generate a key pair and configure it for a user",
+	Long: `Generate a key pair in the configured key directory and set it as the
+user's private_key in the users section of the configuration.
+
+The key is written as id_<type> (id_<type>_<user> with --user) with mode 0600
+and its public key next to it. With --sign a certificate is signed for it
+right away.
+
+Examples:
+  vssh keygen
+  vssh keygen --type rsa --user admin
+  vssh keygen --sign`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		keyType, _ := cmd.Flags().GetString("type")
+		if !slices.Contains(ssh.KeyTypes, keyType) {
+			fmt.Fprintf(os.Stderr, "Error: unsupported key type %s (supported: ed25519, ecdsa, rsa)\n", keyType)
+			os.Exit(1)
+		}
+
+		loaded := mustLoadConfig()
+
+		fileName := "id_" + keyType
+		username, _ := cmd.Flags().GetString("user")
+		if username != "" {
+			fileName += "_" + username
+		} else if username = os.Getenv("USER"); username == "" {
+			fmt.Fprintf(os.Stderr, "Error: no --user given and USER environment variable not set\n")
+			os.Exit(1)
+		}
+
+		keyPath, _ := cmd.Flags().GetString("file")
+		if keyPath == "" {
+			keyPath = filepath.Join(loaded.SSH.KeyDirectory, fileName)
+		}
+
+		force, _ := cmd.Flags().GetBool("force")
+		hostname, _ := os.Hostname()
+		if err := ssh.GenerateKeyPair(keyType, keyPath, username+"@"+hostname, force); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			if !force {
+				fmt.Fprintf(os.Stderr, "Use --force to replace it, or --file to choose another path\n")
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("Generated %s key %s\n", keyType, keyPath)
+
+		key := "users." + username + ".private_key"
+		if err := config.SetValue(config.GetActiveConfigPath(), key, keyPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating configuration: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Set %s to %s\n", key, keyPath)
+
+		if sign, _ := cmd.Flags().GetBool("sign"); sign {
+			s := newSession(cmd)
+			certPath, err := s.ensureCertificate(&ssh.SSHTarget{Username: username})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to sign the key: %v\n", err)
+				os.Exit(1)
+			}
+			if certPath != "" {
+				fmt.Printf("Signed certificate %s\n", certPath)
+			} else {
+				fmt.Println("Signed certificate added to ssh-agent")
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keygenCmd)
+
+	keygenCmd.Flags().String("type", "ed25519", "key type: ed25519, ecdsa or rsa")
+	keygenCmd.Flags().String("user", "", "user to configure the key for (default $USER)")
+	keygenCmd.Flags().String("file", "", "path of the private key (default <key_directory>/id_<type>[_<user>])")
+	keygenCmd.Flags().Bool("force", false, "replace an existing key")
+	keygenCmd.Flags().Bool("sign", false, "sign a certificate for the new key right away")
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"vssh/internal/ssh"
+	"vssh/internal/vault"
+
+	"github.com/spf13/cobra"
+)
+
+// knownHostsCmd manages known_hosts entries for the Vault host CA
+var knownHostsCmd = &cobra.Command{
+	Use:   "known-hosts",
+	Short: "Trust the Vault host CA in known_hosts",
+	Long: `Manage the known_hosts entry trusting host certificates signed by Vault,
+so servers with signed host keys are verified without prompting.`,
+}
+
+// knownHostsSyncCmd adds or updates the @cert-authority line for the host CA
+var knownHostsSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Add or update the @cert-authority line for the Vault host CA",
+	Long: `Read the CA public key of the host signing engine (ssh.host_ca.signing_engine)
+and add an @cert-authority line trusting it for ssh.host_ca.domains to the
+known hosts file (ssh.known_hosts_file, default ~/.ssh/known_hosts). An
+existing line for the engine is updated when the CA key or domains change.
+
+Examples:
+  vssh known-hosts sync
+  vssh known-hosts sync --engine ssh-host-signer --domain '*.example.com'`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := mustLoadConfig()
+
+		engine, _ := cmd.Flags().GetString("engine")
+		if engine == "" {
+			engine = cfg.SSH.HostCA.SigningEngine
+		}
+		domains, _ := cmd.Flags().GetStringSlice("domain")
+		if len(domains) == 0 {
+			domains = cfg.SSH.HostCA.Domains
+		}
+		if len(domains) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no domains to trust the host CA for; set ssh.host_ca.domains or pass --domain\n")
+			os.Exit(1)
+		}
+		path, _ := cmd.Flags().GetString("file")
+		if path == "" {
+			path = cfg.SSH.KnownHostsFile
+		}
+		if path == "" {
+			path = "~/.ssh/known_hosts"
+		}
+
+		vaultClient, err := vault.NewClient(&cfg.Vault)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		caKey, err := vaultClient.SSHCAPublicKey(engine)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		changed, err := ssh.SyncCertAuthority(path, engine, caKey, domains)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if changed {
+			fmt.Printf("Updated %s to trust the %s CA\n", path, engine)
+		} else {
+			fmt.Printf("%s already trusts the %s CA\n", path, engine)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(knownHostsCmd)
+	knownHostsCmd.AddCommand(knownHostsSyncCmd)
+
+	knownHostsSyncCmd.Flags().String("engine", "", "SSH secrets engine mount of the host CA (default ssh.host_ca.signing_engine)")
+	knownHostsSyncCmd.Flags().StringSlice("domain", nil, "host pattern to trust the CA for (repeatable, default ssh.host_ca.domains)")
+	knownHostsSyncCmd.Flags().String("file", "", "known hosts file to update (default ssh.known_hosts_file or ~/.ssh/known_hosts)")
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/spf13/cobra"
+)
+
+// muxCmd manages control master connections
+var muxCmd = &cobra.Command{
+	Use:   "mux",
+	Short: "Manage multiplexed control master connections",
+	Long: `Manage the OpenSSH control master connections vssh shares between
+invocations when ssh.multiplexing is enabled.
+
+The first connection to a host becomes a control master that stays open for
+ssh.multiplexing.persist after the last session ends, so later connections to
+the same host skip the handshake and start almost instantly.
+
+Examples:
+  vssh mux status
+  vssh mux status admin@db01
+  vssh mux stop admin@db01
+  vssh mux stop --all`,
+}
+
+// muxStatusCmd shows control master connections
+var muxStatusCmd = &cobra.Command{
+	Use:               "status [target]",
+	Short:             "Show running control masters",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeTarget,
+	Run: func(cmd *cobra.Command, args []string) {
+		loaded := loadMuxConfig()
+
+		if len(args) == 1 {
+			target := resolveMuxTarget(loaded, args[0])
+			reply, err := ssh.MuxControl(loaded, "", target, "check")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s: %s\n", args[0], reply)
+			return
+		}
+
+		sockets := listMuxSockets(loaded)
+		if len(sockets) == 0 {
+			fmt.Println("No control masters running")
+			return
+		}
+
+		for _, socket := range sockets {
+			reply, err := ssh.MuxControl(loaded, socket.Path, nil, "check")
+			if err != nil {
+				reply = "not responding (" + err.Error() + ")"
+			}
+			fmt.Printf("%-40s  %s\n", socket.Name, reply)
+		}
+	},
+}
+
+// muxStopCmd closes control master connections
+var muxStopCmd = &cobra.Command{
+	Use:               "stop [target]",
+	Short:             "Close control masters",
+	Long:              `Close the control master for target, or every control master with --all.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeTarget,
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) == 1) {
+			fmt.Fprintf(os.Stderr, "Error: give either a target or --all\n")
+			os.Exit(1)
+		}
+
+		loaded := loadMuxConfig()
+
+		if !all {
+			target := resolveMuxTarget(loaded, args[0])
+			if _, err := ssh.MuxControl(loaded, "", target, "exit"); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+				os.Exit(1)
+			}
+			fmt.Printf("Stopped control master for %s\n", args[0])
+			return
+		}
+
+		failed := false
+		for _, socket := range listMuxSockets(loaded) {
+			if _, err := ssh.MuxControl(loaded, socket.Path, nil, "exit"); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", socket.Name, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("Stopped control master %s\n", socket.Name)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// loadMuxConfig loads the configuration for the mux commands
+func loadMuxConfig() *types.Config {
+	loaded := mustLoadConfig()
+	if !loaded.SSH.Multiplexing.Enabled {
+		fmt.Fprintf(os.Stderr, "Warning: ssh.multiplexing.enabled is false\n")
+	}
+	return loaded
+}
+
+// resolveMuxTarget resolves a target the way connections to it are made
+func resolveMuxTarget(cfg *types.Config, rawTarget string) *ssh.SSHTarget {
+	target, err := ssh.ResolveTarget(cfg, rawTarget)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid SSH target: %v\n", err)
+		os.Exit(1)
+	}
+	return target
+}
+
+// listMuxSockets lists the control sockets, exiting on error
+func listMuxSockets(cfg *types.Config) []ssh.MuxSocket {
+	sockets, err := ssh.MuxSockets(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return sockets
+}
+
+func init() {
+	rootCmd.AddCommand(muxCmd)
+	muxCmd.AddCommand(muxStatusCmd)
+	muxCmd.AddCommand(muxStopCmd)
+
+	muxStopCmd.Flags().Bool("all", false, "close every control master")
+}
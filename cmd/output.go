@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats for --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFormats are the formats --output accepts
+var outputFormats = []string{outputTable, outputJSON, outputYAML}
+
+// outputFormat returns the format given with --output, exiting on an unknown one
+func outputFormat(cmd *cobra.Command) string {
+	format, _ := cmd.Flags().GetString("output")
+	format = strings.ToLower(format)
+	if !slices.Contains(outputFormats, format) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --output %s, must be one of %s\n", format, strings.Join(outputFormats, ", "))
+		os.Exit(1)
+	}
+	return format
+}
+
+// printStructured prints value as JSON or YAML, exiting if it can't be encoded
+func printStructured(format string, value interface{}) {
+	if err := writeStructured(os.Stdout, format, value); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeStructured writes value to w as JSON or YAML. YAML output is converted
+// from the JSON encoding, so both use the same field names and order.
+func writeStructured(w io.Writer, format string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding output: %w", err)
+	}
+	if format == outputJSON {
+		_, err := fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	// JSON is YAML in flow style; decode it and print it in block style
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("error encoding output: %w", err)
+	}
+	clearStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return fmt.Errorf("error encoding output: %w", err)
+	}
+	encoder.Close()
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// clearStyle resets the style of a YAML node and its children, so they are
+// printed in the default block style with quotes only where needed
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"vssh/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+// printCommandCmd prints an ssh command line that uses a Vault-signed certificate
+var printCommandCmd = &cobra.Command{
+	Use:   "print-command [user@host]",
+	Short: "Print an ssh command that uses a Vault-signed certificate",
+	Long: `Ensure a valid Vault-signed certificate and print an ssh command line
+that uses it, for tools that run ssh themselves.
+
+The command has no destination, since tools such as git and rsync add their
+own. With a target the certificate is signed for its user, role and signing
+engine, and its port and jump hosts are included. Without one the certificate
+is signed for the current user.
+
+Examples:
+  GIT_SSH_COMMAND="$(vssh print-command git@git.example.com)" git fetch
+  rsync -e "$(vssh print-command admin@db01)" backup.tar admin@db01:/srv/
+  export GIT_SSH_COMMAND="$(vssh print-command)"`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeTarget,
+	Run: func(cmd *cobra.Command, args []string) {
+		s := newSession(cmd)
+
+		var sshArgs []string
+		if len(args) == 0 {
+			target := &ssh.SSHTarget{Username: os.Getenv("USER")}
+			if target.Username == "" {
+				fmt.Fprintf(os.Stderr, "Error: no target given and USER environment variable not set\n")
+				os.Exit(1)
+			}
+
+			certPath, err := s.ensureCertificate(target)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to ensure SSH certificate: %v\n", err)
+				os.Exit(exitCode(classify(exitSigning, err)))
+			}
+			keyPath, err := s.signer.GetPrivateKeyPath(target)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to get private key path: %v\n", err)
+				os.Exit(exitSigning)
+			}
+			if s.useAgent {
+				if err := s.addToAgent(keyPath, certPath); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(exitSigning)
+				}
+			} else {
+				if keyPath != "" {
+					sshArgs = append(sshArgs, "-i", keyPath)
+				}
+				sshArgs = append(sshArgs, "-o", "CertificateFile="+certPath)
+			}
+		} else {
+			targets, err := s.expandTargets(args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid SSH target: %v\n", err)
+				os.Exit(1)
+			}
+			if len(targets) != 1 {
+				fmt.Fprintf(os.Stderr, "Error: %s expands to %d targets, print-command needs exactly one\n", args[0], len(targets))
+				os.Exit(1)
+			}
+
+			target, certPath, options, err := s.prepareTarget(targets[0], &ssh.SSHOptions{})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(classify(1, err)))
+			}
+
+			if options.IdentityFile != "" {
+				sshArgs = append(sshArgs, "-i", options.IdentityFile)
+			}
+			if certPath != "" {
+				sshArgs = append(sshArgs, "-o", "CertificateFile="+certPath)
+			}
+			if target.Port != "" {
+				sshArgs = append(sshArgs, "-p", target.Port)
+			}
+			if len(options.JumpHosts) > 0 {
+				sshArgs = append(sshArgs, "-o", "ProxyCommand="+ssh.ProxyCommand(options.JumpHosts))
+			}
+		}
+
+		sshArgs = append(sshArgs, ssh.KeyProviderArgs(s.config)...)
+
+		words := []string{"ssh"}
+		for _, arg := range sshArgs {
+			words = append(words, ssh.ShellQuote(arg))
+		}
+		fmt.Println(strings.Join(words, " "))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(printCommandCmd)
+}
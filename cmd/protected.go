@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"vssh/internal/ssh"
+	"vssh/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// confirmProtected asks for the host name to be typed before connecting to a
+// protected host, and for a reason if the host requires one and --reason
+// wasn't given. Each host is confirmed once per invocation, and the
+// confirmation is logged as an event for the audit log and kept in the
+// connection history.
+func (s *session) confirmProtected(target *ssh.SSHTarget) error {
+	settings := s.config.ResolveHost(target.Hostname)
+	if !settings.Protected {
+		return nil
+	}
+	if s.dryRun {
+		fmt.Printf("%s is a protected host: the connection would be confirmed first\n", target.Hostname)
+		return nil
+	}
+	if !s.confirm {
+		return nil
+	}
+
+	// Parallel runs confirm their hosts one at a time
+	s.confirmMu.Lock()
+	defer s.confirmMu.Unlock()
+	if _, ok := s.confirmed[target.Hostname]; ok {
+		return nil
+	}
+
+	tty, err := utils.OpenTerminal()
+	if err != nil {
+		return fmt.Errorf("%s is a protected host and can only be confirmed on a terminal: %w", target.Hostname, err)
+	}
+	defer tty.Close()
+	reader := bufio.NewReader(tty.In)
+
+	fmt.Fprintf(tty.Out, "%s is a protected host.\n", target.Hostname)
+	fmt.Fprint(tty.Out, "Type the host name to connect: ")
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("error reading confirmation: %w", err)
+	}
+	if strings.TrimSpace(answer) != target.Hostname {
+		return fmt.Errorf("%s was not confirmed, not connecting", target.Hostname)
+	}
+
+	reason := s.reason
+	if settings.RequireReason && reason == "" {
+		fmt.Fprint(tty.Out, "Reason: ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("error reading reason: %w", err)
+		}
+		if reason = strings.TrimSpace(answer); reason == "" {
+			return fmt.Errorf("%s requires a reason, not connecting", target.Hostname)
+		}
+	}
+
+	if s.confirmed == nil {
+		s.confirmed = make(map[string]string)
+	}
+	s.confirmed[target.Hostname] = reason
+	s.logger.WithFields(logrus.Fields{
+		"event":  "protected_host_confirmed",
+		"user":   target.Username,
+		"host":   target.Hostname,
+		"reason": reason,
+	}).Infof("Connection to protected host %s confirmed (reason: %q)", target.Hostname, reason)
+	return nil
+}
+
+// confirmedReason returns the reason given when a protected host was
+// confirmed, if any, and whether it was confirmed
+func (s *session) confirmedReason(hostname string) (string, bool) {
+	s.confirmMu.Lock()
+	defer s.confirmMu.Unlock()
+	reason, ok := s.confirmed[hostname]
+	return reason, ok
+}
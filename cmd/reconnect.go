@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"vssh/internal/recording"
+	"vssh/internal/ssh"
+)
+
+// stableConnection is how long a connection must last for a drop to start
+// the --reconnect budget over, so only drops in a row use it up
+const stableConnection = time.Minute
+
+// connectReconnecting connects to a target like connectRecorded. With
+// --reconnect, when the connection drops it prepares the target again, which
+// renews its certificate if it expired, and reconnects after
+// --retry-interval, up to s.reconnects times in a row. Sessions ended by a
+// signal to vssh aren't reconnected.
+func connectReconnecting(s *session, rawTarget string, sshOptions *ssh.SSHOptions, target *ssh.SSHTarget, certPath string, options *ssh.SSHOptions, command []string, recorder *recording.Recorder) error {
+	defer closeRecording(s, recorder)
+	if s.reconnects <= 0 {
+		return connectRecorded(s, target, certPath, options, command, recorder)
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(interrupted)
+
+	attempts := 0
+	for {
+		started := time.Now()
+		err := connectRecorded(s, target, certPath, options, command, recorder)
+		// ssh exits with 255 when the connection fails, other codes are the
+		// remote command's or vssh's own
+		if exitCode(err) != 255 || len(interrupted) > 0 {
+			return err
+		}
+		if time.Since(started) >= stableConnection {
+			attempts = 0
+		}
+
+		for {
+			if attempts >= s.reconnects {
+				s.logger.Errorf("Giving up on %s after %d reconnection attempts", target.Hostname, attempts)
+				return err
+			}
+			attempts++
+			s.logger.Warnf("Connection to %s lost, reconnecting in %s (attempt %d of %d)...", target.Hostname, s.retryInterval, attempts, s.reconnects)
+			select {
+			case <-interrupted:
+				return err
+			case <-time.After(s.retryInterval):
+			}
+
+			var prepareErr error
+			target, certPath, options, prepareErr = s.reconnectTarget(rawTarget, sshOptions)
+			if len(interrupted) > 0 {
+				return err
+			}
+			if prepareErr == nil {
+				break
+			}
+			s.logger.Warnf("Reconnecting to %s failed: %v", rawTarget, prepareErr)
+			err = prepareErr
+		}
+		s.logger.Infof("Reconnecting to %s", target.Hostname)
+	}
+}
+
+// reconnectTarget prepares a target again for reconnecting, signing a new
+// certificate if the one used before expired, and waits for it with --wait
+func (s *session) reconnectTarget(rawTarget string, sshOptions *ssh.SSHOptions) (*ssh.SSHTarget, string, *ssh.SSHOptions, error) {
+	target, certPath, options, err := s.prepareTarget(rawTarget, sshOptions)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if err := s.waitForHost(target, options); err != nil {
+		return nil, "", nil, err
+	}
+	return target, certPath, options, nil
+}
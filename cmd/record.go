@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"vssh/internal/recording"
+	"vssh/internal/ssh"
+
+	"golang.org/x/term"
+)
+
+// recordInDirectory is the value of --record without a path, which records in
+// recording.directory
+const recordInDirectory = "auto"
+
+// recordingPath returns where to record a session with target: the path given
+// with --record, a new file in recording.directory for --record without one or
+// recording.enabled, or "" not to record
+func recordingPath(s *session, target *ssh.SSHTarget) string {
+	path := s.record
+	if path == "" && s.config.Recording.Enabled {
+		path = recordInDirectory
+	}
+	if path == recordInDirectory {
+		path = recording.DefaultPath(s.config.Recording.Directory, target.Username, target.Hostname, time.Now())
+	}
+	return path
+}
+
+// startRecording starts recording a session with target if asked to, and
+// returns nil otherwise. A session that should be recorded but can't be is
+// not started.
+func startRecording(s *session, target *ssh.SSHTarget) *recording.Recorder {
+	path := recordingPath(s, target)
+	if path == "" {
+		return nil
+	}
+
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	recorder, err := recording.Create(path, width, height, target.Username+"@"+target.Hostname)
+	if err != nil {
+		fatalf(s.logger, 1, "Failed to start recording: %v", err)
+	}
+	s.logger.Infof("Recording session to %s", path)
+	return recorder
+}
+
+// connectRecorded connects to target like Client.Connect, copying the
+// session's output to recorder if it isn't nil
+func connectRecorded(s *session, target *ssh.SSHTarget, certPath string, options *ssh.SSHOptions, command []string, recorder *recording.Recorder) error {
+	if recorder == nil {
+		return s.sshClient.Connect(target, certPath, options, command)
+	}
+	return s.sshClient.Execute(target, certPath, options, command, os.Stdin, io.MultiWriter(os.Stdout, recorder), os.Stderr)
+}
+
+// closeRecording closes recorder, if it isn't nil, once the sessions it
+// records are over
+func closeRecording(s *session, recorder *recording.Recorder) {
+	if recorder == nil {
+		return
+	}
+	if err := recorder.Close(); err != nil {
+		s.logger.Warnf("Recording %s is incomplete: %v", recorder.Path(), err)
+	}
+}
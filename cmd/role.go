@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"vssh/internal/vault"
+
+	"github.com/spf13/cobra"
+)
+
+// roleCmd represents the role command
+var roleCmd = &cobra.Command{
+	Use:   "role",
+	Short: "Inspect Vault SSH roles",
+	Long: `Inspect the roles of the Vault SSH secrets engine that sign certificates,
+to find out which users a role can sign for and how long its certificates last.`,
+}
+
+// roleShowCmd shows the settings of a role
+var roleShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show what a Vault SSH role allows",
+	Long: `Show the settings of a Vault SSH role that shape its certificates: the users
+(principals) it may sign for, its default and maximum TTL, the key types it
+accepts and the extensions and critical options it grants.
+
+Examples:
+  vssh role show alice
+  vssh role show deploy --engine ssh-prod`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := outputFormat(cmd)
+		s := newSession(cmd)
+		engine, _ := cmd.Flags().GetString("engine")
+		if engine == "" {
+			engine = s.config.SSH.SigningEngine
+		}
+
+		role, err := s.vaultClient.SSHRole(engine, args[0])
+		if err != nil {
+			s.logger.Fatalf("%v", err)
+		}
+
+		if format != outputTable {
+			printStructured(format, newRoleOutput(args[0], strings.Trim(engine, "/"), role))
+			return
+		}
+
+		fmt.Printf("Role:                %s (%s)\n", args[0], strings.Trim(engine, "/"))
+		fmt.Printf("Key type:            %s\n", role.KeyType)
+		fmt.Printf("Certificates:        %s\n", roleCertTypes(role))
+		allowedUsers := listOrNone(role.AllowedUsers)
+		if role.AllowedUsersTemplate {
+			allowedUsers += " (templated)"
+		}
+		fmt.Printf("Allowed users:       %s\n", allowedUsers)
+		fmt.Printf("Default user:        %s\n", valueOrNone(role.DefaultUser))
+		if role.AllowHostCertificates {
+			fmt.Printf("Allowed domains:     %s\n", listOrNone(role.AllowedDomains))
+		}
+		fmt.Printf("Default TTL:         %s\n", roleTTL(role.TTL, "engine default"))
+		fmt.Printf("Max TTL:             %s\n", roleTTL(role.MaxTTL, "engine maximum"))
+		fmt.Printf("Key types:           %s\n", roleKeyLengths(role.AllowedUserKeyLengths))
+		fmt.Printf("Allowed extensions:  %s\n", listOrNone(role.AllowedExtensions))
+		fmt.Printf("Default extensions:  %s\n", valueOrNone(formatOptions(role.DefaultExtensions)))
+		fmt.Printf("Allowed crit. opts:  %s\n", listOrNone(role.AllowedCriticalOptions))
+		fmt.Printf("Default crit. opts:  %s\n", valueOrNone(formatOptions(role.DefaultCriticalOptions)))
+		if role.AllowUserKeyIDs {
+			fmt.Printf("Key IDs:             requested key IDs allowed\n")
+		} else {
+			fmt.Printf("Key IDs:             %s\n", valueOrNone(role.KeyIDFormat))
+		}
+
+		// The most common surprises: a shorter TTL than configured, and no way
+		// to log in as the requested user
+		if ttl := s.config.SSH.CertificateTTL; role.MaxTTL > 0 && ttl > role.MaxTTL {
+			fmt.Printf("\nssh.certificate_ttl (%s) exceeds the max TTL, so certificates are clamped to %s\n", ttl, role.MaxTTL)
+		}
+		if role.AllowUserCertificates && len(role.AllowedUsers) == 0 && role.DefaultUser == "" {
+			fmt.Printf("\nThe role allows no users, so its certificates can't log in anywhere\n")
+		}
+	},
+}
+
+// roleOutput is a Vault SSH role as printed with --output. TTLs are in
+// seconds, 0 deferring to the engine.
+type roleOutput struct {
+	Name                   string            `json:"name"`
+	Engine                 string            `json:"engine"`
+	KeyType                string            `json:"key_type"`
+	AllowUserCertificates  bool              `json:"allow_user_certificates"`
+	AllowHostCertificates  bool              `json:"allow_host_certificates"`
+	AllowedUsers           []string          `json:"allowed_users"`
+	AllowedUsersTemplate   bool              `json:"allowed_users_template"`
+	DefaultUser            string            `json:"default_user"`
+	AllowedDomains         []string          `json:"allowed_domains"`
+	TTL                    int64             `json:"ttl"`
+	MaxTTL                 int64             `json:"max_ttl"`
+	AllowedUserKeyLengths  map[string][]int  `json:"allowed_user_key_lengths"`
+	AllowedExtensions      []string          `json:"allowed_extensions"`
+	DefaultExtensions      map[string]string `json:"default_extensions"`
+	AllowedCriticalOptions []string          `json:"allowed_critical_options"`
+	DefaultCriticalOptions map[string]string `json:"default_critical_options"`
+	AllowUserKeyIDs        bool              `json:"allow_user_key_ids"`
+	KeyIDFormat            string            `json:"key_id_format"`
+}
+
+// newRoleOutput returns the --output form of a role, with empty lists rather
+// than null for unset ones
+func newRoleOutput(name, engine string, role *vault.SSHRole) roleOutput {
+	list := func(values []string) []string {
+		if values == nil {
+			return []string{}
+		}
+		return values
+	}
+	return roleOutput{
+		Name:                   name,
+		Engine:                 engine,
+		KeyType:                role.KeyType,
+		AllowUserCertificates:  role.AllowUserCertificates,
+		AllowHostCertificates:  role.AllowHostCertificates,
+		AllowedUsers:           list(role.AllowedUsers),
+		AllowedUsersTemplate:   role.AllowedUsersTemplate,
+		DefaultUser:            role.DefaultUser,
+		AllowedDomains:         list(role.AllowedDomains),
+		TTL:                    int64(role.TTL.Seconds()),
+		MaxTTL:                 int64(role.MaxTTL.Seconds()),
+		AllowedUserKeyLengths:  role.AllowedUserKeyLengths,
+		AllowedExtensions:      list(role.AllowedExtensions),
+		DefaultExtensions:      role.DefaultExtensions,
+		AllowedCriticalOptions: list(role.AllowedCriticalOptions),
+		DefaultCriticalOptions: role.DefaultCriticalOptions,
+		AllowUserKeyIDs:        role.AllowUserKeyIDs,
+		KeyIDFormat:            role.KeyIDFormat,
+	}
+}
+
+// roleCertTypes describes the certificate types a role signs
+func roleCertTypes(role *vault.SSHRole) string {
+	var certTypes []string
+	if role.AllowUserCertificates {
+		certTypes = append(certTypes, "user")
+	}
+	if role.AllowHostCertificates {
+		certTypes = append(certTypes, "host")
+	}
+	return listOrNone(certTypes)
+}
+
+// roleTTL formats a role TTL, where 0 defers to the engine
+func roleTTL(ttl time.Duration, unset string) string {
+	if ttl == 0 {
+		return unset
+	}
+	return ttl.String()
+}
+
+// roleKeyLengths formats the key types a role accepts, with their allowed lengths
+func roleKeyLengths(lengths map[string][]int) string {
+	if len(lengths) == 0 {
+		return "any"
+	}
+	var keyTypes []string
+	for _, keyType := range slices.Sorted(maps.Keys(lengths)) {
+		var sizes []string
+		for _, length := range lengths[keyType] {
+			if length > 0 {
+				sizes = append(sizes, fmt.Sprint(length))
+			}
+		}
+		if len(sizes) > 0 {
+			keyType += " (" + strings.Join(sizes, ", ") + ")"
+		}
+		keyTypes = append(keyTypes, keyType)
+	}
+	return strings.Join(keyTypes, ", ")
+}
+
+// listOrNone joins a list, or returns "none" for an empty one
+func listOrNone(list []string) string {
+	return valueOrNone(strings.Join(list, ", "))
+}
+
+// valueOrNone returns value, or "none" when it is empty
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+func init() {
+	rootCmd.AddCommand(roleCmd)
+	roleCmd.AddCommand(roleShowCmd)
+
+	roleShowCmd.Flags().String("engine", "", "SSH secrets engine mount (default ssh.signing_engine)")
+}
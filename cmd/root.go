@@ -1,14 +1,14 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
-	"vssh/internal/auth"
-	"vssh/internal/config"
 	"vssh/internal/ssh"
-	"vssh/internal/utils"
-	"vssh/internal/vault"
 	"vssh/pkg/types"
 
 	"github.com/spf13/cobra"
@@ -34,120 +34,240 @@ func SetVersionInfo(v, c, d string) {
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "vssh [user@]hostname [command]",
+	Use:   "vssh [ssh options] [user@]hostname [command]",
 	Short: "SSH with Vault-signed certificates",
 	Long: `vssh is a CLI tool that signs SSH keys with HashiCorp Vault and uses 
 the signed certificate for SSH authentication. It acts as a wrapper around SSH,
 providing seamless certificate-based authentication through Vault.
 
+vssh accepts the OpenSSH client options (-p, -i, -l, -J, -4, -6, -o, -L, -v, ...)
+before or directly after the destination, exactly like ssh. -p, -i, -l and -J
+are also used when resolving the target and signing certificates, so every jump
+host authenticates with a certificate too; the rest are passed through to ssh
+unchanged.
+
 Examples:
   vssh user@server.com
   vssh user@server.com ls -la
-  vssh -p 2222 user@server.com`,
-	DisableFlagParsing: false,
+  vssh -t user@server.com sudo -i
+  vssh -p 2222 user@server.com
+  vssh -J jump@bastion.example.com user@server.com
+  vssh -i ~/.ssh/id_ed25519 -o StrictHostKeyChecking=accept-new server.com
+  vssh host1 host2 host3 -- uptime
+  vssh @web -- uptime
+  vssh '!3'
+  vssh --tag Environment=staging -- uptime
+  vssh --dry-run -J bastion admin@db01`,
+	// Flags follow ssh's syntax and are parsed by ssh.ParseSSHArgs
+	DisableFlagParsing: true,
 	Args:               cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) == 0 {
+		args, err := parseRootFlags(cmd, args)
+		if err != nil {
+			cmd.PrintErrf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if help, _ := cmd.Flags().GetBool("help"); help || (len(args) > 0 && args[0] == "-h") {
 			cmd.Help()
 			return
 		}
 
-		// Initialize logger
-		debug, _ := cmd.Flags().GetBool("debug")
-		verbose, _ := cmd.Flags().GetBool("verbose")
-		if debug || verbose {
-			utils.InitLogger(true)
-		} else {
-			utils.InitLogger(false)
+		// Targets listed before "--" are connected to in turn, running the command after it.
+		// With --tag the selected hosts are targets too, so any arguments without "--"
+		// are the command.
+		tag, _ := cmd.Flags().GetString("tag")
+		sshArgs, command := args, []string(nil)
+		dash := slices.Index(args, "--")
+		if dash >= 0 {
+			sshArgs, command = args[:dash], args[dash+1:]
 		}
 
-		logger := utils.GetLogger()
-		logger.Debug("Starting vssh")
-
-		// Load configuration
-		var err error
-		cfg, err = config.LoadConfig()
+		sshOptions, positional, err := ssh.ParseSSHArgs(sshArgs)
 		if err != nil {
-			logger.Fatalf("Failed to load configuration: %v", err)
+			cmd.PrintErrf("Error: %v\n", err)
+			os.Exit(1)
 		}
 
-		logger.Debugf("Configuration loaded successfully")
-		logger.Debugf("Vault address: %s", cfg.Vault.Address)
-		logger.Debugf("Auth method: %s", cfg.Vault.AuthMethod)
-
-		// Create Vault client
-		vaultClient, err := vault.NewClient(&cfg.Vault)
-		if err != nil {
-			logger.Fatalf("Failed to create Vault client: %v", err)
+		if dash >= 0 && len(positional) == 0 && tag == "" {
+			// Nothing came before "--", so it only ended the ssh options
+			positional, command, dash = command, nil, -1
 		}
 
-		// Create authenticator and ensure we have a valid token
-		authenticator := auth.NewAuthenticator(vaultClient, &cfg.Vault, logger)
-		if err := authenticator.EnsureAuthenticated(); err != nil {
-			logger.Fatalf("Authentication failed: %v", err)
+		var targets []string
+		switch {
+		case dash >= 0:
+			targets = positional
+		case tag != "":
+			command = positional
+		case len(positional) > 0:
+			targets, command = positional[:1], positional[1:]
 		}
-
-		// Parse SSH target
-		target, err := ssh.ParseSSHTarget(args[0])
-		if err != nil {
-			logger.Fatalf("Invalid SSH target: %v", err)
+		if tag != "" {
+			targets = append(targets, "@tag:"+tag)
 		}
-
-		logger.Debugf("Parsed SSH target - Username: %s, Hostname: %s", target.Username, target.Hostname)
-
-		// Create SSH signer and ensure certificate
-		signer := ssh.NewSigner(vaultClient, cfg, logger)
-		certPath, err := signer.EnsureSSHCertificate(target.Username)
-		if err != nil {
-			logger.Fatalf("Failed to ensure SSH certificate: %v", err)
+		if len(targets) == 0 {
+			cmd.Help()
+			return
 		}
 
-		logger.Debugf("About to parse SSH arguments: %v", args)
+		// -v and -d raise vssh's own log level as well as ssh's
+		if sshOptions.Verbose > 0 {
+			cmd.Flags().Set("verbose", "true")
+		}
+		if debug, _ := cmd.Flags().GetBool("debug"); debug {
+			sshOptions.Debug = true
+		} else if sshOptions.Debug {
+			cmd.Flags().Set("debug", "true")
+		}
 
-		// Parse SSH arguments
-		sshOptions, command, err := ssh.ParseSSHArgs(args)
-		if err != nil {
-			logger.Fatalf("Failed to parse SSH arguments: %v", err)
+		// -q silences vssh's own informational output as well as ssh's
+		if quiet, _ := cmd.Flags().GetBool("quiet"); quiet {
+			sshOptions.Quiet = true
+		} else if sshOptions.Quiet {
+			cmd.Flags().Set("quiet", "true")
 		}
 
-		logger.Debugf("SSH options parsed successfully")
+		s := newSession(cmd)
+		s.otp = true
+		s.record, _ = cmd.Flags().GetString("record")
+		s.wait, _ = cmd.Flags().GetBool("wait")
+		s.retryInterval, _ = cmd.Flags().GetDuration("retry-interval")
+		s.waitTimeout, _ = cmd.Flags().GetDuration("timeout")
+		s.reconnects, _ = cmd.Flags().GetInt("reconnect")
+		logger := s.logger
 
-		// Get private key path for identity
-		privateKeyPath, err := signer.GetPrivateKeyPath(target.Username)
+		targets, err = s.expandTargets(targets)
 		if err != nil {
-			logger.Fatalf("Failed to get private key path: %v", err)
+			logger.Errorf("Invalid SSH target: %v", err)
+			os.Exit(255)
+		}
+		if s.record != "" && s.record != recordInDirectory && len(targets) > 1 {
+			logger.Errorf("--record=<path> records one session, use --record to record each of the %d targets in recording.directory", len(targets))
+			os.Exit(1)
+		}
+		if (s.wait || s.reconnects > 0) && s.retryInterval <= 0 {
+			logger.Errorf("--retry-interval must be positive")
+			os.Exit(1)
+		}
+		if s.wait && s.waitTimeout <= 0 {
+			logger.Errorf("--timeout must be positive")
+			os.Exit(1)
 		}
-		sshOptions.IdentityFile = privateKeyPath
-
-		logger.Debugf("Private key path: %s", privateKeyPath)
 
-		// Create SSH client and connect
-		sshClient := ssh.NewClient(cfg, logger)
+		logger.Debugf("SSH options parsed: %+v", *sshOptions)
 
-		// Validate SSH binary is available
-		if err := sshClient.ValidateSSHBinary(); err != nil {
-			logger.Fatalf("SSH validation failed: %v", err)
+		if s.dryRun {
+			printDryRun(s, targets, sshOptions, command)
+			return
 		}
 
-		logger.Debugf("SSH binary validation passed")
-
-		fmt.Printf("Connecting to %s with Vault-signed certificate...\n", args[0])
-		logger.Infof("Using certificate: %s", certPath)
-		logger.Infof("Using private key: %s", privateKeyPath)
+		if len(targets) == 1 {
+			target, certPath, targetOptions, err := s.prepareTarget(targets[0], sshOptions)
+			if err != nil {
+				logger.Errorf("%v", err)
+				os.Exit(exitCode(err))
+			}
+
+			if err := s.waitForHost(target, targetOptions); err != nil {
+				logger.Errorf("%v", err)
+				os.Exit(exitCode(err))
+			}
+
+			conn := newConnection(s, targets[0], target, certPath)
+			if err := beforeConnect(s, conn); err != nil {
+				logger.Errorf("%v", err)
+				os.Exit(exitCode(err))
+			}
+
+			credential := "Vault-signed certificate"
+			if targetOptions.Password != "" {
+				credential = "Vault one-time password"
+			}
+			if !sshOptions.Quiet {
+				// Keep stdout for the remote command's output
+				fmt.Fprintf(os.Stderr, "Connecting to %s with %s...\n", targets[0], credential)
+			}
+			if targetOptions.Password == "" {
+				logger.Infof("Using certificate: %s", certPath)
+				logger.Infof("Using private key: %s", targetOptions.IdentityFile)
+			}
+
+			// Execute SSH connection
+			logger.Debugf("About to execute SSH connection")
+			recorder := startRecording(s, target)
+			started := time.Now()
+			err = connectReconnecting(s, targets[0], sshOptions, target, certPath, targetOptions, command, recorder)
+			logConnection(s, target, started, err)
+			recordConnection(s, err)
+			recordHistory(s, conn, started, err)
+			afterDisconnect(s, conn, started, err)
+			if !sshOptions.Quiet {
+				printSessionSummary(s, conn, started, err)
+			}
+			if err != nil {
+				// Exit like ssh: with the remote command's exit status, or 255
+				// if the connection failed. ssh has already reported why.
+				// Failures to start ssh get their own exit code.
+				var exitErr *ssh.ExitError
+				if !errors.As(err, &exitErr) {
+					logger.Errorf("SSH connection failed: %v", err)
+				}
+				logger.Debugf("%v", err)
+				os.Exit(exitCode(err))
+			}
+
+			logger.Debugf("SSH connection completed successfully")
+			return
+		}
 
-		// Execute SSH connection
-		logger.Debugf("About to execute SSH connection")
-		if err := sshClient.Connect(target, certPath, sshOptions, command); err != nil {
-			logger.Fatalf("SSH connection failed: %v", err)
+		// Run the command on each target sequentially. Certificates are cached,
+		// so targets sharing a username reuse the same signed certificate.
+		var failed []string
+		status := 0
+		for _, rawTarget := range targets {
+			fmt.Printf("==> %s <==\n", rawTarget)
+
+			target, certPath, targetOptions, err := s.prepareTarget(rawTarget, sshOptions)
+			if err == nil {
+				err = s.waitForHost(target, targetOptions)
+			}
+			if err == nil {
+				conn := newConnection(s, rawTarget, target, certPath)
+				if err = beforeConnect(s, conn); err == nil {
+					recorder := startRecording(s, target)
+					started := time.Now()
+					err = connectReconnecting(s, rawTarget, sshOptions, target, certPath, targetOptions, command, recorder)
+					logConnection(s, target, started, err)
+					recordConnection(s, err)
+					recordHistory(s, conn, started, err)
+					afterDisconnect(s, conn, started, err)
+					if !sshOptions.Quiet {
+						printSessionSummary(s, conn, started, err)
+					}
+				}
+			}
+			if err != nil {
+				logger.Errorf("%s: %v", rawTarget, err)
+				failed = append(failed, rawTarget)
+				status = max(status, exitCode(err))
+			}
 		}
 
-		logger.Debugf("SSH connection completed successfully")
+		// Exit with the highest exit code returned by any host
+		if len(failed) > 0 {
+			logger.Errorf("Command failed on %d of %d hosts: %s", len(failed), len(targets), strings.Join(failed, ", "))
+			os.Exit(status)
+		}
 	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() error {
+	// ssh runs vssh as its askpass program to read one-time passwords
+	if ssh.RunAskpass(os.Args[1:]) {
+		return nil
+	}
 	return rootCmd.Execute()
 }
 
@@ -159,6 +279,14 @@ func init() {
 		Use:   "version",
 		Short: "Print version information",
 		Run: func(cmd *cobra.Command, args []string) {
+			if format := outputFormat(cmd); format != outputTable {
+				printStructured(format, struct {
+					Version string `json:"version"`
+					Commit  string `json:"commit"`
+					Built   string `json:"built"`
+				}{version, commit, date})
+				return
+			}
 			fmt.Printf("vssh %s\n", version)
 			fmt.Printf("Commit: %s\n", commit)
 			fmt.Printf("Built: %s\n", date)
@@ -169,14 +297,72 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/vssh/config.yaml)")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolP("debug", "d", false, "debug output")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "only print warnings and errors")
+	rootCmd.PersistentFlags().Bool("no-color", false, "don't color output (also off when NO_COLOR is set or stderr isn't a terminal)")
+	rootCmd.PersistentFlags().String("reason", "", "reason for connecting to protected hosts, recorded in the audit log")
+	rootCmd.PersistentFlags().String("output", outputTable, "output format of version, history, stats, role show and agentd status: table, json or yaml")
+
+	// SSH options are parsed by ssh.ParseSSHArgs, so only long flags are defined here
+	rootCmd.Flags().String("tag", "", "connect to inventory hosts with these tags (key=value[,key=value])")
+	rootCmd.Flags().Bool("dry-run", false, "print the certificates and ssh command without signing or connecting")
+	rootCmd.Flags().Bool("auto-keygen", false, "generate an ed25519 key pair if the key to sign is missing")
+	rootCmd.Flags().String("key-type", "", "sign and use the user's key of this type (ed25519, ecdsa, rsa, ed25519-sk, ecdsa-sk)")
+	rootCmd.Flags().Duration("ttl", 0, "sign a new certificate valid this long instead of ssh.certificate_ttl (clamped by the role's max_ttl)")
+	rootCmd.Flags().StringSlice("principal", nil, "request this principal in the certificate instead of the configured ones (repeatable)")
+	rootCmd.Flags().Bool("strict", false, "fail instead of warning when a new certificate isn't valid for the login user")
+	rootCmd.Flags().Bool("agent", false, "load the certificate into ssh-agent and authenticate through it")
+	rootCmd.Flags().String("mode", "", "log in with a signed certificate (ca) or a Vault one-time password (otp) instead of the host's mode")
+	rootCmd.Flags().String("record", "", "record the session as an asciicast file, in recording.directory or at --record=<path>")
+	rootCmd.Flags().Lookup("record").NoOptDefVal = recordInDirectory
+	rootCmd.Flags().Bool("wait", false, "retry until the host's SSH server answers, such as while it reboots, then connect with the signed certificate")
+	rootCmd.Flags().Int("reconnect", 0, "reconnect up to this many times in a row (3 without a number) when the connection drops")
+	rootCmd.Flags().Lookup("reconnect").NoOptDefVal = "3"
+	rootCmd.Flags().Duration("retry-interval", 5*time.Second, "time between attempts to reach the host with --wait or to reconnect with --reconnect")
+	rootCmd.Flags().Duration("timeout", 10*time.Minute, "how long to wait for the host with --wait")
+}
 
-	// SSH-compatible flags
-	rootCmd.Flags().StringP("port", "p", "", "port to connect to on the remote host")
-	rootCmd.Flags().StringP("identity", "i", "", "selects a file from which the identity (private key) is read")
-	rootCmd.Flags().BoolP("force-protocol-version1", "1", false, "forces ssh to try protocol version 1 only")
-	rootCmd.Flags().BoolP("force-protocol-version2", "2", false, "forces ssh to try protocol version 2 only")
-	rootCmd.Flags().BoolP("ipv4", "4", false, "forces ssh to use IPv4 addresses only")
-	rootCmd.Flags().BoolP("ipv6", "6", false, "forces ssh to use IPv6 addresses only")
+// parseRootFlags parses vssh's own long flags (--config, --tag, ...) from the
+// arguments of a command with flag parsing disabled and returns the remaining
+// arguments, which follow ssh's syntax. Arguments after "--" and unknown long
+// flags, which may belong to the remote command, are left in place.
+func parseRootFlags(cmd *cobra.Command, args []string) ([]string, error) {
+	var flags, rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		name, found := strings.CutPrefix(arg, "--")
+		name, _, hasValue := strings.Cut(name, "=")
+		flag := cmd.Flag(name)
+		if !found || flag == nil {
+			rest = append(rest, arg)
+			continue
+		}
+
+		flags = append(flags, arg)
+		if !hasValue && flag.NoOptDefVal == "" && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+
+	// cmd.ParseFlags does nothing with flag parsing disabled, so parse the
+	// command's flags, including the persistent and inherited ones, directly
+	flagSet := cmd.Flags()
+	flagSet.AddFlagSet(cmd.PersistentFlags())
+	flagSet.AddFlagSet(cmd.InheritedFlags())
+	if err := flagSet.Parse(flags); err != nil {
+		return nil, err
+	}
+
+	// The config file flag is only known now, after cobra initialized the configuration
+	if cfgFile != "" {
+		initConfig()
+	}
+	return rest, nil
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -199,7 +385,7 @@ func initConfig() {
 		viper.SetConfigName("config")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	// VSSH_* environment variable overrides are bound in config.LoadConfig
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"vssh/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+// rsyncCmd runs rsync over ssh using a Vault-signed certificate
+var rsyncCmd = &cobra.Command{
+	Use:   "rsync -- <rsync options> <source>... <target>",
+	Short: "Sync files with rsync using a Vault-signed certificate",
+	Long: `Run rsync over ssh, authenticating with a Vault-signed certificate.
+
+The remote path uses rsync's [user@]host:path syntax, and the host is resolved
+like any vssh target. vssh ensures a certificate for its user and runs rsync
+with -e set to an ssh command using it, so rsync options must not set -e or
+--rsh. Put rsync's arguments after "--" so options such as --verbose reach
+rsync rather than vssh.
+
+rsync copies to or from a single remote host, and daemon paths (host::module,
+rsync://) don't use ssh, so they aren't supported. To set the remote shell
+yourself use vssh print-command:
+
+  rsync -av -e "$(vssh print-command admin@db01)" ./site/ admin@db01:/srv/www/
+
+Examples:
+  vssh rsync -- -av ./site/ admin@web1:/srv/www/
+  vssh rsync -- -az --delete admin@db01:/var/backups/ ./backups/`,
+	// rsync's options are passed through unchanged
+	DisableFlagParsing: true,
+	Args:               cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		args, err := parseRootFlags(cmd, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if help, _ := cmd.Flags().GetBool("help"); help || (len(args) > 0 && args[0] == "-h") {
+			cmd.Help()
+			return
+		}
+		if len(args) > 0 && args[0] == "--" {
+			args = args[1:]
+		}
+
+		if ssh.HasRsyncShellOption(args) {
+			fmt.Fprintf(os.Stderr, "Error: vssh sets rsync's remote shell, remove -e/--rsh\n")
+			os.Exit(1)
+		}
+
+		s := newSession(cmd)
+		rsyncArgs, conn, err := s.rsyncArgs(args)
+		if err != nil {
+			s.logger.Errorf("%v", err)
+			os.Exit(exitCode(err))
+		}
+
+		runTool(s, "rsync", rsyncArgs, []*connection{conn})
+	},
+}
+
+// rsyncArgs resolves the remote host of rsync's arguments, ensures a
+// certificate for its user and returns the arguments with -e set to use it,
+// and the connection rsync makes
+func (s *session) rsyncArgs(args []string) ([]string, *connection, error) {
+	var conn *connection
+	var remoteTarget string
+	var rsh string
+	resolved := make([]string, len(args))
+
+	for i, arg := range args {
+		resolved[i] = arg
+		remote, ok := ssh.ParseRsyncRemote(arg)
+		if !ok {
+			continue
+		}
+		if remoteTarget != "" && remote.Target != remoteTarget {
+			return nil, nil, fmt.Errorf("rsync copies to or from one remote host, got %s and %s", remoteTarget, remote.Target)
+		}
+
+		targets, err := s.expandTargets([]string{remote.Target})
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid SSH target: %w", err)
+		}
+		if len(targets) != 1 {
+			return nil, nil, fmt.Errorf("%s expands to %d targets, a remote path needs exactly one", remote.Target, len(targets))
+		}
+
+		target, certPath, options, err := s.prepareTarget(targets[0], &ssh.SSHOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if remoteTarget == "" {
+			sshArgs := s.sshClient.ConnectionArgs(target, certPath, options)
+			if target.Port != "" {
+				sshArgs = append(sshArgs, "-p", target.Port)
+			}
+			rsh = ssh.RsyncShell(sshArgs)
+			remoteTarget = remote.Target
+			conn = newConnection(s, targets[0], target, certPath)
+		}
+		resolved[i] = ssh.FormatRemotePath(target, remote.Path)
+	}
+
+	if remoteTarget == "" {
+		return nil, nil, fmt.Errorf("no remote path given, use [user@]host:path")
+	}
+	return append([]string{"-e", rsh}, resolved...), conn, nil
+}
+
+func init() {
+	rootCmd.AddCommand(rsyncCmd)
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vssh/internal/ssh"
+	"vssh/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// Output formats for vssh run
+const (
+	formatInterleaved = "interleaved"
+	formatGrouped     = "grouped"
+	formatDiff        = "diff"
+)
+
+// runResult is the outcome of running the command on one host
+type runResult struct {
+	host   string
+	code   int
+	err    error
+	output []byte
+
+	// connected is set once the command ran on the host, whatever its exit
+	// status
+	connected bool
+}
+
+// runCmd runs a command on many hosts in parallel
+var runCmd = &cobra.Command{
+	Use:   "run (--hosts <host,...> | --hosts-file <file>) -- <command>",
+	Short: "Run a command on many hosts in parallel",
+	Long: `Run a command on several hosts in parallel with Vault-signed certificates.
+
+Certificates are signed once per username before any connection is made, then
+the command runs on up to --parallel hosts at a time.
+
+Output formats (--format):
+  interleaved  Lines are printed as they arrive, prefixed with their host (default)
+  grouped      Each host's output is printed as one block when the host finishes
+  diff         Hosts with identical output and exit code are printed together
+               once every host has finished (--diff is shorthand)
+
+vssh run exits with the highest exit code returned by any host, so it exits 0
+only if the command succeeded everywhere. Hosts that could not be reached
+count as exit code 255, like ssh itself; failures before connecting count as
+2 for configuration errors, 3 for Vault authentication and 4 for signing.
+
+Examples:
+  vssh run --hosts web1,web2,web3 -- uptime
+  vssh run --hosts @web,db1 --parallel 20 -- sudo systemctl restart nginx
+  vssh run --hosts-file inventory.txt -- uptime
+  vssh run --tag Environment=staging -- uptime
+  vssh run --hosts @web --diff -- cat /etc/os-release`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() != 0 || len(args) == 0 {
+			return fmt.Errorf("the command to run must follow --")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		hosts, _ := cmd.Flags().GetStringSlice("hosts")
+		hostsFile, _ := cmd.Flags().GetString("hosts-file")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		format, _ := cmd.Flags().GetString("format")
+		if diff, _ := cmd.Flags().GetBool("diff"); diff {
+			format = formatDiff
+		}
+
+		switch format {
+		case formatInterleaved, formatGrouped, formatDiff:
+		default:
+			fmt.Fprintf(os.Stderr, "Error: invalid format %q (use interleaved, grouped or diff)\n", format)
+			os.Exit(1)
+		}
+
+		if hostsFile != "" {
+			fileHosts, err := ssh.ReadHostsFile(hostsFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			hosts = append(hosts, fileHosts...)
+		}
+		if tag, _ := cmd.Flags().GetString("tag"); tag != "" {
+			hosts = append(hosts, "@tag:"+tag)
+		}
+		if len(hosts) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: --hosts, --hosts-file or --tag is required\n")
+			os.Exit(1)
+		}
+		if parallel < 1 {
+			parallel = 1
+		}
+
+		s := newSession(cmd)
+		// Batch runs never stop to ask for a role per host
+		s.selectRole = false
+		s.otp = true
+
+		targets, err := s.expandTargets(hosts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		results := runParallel(s, targets, args, parallel, format)
+		if format == formatDiff {
+			printDiffOutput(results)
+		}
+
+		// Report failures and exit with the highest exit code
+		exitCode := 0
+		failed := 0
+		for _, result := range results {
+			if result.code == 0 {
+				continue
+			}
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", result.host, result.err)
+			if result.code > exitCode {
+				exitCode = result.code
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "%d succeeded, %d failed\n", len(results)-failed, failed)
+		os.Exit(exitCode)
+	},
+}
+
+// runParallel signs certificates for every target, then runs command on up to
+// parallel targets at a time, writing output in the given format. Results are
+// returned in target order.
+func runParallel(s *session, targets []string, command []string, parallel int, format string) []runResult {
+	results := make([]runResult, len(targets))
+	options := &ssh.SSHOptions{}
+
+	// Sign sequentially so each username is signed once and reused from disk
+	type prepared struct {
+		target   *ssh.SSHTarget
+		certPath string
+		options  *ssh.SSHOptions
+		conn     *connection
+	}
+	ready := make([]*prepared, len(targets))
+	for i, rawTarget := range targets {
+		results[i].host = rawTarget
+		target, certPath, targetOptions, err := s.prepareTarget(rawTarget, options)
+		var conn *connection
+		if err == nil {
+			conn = newConnection(s, rawTarget, target, certPath)
+			err = beforeConnect(s, conn)
+		}
+		if err != nil {
+			results[i].code, results[i].err = exitCode(err), err
+			continue
+		}
+		ready[i] = &prepared{target: target, certPath: certPath, options: targetOptions, conn: conn}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+
+	for i := range targets {
+		if ready[i] == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			p := ready[i]
+			started := time.Now()
+			var err error
+			if format == formatInterleaved {
+				prefix := results[i].host + ": "
+				stdout := utils.NewPrefixWriter(os.Stdout, prefix, &mu)
+				stderr := utils.NewPrefixWriter(os.Stderr, prefix, &mu)
+				err = s.sshClient.Execute(p.target, p.certPath, p.options, command, nil, stdout, stderr)
+				stdout.Flush()
+				stderr.Flush()
+			} else {
+				// Capture stdout and stderr together to keep their relative order
+				var output bytes.Buffer
+				err = s.sshClient.Execute(p.target, p.certPath, p.options, command, nil, &output, &output)
+				results[i].output = output.Bytes()
+			}
+			recordConnection(s, err)
+			afterDisconnect(s, p.conn, started, err)
+			results[i].connected = ssh.ExitCode(err) != 255
+
+			if err != nil {
+				results[i].err = err
+				results[i].code = exitCode(err)
+			}
+
+			if format == formatGrouped {
+				mu.Lock()
+				printOutputBlock([]string{results[i].host}, results[i].code, results[i].output)
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// printDiffOutput prints each distinct output once, listing the hosts that
+// produced it. The most common output is printed first.
+func printDiffOutput(results []runResult) {
+	type outputGroup struct {
+		hosts  []string
+		code   int
+		output []byte
+	}
+
+	var groups []*outputGroup
+	index := map[string]*outputGroup{}
+	for _, result := range results {
+		// Hosts that failed before connecting have no output to compare
+		if !result.connected {
+			continue
+		}
+
+		key := fmt.Sprintf("%d\x00%s", result.code, result.output)
+		group, exists := index[key]
+		if !exists {
+			group = &outputGroup{code: result.code, output: result.output}
+			index[key] = group
+			groups = append(groups, group)
+		}
+		group.hosts = append(group.hosts, result.host)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].hosts) > len(groups[j].hosts)
+	})
+
+	for _, group := range groups {
+		printOutputBlock(group.hosts, group.code, group.output)
+	}
+}
+
+// printOutputBlock prints output under a header naming the hosts that produced it
+func printOutputBlock(hosts []string, code int, output []byte) {
+	header := strings.Join(hosts, ", ")
+	if len(hosts) > 1 {
+		header = fmt.Sprintf("%s (%d hosts)", header, len(hosts))
+	}
+	if code != 0 {
+		header = fmt.Sprintf("%s [exit %d]", header, code)
+	}
+
+	fmt.Printf("==> %s <==\n", header)
+	os.Stdout.Write(output)
+	if len(output) > 0 && output[len(output)-1] != '\n' {
+		fmt.Println()
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().StringSlice("hosts", nil, "Comma-separated hosts to run on ([user@]host[:port] or @group)")
+	runCmd.Flags().String("hosts-file", "", "File with one target per line (# comments allowed, - for stdin)")
+	runCmd.Flags().String("tag", "", "Run on inventory hosts with these tags (key=value[,key=value])")
+	runCmd.Flags().Int("parallel", 10, "Maximum number of hosts to run on at once")
+	runCmd.Flags().String("format", formatInterleaved, "Output format: interleaved, grouped or diff")
+	runCmd.Flags().Bool("diff", false, "Group hosts with identical output (same as --format diff)")
+	runCmd.RegisterFlagCompletionFunc("hosts", completeHostList)
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"vssh/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+// scpCmd copies files with scp using Vault-signed certificates
+var scpCmd = &cobra.Command{
+	Use:   "scp [scp options] <source>... <target>",
+	Short: "Copy files with scp using a Vault-signed certificate",
+	Long: `Copy files with scp, authenticating with Vault-signed certificates.
+
+Remote paths use scp's syntax, [user@]host:path or scp://[user@]host[:port]/path.
+Hosts are resolved like vssh targets (bookmarks, inventory hosts, ssh_config
+aliases and the hosts configuration), and a certificate is ensured for the
+user of every remote path before scp runs. scp options are passed through;
+-P, -i and -J are also used when signing certificates.
+
+scp uses one port and one set of jump hosts for every remote path, so remote
+paths on hosts with different ports can't be combined in one copy.
+
+Examples:
+  vssh scp backup.tar.gz admin@db01:/srv/backups/
+  vssh scp -r admin@web1:/var/log/nginx ./logs
+  vssh scp -P 2222 report.pdf scp://alice@files.example.com/reports/
+  vssh scp web1:/etc/hosts web2:/tmp/hosts`,
+	// Flags follow scp's syntax and are parsed by ssh.ParseSCPArgs
+	DisableFlagParsing: true,
+	Args:               cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		args, err := parseRootFlags(cmd, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if help, _ := cmd.Flags().GetBool("help"); help || (len(args) > 0 && args[0] == "-h") {
+			cmd.Help()
+			return
+		}
+
+		options, paths, err := ssh.ParseSCPArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(paths) < 2 {
+			fmt.Fprintf(os.Stderr, "Error: a source and a target are required\n")
+			os.Exit(1)
+		}
+
+		s := newSession(cmd)
+		scpArgs, conns, err := s.scpArgs(options, paths)
+		if err != nil {
+			s.logger.Errorf("%v", err)
+			os.Exit(exitCode(err))
+		}
+
+		runTool(s, "scp", scpArgs, conns)
+	},
+}
+
+// scpArgs resolves the remote paths, ensures certificates for their users and
+// returns the arguments for scp and the connections it makes
+func (s *session) scpArgs(options *ssh.SSHOptions, paths []string) ([]string, []*connection, error) {
+	var connectionArgs []string
+	var conns []*connection
+	port, remotes := options.Port, 0
+
+	resolved := make([]string, len(paths))
+	for i, path := range paths {
+		resolved[i] = path
+		remote, ok := ssh.ParseRemotePath(path)
+		if !ok {
+			continue
+		}
+
+		targets, err := s.expandTargets([]string{remote.Target})
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid SSH target: %w", err)
+		}
+		if len(targets) != 1 {
+			return nil, nil, fmt.Errorf("%s expands to %d targets, a remote path needs exactly one", remote.Target, len(targets))
+		}
+
+		target, certPath, targetOptions, err := s.prepareTarget(targets[0], options)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// scp has a single port option for every remote path
+		if options.Port == "" {
+			if remotes > 0 && target.Port != port {
+				return nil, nil, fmt.Errorf("remote paths on hosts with different ports can't be copied in one scp")
+			}
+			port = target.Port
+		}
+		remotes++
+		conns = append(conns, newConnection(s, targets[0], target, certPath))
+
+		resolved[i] = ssh.FormatRemotePath(target, remote.Path)
+		connectionArgs = append(connectionArgs, s.sshClient.ConnectionArgs(target, certPath, targetOptions)...)
+	}
+	if remotes == 0 {
+		return nil, nil, fmt.Errorf("no remote path given, use [user@]host:path")
+	}
+
+	args := append([]string{}, options.ExtraArgs...)
+	if port != "" {
+		args = append(args, "-P", port)
+	}
+	args = append(args, connectionArgs...)
+	args = append(args, "--")
+	return append(args, resolved...), conns, nil
+}
+
+// runTool runs a program that uses ssh for conns and exits with its exit status
+func runTool(s *session, program string, args []string, conns []*connection) {
+	err := withHooks(s, conns, func() error {
+		return s.sshClient.RunTool(program, args)
+	})
+	if err == nil {
+		return
+	}
+
+	// The program has already reported why it failed
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) {
+		s.logger.Errorf("%v", err)
+	}
+	os.Exit(exitCode(err))
+}
+
+func init() {
+	rootCmd.AddCommand(scpCmd)
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"vssh/internal/ssh"
+	"vssh/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// setupCmd groups commands that integrate vssh with other tools
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Integrate vssh with other tools",
+}
+
+// setupSSHConfigCmd generates an OpenSSH client configuration
+var setupSSHConfigCmd = &cobra.Command{
+	Use:   "ssh-config",
+	Short: "Generate an ssh_config for the hosts in the vssh configuration",
+	Long: `Generate OpenSSH client configuration so that plain ssh, scp, git and
+IDEs use the certificates vssh signs.
+
+Every hosts entry and group becomes a Host block with its User, IdentityFile,
+CertificateFile and, for hosts behind jump hosts, a ProxyCommand. The
+configuration is printed by default. With --write it is saved to --file and
+an Include for it is added to the top of ssh.ssh_config_file.
+
+Certificates are short-lived and ssh doesn't renew them. Run any vssh command
+for a host, such as vssh print-command user@host, to sign a new one when it
+has expired.
+
+Examples:
+  vssh setup ssh-config
+  vssh setup ssh-config --write`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := mustLoadConfig()
+
+		username := os.Getenv("USER")
+		if username == "" {
+			fmt.Fprintf(os.Stderr, "Error: USER environment variable not set\n")
+			os.Exit(1)
+		}
+
+		signer := ssh.NewSigner(nil, cfg, utils.GetLogger())
+		content, err := ssh.GenerateSSHConfig(cfg, signer, username)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if write, _ := cmd.Flags().GetBool("write"); !write {
+			fmt.Print(content)
+			return
+		}
+
+		path, _ := cmd.Flags().GetString("file")
+		sshConfigPath := cfg.SSH.SSHConfigFile
+		if sshConfigPath == "" || sshConfigPath == "none" {
+			sshConfigPath = "~/.ssh/config"
+		}
+
+		added, err := ssh.WriteSSHConfig(path, sshConfigPath, content)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %s\n", path)
+		if added {
+			fmt.Printf("Added Include %s to %s\n", path, sshConfigPath)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(setupCmd)
+	setupCmd.AddCommand(setupSSHConfigCmd)
+
+	setupSSHConfigCmd.Flags().Bool("write", false, "write the configuration and include it from the ssh config")
+	setupSSHConfigCmd.Flags().String("file", "~/.ssh/vssh.conf", "file to write the configuration to")
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"strings"
+
+	"vssh/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+// sftpCmd starts an sftp session using a Vault-signed certificate
+var sftpCmd = &cobra.Command{
+	Use:   "sftp [sftp options] [user@]host[:path]",
+	Short: "Start an sftp session using a Vault-signed certificate",
+	Long: `Start an interactive sftp session, authenticating with a Vault-signed
+certificate. Useful for hosts that only expose the SFTP subsystem.
+
+The destination is [user@]host[:path] or sftp://[user@]host[:port]/path, and the
+host is resolved like any vssh target. sftp options are passed through; -P, -i
+and -J are also used when signing certificates.
+
+Examples:
+  vssh sftp admin@files.example.com
+  vssh sftp -P 2222 alice@files.example.com:/uploads
+  vssh sftp -b batch.txt sftp://alice@files.example.com/reports`,
+	// Flags follow sftp's syntax and are parsed by ssh.ParseSFTPArgs
+	DisableFlagParsing: true,
+	Args:               cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		args, err := parseRootFlags(cmd, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if help, _ := cmd.Flags().GetBool("help"); help || (len(args) > 0 && args[0] == "-h") {
+			cmd.Help()
+			return
+		}
+
+		options, destination, err := ssh.ParseSFTPArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(destination) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: exactly one destination is required\n")
+			os.Exit(1)
+		}
+
+		remote, ok := ssh.ParseRemotePath(destination[0])
+		if !ok {
+			remote = &ssh.RemotePath{Target: destination[0]}
+		}
+
+		s := newSession(cmd)
+		targets, err := s.expandTargets([]string{remote.Target})
+		if err != nil {
+			s.logger.Errorf("Invalid SSH target: %v", err)
+			os.Exit(255)
+		}
+		if len(targets) != 1 {
+			s.logger.Errorf("%s expands to %d targets, sftp needs exactly one", remote.Target, len(targets))
+			os.Exit(255)
+		}
+
+		target, certPath, targetOptions, err := s.prepareTarget(targets[0], options)
+		if err != nil {
+			s.logger.Errorf("%v", err)
+			os.Exit(exitCode(err))
+		}
+
+		sftpArgs := append([]string{}, options.ExtraArgs...)
+		if port := cmp.Or(options.Port, target.Port); port != "" {
+			sftpArgs = append(sftpArgs, "-P", port)
+		}
+		sftpArgs = append(sftpArgs, s.sshClient.ConnectionArgs(target, certPath, targetOptions)...)
+
+		// Without a path sftp starts in the home directory
+		sftpArgs = append(sftpArgs, "--", strings.TrimSuffix(ssh.FormatRemotePath(target, remote.Path), ":"))
+
+		runTool(s, "sftp", sftpArgs, []*connection{newConnection(s, targets[0], target, certPath)})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sftpCmd)
+}
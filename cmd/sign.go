@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"vssh/pkg/types"
+
+	"github.com/spf13/cobra"
+)
+
+// signCmd signs arbitrary public keys
+var signCmd = &cobra.Command{
+	Use:   "sign --key <path|-> | --keys-dir <dir> --out <dir>",
+	Short: "Sign public keys and print or save the certificates",
+	Long: `Sign any SSH public key with Vault and print the certificate, or write it
+to --out. The key is read from a file, or from stdin with --key -.
+
+With --keys-dir every *.pub key in the directory is signed, up to --parallel
+at a time, and each certificate is written to the --out directory as
+<name>-cert.pub.
+
+The role defaults to the one vssh would use for the current user, and the TTL
+to ssh.certificate_ttl. The certificate is valid for the --principal names,
+or the role's default principals.
+
+Examples:
+  vssh sign --key ~/.ssh/deploy_ed25519.pub --out ~/.ssh/deploy_ed25519-cert.pub
+  vssh sign --key - --role ci --ttl 15m < key.pub > key-cert.pub
+  vssh sign --keys-dir ./pubkeys --out ./certs --role services`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		keyPath, _ := cmd.Flags().GetString("key")
+		keysDir, _ := cmd.Flags().GetString("keys-dir")
+		out, _ := cmd.Flags().GetString("out")
+		if (keyPath == "") == (keysDir == "") {
+			fmt.Fprintf(os.Stderr, "Error: give either --key or --keys-dir\n")
+			os.Exit(1)
+		}
+		if keysDir != "" && out == "" {
+			fmt.Fprintf(os.Stderr, "Error: --keys-dir requires an --out directory\n")
+			os.Exit(1)
+		}
+
+		var publicKey []byte
+		var err error
+		switch {
+		case keysDir != "":
+			// Each key is read when it is signed
+		case keyPath == "-":
+			publicKey, err = io.ReadAll(os.Stdin)
+		default:
+			publicKey, err = os.ReadFile(keyPath)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to read public key: %v\n", err)
+			os.Exit(1)
+		}
+
+		s := newSession(cmd)
+		role, _ := cmd.Flags().GetString("role")
+		if role == "" {
+			role = s.signer.RoleFor(os.Getenv("USER"), types.HostSettings{})
+		}
+		engine, _ := cmd.Flags().GetString("engine")
+		if engine == "" {
+			engine = s.config.SSH.SigningEngine
+		}
+		ttl, _ := cmd.Flags().GetDuration("ttl")
+		if ttl == 0 {
+			ttl = s.config.SSH.CertificateTTL
+		}
+		principals, _ := cmd.Flags().GetStringSlice("principal")
+
+		if keysDir != "" {
+			parallel, _ := cmd.Flags().GetInt("parallel")
+			if !signKeysDir(s, keysDir, out, parallel, func(publicKey string) (string, error) {
+				return s.signer.SignKey(engine, role, publicKey, principals, ttl)
+			}) {
+				os.Exit(exitSigning)
+			}
+			return
+		}
+
+		certificate, err := s.signer.SignKey(engine, role, string(publicKey), principals, ttl)
+		if err != nil {
+			fatalf(s.logger, exitSigning, "Failed to sign %s: %v", keyPath, err)
+		}
+
+		if out == "" {
+			fmt.Print(certificate)
+			return
+		}
+		if err := os.WriteFile(out, []byte(certificate), 0644); err != nil {
+			s.logger.Fatalf("Failed to write certificate: %v", err)
+		}
+		s.logger.Infof("Certificate written to %s", out)
+	},
+}
+
+// signKeysDir signs every public key in keysDir with sign, up to parallel at a
+// time, and writes the certificates to out. It reports whether all succeeded.
+func signKeysDir(s *session, keysDir, out string, parallel int, sign func(publicKey string) (string, error)) bool {
+	keyPaths, err := filepath.Glob(filepath.Join(keysDir, "*.pub"))
+	if err != nil {
+		s.logger.Fatalf("Failed to list %s: %v", keysDir, err)
+	}
+	// Certificates signed earlier into the same directory are not keys
+	keyPaths = slices.DeleteFunc(keyPaths, func(path string) bool {
+		return strings.HasSuffix(path, "-cert.pub")
+	})
+	if len(keyPaths) == 0 {
+		s.logger.Fatalf("No public keys (*.pub) in %s", keysDir)
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		s.logger.Fatalf("Failed to create %s: %v", out, err)
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	errs := make([]error, len(keyPaths))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+
+	for i, keyPath := range keyPaths {
+		wg.Add(1)
+		go func(i int, keyPath string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			publicKey, err := os.ReadFile(keyPath)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			certificate, err := sign(string(publicKey))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			certPath := filepath.Join(out, strings.TrimSuffix(filepath.Base(keyPath), ".pub")+"-cert.pub")
+			if err := os.WriteFile(certPath, []byte(certificate), 0644); err != nil {
+				errs[i] = err
+				return
+			}
+			s.logger.Infof("Signed %s: %s", keyPath, certPath)
+		}(i, keyPath)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", keyPaths[i], err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d signed, %d failed\n", len(keyPaths)-failed, failed)
+	return failed == 0
+}
+
+func init() {
+	rootCmd.AddCommand(signCmd)
+
+	signCmd.Flags().String("key", "", "public key to sign, or - to read it from stdin")
+	signCmd.Flags().String("keys-dir", "", "directory of public keys (*.pub) to sign")
+	signCmd.Flags().String("out", "", "file to write the certificate to (default stdout), or directory with --keys-dir")
+	signCmd.Flags().Int("parallel", 10, "maximum number of keys signed at once with --keys-dir")
+	signCmd.Flags().String("role", "", "Vault role to sign with (default the current user's role)")
+	signCmd.Flags().String("engine", "", "SSH secrets engine mount (default ssh.signing_engine)")
+	signCmd.Flags().Duration("ttl", 0, "certificate validity (default ssh.certificate_ttl, clamped by the role's max_ttl)")
+	signCmd.Flags().StringSlice("principal", nil, "principal the certificate is valid for (repeatable, default the role's)")
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"vssh/internal/ssh"
+
+	"github.com/spf13/cobra"
+)
+
+// signHostCmd signs a server's host key
+var signHostCmd = &cobra.Command{
+	Use:   "sign-host --key <public key> --role <role>",
+	Short: "Sign an SSH host key",
+	Long: `Sign a server's host public key with a Vault role for host certificates
+(cert_type=host), so clients trusting the host CA can verify the server
+without prompting.
+
+The key is signed by the host CA engine, ssh.host_ca.signing_engine unless
+--engine is given, which vssh known-hosts sync and host verification trust.
+The certificate is valid for the --principal names, by default the machine's
+hostname, and printed. With --install it is written next to the key as
+<key>-cert.pub and a HostCertificate directive for it is added to the sshd
+config; reload sshd afterwards.
+
+Examples:
+  vssh sign-host --key /etc/ssh/ssh_host_ed25519_key.pub --role hostrole
+  sudo vssh sign-host --key /etc/ssh/ssh_host_ed25519_key.pub --role hostrole \
+    --principal web01.example.com --install`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		keyPath, _ := cmd.Flags().GetString("key")
+		role, _ := cmd.Flags().GetString("role")
+		if keyPath == "" || role == "" {
+			fmt.Fprintf(os.Stderr, "Error: --key and --role are required\n")
+			os.Exit(1)
+		}
+
+		principals, _ := cmd.Flags().GetStringSlice("principal")
+		if len(principals) == 0 {
+			hostname, err := os.Hostname()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: no --principal given and the hostname is unknown: %v\n", err)
+				os.Exit(1)
+			}
+			principals = []string{hostname}
+		}
+
+		s := newSession(cmd)
+		engine, _ := cmd.Flags().GetString("engine")
+		if engine == "" {
+			engine = s.config.SSH.HostCA.SigningEngine
+		}
+		ttl, _ := cmd.Flags().GetDuration("ttl")
+
+		certificate, err := s.signer.SignHostKey(engine, role, keyPath, principals, ttl)
+		if err != nil {
+			fatalf(s.logger, exitSigning, "Failed to sign host key: %v", err)
+		}
+
+		if install, _ := cmd.Flags().GetBool("install"); !install {
+			fmt.Print(certificate)
+			return
+		}
+
+		sshdConfig, _ := cmd.Flags().GetString("sshd-config")
+		certPath, added, err := ssh.InstallHostCertificate(keyPath, certificate, sshdConfig)
+		if err != nil {
+			s.logger.Fatalf("Failed to install host certificate: %v", err)
+		}
+		fmt.Printf("Wrote %s\n", certPath)
+		if added {
+			fmt.Printf("Added HostCertificate %s to %s\n", certPath, sshdConfig)
+		}
+		fmt.Println("Reload sshd to use the certificate")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(signHostCmd)
+
+	signHostCmd.Flags().String("key", "", "host public key to sign")
+	signHostCmd.Flags().String("role", "", "Vault role for host certificates")
+	signHostCmd.Flags().String("engine", "", "SSH secrets engine mount of the host CA (default ssh.host_ca.signing_engine)")
+	signHostCmd.Flags().StringSlice("principal", nil, "hostname the certificate is valid for (repeatable, default the machine's hostname)")
+	signHostCmd.Flags().Duration("ttl", 0, "certificate validity (default the role's ttl)")
+	signHostCmd.Flags().Bool("install", false, "write the certificate next to the key and add it to the sshd config")
+	signHostCmd.Flags().String("sshd-config", "/etc/ssh/sshd_config", "sshd config to add the HostCertificate directive to")
+}
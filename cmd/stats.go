@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"vssh/internal/auth"
+	"vssh/internal/config"
+	"vssh/internal/metrics"
+	"vssh/internal/ssh"
+	"vssh/internal/vault"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// statsCmd shows the metrics recorded with metrics.enabled
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show Vault login and signing latency and connection counts",
+	Long: `Show how long Vault logins and certificate signing took and how many
+connections were made since the metrics were last reset, so the time Vault adds
+to each connection can be quantified. Metrics are recorded with metrics.enabled.
+
+--prometheus prints them in the Prometheus text format, which vssh agentd can
+also export with agentd.metrics_file and agentd.metrics_listen.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := outputFormat(cmd)
+
+		loaded := mustLoadConfig()
+		store := metricsStore()
+
+		if reset, _ := cmd.Flags().GetBool("reset"); reset {
+			if err := store.Reset(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("Metrics reset")
+			return
+		}
+
+		m, err := store.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if prometheus, _ := cmd.Flags().GetBool("prometheus"); prometheus {
+			metrics.WritePrometheus(os.Stdout, m)
+			return
+		}
+		if format != outputTable {
+			printStructured(format, m)
+			return
+		}
+
+		if !loaded.Metrics.Enabled {
+			fmt.Println("Metrics are not recorded; enable metrics.enabled to record them")
+		}
+		fmt.Printf("Since %s\n\n", m.Since.Local().Format("2006-01-02 15:04"))
+		fmt.Printf("%-12s  %8s  %8s  %8s  %8s\n", "", "Count", "Failures", "Average", "Max")
+		printLatency("Vault login", &m.Auth)
+		printLatency("Signing", &m.Signing)
+		fmt.Printf("%-12s  %8d  %8d\n", "Connections", m.Connections.Count, m.Connections.Failures)
+	},
+}
+
+// printLatency prints a row of the stats table
+func printLatency(name string, l *metrics.Latency) {
+	maximum := time.Duration(l.Max * float64(time.Second))
+	fmt.Printf("%-12s  %8d  %8d  %8s  %8s\n", name, l.Count, l.Failures,
+		l.Average().Round(time.Millisecond), maximum.Round(time.Millisecond))
+}
+
+// metricsStore returns the store of the metrics shown by vssh stats
+func metricsStore() *metrics.Store {
+	return metrics.NewStore(metrics.DefaultPath(config.GetStateDir()))
+}
+
+// updateMetrics applies update to the recorded metrics if metrics.enabled is
+// set, warning if they can't be saved
+func updateMetrics(cfg *types.Config, logger *logrus.Logger, update func(m *metrics.Metrics)) {
+	if !cfg.Metrics.Enabled {
+		return
+	}
+	if err := metricsStore().Update(update); err != nil {
+		logger.Warnf("Failed to record metrics: %v", err)
+	}
+}
+
+// newAuthenticator returns an authenticator for vaultConfig that records how
+// long logins take
+func newAuthenticator(cfg *types.Config, vaultClient *vault.Client, vaultConfig *types.VaultConfig, logger *logrus.Logger) *auth.Authenticator {
+	authenticator := auth.NewAuthenticator(vaultClient, vaultConfig, logger)
+	authenticator.OnAuthenticated(func(vaultTime time.Duration, err error) {
+		updateMetrics(cfg, logger, func(m *metrics.Metrics) {
+			m.Auth.Observe(vaultTime, err)
+		})
+	})
+	return authenticator
+}
+
+// recordSigning records how long every signing request of signer takes
+func recordSigning(s *session, signer *ssh.Signer) {
+	signer.OnSigned(func(d time.Duration, err error) {
+		updateMetrics(s.config, s.logger, func(m *metrics.Metrics) {
+			m.Signing.Observe(d, err)
+		})
+	})
+}
+
+// recordConnection counts a finished connection, as failed if ssh couldn't
+// connect or be started
+func recordConnection(s *session, connectErr error) {
+	var launchErr *ssh.LaunchError
+	if ssh.ExitCode(connectErr) != 255 && !errors.As(connectErr, &launchErr) {
+		connectErr = nil
+	}
+	updateMetrics(s.config, s.logger, func(m *metrics.Metrics) {
+		m.Connections.Add(connectErr)
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().Bool("prometheus", false, "Print the metrics in the Prometheus text format")
+	statsCmd.Flags().Bool("reset", false, "Remove the recorded metrics")
+}
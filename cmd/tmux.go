@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"vssh/internal/ssh"
+	"vssh/internal/tmux"
+
+	"github.com/spf13/cobra"
+)
+
+// tmuxCmd opens a tmux window with a pane per host
+var tmuxCmd = &cobra.Command{
+	Use:   "tmux (--hosts <host,...> | --group <name> | --tag <key=value>) [-- <command>]",
+	Short: "Open a tmux window with a pane connected to each host",
+	Long: `Open a tmux window with one pane per host, each running vssh to connect to
+its host, or to run a command there.
+
+Certificates are signed before the window opens, so the panes reuse them and
+don't each ask to log in to Vault. With --sync, input typed in one pane is sent
+to all of them.
+
+Outside tmux the window is opened in the session named by --session, which is
+created if needed, and attached to. Inside tmux it is opened in that session
+and switched to.
+
+Examples:
+  vssh tmux --hosts web1,web2,web3
+  vssh tmux --group web --sync
+  vssh tmux --tag Environment=staging -- sudo journalctl -f`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 && cmd.ArgsLenAtDash() != 0 {
+			return fmt.Errorf("the command to run must follow --")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		hosts, _ := cmd.Flags().GetStringSlice("hosts")
+		group, _ := cmd.Flags().GetString("group")
+		sync, _ := cmd.Flags().GetBool("sync")
+		sessionName, _ := cmd.Flags().GetString("session")
+
+		window := "vssh"
+		if group != "" {
+			hosts = append(hosts, "@"+group)
+			window = group
+		}
+		if tag, _ := cmd.Flags().GetString("tag"); tag != "" {
+			hosts = append(hosts, "@tag:"+tag)
+		}
+		if len(hosts) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: --hosts, --group or --tag is required\n")
+			os.Exit(1)
+		}
+		if sessionName == "" || strings.ContainsAny(sessionName, ":.") {
+			fmt.Fprintf(os.Stderr, "Error: invalid tmux session name %q\n", sessionName)
+			os.Exit(1)
+		}
+
+		s := newSession(cmd)
+		// Panes ask for their own roles and confirmations when they connect
+		s.selectRole = false
+		s.confirm = false
+
+		targets, err := s.expandTargets(hosts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(targets) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no hosts to connect to\n")
+			os.Exit(1)
+		}
+
+		executable, err := os.Executable()
+		if err != nil {
+			s.logger.Errorf("Failed to find the vssh executable: %v", err)
+			os.Exit(1)
+		}
+
+		// Sign sequentially so each username is signed once and reused by
+		// the panes. Hosts that fail are still opened to show why.
+		options := &ssh.SSHOptions{}
+		panes := make([]string, len(targets))
+		for i, rawTarget := range targets {
+			if _, _, _, err := s.prepareTarget(rawTarget, options); err != nil && !errors.Is(err, errOTPUnsupported) {
+				s.logger.Warnf("%s: %v", rawTarget, err)
+			}
+			panes[i] = paneCommand(executable, rawTarget, args)
+		}
+
+		s.logger.Debugf("Opening tmux window %s in session %s with %d panes", window, sessionName, len(panes))
+		if err := tmux.Open(tmux.Window{Session: sessionName, Name: window, Panes: panes, Sync: sync}); err != nil {
+			s.logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// paneCommand returns the shell command of a pane connecting to target with
+// vssh, running command there if there is one
+func paneCommand(executable, target string, command []string) string {
+	words := []string{executable}
+	if cfgFile != "" {
+		words = append(words, "--config", cfgFile)
+	}
+	words = append(words, target)
+	if len(command) > 0 {
+		words = append(words, "--")
+		words = append(words, command...)
+	}
+	for i, word := range words {
+		words[i] = ssh.ShellQuote(word)
+	}
+	return strings.Join(words, " ")
+}
+
+func init() {
+	rootCmd.AddCommand(tmuxCmd)
+	tmuxCmd.Flags().StringSlice("hosts", nil, "Comma-separated hosts to open panes for ([user@]host[:port] or @group)")
+	tmuxCmd.Flags().String("group", "", "Open panes for the hosts of this group, in a window named after it")
+	tmuxCmd.Flags().String("tag", "", "Open panes for inventory hosts with these tags (key=value[,key=value])")
+	tmuxCmd.Flags().Bool("sync", false, "Send input typed in one pane to all panes")
+	tmuxCmd.Flags().String("session", "vssh", "tmux session to open the window in")
+	tmuxCmd.RegisterFlagCompletionFunc("hosts", completeHostList)
+}
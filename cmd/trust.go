@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"vssh/internal/ssh"
+	"vssh/internal/vault"
+
+	"github.com/spf13/cobra"
+)
+
+// trustCmd fetches the CA public key servers trust user certificates from
+var trustCmd = &cobra.Command{
+	Use:   "trust [--engine <mount>]",
+	Short: "Print the CA public key of an SSH secrets engine",
+	Long: `Print the CA public key of an SSH secrets engine, which servers trust to
+accept the user certificates it signs. No Vault login is needed.
+
+With --write the key is added to a TrustedUserCAKeys file instead, keeping any
+other CA keys in it, and the sshd_config line to use it is printed.
+
+Examples:
+  vssh trust
+  vssh trust --engine ssh-prod
+  sudo vssh trust --write /etc/ssh/trusted-user-ca-keys.pem`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := mustLoadConfig()
+
+		engine, _ := cmd.Flags().GetString("engine")
+		if engine == "" {
+			engine = cfg.SSH.SigningEngine
+		}
+
+		vaultClient, err := vault.NewClient(&cfg.Vault)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		caKey, err := vaultClient.SSHCAPublicKey(engine)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		path, _ := cmd.Flags().GetString("write")
+		if path == "" {
+			fmt.Println(caKey)
+			return
+		}
+
+		added, err := ssh.AddTrustedCAKey(path, caKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if added {
+			fmt.Printf("Added the %s CA key to %s\n", engine, path)
+		} else {
+			fmt.Printf("%s already trusts the %s CA key\n", path, engine)
+		}
+		fmt.Printf("\nAdd this line to sshd_config and reload sshd:\n  TrustedUserCAKeys %s\n", path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(trustCmd)
+
+	trustCmd.Flags().String("engine", "", "SSH secrets engine mount (default ssh.signing_engine)")
+	trustCmd.Flags().String("write", "", "TrustedUserCAKeys file to add the CA key to")
+}
@@ -0,0 +1,371 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"vssh/internal/config"
+	"vssh/internal/ssh"
+	"vssh/internal/tunnel"
+
+	"github.com/spf13/cobra"
+)
+
+// How long to wait for ssh to authenticate and set up the forwards
+const (
+	tunnelStartTimeout = 30 * time.Second
+	tunnelSettleTime   = 2 * time.Second
+)
+
+// tunnelCmd opens a forwarding-only ssh session
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel <target> (-L|-R|-D <spec>)... [--background]",
+	Short: "Open a port forwarding tunnel",
+	Long: `Open a forwarding-only ssh session (ssh -N) authenticated with a
+Vault-signed certificate.
+
+Forwards use ssh's syntax and may be repeated:
+  -L [bind_address:]port:host:hostport   forward a local port to host:hostport
+  -R [bind_address:]port:host:hostport   forward a remote port to host:hostport
+  -D [bind_address:]port                 local SOCKS proxy
+
+The tunnel stays open until interrupted with Ctrl-C. With --background it is
+detached from the terminal once the forwards are up, and is stopped with
+vssh tunnel stop.
+
+Examples:
+  vssh tunnel admin@db01 -L 5432:localhost:5432
+  vssh tunnel bastion -L 8080:intranet:80 -D 1080 --background
+  vssh tunnel list
+  vssh tunnel stop bastion`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTarget,
+	Run: func(cmd *cobra.Command, args []string) {
+		background, _ := cmd.Flags().GetBool("background")
+
+		var forwards, addresses []string
+		for _, flag := range []struct{ option, name string }{{"-L", "local"}, {"-R", "remote"}, {"-D", "dynamic"}} {
+			option := flag.option
+			specs, _ := cmd.Flags().GetStringArray(flag.name)
+			for _, spec := range specs {
+				forwards = append(forwards, option, spec)
+				if option == "-R" {
+					continue
+				}
+
+				address, err := tunnel.ListenAddress(option, spec)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				if address != "" && tunnel.Listening(address) {
+					fmt.Fprintf(os.Stderr, "Error: %s is already in use\n", address)
+					os.Exit(1)
+				}
+				if address != "" {
+					addresses = append(addresses, address)
+				}
+			}
+		}
+		if len(forwards) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: at least one -L, -R or -D forward is required\n")
+			os.Exit(1)
+		}
+
+		s := newSession(cmd)
+		s.otp = true
+		logger := s.logger
+
+		targets, err := s.expandTargets(args)
+		if err != nil {
+			logger.Fatalf("Invalid SSH target: %v", err)
+		}
+		if len(targets) != 1 {
+			logger.Fatalf("A tunnel needs exactly one target, %s expands to %d", args[0], len(targets))
+		}
+
+		options := &ssh.SSHOptions{
+			NoCommand: true,
+			ExtraArgs: append([]string{"-o", "ExitOnForwardFailure=yes"}, forwards...),
+		}
+		target, certPath, targetOptions, err := s.prepareTarget(targets[0], options)
+		if err != nil {
+			fatalf(logger, exitCode(err), "%v", err)
+		}
+
+		record := tunnel.Tunnel{
+			Target:     args[0],
+			Forwards:   describeForwards(forwards),
+			Background: background,
+			Started:    time.Now(),
+		}
+		sshCmd := s.sshClient.Command(target, certPath, targetOptions, nil)
+		conn := newConnection(s, targets[0], target, certPath)
+
+		// vssh isn't there when a background tunnel closes, so only its
+		// start is seen by hooks and the webhook
+		if background {
+			if err := beforeConnect(s, conn); err != nil {
+				fatalf(logger, exitCode(err), "%v", err)
+			}
+			startBackgroundTunnel(sshCmd, record, addresses)
+			return
+		}
+		err = withHooks(s, []*connection{conn}, func() error {
+			return runTunnel(sshCmd, record, addresses)
+		})
+		if err != nil {
+			var exitErr *ssh.ExitError
+			if !errors.As(err, &exitErr) {
+				logger.Errorf("%v", err)
+			}
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+// runTunnel keeps a tunnel open in the foreground until ssh exits or vssh is
+// interrupted, in which case ssh is stopped cleanly. ssh's failures are
+// reported, returning an ExitError.
+func runTunnel(sshCmd *exec.Cmd, record tunnel.Tunnel, addresses []string) error {
+	sshCmd.Stdout = os.Stdout
+	sshCmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := sshCmd.Start(); err != nil {
+		return classify(exitLaunch, fmt.Errorf("failed to start ssh: %w", err))
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- sshCmd.Wait() }()
+
+	record.PID = sshCmd.Process.Pid
+	store := tunnelStore()
+	if err := store.Add(record); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record tunnel: %v\n", err)
+	}
+
+	if err := waitForTunnel(exited, addresses); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: tunnel to %s failed: %v\n", record.Target, err)
+		store.Remove(record.PID)
+		return &ssh.ExitError{Code: 255}
+	}
+	fmt.Printf("Tunnel to %s open: %s (Ctrl-C to close)\n", record.Target, strings.Join(record.Forwards, ", "))
+
+	select {
+	case err := <-exited:
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Tunnel to %s closed: %v\n", record.Target, err)
+			store.Remove(record.PID)
+			return &ssh.ExitError{Code: 255}
+		}
+	case <-signals:
+		tunnel.Terminate(sshCmd.Process)
+		<-exited
+	}
+	store.Remove(record.PID)
+	fmt.Printf("Tunnel to %s closed\n", record.Target)
+	return nil
+}
+
+// startBackgroundTunnel starts ssh detached from the terminal and returns once
+// its forwards are up. ssh's output goes to the tunnel log in the state directory.
+func startBackgroundTunnel(sshCmd *exec.Cmd, record tunnel.Tunnel, addresses []string) {
+	logPath := filepath.Join(config.GetStateDir(), "tunnels.log")
+	if err := os.MkdirAll(filepath.Dir(logPath), 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating state directory: %v\n", err)
+		os.Exit(1)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening tunnel log: %v\n", err)
+		os.Exit(1)
+	}
+	defer logFile.Close()
+	logStart, _ := logFile.Seek(0, io.SeekEnd)
+
+	sshCmd.Stdout = logFile
+	sshCmd.Stderr = logFile
+	tunnel.Detach(sshCmd)
+
+	if err := sshCmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to start ssh: %v\n", err)
+		os.Exit(exitLaunch)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- sshCmd.Wait() }()
+
+	if err := waitForTunnel(exited, addresses); err != nil {
+		tunnel.Terminate(sshCmd.Process)
+		fmt.Fprintf(os.Stderr, "Error: tunnel to %s failed: %v\n", record.Target, err)
+		if output := readFrom(logPath, logStart); output != "" {
+			fmt.Fprint(os.Stderr, output)
+		}
+		os.Exit(255)
+	}
+
+	record.PID = sshCmd.Process.Pid
+	if err := tunnelStore().Add(record); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record tunnel: %v\n", err)
+	}
+	sshCmd.Process.Release()
+
+	fmt.Printf("Tunnel to %s running in the background (pid %d): %s\n", record.Target, record.PID, strings.Join(record.Forwards, ", "))
+	fmt.Printf("Stop it with: vssh tunnel stop %s\n", record.Target)
+}
+
+// waitForTunnel waits until ssh listens on every local forward address. Without
+// local addresses it only checks that ssh is still running after a moment.
+func waitForTunnel(exited <-chan error, addresses []string) error {
+	deadline := time.After(tunnelStartTimeout)
+	if len(addresses) == 0 {
+		deadline = time.After(tunnelSettleTime)
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				return fmt.Errorf("ssh exited")
+			}
+			return fmt.Errorf("ssh exited: %w", err)
+		case <-deadline:
+			if len(addresses) == 0 {
+				return nil
+			}
+			return fmt.Errorf("timed out waiting for %s", strings.Join(addresses, ", "))
+		case <-ticker.C:
+			if len(addresses) > 0 && allListening(addresses) {
+				return nil
+			}
+		}
+	}
+}
+
+// allListening reports whether every address has a listener
+func allListening(addresses []string) bool {
+	for _, address := range addresses {
+		if !tunnel.Listening(address) {
+			return false
+		}
+	}
+	return true
+}
+
+// describeForwards formats option/spec pairs as "-L spec" strings
+func describeForwards(forwards []string) []string {
+	var described []string
+	for i := 0; i+1 < len(forwards); i += 2 {
+		described = append(described, forwards[i]+" "+forwards[i+1])
+	}
+	return described
+}
+
+// readFrom returns the contents of a file from the given offset
+func readFrom(path string, offset int64) string {
+	data, err := os.ReadFile(path)
+	if err != nil || offset > int64(len(data)) {
+		return ""
+	}
+	return string(data[offset:])
+}
+
+// tunnelStore returns the store of running tunnels
+func tunnelStore() *tunnel.Store {
+	return tunnel.NewStore(tunnel.DefaultPath(config.GetStateDir()))
+}
+
+// tunnelStopCmd stops running tunnels
+var tunnelStopCmd = &cobra.Command{
+	Use:   "stop [target]",
+	Short: "Stop running tunnels",
+	Long:  `Stop the tunnels opened to target, or every tunnel with --all.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) == 1) {
+			fmt.Fprintf(os.Stderr, "Error: give either a target or --all\n")
+			os.Exit(1)
+		}
+
+		store := tunnelStore()
+		tunnels, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		stopped := 0
+		for _, running := range tunnels {
+			if !all && running.Target != args[0] {
+				continue
+			}
+			if err := tunnel.Stop(running); err != nil {
+				fmt.Fprintf(os.Stderr, "Error stopping tunnel to %s (pid %d): %v\n", running.Target, running.PID, err)
+				continue
+			}
+			store.Remove(running.PID)
+			stopped++
+			fmt.Printf("Stopped tunnel to %s (pid %d)\n", running.Target, running.PID)
+		}
+
+		if stopped == 0 {
+			fmt.Fprintf(os.Stderr, "No running tunnels to stop\n")
+			os.Exit(1)
+		}
+	},
+}
+
+// tunnelListCmd lists running tunnels
+var tunnelListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List running tunnels",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		tunnels, err := tunnelStore().List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(tunnels) == 0 {
+			fmt.Println("No running tunnels")
+			return
+		}
+
+		for _, running := range tunnels {
+			fmt.Printf("%7d  %s  %-30s  %s\n",
+				running.PID,
+				running.Started.Local().Format("2006-01-02 15:04"),
+				running.Target,
+				strings.Join(running.Forwards, ", "))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tunnelCmd)
+	tunnelCmd.AddCommand(tunnelStopCmd)
+	tunnelCmd.AddCommand(tunnelListCmd)
+
+	tunnelCmd.Flags().StringArrayP("local", "L", nil, "forward a local port ([bind_address:]port:host:hostport)")
+	tunnelCmd.Flags().StringArrayP("remote", "R", nil, "forward a remote port ([bind_address:]port:host:hostport)")
+	tunnelCmd.Flags().StringArrayP("dynamic", "D", nil, "open a local SOCKS proxy ([bind_address:]port)")
+	tunnelCmd.Flags().BoolP("background", "f", false, "detach from the terminal once the tunnel is up")
+
+	tunnelStopCmd.Flags().Bool("all", false, "stop every running tunnel")
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"vssh/internal/ssh"
+)
+
+// waitForHost waits with --wait until the target's SSH server answers. The
+// target is prepared first, so its certificate is signed once and reused
+// however long the host takes to come up.
+func (s *session) waitForHost(target *ssh.SSHTarget, options *ssh.SSHOptions) error {
+	if !s.wait {
+		return nil
+	}
+	return s.sshClient.WaitForHost(target, options, s.retryInterval, s.waitTimeout)
+}
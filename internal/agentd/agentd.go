@@ -0,0 +1,245 @@
+package agentd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"vssh/internal/ssh"
+	"vssh/internal/utils"
+	"vssh/internal/vault"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Status is the state of a running daemon after its last refresh
+type Status struct {
+	PID          int                 `json:"pid"`
+	Started      time.Time           `json:"started"`
+	LastRefresh  time.Time           `json:"last_refresh"`
+	NextRefresh  time.Time           `json:"next_refresh"`
+	TokenExpires time.Time           `json:"token_expires,omitempty"` // zero for a token that never expires
+	TokenError   string              `json:"token_error,omitempty"`
+	Certificates []CertificateStatus `json:"certificates"`
+}
+
+// CertificateStatus is the state of the certificate kept fresh for a target
+type CertificateStatus struct {
+	Target  string    `json:"target"`
+	Path    string    `json:"path,omitempty"` // empty with ssh.agent_only
+	Expires time.Time `json:"expires,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Daemon keeps the certificates of a set of targets, and the Vault token used
+// to sign them, fresh so connections never wait for signing
+type Daemon struct {
+	config      *types.Config
+	vaultClient *vault.Client
+	signer      *ssh.Signer
+	logger      *logrus.Logger
+
+	mu      sync.Mutex
+	targets []*ssh.SSHTarget
+	status  Status
+}
+
+// New creates a daemon refreshing the certificates of targets
+func New(config *types.Config, vaultClient *vault.Client, signer *ssh.Signer, logger *logrus.Logger, targets []*ssh.SSHTarget) *Daemon {
+	return &Daemon{
+		config:      config,
+		vaultClient: vaultClient,
+		signer:      signer,
+		logger:      logger,
+		targets:     targets,
+		status:      Status{PID: os.Getpid(), Started: time.Now()},
+	}
+}
+
+// DefaultStatusPath returns the daemon status file location inside stateDir
+func DefaultStatusPath(stateDir string) string {
+	return filepath.Join(stateDir, "agentd.json")
+}
+
+// Run refreshes every agentd.check_interval until ctx is cancelled, writing
+// the status to statusPath after each refresh. The status file is removed
+// when the daemon stops.
+func (d *Daemon) Run(ctx context.Context, statusPath string) error {
+	defer os.Remove(statusPath)
+
+	ticker := time.NewTicker(d.config.Agentd.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		status := d.Refresh()
+		if err := WriteStatus(statusPath, status); err != nil {
+			d.logger.Warnf("Failed to write daemon status: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh renews the Vault token if it is close to expiring and re-signs
+// every certificate within ssh.renew_before of expiring
+func (d *Daemon) Refresh() Status {
+	status := d.Status()
+	status.LastRefresh = time.Now()
+	status.NextRefresh = status.LastRefresh.Add(d.config.Agentd.CheckInterval)
+	status.TokenExpires, status.TokenError = time.Time{}, ""
+
+	tokenExpires, err := d.refreshToken()
+	if err != nil {
+		d.logger.Warnf("%v", err)
+		status.TokenError = err.Error()
+	}
+	status.TokenExpires = tokenExpires
+
+	status.Certificates = nil
+	d.mu.Lock()
+	targets := append([]*ssh.SSHTarget(nil), d.targets...)
+	d.mu.Unlock()
+	for _, target := range targets {
+		status.Certificates = append(status.Certificates, d.refreshTarget(target))
+	}
+
+	d.mu.Lock()
+	d.status = status
+	d.mu.Unlock()
+	return status
+}
+
+// Status returns the status after the last refresh
+func (d *Daemon) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+// refreshToken renews the Vault token when it would expire before the next
+// few refreshes, and returns when it expires
+func (d *Daemon) refreshToken() (time.Time, error) {
+	ttl, renewable, err := d.vaultClient.TokenTTL()
+	if err != nil {
+		// Another vssh invocation may have logged in again since
+		if loadErr := d.vaultClient.LoadTokenFromFile(); loadErr == nil {
+			ttl, renewable, err = d.vaultClient.TokenTTL()
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("no valid Vault token, log in again with any vssh command: %w", err)
+		}
+	}
+	if ttl == 0 {
+		return time.Time{}, nil
+	}
+
+	if renewable && ttl < 2*d.config.Agentd.CheckInterval+5*time.Minute {
+		renewed, err := d.vaultClient.RenewToken()
+		if err != nil {
+			return time.Now().Add(ttl), err
+		}
+		d.logger.Infof("Renewed Vault token, valid for %v", renewed)
+		ttl = renewed
+	}
+	return time.Now().Add(ttl), nil
+}
+
+// refreshTarget ensures a valid certificate for a target and its jump hosts
+func (d *Daemon) refreshTarget(target *ssh.SSHTarget) CertificateStatus {
+	status := CertificateStatus{Target: fmt.Sprintf("%s@%s", target.Username, target.Hostname)}
+
+	hops := []*ssh.SSHTarget{target}
+	if target.ProxyJump != "" {
+		jumpHosts, err := ssh.ParseProxyJump(d.config, target.ProxyJump)
+		if err != nil {
+			status.Error = fmt.Sprintf("invalid ProxyJump: %v", err)
+			return status
+		}
+		hops = append(hops, jumpHosts...)
+	}
+
+	for i, hop := range hops {
+		certPath, err := d.ensureCertificate(hop)
+		if err != nil {
+			status.Error = err.Error()
+			d.logger.Warnf("Failed to refresh certificate for %s@%s: %v", hop.Username, hop.Hostname, err)
+			return status
+		}
+		if i > 0 || certPath == "" {
+			continue
+		}
+
+		status.Path = certPath
+		if expires, err := ssh.CertificateExpiry(certPath); err == nil {
+			status.Expires = expires
+		}
+	}
+	return status
+}
+
+// ensureCertificate ensures a valid certificate for target, in the ssh-agent
+// as well when vssh is configured to use it, and returns its path
+func (d *Daemon) ensureCertificate(target *ssh.SSHTarget) (string, error) {
+	if d.config.SSH.AgentOnly {
+		return "", d.signer.EnsureAgentCertificate(target)
+	}
+
+	certPath, err := d.signer.EnsureSSHCertificate(target)
+	if err != nil {
+		return "", err
+	}
+	if d.config.SSH.UseAgent {
+		keyPath, err := d.signer.GetPrivateKeyPath(target)
+		if err != nil {
+			return "", err
+		}
+		if err := ssh.AddCertificateToAgent(keyPath, certPath); err != nil {
+			return "", err
+		}
+	}
+	return certPath, nil
+}
+
+// WriteStatus saves a daemon status to path
+func WriteStatus(path string, status Status) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding daemon status: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("error creating state directory: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("error writing daemon status: %w", err)
+	}
+	return nil
+}
+
+// ReadStatus loads the status written by a running daemon. It returns nil
+// when no daemon is running.
+func ReadStatus(path string) (*Status, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading daemon status: %w", err)
+	}
+
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("error parsing daemon status %s: %w", path, err)
+	}
+	return &status, nil
+}
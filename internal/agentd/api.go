@@ -0,0 +1,228 @@
+package agentd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vssh/internal/ssh"
+)
+
+// The control API is HTTP with JSON bodies over a Unix socket (AF_UNIX is
+// also supported on Windows 10 and later):
+//
+//	GET  /v1/status       the daemon Status
+//	POST /v1/certificate  {"target": "alice@db01"} -> CertificateResponse
+//
+// Errors are returned as {"error": "..."} with a 4xx or 5xx status code.
+
+// clientTimeout bounds API requests, which may include signing with Vault
+const clientTimeout = time.Minute
+
+// CertificateRequest asks the daemon for a valid certificate for a target
+type CertificateRequest struct {
+	Target string `json:"target"`
+}
+
+// CertificateResponse is a valid certificate for a target. With ssh.agent_only
+// the certificate is only in the ssh-agent and Path and Certificate are empty.
+type CertificateResponse struct {
+	Target         string    `json:"target"`
+	Path           string    `json:"path,omitempty"`
+	Certificate    string    `json:"certificate,omitempty"`
+	PrivateKeyPath string    `json:"private_key_path,omitempty"`
+	Expires        time.Time `json:"expires,omitempty"`
+	InAgent        bool      `json:"in_agent"`
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// DefaultSocketPath returns the control socket location inside stateDir
+func DefaultSocketPath(stateDir string) string {
+	return filepath.Join(stateDir, "agentd.sock")
+}
+
+// Serve answers control API requests on the socket at socketPath until ctx is
+// cancelled. Only the owner of the socket can connect to it.
+func (d *Daemon) Serve(ctx context.Context, socketPath string) error {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return fmt.Errorf("error creating state directory: %w", err)
+	}
+	// A socket left behind by a daemon that didn't stop cleanly
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	defer os.Remove(socketPath)
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict %s: %w", socketPath, err)
+	}
+
+	server := &http.Server{Handler: d.Handler()}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler of the control API
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	mux.HandleFunc("POST /v1/certificate", func(w http.ResponseWriter, r *http.Request) {
+		var request CertificateRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Target == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "expected {\"target\": \"[user@]host\"}"})
+			return
+		}
+
+		response, err := d.Certificate(request.Target)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+	return mux
+}
+
+// Certificate ensures a valid certificate for a target, given as on the vssh
+// command line, and keeps it fresh from then on
+func (d *Daemon) Certificate(rawTarget string) (*CertificateResponse, error) {
+	target, err := ssh.ResolveTarget(d.config, rawTarget)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH target: %w", err)
+	}
+
+	certPath, err := d.ensureCertificate(target)
+	if err != nil {
+		return nil, err
+	}
+	d.track(target)
+
+	response := &CertificateResponse{
+		Target:  fmt.Sprintf("%s@%s", target.Username, target.Hostname),
+		InAgent: d.config.SSH.UseAgent || d.config.SSH.AgentOnly,
+	}
+	if certPath == "" {
+		return response, nil
+	}
+
+	certificate, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	response.Path = certPath
+	response.Certificate = string(certificate)
+	response.Expires, _ = ssh.CertificateExpiry(certPath)
+	response.PrivateKeyPath, _ = d.signer.GetPrivateKeyPath(target)
+	return response, nil
+}
+
+// track adds a target to the ones refreshed, unless it already is
+func (d *Daemon) track(target *ssh.SSHTarget) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, tracked := range d.targets {
+		if tracked.Username == target.Username && tracked.Hostname == target.Hostname && tracked.Port == target.Port {
+			return
+		}
+	}
+	d.targets = append(d.targets, target)
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(value)
+}
+
+// Client talks to a running daemon over its control socket
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a client for the daemon listening on socketPath
+func NewClient(socketPath string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &Client{http: &http.Client{Transport: transport, Timeout: clientTimeout}}
+}
+
+// Status returns the daemon's status
+func (c *Client) Status() (*Status, error) {
+	var status Status
+	if err := c.do(http.MethodGet, "/v1/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Certificate asks the daemon for a valid certificate for target
+func (c *Client) Certificate(target string) (*CertificateResponse, error) {
+	var response CertificateResponse
+	if err := c.do(http.MethodPost, "/v1/certificate", CertificateRequest{Target: target}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// do sends a request with an optional JSON body and decodes the response into result
+func (c *Client) do(method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	// The host is ignored, requests always go to the socket
+	request, err := http.NewRequest(method, "http://agentd"+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return fmt.Errorf("vssh agentd is not reachable: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var failure errorResponse
+		if err := json.NewDecoder(response.Body).Decode(&failure); err != nil || failure.Error == "" {
+			return fmt.Errorf("vssh agentd returned %s", response.Status)
+		}
+		return fmt.Errorf("%s", failure.Error)
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
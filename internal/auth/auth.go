@@ -3,15 +3,15 @@ package auth
 import (
 	"bufio"
 	"fmt"
-	"os"
 	"strings"
-	"syscall"
+	"time"
 
+	"vssh/internal/utils"
 	"vssh/internal/vault"
 	"vssh/pkg/types"
 
+	"github.com/hashicorp/vault/api"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/term"
 )
 
 // Authenticator handles Vault authentication
@@ -19,6 +19,16 @@ type Authenticator struct {
 	client *vault.Client
 	config *types.VaultConfig
 	logger *logrus.Logger
+
+	// tty is the terminal prompts use while authenticating
+	tty *utils.Terminal
+
+	// vaultTime is the time spent waiting for Vault, not for prompts, while
+	// authenticating
+	vaultTime time.Duration
+
+	// onAuthenticated is called after every authentication, see OnAuthenticated
+	onAuthenticated func(vaultTime time.Duration, err error)
 }
 
 // NewAuthenticator creates a new authenticator
@@ -30,27 +40,63 @@ func NewAuthenticator(client *vault.Client, config *types.VaultConfig, logger *l
 	}
 }
 
+// OnAuthenticated registers a function called after every authentication
+// with the time spent waiting for Vault and its error, if any
+func (a *Authenticator) OnAuthenticated(hook func(vaultTime time.Duration, err error)) {
+	a.onAuthenticated = hook
+}
+
 // EnsureAuthenticated ensures the client has a valid token, prompting for authentication if needed
 func (a *Authenticator) EnsureAuthenticated() error {
+	a.vaultTime = 0
+	err := a.ensureAuthenticated()
+	if a.onAuthenticated != nil {
+		a.onAuthenticated(a.vaultTime, err)
+	}
+	return err
+}
+
+// ensureAuthenticated checks the token and logs in if it isn't valid
+func (a *Authenticator) ensureAuthenticated() error {
 	// First, try to load existing token
 	if err := a.client.LoadTokenFromFile(); err != nil {
 		a.logger.Debugf("Could not load token from file: %v", err)
 	}
 
 	// Check if current token is valid
-	if a.client.IsTokenValid() {
+	if a.tokenValid() {
 		a.logger.Debug("Using existing valid token")
 		return nil
 	}
 
+	// Only one vssh process logs in at a time, the others wait for it and use
+	// the token it saved
+	unlock, err := a.client.LockToken()
+	if err != nil {
+		a.logger.Debugf("Could not lock the token file: %v", err)
+	} else {
+		defer unlock()
+		if err := a.client.LoadTokenFromFile(); err == nil && a.tokenValid() {
+			a.logger.Debug("Using token saved by another vssh process")
+			return nil
+		}
+	}
+
 	a.logger.Info("No valid token found, authentication required")
 
+	// Prompt on the terminal even when stdin is piped to the remote command
+	tty, err := utils.OpenTerminal()
+	if err != nil {
+		return fmt.Errorf("authentication required: %w", err)
+	}
+	defer tty.Close()
+	a.tty = tty
+
 	// Determine authentication method
 	authMethod := types.AuthMethod(a.config.AuthMethod)
 
 	// If no auth method configured, prompt user to choose
 	if authMethod == "" || !authMethod.IsValid() {
-		var err error
 		authMethod, err = a.promptForAuthMethod()
 		if err != nil {
 			return fmt.Errorf("failed to get authentication method: %w", err)
@@ -74,14 +120,14 @@ func (a *Authenticator) EnsureAuthenticated() error {
 
 // promptForAuthMethod prompts the user to choose an authentication method
 func (a *Authenticator) promptForAuthMethod() (types.AuthMethod, error) {
-	fmt.Println("Please choose an authentication method:")
-	fmt.Println("1. Token")
-	fmt.Println("2. Username/Password")
-	fmt.Println("3. LDAP")
-	fmt.Println("4. OIDC")
-	fmt.Print("Enter your choice (1-4): ")
-
-	reader := bufio.NewReader(os.Stdin)
+	fmt.Fprintln(a.tty.Out, "Please choose an authentication method:")
+	fmt.Fprintln(a.tty.Out, "1. Token")
+	fmt.Fprintln(a.tty.Out, "2. Username/Password")
+	fmt.Fprintln(a.tty.Out, "3. LDAP")
+	fmt.Fprintln(a.tty.Out, "4. OIDC")
+	fmt.Fprint(a.tty.Out, "Enter your choice (1-4): ")
+
+	reader := bufio.NewReader(a.tty.In)
 	choice, err := reader.ReadString('\n')
 	if err != nil {
 		return "", fmt.Errorf("error reading input: %w", err)
@@ -120,14 +166,13 @@ func (a *Authenticator) authenticate(method types.AuthMethod) error {
 
 // authenticateToken prompts for a token and sets it
 func (a *Authenticator) authenticateToken() error {
-	fmt.Print("Enter Vault token: ")
+	fmt.Fprint(a.tty.Out, "Enter Vault token: ")
 
 	// Read token securely (hidden input)
-	tokenBytes, err := term.ReadPassword(int(syscall.Stdin))
+	tokenBytes, err := a.tty.ReadPassword()
 	if err != nil {
 		return fmt.Errorf("error reading token: %w", err)
 	}
-	fmt.Println() // Add newline after hidden input
 
 	token := strings.TrimSpace(string(tokenBytes))
 	if token == "" {
@@ -136,7 +181,7 @@ func (a *Authenticator) authenticateToken() error {
 
 	// Set token and validate
 	a.client.SetToken(token)
-	if !a.client.IsTokenValid() {
+	if !a.tokenValid() {
 		return fmt.Errorf("invalid token provided")
 	}
 
@@ -145,12 +190,12 @@ func (a *Authenticator) authenticateToken() error {
 
 // authenticateUserPass performs username/password authentication
 func (a *Authenticator) authenticateUserPass() error {
-	reader := bufio.NewReader(os.Stdin)
+	reader := bufio.NewReader(a.tty.In)
 
 	// Get username
 	username := a.config.UserPass.Username
 	if username == "" {
-		fmt.Print("Username: ")
+		fmt.Fprint(a.tty.Out, "Username: ")
 		input, err := reader.ReadString('\n')
 		if err != nil {
 			return fmt.Errorf("error reading username: %w", err)
@@ -163,12 +208,11 @@ func (a *Authenticator) authenticateUserPass() error {
 	}
 
 	// Get password
-	fmt.Print("Password: ")
-	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprint(a.tty.Out, "Password: ")
+	passwordBytes, err := a.tty.ReadPassword()
 	if err != nil {
 		return fmt.Errorf("error reading password: %w", err)
 	}
-	fmt.Println() // Add newline after hidden input
 
 	password := strings.TrimSpace(string(passwordBytes))
 	if password == "" {
@@ -186,7 +230,9 @@ func (a *Authenticator) authenticateUserPass() error {
 		"password": password,
 	}
 
-	secret, err := a.client.GetClient().Logical().Write(path, data)
+	spinner := utils.StartSpinner("Logging in to Vault...")
+	secret, err := a.write(path, data)
+	spinner.Stop()
 	if err != nil {
 		return fmt.Errorf("userpass authentication failed: %w", err)
 	}
@@ -202,12 +248,12 @@ func (a *Authenticator) authenticateUserPass() error {
 
 // authenticateLDAP performs LDAP authentication
 func (a *Authenticator) authenticateLDAP() error {
-	reader := bufio.NewReader(os.Stdin)
+	reader := bufio.NewReader(a.tty.In)
 
 	// Get username
 	username := a.config.LDAP.Username
 	if username == "" {
-		fmt.Print("LDAP Username: ")
+		fmt.Fprint(a.tty.Out, "LDAP Username: ")
 		input, err := reader.ReadString('\n')
 		if err != nil {
 			return fmt.Errorf("error reading username: %w", err)
@@ -220,12 +266,11 @@ func (a *Authenticator) authenticateLDAP() error {
 	}
 
 	// Get password
-	fmt.Print("LDAP Password: ")
-	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprint(a.tty.Out, "LDAP Password: ")
+	passwordBytes, err := a.tty.ReadPassword()
 	if err != nil {
 		return fmt.Errorf("error reading password: %w", err)
 	}
-	fmt.Println() // Add newline after hidden input
 
 	password := strings.TrimSpace(string(passwordBytes))
 	if password == "" {
@@ -243,7 +288,9 @@ func (a *Authenticator) authenticateLDAP() error {
 		"password": password,
 	}
 
-	secret, err := a.client.GetClient().Logical().Write(path, data)
+	spinner := utils.StartSpinner("Logging in to Vault...")
+	secret, err := a.write(path, data)
+	spinner.Stop()
 	if err != nil {
 		return fmt.Errorf("LDAP authentication failed: %w", err)
 	}
@@ -269,8 +316,8 @@ func (a *Authenticator) authenticateOIDC() error {
 		return fmt.Errorf("OIDC role not configured")
 	}
 
-	fmt.Printf("Starting OIDC authentication for role: %s\n", role)
-	fmt.Println("This will open a browser window for authentication...")
+	fmt.Fprintf(a.tty.Out, "Starting OIDC authentication for role: %s\n", role)
+	fmt.Fprintln(a.tty.Out, "This will open a browser window for authentication...")
 
 	// Start OIDC auth
 	path := fmt.Sprintf("auth/%s/oidc/auth_url", mount)
@@ -279,7 +326,7 @@ func (a *Authenticator) authenticateOIDC() error {
 		"redirect_uri": "http://localhost:8250/oidc/callback",
 	}
 
-	secret, err := a.client.GetClient().Logical().Write(path, data)
+	secret, err := a.write(path, data)
 	if err != nil {
 		return fmt.Errorf("failed to get OIDC auth URL: %w", err)
 	}
@@ -293,10 +340,10 @@ func (a *Authenticator) authenticateOIDC() error {
 		return fmt.Errorf("invalid auth URL returned")
 	}
 
-	fmt.Printf("Please visit this URL to authenticate: %s\n", authURL)
-	fmt.Print("Enter the authorization code: ")
+	fmt.Fprintf(a.tty.Out, "Please visit this URL to authenticate: %s\n", authURL)
+	fmt.Fprint(a.tty.Out, "Enter the authorization code: ")
 
-	reader := bufio.NewReader(os.Stdin)
+	reader := bufio.NewReader(a.tty.In)
 	code, err := reader.ReadString('\n')
 	if err != nil {
 		return fmt.Errorf("error reading authorization code: %w", err)
@@ -314,7 +361,9 @@ func (a *Authenticator) authenticateOIDC() error {
 		"state": secret.Data["state"],
 	}
 
-	authSecret, err := a.client.GetClient().Logical().Write(completePath, completeData)
+	spinner := utils.StartSpinner("Logging in to Vault...")
+	authSecret, err := a.write(completePath, completeData)
+	spinner.Stop()
 	if err != nil {
 		return fmt.Errorf("OIDC authentication failed: %w", err)
 	}
@@ -327,3 +376,17 @@ func (a *Authenticator) authenticateOIDC() error {
 	a.client.SetToken(authSecret.Auth.ClientToken)
 	return nil
 }
+
+// tokenValid checks the token with Vault, timing the request
+func (a *Authenticator) tokenValid() bool {
+	started := time.Now()
+	defer func() { a.vaultTime += time.Since(started) }()
+	return a.client.IsTokenValid()
+}
+
+// write sends a login request to Vault, timing it
+func (a *Authenticator) write(path string, data map[string]interface{}) (*api.Secret, error) {
+	started := time.Now()
+	defer func() { a.vaultTime += time.Since(started) }()
+	return a.client.GetClient().Logical().Write(path, data)
+}
@@ -1,9 +1,15 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"vssh/pkg/types"
 
@@ -15,7 +21,10 @@ func LoadConfig() (*types.Config, error) {
 	config := &types.Config{}
 
 	// Set defaults
-	setDefaults()
+	setDefaults(viper.GetViper())
+
+	// Allow every key to be overridden with a VSSH_ environment variable
+	bindEnv(viper.GetViper())
 
 	// Read configuration
 	if err := viper.ReadInConfig(); err != nil {
@@ -39,7 +48,7 @@ func LoadConfig() (*types.Config, error) {
 }
 
 // setDefaults sets default configuration values
-func setDefaults() {
+func setDefaults(v *viper.Viper) {
 	// Get home directory for default paths
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -47,52 +56,111 @@ func setDefaults() {
 	}
 
 	// Vault defaults
-	viper.SetDefault("vault.address", "https://vault.example.com")
+	v.SetDefault("vault.address", "https://vault.example.com")
 	// viper.SetDefault("vault.role", "ssh-client-role")  # Removed - will use username as role
-	viper.SetDefault("vault.auth_method", "token")
-	viper.SetDefault("vault.token.token_path", filepath.Join(home, ".vault-token"))
-	viper.SetDefault("vault.userpass.mount", "userpass")
-	viper.SetDefault("vault.ldap.mount", "ldap")
-	viper.SetDefault("vault.oidc.mount", "oidc")
+	v.SetDefault("vault.auth_method", "token")
+	v.SetDefault("vault.select_role", true)
+	v.SetDefault("vault.userpass.mount", "userpass")
+	v.SetDefault("vault.ldap.mount", "ldap")
+	v.SetDefault("vault.oidc.mount", "oidc")
 
 	// SSH defaults
-	viper.SetDefault("ssh.key_directory", filepath.Join(home, ".ssh"))
-	viper.SetDefault("ssh.certificate_ttl", "4h")
-	viper.SetDefault("ssh.signing_engine", "ssh-client-signer")
+	v.SetDefault("ssh.key_directory", filepath.Join(home, ".ssh"))
+	v.SetDefault("ssh.certificate_directory", filepath.Join(GetCacheDir(), "certs"))
+	v.SetDefault("ssh.certificate_ttl", "4h")
+	v.SetDefault("ssh.renew_before", types.DefaultRenewBefore)
+	v.SetDefault("ssh.signing_engine", "ssh-client-signer")
+	v.SetDefault("ssh.ssh_config_file", filepath.Join(home, ".ssh", "config"))
+	v.SetDefault("ssh.forward_agent", false)
+	v.SetDefault("ssh.forward_x11", false)
+	v.SetDefault("ssh.forward_x11_trusted", false)
+	v.SetDefault("ssh.multiplexing.enabled", false)
+	v.SetDefault("ssh.native", false)
+	v.SetDefault("ssh.auto_generate_key", false)
+	v.SetDefault("ssh.use_agent", false)
+	v.SetDefault("ssh.agent_only", false)
+	v.SetDefault("ssh.ephemeral_key", false)
+	v.SetDefault("ssh.multiplexing.control_path", filepath.Join(GetStateDir(), "mux", "%r@%h:%p"))
+	v.SetDefault("ssh.multiplexing.persist", "10m")
+	v.SetDefault("ssh.host_ca.signing_engine", "ssh-host-signer")
+
+	// Inventory defaults
+	v.SetDefault("inventory.ec2.enabled", false)
+	v.SetDefault("inventory.ec2.address_type", "private")
+
+	// History defaults
+	v.SetDefault("history.enabled", true)
+	v.SetDefault("history.max_entries", 1000)
+	v.SetDefault("history.record_certificates", false)
+
+	// Log file defaults
+	v.SetDefault("logging.file", "")
+	v.SetDefault("logging.level", "debug")
+	v.SetDefault("logging.format", "text")
+	v.SetDefault("logging.max_size", 10)
+	v.SetDefault("logging.max_backups", 3)
+	v.SetDefault("logging.syslog.enabled", false)
+	v.SetDefault("logging.syslog.tag", "vssh")
+	v.SetDefault("logging.syslog.facility", "auth")
+
+	// Session recording defaults
+	v.SetDefault("recording.enabled", false)
+	v.SetDefault("recording.directory", filepath.Join(GetStateDir(), "recordings"))
+
+	// Webhook defaults
+	v.SetDefault("webhook.url", "")
+	v.SetDefault("webhook.secret", "")
+	v.SetDefault("webhook.timeout", "5s")
+
+	// Metrics defaults
+	v.SetDefault("metrics.enabled", true)
+
+	// UI defaults
+	v.SetDefault("ui.session_summary", false)
+
+	// Certificate refresh daemon defaults
+	v.SetDefault("agentd.check_interval", "1m")
+	v.SetDefault("agentd.metrics_file", "")
+	v.SetDefault("agentd.metrics_listen", "")
 
 	// Debug default
-	viper.SetDefault("debug", false)
+	v.SetDefault("debug", false)
+}
+
+// EnvPrefix is the prefix for environment variables overriding configuration keys
+const EnvPrefix = "VSSH"
+
+// bindEnv binds every configuration key to a VSSH_ prefixed environment variable,
+// e.g. vault.address to VSSH_VAULT_ADDRESS
+func bindEnv(v *viper.Viper) {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	// AutomaticEnv only applies to keys viper already knows about, so bind
+	// each key explicitly to make Unmarshal see values without a default
+	for _, key := range ConfigKeys() {
+		v.BindEnv(key)
+	}
 }
 
 // validateConfig validates the loaded configuration
 func validateConfig(config *types.Config) error {
 	// Validate Vault configuration
-	if config.Vault.Address == "" {
-		return fmt.Errorf("vault.address is required")
+	applyNamespaceDefault(&config.Vault)
+	applyTokenDefaults(&config.Vault)
+	if err := validateVault("vault", config.Vault); err != nil {
+		return err
 	}
 
-	// vault.role is now optional - will use username as role by default
-
-	// Validate auth method
-	authMethod := types.AuthMethod(config.Vault.AuthMethod)
-	if !authMethod.IsValid() {
-		return fmt.Errorf("invalid auth method: %s. Supported methods: token, userpass, ldap, oidc", config.Vault.AuthMethod)
-	}
-
-	// Validate auth method specific configuration
-	switch authMethod {
-	case types.AuthMethodUserPass:
-		if config.Vault.UserPass.Username == "" {
-			// Username can be prompted at runtime, so this is not required
-		}
-	case types.AuthMethodLDAP:
-		if config.Vault.LDAP.Username == "" {
-			// Username can be prompted at runtime, so this is not required
-		}
-	case types.AuthMethodOIDC:
-		if config.Vault.OIDC.Role == "" {
-			return fmt.Errorf("vault.oidc.role is required when using oidc auth")
+	// Additional Vault clusters get the same defaults as the vault section,
+	// except for a token file of their own
+	for name, vault := range config.Vaults {
+		applyVaultDefaults(name, &vault)
+		if err := validateVault("vaults."+name, vault); err != nil {
+			return err
 		}
+		config.Vaults[name] = vault
 	}
 
 	// Validate SSH configuration
@@ -105,26 +173,332 @@ func validateConfig(config *types.Config) error {
 		return fmt.Errorf("ssh.certificate_ttl must be greater than 0")
 	}
 
+	if _, err := config.SSH.RenewMargin(config.SSH.CertificateTTL); err != nil {
+		return err
+	}
+
+	if _, err := config.SSH.KeyID(types.KeyIDFields{}); err != nil {
+		return err
+	}
+
+	if err := validateHostKeyChecking("ssh.strict_host_key_checking", config.SSH.StrictHostKeyChecking); err != nil {
+		return err
+	}
+
+	for _, domain := range config.SSH.HostCA.Domains {
+		if domain == "" || strings.ContainsAny(domain, ", \t") {
+			return fmt.Errorf("ssh.host_ca.domains must be host patterns without commas or spaces, got %q", domain)
+		}
+	}
+	switch config.SSH.HostCA.Verify {
+	case "", "warn", "enforce":
+	default:
+		return fmt.Errorf("ssh.host_ca.verify must be warn or enforce, got %s", config.SSH.HostCA.Verify)
+	}
+	if config.SSH.HostCA.Verify != "" && len(config.SSH.HostCA.Domains) == 0 {
+		return fmt.Errorf("ssh.host_ca.verify requires ssh.host_ca.domains")
+	}
+
+	// An ephemeral key only exists in the agent
+	if config.SSH.EphemeralKey && !config.SSH.AgentOnly {
+		return fmt.Errorf("ssh.ephemeral_key requires ssh.agent_only")
+	}
+	if config.SSH.PKCS11Provider != "" && (config.SSH.UseAgent || config.SSH.AgentOnly) {
+		return fmt.Errorf("ssh.pkcs11_provider can't be combined with ssh.use_agent or ssh.agent_only")
+	}
+
 	// Validate user configurations
 	for username, userConfig := range config.Users {
-		if userConfig.PrivateKey == "" {
-			return fmt.Errorf("private_key is required for user %s", username)
+		if userConfig.PrivateKey == "" && len(userConfig.Keys) == 0 {
+			return fmt.Errorf("private_key or keys is required for user %s", username)
+		}
+		if err := validatePrincipals(fmt.Sprintf("principals for user %s", username), userConfig.Principals); err != nil {
+			return err
 		}
 
-		// Expand tilde in private key path
-		if userConfig.PrivateKey[0] == '~' {
-			home, err := os.UserHomeDir()
-			if err != nil {
+		// Expand tilde in private key paths
+		var err error
+		if userConfig.PrivateKey, err = expandUserPath(userConfig.PrivateKey); err != nil {
+			return fmt.Errorf("error getting home directory for user %s: %w", username, err)
+		}
+		keys := make([]string, len(userConfig.Keys))
+		for i, key := range userConfig.Keys {
+			if key == "" {
+				return fmt.Errorf("keys entry %d for user %s is empty", i+1, username)
+			}
+			if keys[i], err = expandUserPath(key); err != nil {
 				return fmt.Errorf("error getting home directory for user %s: %w", username, err)
 			}
-			userConfig.PrivateKey = filepath.Join(home, userConfig.PrivateKey[1:])
-			config.Users[username] = userConfig
+		}
+		userConfig.Keys = keys
+		config.Users[username] = userConfig
+	}
+
+	// Validate bookmarks
+	for name, target := range config.Bookmarks {
+		if target == "" {
+			return fmt.Errorf("bookmark %s must have a target", name)
+		}
+	}
+
+	// Validate inventory configuration
+	switch config.Inventory.EC2.AddressType {
+	case "", "private", "public":
+	default:
+		return fmt.Errorf("inventory.ec2.address_type must be private or public")
+	}
+
+	if config.History.MaxEntries < 0 {
+		return fmt.Errorf("history.max_entries must not be negative")
+	}
+
+	if !slices.Contains(types.LogLevels, config.Logging.Level) {
+		return fmt.Errorf("logging.level must be one of %s", strings.Join(types.LogLevels, ", "))
+	}
+	if !slices.Contains(types.LogFormats, config.Logging.Format) {
+		return fmt.Errorf("logging.format must be one of %s", strings.Join(types.LogFormats, ", "))
+	}
+	if config.Logging.MaxSize < 0 || config.Logging.MaxBackups < 0 {
+		return fmt.Errorf("logging.max_size and logging.max_backups must not be negative")
+	}
+	if slices.Contains(config.Hooks.PreConnect, "") || slices.Contains(config.Hooks.PostDisconnect, "") {
+		return fmt.Errorf("hooks.pre_connect and hooks.post_disconnect commands must not be empty")
+	}
+
+	if config.Webhook.URL != "" {
+		if u, err := url.Parse(config.Webhook.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("webhook.url must be an http or https URL")
+		}
+		if config.Webhook.Timeout <= 0 {
+			return fmt.Errorf("webhook.timeout must be greater than 0")
+		}
+	}
+
+	if err := validateSyslog(config.Logging.Syslog); err != nil {
+		return err
+	}
+	logFile, err := expandUserPath(config.Logging.File)
+	if err != nil {
+		return fmt.Errorf("error expanding logging.file: %w", err)
+	}
+	config.Logging.File = logFile
+	recordingDir, err := expandUserPath(config.Recording.Directory)
+	if err != nil {
+		return fmt.Errorf("error expanding recording.directory: %w", err)
+	}
+	config.Recording.Directory = recordingDir
+
+	if config.Agentd.CheckInterval <= 0 {
+		return fmt.Errorf("agentd.check_interval must be greater than 0")
+	}
+	metricsFile, err := expandUserPath(config.Agentd.MetricsFile)
+	if err != nil {
+		return fmt.Errorf("error expanding agentd.metrics_file: %w", err)
+	}
+	config.Agentd.MetricsFile = metricsFile
+	if listen := config.Agentd.MetricsListen; listen != "" {
+		if _, _, err := net.SplitHostPort(listen); err != nil {
+			return fmt.Errorf("invalid agentd.metrics_listen %q: %w", listen, err)
+		}
+	}
+
+	// Validate host pattern mappings
+	for i, host := range config.Hosts {
+		if host.Pattern == "" {
+			return fmt.Errorf("pattern is required for hosts entry %d", i+1)
+		}
+		if err := validateHostKeyChecking(fmt.Sprintf("strict_host_key_checking for hosts entry %d", i+1), host.StrictHostKeyChecking); err != nil {
+			return err
+		}
+		if err := validateKeyType(fmt.Sprintf("key_type for hosts entry %d", i+1), host.KeyType); err != nil {
+			return err
+		}
+		if err := validatePrincipals(fmt.Sprintf("principals for hosts entry %d", i+1), host.Principals); err != nil {
+			return err
+		}
+		if err := validateMode(fmt.Sprintf("mode for hosts entry %d", i+1), host.Mode); err != nil {
+			return err
+		}
+		if err := validateVaultName(config, fmt.Sprintf("vault for hosts entry %d", i+1), host.Vault); err != nil {
+			return err
+		}
+	}
+
+	// Validate host groups
+	for name, group := range config.Groups {
+		if len(group.Hosts) == 0 {
+			return fmt.Errorf("group %s must list at least one host", name)
+		}
+		if err := validateHostKeyChecking(fmt.Sprintf("strict_host_key_checking for group %s", name), group.StrictHostKeyChecking); err != nil {
+			return err
+		}
+		if err := validateKeyType(fmt.Sprintf("key_type for group %s", name), group.KeyType); err != nil {
+			return err
+		}
+		if err := validatePrincipals(fmt.Sprintf("principals for group %s", name), group.Principals); err != nil {
+			return err
+		}
+		if err := validateMode(fmt.Sprintf("mode for group %s", name), group.Mode); err != nil {
+			return err
+		}
+		if err := validateVaultName(config, fmt.Sprintf("vault for group %s", name), group.Vault); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// validateVault checks the configuration of a Vault cluster under key
+func validateVault(key string, vault types.VaultConfig) error {
+	if vault.Address == "" {
+		return fmt.Errorf("%s.address is required", key)
+	}
+
+	// vault.role is now optional - will use username as role by default
+
+	// Validate auth method
+	authMethod := types.AuthMethod(vault.AuthMethod)
+	if !authMethod.IsValid() {
+		return fmt.Errorf("invalid auth method: %s. Supported methods: token, userpass, ldap, oidc", vault.AuthMethod)
+	}
+
+	// Validate auth method specific configuration. Usernames for userpass and
+	// LDAP can be prompted at runtime, so they are not required.
+	if authMethod == types.AuthMethodOIDC && vault.OIDC.Role == "" {
+		return fmt.Errorf("%s.oidc.role is required when using oidc auth", key)
+	}
+	return nil
+}
+
+// applyVaultDefaults fills in the defaults of the vault section for an
+// additional Vault cluster, with its token in the state directory
+func applyVaultDefaults(name string, vault *types.VaultConfig) {
+	applyNamespaceDefault(vault)
+	if vault.AuthMethod == "" {
+		vault.AuthMethod = string(types.AuthMethodToken)
+	}
+	if vault.Token.TokenPath == "" {
+		vault.Token.TokenPath = filepath.Join(GetStateDir(), "tokens", name)
+	}
+	if vault.UserPass.Mount == "" {
+		vault.UserPass.Mount = "userpass"
+	}
+	if vault.LDAP.Mount == "" {
+		vault.LDAP.Mount = "ldap"
+	}
+	if vault.OIDC.Mount == "" {
+		vault.OIDC.Mount = "oidc"
+	}
+}
+
+// applyNamespaceDefault sets the namespace of a Vault cluster without one to
+// VAULT_NAMESPACE, which the Vault client uses then, so tokens and
+// certificates of different namespaces are kept apart
+func applyNamespaceDefault(vault *types.VaultConfig) {
+	if vault.Namespace == "" {
+		vault.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+}
+
+// applyTokenDefaults keeps the token of the vault section in a file of its own
+// for its address and namespace, unless token.token_path is set, so switching
+// between clusters keeps the token of each. A token from `vault login` in
+// ~/.vault-token is used until vssh saves one of its own.
+func applyTokenDefaults(vault *types.VaultConfig) {
+	if vault.Token.TokenPath != "" {
+		return
+	}
+	vault.Token.TokenPath = TokenPath(vault.Address, vault.Namespace)
+	if home, err := os.UserHomeDir(); err == nil {
+		vault.Token.ImportPath = filepath.Join(home, ".vault-token")
+	}
+}
+
+// TokenPath returns the file in the state directory keeping the token of the
+// Vault cluster at address and namespace
+func TokenPath(address, namespace string) string {
+	hash := sha256.Sum256([]byte(strings.TrimRight(address, "/") + "\x00" + namespace))
+	return filepath.Join(GetStateDir(), "tokens", hex.EncodeToString(hash[:])[:16])
+}
+
+// validateVaultName checks that a host setting names a configured Vault
+// cluster, if any
+func validateVaultName(config *types.Config, name, value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, ok := config.Vaults[strings.ToLower(value)]; !ok {
+		return fmt.Errorf("%s names an unknown Vault cluster %s, add it to vaults", name, value)
+	}
+	return nil
+}
+
+// validateHostKeyChecking checks a StrictHostKeyChecking value, which may be empty
+func validateHostKeyChecking(name, value string) error {
+	switch value {
+	case "", "yes", "no", "accept-new", "ask", "off":
+		return nil
+	default:
+		return fmt.Errorf("%s must be yes, no, accept-new, ask or off", name)
+	}
+}
+
+// validateKeyType checks a key type, which may be empty
+func validateKeyType(name, value string) error {
+	if value == "" || slices.Contains(types.KeyTypes, value) {
+		return nil
+	}
+	return fmt.Errorf("%s must be one of %s", name, strings.Join(types.KeyTypes, ", "))
+}
+
+// validateMode checks an authentication mode, which may be empty
+func validateMode(name, value string) error {
+	if value == "" || slices.Contains(types.Modes, value) {
+		return nil
+	}
+	return fmt.Errorf("%s must be one of %s", name, strings.Join(types.Modes, ", "))
+}
+
+// validatePrincipals checks certificate principals, which Vault receives
+// comma-separated
+func validatePrincipals(name string, principals []string) error {
+	for _, principal := range principals {
+		if principal == "" || strings.Contains(principal, ",") {
+			return fmt.Errorf("%s must be non-empty and contain no commas, got %q", name, principal)
+		}
+	}
+	return nil
+}
+
+// validateSyslog checks where syslog events are sent
+func validateSyslog(syslog types.SyslogConfig) error {
+	switch syslog.Network {
+	case "", "udp", "tcp":
+	default:
+		return fmt.Errorf("logging.syslog.network must be udp or tcp, or empty for the local daemon")
+	}
+	if (syslog.Network == "") != (syslog.Address == "") {
+		return fmt.Errorf("logging.syslog.network and logging.syslog.address must be set together")
+	}
+	if !slices.Contains(types.SyslogFacilities, syslog.Facility) {
+		return fmt.Errorf("logging.syslog.facility must be one of %s", strings.Join(types.SyslogFacilities, ", "))
+	}
+	return nil
+}
+
+// expandUserPath expands a leading ~ to the home directory
+func expandUserPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
 // CreateDefaultConfig creates a default configuration file
 func CreateDefaultConfig(configPath string) error {
 	// Ensure config directory exists
@@ -148,9 +522,11 @@ vault:
   role: "ssh-client-role"
   auth_method: "token"  # Options: token, userpass, ldap, oidc
   
-  # Token authentication (default)
-  token:
-    token_path: "%s/.vault-token"
+  # Token authentication (default). The token is kept in a file of its own
+  # for each Vault address and namespace, and one from "vault login" in
+  # ~/.vault-token is used until vssh saves its own
+  # token:
+  #   token_path: "%s/.vault-token"
   
   # Username/Password authentication
   # userpass:
@@ -169,8 +545,12 @@ vault:
 
 ssh:
   key_directory: "%s/.ssh"
+  # Signed certificates are cached here (default: $XDG_CACHE_HOME/vssh/certs)
+  # certificate_directory: "%s/.cache/vssh/certs"
   certificate_ttl: "4h"
   signing_engine: "ssh-client-signer"
+  # OpenSSH client config used to resolve host aliases ("none" to disable)
+  # ssh_config_file: "%s/.ssh/config"
 
 # Per-user SSH key configuration
 users:
@@ -183,9 +563,83 @@ users:
   #   private_key: "%s/.ssh/user2_rsa"
   #   vault_role: "user2-role"
 
+# Per-host settings, matched by glob pattern in order (first match wins)
+hosts:
+  # - pattern: "*.prod.example.com"
+  #   role: "prod-ssh"  # Vault role used when connecting to matching hosts
+  #   signing_engine: "ssh-prod"  # SSH secrets engine (CA) for matching hosts
+  #   user: "ubuntu"  # Remote username when the target has no user@ prefix
+  #   proxy_jump: "bastion.prod.example.com"  # Jump host(s), each hop uses a signed certificate
+  #   banner: "Change freeze in effect, see CHG-1234"  # Shown before connecting
+  #   protected: true  # Type the host name to confirm before connecting
+  #   require_reason: true  # Also ask for a reason, recorded in the audit log
+
+# Named host groups sharing settings; hosts may be names or glob patterns
+groups:
+  # web:
+  #   hosts: ["web01.example.com", "web02.example.com"]
+  #   role: "web-ssh"
+
+# Short names for frequently used targets, usable as "vssh <name>"
+bookmarks:
+  # db: "admin@db01.prod.example.com:2222"
+
+# Host inventory (name, address, user, role, tags) loaded from a Vault KV secret
+# inventory:
+#   vault_path: "secret/data/ssh/inventory"  # KV v2 paths include data/
+#   ec2:  # Running EC2 instances, listed with the aws CLI
+#     enabled: true
+#     region: "us-east-1"
+#     filters: {Team: "platform"}  # Only instances with these tags
+#     address_type: "private"  # private or public IP
+#     user: "ec2-user"
+
+# Connection history used by "vssh history"
+# history:
+#   enabled: true
+#   max_entries: 1000
+#   record_certificates: false  # Keep serial numbers of issued certificates
+
+# Signing and login latency and connection counts shown by "vssh stats"
+# metrics:
+#   enabled: true
+
+# Print a summary line (duration, certificate serial, exit code) when a session ends
+# ui:
+#   session_summary: true
+
+# Log file, written at its own level whatever the console shows
+# logging:
+#   file: "~/.local/state/vssh/vssh.log"
+#   level: "debug"
+#   format: "text"  # text or json
+#   max_size: 10  # Megabytes before the file is rotated
+#   max_backups: 3
+#   syslog:  # Connection and signing events
+#     enabled: true
+#     network: "udp"  # udp or tcp for a remote server, unset for the local daemon
+#     address: "logs.example.com:514"
+
+# Record terminal sessions as asciicast files (also with --record)
+# recording:
+#   enabled: false
+#   directory: "~/.local/state/vssh/recordings"
+
+# Shell commands run around every connection, with VSSH_HOST, VSSH_USER,
+# VSSH_CERT_SERIAL, ... in their environment
+# hooks:
+#   pre_connect: ["vpn-check"]  # A failing command stops the connection
+#   post_disconnect: ["notify-send \"Disconnected from $VSSH_HOST\""]
+
+# Post session_start and session_end events to a webhook
+# webhook:
+#   url: "https://soc.example.com/hooks/vssh"
+#   secret: ""  # HMAC-SHA256 key, or set VSSH_WEBHOOK_SECRET
+#   timeout: "5s"
+
 # Enable debug logging
 debug: false
-`, home, home, home, home)
+`, home, home, home, home, home, home)
 
 	// Write the configuration file
 	if err := os.WriteFile(configPath, []byte(defaultConfig), 0644); err != nil {
@@ -203,3 +657,29 @@ func GetConfigPath() string {
 	}
 	return filepath.Join(home, ".config", "vssh", "config.yaml")
 }
+
+// GetCacheDir returns the directory for files vssh can recreate, such as
+// signed certificates. It follows $XDG_CACHE_HOME, defaulting to ~/.cache/vssh.
+func GetCacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "vssh")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cache", "vssh")
+}
+
+// GetStateDir returns the directory for state vssh keeps between runs, such as
+// connection history. It follows $XDG_STATE_HOME, defaulting to ~/.local/state/vssh.
+func GetStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "vssh")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "state", "vssh")
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"vssh/pkg/types"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// GetActiveConfigPath returns the configuration file currently in use,
+// falling back to the default location when no file has been loaded
+func GetActiveConfigPath() string {
+	if path := viper.ConfigFileUsed(); path != "" {
+		return path
+	}
+	return GetConfigPath()
+}
+
+// ValidateData parses raw YAML configuration and runs the same validation as LoadConfig
+func ValidateData(data []byte) (*types.Config, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	setDefaults(v)
+
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("error parsing config: %w", err)
+	}
+
+	config := &types.Config{}
+	if err := v.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("error unmarshaling config: %w", err)
+	}
+
+	if err := validateConfig(config); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+
+	return config, nil
+}
+
+// Setting is a single dotted configuration key and its typed value
+type Setting struct {
+	Key   string
+	Value interface{}
+}
+
+// SetValue sets a dotted configuration key in the YAML file at configPath.
+// Comments and unrelated keys are preserved, and the file is only written
+// if the resulting configuration is valid.
+func SetValue(configPath, key string, value interface{}) error {
+	return SetValues(configPath, Setting{Key: key, Value: value})
+}
+
+// SetValues applies several settings to the YAML file at configPath at once,
+// validating only the final result
+func SetValues(configPath string, settings ...Setting) error {
+	return editFile(configPath, func(root *yaml.Node) error {
+		for _, setting := range settings {
+			if err := setNode(root, splitKey(setting.Key), valueNode(setting.Value)); err != nil {
+				return fmt.Errorf("error setting %s: %w", setting.Key, err)
+			}
+		}
+		return nil
+	})
+}
+
+// UnsetValue removes a dotted configuration key from the YAML file at configPath.
+// It returns an error if the key is not present in the file.
+func UnsetValue(configPath, key string) error {
+	return editFile(configPath, func(root *yaml.Node) error {
+		if !unsetNode(root, splitKey(key)) {
+			return fmt.Errorf("%s is not set in %s", key, configPath)
+		}
+		return nil
+	})
+}
+
+// editFile applies edit to the root mapping of the YAML file at configPath and
+// writes the result only if the resulting configuration is valid
+func editFile(configPath string, edit func(root *yaml.Node) error) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	// An empty file has no document node yet
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a YAML mapping", configPath)
+	}
+
+	if err := edit(root); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+	encoder.Close()
+
+	if _, err := ValidateData(buf.Bytes()); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+
+	if err := os.WriteFile(configPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+
+	return nil
+}
+
+// setNode sets the value at the given path within a YAML mapping node,
+// creating intermediate mappings as needed
+func setNode(mapping *yaml.Node, parts []string, value *yaml.Node) error {
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value != parts[0] {
+			continue
+		}
+
+		existing := mapping.Content[i+1]
+		if len(parts) == 1 {
+			// Keep any comments attached to the old value
+			value.HeadComment = existing.HeadComment
+			value.LineComment = existing.LineComment
+			value.FootComment = existing.FootComment
+			mapping.Content[i+1] = value
+			return nil
+		}
+
+		if existing.Kind != yaml.MappingNode {
+			if existing.Tag != "!!null" {
+				return fmt.Errorf("%s is not a mapping", parts[0])
+			}
+			existing.Kind = yaml.MappingNode
+			existing.Tag = "!!map"
+			existing.Value = ""
+		}
+		return setNode(existing, parts[1:], value)
+	}
+
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: parts[0]}
+	if len(parts) == 1 {
+		mapping.Content = append(mapping.Content, keyNode, value)
+		return nil
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	mapping.Content = append(mapping.Content, keyNode, child)
+	return setNode(child, parts[1:], value)
+}
+
+// unsetNode removes the key at the given path within a YAML mapping node,
+// reporting whether it was found
+func unsetNode(mapping *yaml.Node, parts []string) bool {
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value != parts[0] {
+			continue
+		}
+
+		if len(parts) == 1 {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return true
+		}
+
+		existing := mapping.Content[i+1]
+		if existing.Kind != yaml.MappingNode {
+			return false
+		}
+		return unsetNode(existing, parts[1:])
+	}
+	return false
+}
+
+// valueNode converts a typed configuration value into a YAML node
+func valueNode(value interface{}) *yaml.Node {
+	switch v := value.(type) {
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case int:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(v)}
+	case time.Duration:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v.String()}
+	case []string:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+		}
+		return seq
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprintf("%v", v)}
+	}
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"vssh/pkg/types"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// GetValue returns the value of a dotted configuration key (e.g. "vault.address")
+// from the loaded configuration
+func GetValue(config *types.Config, key string) (interface{}, error) {
+	value, err := lookupValue(reflect.ValueOf(config).Elem(), splitKey(key), key)
+	if err != nil {
+		return nil, err
+	}
+	return value.Interface(), nil
+}
+
+// FormatValue renders a configuration value the way it would appear in YAML
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Duration:
+		return v.String()
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// ParseValue converts a string into the type expected for a dotted configuration key.
+// It returns an error if the key is unknown or the value does not match the key's type.
+func ParseValue(key, raw string) (interface{}, error) {
+	fieldType, err := lookupType(reflect.TypeOf(types.Config{}), splitKey(key), key)
+	if err != nil {
+		return nil, err
+	}
+
+	if fieldType == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration for %s: %w", key, err)
+		}
+		return d, nil
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean for %s: %s", key, raw)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer for %s: %s", key, raw)
+		}
+		return int(i), nil
+	case reflect.Slice:
+		if fieldType.Elem().Kind() == reflect.String {
+			var items []string
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			return items, nil
+		}
+	case reflect.Struct, reflect.Map:
+		return nil, fmt.Errorf("%s is a section, not a single value", key)
+	}
+
+	return nil, fmt.Errorf("%s cannot be set from the command line, edit the configuration file instead", key)
+}
+
+// ConfigKeys returns the dotted names of every settable configuration key.
+// Keys below maps (such as users) are not included since their names are user-defined.
+func ConfigKeys() []string {
+	return collectKeys(reflect.TypeOf(types.Config{}), "")
+}
+
+// collectKeys lists the leaf keys of a configuration struct type
+func collectKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("mapstructure")
+		name := strings.Split(tag, ",")[0]
+
+		if strings.Contains(tag, "squash") && field.Type.Kind() == reflect.Struct {
+			keys = append(keys, collectKeys(field.Type, prefix)...)
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		switch {
+		case field.Type.Kind() == reflect.Struct && field.Type != durationType:
+			keys = append(keys, collectKeys(field.Type, key)...)
+		case field.Type.Kind() == reflect.Map:
+			// Map keys are user-defined
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// splitKey splits a dotted configuration key into its parts
+func splitKey(key string) []string {
+	return strings.Split(strings.Trim(key, "."), ".")
+}
+
+// lookupValue walks a configuration value following the mapstructure key names
+func lookupValue(v reflect.Value, parts []string, key string) (reflect.Value, error) {
+	if len(parts) == 0 || parts[0] == "" {
+		return v, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		index, ok := fieldIndex(v.Type(), parts[0])
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("unknown configuration key: %s", key)
+		}
+		return lookupValue(v.FieldByIndex(index), parts[1:], key)
+	case reflect.Map:
+		entry := v.MapIndex(reflect.ValueOf(parts[0]))
+		if !entry.IsValid() {
+			return reflect.Value{}, fmt.Errorf("configuration key not set: %s", key)
+		}
+		return lookupValue(entry, parts[1:], key)
+	default:
+		return reflect.Value{}, fmt.Errorf("unknown configuration key: %s", key)
+	}
+}
+
+// lookupType walks the configuration types following the mapstructure key names
+func lookupType(t reflect.Type, parts []string, key string) (reflect.Type, error) {
+	if len(parts) == 0 || parts[0] == "" {
+		return t, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		index, ok := fieldIndex(t, parts[0])
+		if !ok {
+			return nil, fmt.Errorf("unknown configuration key: %s", key)
+		}
+		return lookupType(t.FieldByIndex(index).Type, parts[1:], key)
+	case reflect.Map:
+		return lookupType(t.Elem(), parts[1:], key)
+	default:
+		return nil, fmt.Errorf("unknown configuration key: %s", key)
+	}
+}
+
+// fieldIndex finds the struct field whose mapstructure tag matches name,
+// descending into squashed embedded structs
+func fieldIndex(t reflect.Type, name string) ([]int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("mapstructure")
+		tagName := strings.Split(tag, ",")[0]
+
+		if strings.Contains(tag, "squash") && field.Type.Kind() == reflect.Struct {
+			if index, ok := fieldIndex(field.Type, name); ok {
+				return append([]int{i}, index...), true
+			}
+			continue
+		}
+
+		if tagName == name {
+			return []int{i}, true
+		}
+	}
+	return nil, false
+}
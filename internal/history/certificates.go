@@ -0,0 +1,52 @@
+package history
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// Certificate records one certificate issued by Vault, so a serial number
+// seen on a server can be traced back to where it was requested
+type Certificate struct {
+	Serial     uint64    `json:"serial"`
+	KeyID      string    `json:"key_id"`
+	User       string    `json:"user,omitempty"`
+	Host       string    `json:"host,omitempty"`
+	Principals []string  `json:"principals,omitempty"`
+	Role       string    `json:"role"`
+	Engine     string    `json:"engine"`
+	Issued     time.Time `json:"issued"`
+	Expires    time.Time `json:"expires,omitzero"`
+}
+
+// CertificateStore is a JSON file of issued certificates, oldest first
+type CertificateStore struct {
+	path       string
+	maxEntries int
+}
+
+// NewCertificateStore creates a store backed by path that keeps at most
+// maxEntries certificates. A maxEntries of 0 keeps every certificate.
+func NewCertificateStore(path string, maxEntries int) *CertificateStore {
+	return &CertificateStore{
+		path:       path,
+		maxEntries: maxEntries,
+	}
+}
+
+// DefaultCertificatesPath returns the issued certificates file location inside stateDir
+func DefaultCertificatesPath(stateDir string) string {
+	return filepath.Join(stateDir, "certificates.json")
+}
+
+// Load returns all recorded certificates, oldest first
+func (s *CertificateStore) Load() ([]Certificate, error) {
+	return load[Certificate](s.path)
+}
+
+// Add records an issued certificate, dropping the oldest beyond the limit.
+// Concurrent signings, in this process or others, are serialized by the
+// lock on the file.
+func (s *CertificateStore) Add(cert Certificate) error {
+	return add(s.path, s.maxEntries, cert)
+}
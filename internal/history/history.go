@@ -0,0 +1,127 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vssh/internal/utils"
+)
+
+// Entry records one successful connection, or a failed connection to a
+// protected host that was confirmed
+type Entry struct {
+	Target   string        `json:"target"`
+	User     string        `json:"user"`
+	Host     string        `json:"host"`
+	Port     string        `json:"port,omitempty"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+
+	// ExitCode is vssh's exit code for the session, and CertSerial the
+	// serial number of the certificate used, if any
+	ExitCode   int    `json:"exit_code"`
+	CertSerial string `json:"cert_serial,omitempty"`
+
+	// Protected is set when the host was protected and its connection
+	// confirmed, with Reason if one was given
+	Protected bool   `json:"protected,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Store is a JSON file of connection history entries, oldest first
+type Store struct {
+	path       string
+	maxEntries int
+}
+
+// NewStore creates a store backed by path that keeps at most maxEntries
+// entries. A maxEntries of 0 keeps every entry.
+func NewStore(path string, maxEntries int) *Store {
+	return &Store{
+		path:       path,
+		maxEntries: maxEntries,
+	}
+}
+
+// DefaultPath returns the history file location inside stateDir
+func DefaultPath(stateDir string) string {
+	return filepath.Join(stateDir, "history.json")
+}
+
+// Load returns all recorded entries, oldest first. A missing file is an empty history.
+func (s *Store) Load() ([]Entry, error) {
+	return load[Entry](s.path)
+}
+
+// Add appends an entry, dropping the oldest entries beyond the limit
+func (s *Store) Add(entry Entry) error {
+	return add(s.path, s.maxEntries, entry)
+}
+
+// load reads the entries of a JSON history file, oldest first
+func load[T any](path string) ([]T, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history file: %w", err)
+	}
+
+	var entries []T
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error parsing history file %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// add appends an entry to a JSON history file, keeping at most maxEntries.
+// The file is locked while it is updated, so entries added by concurrent
+// vssh processes are all kept.
+func add[T any](path string, maxEntries int, entry T) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("error creating history directory: %w", err)
+	}
+	unlock, err := utils.AcquireLock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := load[T](path)
+	if err != nil {
+		return err
+	}
+
+	entries = append(entries, entry)
+	if maxEntries > 0 && len(entries) > maxEntries {
+		entries = entries[len(entries)-maxEntries:]
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding history: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated history
+	if err := utils.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("error writing history file: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns entry number n, counting from 1 for the oldest entry
+func (s *Store) Get(n int) (*Entry, error) {
+	entries, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 || n > len(entries) {
+		return nil, fmt.Errorf("no history entry %d", n)
+	}
+	return &entries[n-1], nil
+}
@@ -0,0 +1,24 @@
+// Package hooks runs the commands configured to run around SSH sessions.
+package hooks
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Run runs commands in order with the shell, adding env to vssh's
+// environment, and stops at the first that fails. Their output goes to out,
+// and they read nothing, so stdin stays with the session.
+func Run(commands []string, env []string, out io.Writer) error {
+	for _, command := range commands {
+		cmd := shellCommand(command)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout = out
+		cmd.Stderr = out
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("hook %q failed: %w", command, err)
+		}
+	}
+	return nil
+}
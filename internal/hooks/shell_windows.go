@@ -0,0 +1,10 @@
+//go:build windows
+
+package hooks
+
+import "os/exec"
+
+// shellCommand runs command with cmd.exe
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
@@ -0,0 +1,75 @@
+package inventory
+
+import (
+	"sort"
+	"strings"
+)
+
+// AnsibleHost is a host in an Ansible dynamic inventory
+type AnsibleHost struct {
+	Name   string
+	Groups []string
+	Vars   map[string]interface{}
+}
+
+// Ansible builds the JSON structure of an Ansible dynamic inventory
+// ("--list" output), including _meta.hostvars so Ansible doesn't call
+// the inventory once per host
+func Ansible(hosts []AnsibleHost) map[string]interface{} {
+	hostvars := map[string]interface{}{}
+	groups := map[string][]string{}
+	var all []string
+
+	for _, host := range hosts {
+		all = append(all, host.Name)
+		hostvars[host.Name] = host.Vars
+		for _, group := range host.Groups {
+			name := AnsibleGroupName(group)
+			groups[name] = append(groups[name], host.Name)
+		}
+	}
+
+	result := map[string]interface{}{
+		"_meta": map[string]interface{}{"hostvars": hostvars},
+		"all":   map[string]interface{}{"hosts": sortedUnique(all)},
+	}
+	for name, members := range groups {
+		result[name] = map[string]interface{}{"hosts": sortedUnique(members)}
+	}
+	return result
+}
+
+// AnsibleGroupName converts a group or tag name into a valid Ansible group
+// name by replacing characters other than letters, digits and _ with _
+func AnsibleGroupName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// TagGroups returns the tag_<key>_<value> group names for a host's tags
+func TagGroups(tags map[string]string) []string {
+	var groups []string
+	for key, value := range tags {
+		groups = append(groups, AnsibleGroupName("tag_"+key+"_"+value))
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// sortedUnique sorts values and removes duplicates
+func sortedUnique(values []string) []string {
+	sort.Strings(values)
+	result := values[:0]
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
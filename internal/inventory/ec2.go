@@ -0,0 +1,98 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"vssh/pkg/types"
+)
+
+// ec2Output is the subset of "aws ec2 describe-instances" output vssh uses
+type ec2Output struct {
+	Reservations []struct {
+		Instances []struct {
+			InstanceID       string `json:"InstanceId"`
+			PrivateIPAddress string `json:"PrivateIpAddress"`
+			PublicIPAddress  string `json:"PublicIpAddress"`
+			Tags             []struct {
+				Key   string `json:"Key"`
+				Value string `json:"Value"`
+			} `json:"Tags"`
+		} `json:"Instances"`
+	} `json:"Reservations"`
+}
+
+// LoadEC2 lists running EC2 instances with the AWS CLI, using its usual
+// credential chain. Tag filters from the configuration are applied by EC2.
+func LoadEC2(config types.EC2InventoryConfig) ([]Host, error) {
+	args := []string{"ec2", "describe-instances", "--output", "json",
+		"--filters", "Name=instance-state-name,Values=running"}
+	for key, value := range config.Filters {
+		args = append(args, fmt.Sprintf("Name=tag:%s,Values=%s", key, value))
+	}
+	if config.Region != "" {
+		args = append(args, "--region", config.Region)
+	}
+	if config.Profile != "" {
+		args = append(args, "--profile", config.Profile)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("aws", args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("aws ec2 describe-instances failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	hosts, err := ParseEC2Instances(output, config.AddressType)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hosts {
+		hosts[i].User = config.User
+		hosts[i].Role = config.Role
+	}
+	return hosts, nil
+}
+
+// ParseEC2Instances converts describe-instances JSON into hosts. Hosts are
+// named by their Name tag, falling back to the instance ID, and addressed by
+// their private IP unless addressType is "public". Instances without an
+// address of the requested type are skipped.
+func ParseEC2Instances(data []byte, addressType string) ([]Host, error) {
+	var output ec2Output
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse EC2 instances: %w", err)
+	}
+
+	var hosts []Host
+	for _, reservation := range output.Reservations {
+		for _, instance := range reservation.Instances {
+			host := Host{
+				Name:    instance.InstanceID,
+				Address: instance.PrivateIPAddress,
+				Tags:    map[string]string{"InstanceId": instance.InstanceID},
+			}
+			if addressType == "public" {
+				host.Address = instance.PublicIPAddress
+			}
+			if host.Address == "" {
+				continue
+			}
+
+			for _, tag := range instance.Tags {
+				host.Tags[tag.Key] = tag.Value
+				if tag.Key == "Name" && tag.Value != "" {
+					host.Name = tag.Value
+				}
+			}
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
@@ -0,0 +1,135 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"vssh/pkg/types"
+)
+
+// Host is an inventory entry describing how to reach a host
+type Host struct {
+	Name    string            `json:"name"`
+	Address string            `json:"address"`
+	User    string            `json:"user,omitempty"`
+	Role    string            `json:"role,omitempty"`
+	Tags    map[string]string `json:"tags,omitempty"`
+}
+
+// Inventory is the set of hosts loaded from the configured sources
+type Inventory struct {
+	Hosts []Host
+}
+
+// Find returns the host with the given name, or nil if there is none.
+// Names are matched case-insensitively.
+func (inv *Inventory) Find(name string) *Host {
+	for i := range inv.Hosts {
+		if strings.EqualFold(inv.Hosts[i].Name, name) {
+			return &inv.Hosts[i]
+		}
+	}
+	return nil
+}
+
+// Select returns the hosts carrying every tag in selector
+func (inv *Inventory) Select(selector map[string]string) []Host {
+	var hosts []Host
+	for _, host := range inv.Hosts {
+		if host.HasTags(selector) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// HostConfigs returns hosts configuration entries applying each inventory
+// host's user and role to its address
+func (inv *Inventory) HostConfigs() []types.HostConfig {
+	var configs []types.HostConfig
+	for _, host := range inv.Hosts {
+		if host.User == "" && host.Role == "" {
+			continue
+		}
+		configs = append(configs, types.HostConfig{
+			Pattern:      host.Address,
+			HostSettings: types.HostSettings{User: host.User, Role: host.Role},
+		})
+	}
+	return configs
+}
+
+// HasTags reports whether the host has every tag in selector. Tag keys are
+// case-insensitive and values must match exactly.
+func (h Host) HasTags(selector map[string]string) bool {
+	for key, want := range selector {
+		found := false
+		for tagKey, value := range h.Tags {
+			if strings.EqualFold(tagKey, key) && value == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTagSelector parses a comma-separated list of key=value tags
+func ParseTagSelector(s string) (map[string]string, error) {
+	selector := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag selector %q, expected key=value", pair)
+		}
+		selector[key] = value
+	}
+	if len(selector) == 0 {
+		return nil, fmt.Errorf("empty tag selector")
+	}
+	return selector, nil
+}
+
+// DecodeHosts converts secret data mapping host names to host objects into
+// hosts. Values may be objects or JSON-encoded strings, as written by
+// "vault kv put" from a JSON file or from key=value arguments respectively.
+func DecodeHosts(data map[string]interface{}) ([]Host, error) {
+	var hosts []Host
+	for name, value := range data {
+		var raw []byte
+		switch v := value.(type) {
+		case string:
+			raw = []byte(v)
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid inventory entry %s: %w", name, err)
+			}
+			raw = encoded
+		}
+
+		var host Host
+		if err := json.Unmarshal(raw, &host); err != nil {
+			return nil, fmt.Errorf("invalid inventory entry %s: %w", name, err)
+		}
+		host.Name = name
+		if host.Address == "" {
+			host.Address = name
+		}
+		hosts = append(hosts, host)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Name < hosts[j].Name
+	})
+	return hosts, nil
+}
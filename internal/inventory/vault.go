@@ -0,0 +1,17 @@
+package inventory
+
+import (
+	"fmt"
+
+	"vssh/internal/vault"
+)
+
+// LoadVaultKV loads hosts from a Vault KV secret whose keys are host names
+// and whose values describe each host
+func LoadVaultKV(client *vault.Client, path string) ([]Host, error) {
+	data, err := client.ReadKV(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load inventory: %w", err)
+	}
+	return DecodeHosts(data)
+}
@@ -0,0 +1,169 @@
+// Package metrics keeps counts and latencies of Vault logins, certificate
+// signing and connections in a local file, and writes them in the Prometheus
+// text format.
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vssh/internal/utils"
+)
+
+// Buckets are the upper bounds in seconds of the latency histograms
+var Buckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics are the totals recorded since Since
+type Metrics struct {
+	Since       time.Time `json:"since"`
+	Auth        Latency   `json:"auth"`
+	Signing     Latency   `json:"signing"`
+	Connections Counter   `json:"connections"`
+}
+
+// Latency counts requests to Vault and how long they took, failed ones
+// included
+type Latency struct {
+	Count    uint64  `json:"count"`
+	Failures uint64  `json:"failures"`
+	Seconds  float64 `json:"seconds"`
+	Max      float64 `json:"max"`
+
+	// Buckets counts the requests taking at most each of Buckets, and not
+	// the one before it
+	Buckets []uint64 `json:"buckets"`
+}
+
+// Counter counts events and how many of them failed
+type Counter struct {
+	Count    uint64 `json:"count"`
+	Failures uint64 `json:"failures"`
+}
+
+// Observe records a request that took d, failed if err is set
+func (l *Latency) Observe(d time.Duration, err error) {
+	seconds := d.Seconds()
+	l.Count++
+	if err != nil {
+		l.Failures++
+	}
+	l.Seconds += seconds
+	l.Max = max(l.Max, seconds)
+
+	if len(l.Buckets) != len(Buckets) {
+		l.Buckets = make([]uint64, len(Buckets))
+	}
+	for i, bound := range Buckets {
+		if seconds <= bound {
+			l.Buckets[i]++
+			break
+		}
+	}
+}
+
+// Average returns the mean latency, 0 without requests
+func (l *Latency) Average() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+	return time.Duration(l.Seconds / float64(l.Count) * float64(time.Second))
+}
+
+// Add counts an event, failed if err is set
+func (c *Counter) Add(err error) {
+	c.Count++
+	if err != nil {
+		c.Failures++
+	}
+}
+
+// Store is a JSON file of metrics
+type Store struct {
+	path string
+}
+
+// NewStore creates a store backed by path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// DefaultPath returns the metrics file location inside stateDir
+func DefaultPath(stateDir string) string {
+	return filepath.Join(stateDir, "metrics.json")
+}
+
+// Load returns the recorded metrics. A missing file has no metrics since now.
+func (s *Store) Load() (*Metrics, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return &Metrics{Since: time.Now()}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading metrics file: %w", err)
+	}
+
+	var m Metrics
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing metrics file %s: %w", s.path, err)
+	}
+	return &m, nil
+}
+
+// Update changes the recorded metrics with update and saves them. The file
+// is locked meanwhile, so no update by a concurrent vssh process is lost.
+func (s *Store) Update(update func(m *Metrics)) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := s.Load()
+	if err != nil {
+		return err
+	}
+	update(m)
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding metrics: %w", err)
+	}
+	return writeFile(s.path, data, 0600)
+}
+
+// Reset removes the recorded metrics
+func (s *Store) Reset() error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing metrics file: %w", err)
+	}
+	return nil
+}
+
+// lock takes the lock on the metrics file, creating its directory if needed
+func (s *Store) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return nil, fmt.Errorf("error creating metrics directory: %w", err)
+	}
+	return utils.AcquireLock(s.path + ".lock")
+}
+
+// writeFile replaces the file at path with data, through a temporary file so
+// readers never see it half written
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("error creating metrics directory: %w", err)
+	}
+	if err := utils.WriteFileAtomic(path, data, perm); err != nil {
+		return fmt.Errorf("error writing metrics file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// WritePrometheus writes m in the Prometheus text exposition format
+func WritePrometheus(w io.Writer, m *Metrics) error {
+	var b bytes.Buffer
+	writeHistogram(&b, "vssh_auth_duration_seconds", "Time spent in Vault checking the token and logging in.", &m.Auth)
+	writeCounter(&b, "vssh_auth_failures_total", "Vault authentications that failed.", m.Auth.Failures)
+	writeHistogram(&b, "vssh_signing_duration_seconds", "Time spent in Vault signing certificates.", &m.Signing)
+	writeCounter(&b, "vssh_signing_failures_total", "Certificate signing requests that failed.", m.Signing.Failures)
+	writeCounter(&b, "vssh_connections_total", "Connections made.", m.Connections.Count)
+	writeCounter(&b, "vssh_connection_failures_total", "Connections that could not be established.", m.Connections.Failures)
+	fmt.Fprintf(&b, "# HELP vssh_metrics_since_seconds Time the metrics were first recorded, in seconds since the epoch.\n")
+	fmt.Fprintf(&b, "# TYPE vssh_metrics_since_seconds gauge\n")
+	fmt.Fprintf(&b, "vssh_metrics_since_seconds %d\n", m.Since.Unix())
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeCounter writes a counter metric
+func writeCounter(b *bytes.Buffer, name, help string, value uint64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+	fmt.Fprintf(b, "%s %d\n", name, value)
+}
+
+// writeHistogram writes a latency as a histogram metric with cumulative
+// buckets
+func writeHistogram(b *bytes.Buffer, name, help string, l *Latency) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s histogram\n", name)
+	var cumulative uint64
+	for i, bound := range Buckets {
+		if i < len(l.Buckets) {
+			cumulative += l.Buckets[i]
+		}
+		fmt.Fprintf(b, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, l.Count)
+	fmt.Fprintf(b, "%s_sum %s\n", name, strconv.FormatFloat(l.Seconds, 'g', -1, 64))
+	fmt.Fprintf(b, "%s_count %d\n", name, l.Count)
+}
+
+// WriteTextfile writes the metrics in store to path in the Prometheus text
+// format, for node_exporter's textfile collector. The file is readable by
+// everyone so the collector can read it.
+func WriteTextfile(path string, store *Store) error {
+	m, err := store.Load()
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := WritePrometheus(&b, m); err != nil {
+		return err
+	}
+	return writeFile(path, b.Bytes(), 0644)
+}
+
+// Handler serves the metrics in store in the Prometheus text format
+func Handler(store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m, err := store.Load()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(w, m)
+	})
+}
@@ -0,0 +1,127 @@
+// Package recording records the output of terminal sessions as asciicast v2
+// files, which asciinema can play back.
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// header is the first line of an asciicast v2 file
+type header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes a session's output to an asciicast file as it happens. It
+// never fails a write, so a full disk doesn't break the session it records;
+// the first error is returned by Close instead.
+type Recorder struct {
+	path  string
+	start time.Time
+
+	mu      sync.Mutex
+	file    *os.File
+	pending []byte
+	err     error
+}
+
+// Create starts a recording at path of a terminal width by height, creating
+// its directory if needed. Only the owner can read the recording.
+func Create(path string, width, height int, title string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("error creating recording directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error creating recording: %w", err)
+	}
+
+	r := &Recorder{path: path, start: time.Now(), file: file}
+	line, _ := json.Marshal(header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: r.start.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	})
+	if _, err := fmt.Fprintf(file, "%s\n", line); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error writing recording: %w", err)
+	}
+	return r, nil
+}
+
+// Path returns where the recording is written
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Write records p as output at the time it is written. A multi-byte
+// character split across writes is recorded once it is complete.
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data := append(r.pending, p...)
+	cut := completeUTF8(data)
+	r.pending = append([]byte(nil), data[cut:]...)
+	r.event(data[:cut])
+	return len(p), nil
+}
+
+// event appends an output event, remembering the first error
+func (r *Recorder) event(data []byte) {
+	if len(data) == 0 || r.err != nil {
+		return
+	}
+	elapsed := math.Round(time.Since(r.start).Seconds()*1e6) / 1e6
+	line, _ := json.Marshal([]interface{}{elapsed, "o", string(data)})
+	if _, err := fmt.Fprintf(r.file, "%s\n", line); err != nil {
+		r.err = fmt.Errorf("error writing recording: %w", err)
+	}
+}
+
+// Close records any incomplete character left and closes the recording
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.event(r.pending)
+	r.pending = nil
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("error writing recording: %w", err)
+	}
+	return r.err
+}
+
+// completeUTF8 returns the length of data without an incomplete UTF-8
+// character at its end
+func completeUTF8(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
+
+// DefaultPath returns the path of a recording of a session with user@host
+// started at started in dir
+func DefaultPath(dir, user, host string, started time.Time) string {
+	return filepath.Join(dir, fmt.Sprintf("%s-%s@%s.cast", started.Format("20060102-150405"), user, host))
+}
@@ -0,0 +1,265 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ConnectAgent connects to the running ssh-agent: the SSH_AUTH_SOCK socket,
+// or on Windows the OpenSSH Authentication Agent's named pipe. The returned
+// function closes the connection.
+func ConnectAgent() (agent.ExtendedAgent, func() error, error) {
+	conn, err := dialAgent()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+	return agent.NewClient(conn), conn.Close, nil
+}
+
+// agentComment marks the identities vssh adds to the agent
+const agentComment = "vssh"
+
+// agentIdentity returns the comment of the agent identity holding the
+// certificate that would otherwise be stored at certPath
+func agentIdentity(certPath string) string {
+	return agentComment + " " + strings.TrimSuffix(filepath.Base(certPath), ".pub")
+}
+
+// AddCertificateToAgent loads a private key and the certificate signed for it
+// into the ssh-agent, which removes them when the certificate expires. A
+// certificate already in the agent isn't added again, so the passphrase of an
+// encrypted key is only asked for once per certificate. Expired and
+// superseded vssh certificates are removed from the agent on the way.
+func AddCertificateToAgent(keyPath, certPath string) error {
+	if keyPath == "" {
+		return fmt.Errorf("keys on a PKCS#11 token can't be added to ssh-agent by vssh, use ssh-add -s instead")
+	}
+
+	cert, err := readCertificate(certPath)
+	if err != nil {
+		return err
+	}
+
+	keyring, closeAgent, err := ConnectAgent()
+	if err != nil {
+		return err
+	}
+	defer closeAgent()
+
+	keys, err := keyring.List()
+	if err != nil {
+		return fmt.Errorf("failed to list ssh-agent identities: %w", err)
+	}
+	for _, key := range keys {
+		if bytes.Equal(key.Blob, cert.Marshal()) {
+			PruneAgent(keyring)
+			return nil
+		}
+	}
+
+	privateKey, err := loadPrivateKey(keyPath)
+	if err != nil {
+		return err
+	}
+	if err := addToKeyring(keyring, privateKey, cert, agentIdentity(certPath)); err != nil {
+		return err
+	}
+	PruneAgent(keyring)
+	return nil
+}
+
+// EnsureAgentCertificate ensures the ssh-agent holds a valid certificate for
+// the target's user without writing anything to disk. A certificate vssh
+// added earlier is reused; otherwise the public key is signed, or with
+// ssh.ephemeral_key a key generated in memory for this session, and the
+// certificate is added to the agent with its private key. Expired and
+// superseded vssh certificates are removed from the agent on the way.
+func (s *Signer) EnsureAgentCertificate(target *SSHTarget) error {
+	// The agent identity depends on the key, so generate it first
+	if !s.config.SSH.EphemeralKey {
+		if err := s.ensureKeyPair(target); err != nil {
+			return err
+		}
+	}
+
+	plan, err := s.PlanCertificate(target)
+	if err != nil {
+		return err
+	}
+	identity := agentIdentity(plan.Path)
+
+	keyring, closeAgent, err := ConnectAgent()
+	if err != nil {
+		return err
+	}
+	defer closeAgent()
+
+	keys, err := keyring.List()
+	if err != nil {
+		return fmt.Errorf("failed to list ssh-agent identities: %w", err)
+	}
+	for _, key := range keys {
+		if key.Comment != identity || target.TTL > 0 {
+			continue
+		}
+		if cert, ok := parseAgentCertificate(key); ok && s.certificateUsable(cert) && s.matchesPlan(cert, plan) {
+			s.logger.Debugf("Using valid certificate in ssh-agent: %s", identity)
+			s.pruneAgent(keyring)
+			return nil
+		}
+	}
+
+	s.logger.Infof("Generating new SSH certificate for user %s with role %s", target.Username, plan.Role)
+
+	var privateKey interface{}
+	var publicKey string
+	if s.config.SSH.EphemeralKey {
+		publicEd25519, privateEd25519, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+		sshPublicKey, err := gossh.NewPublicKey(publicEd25519)
+		if err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+		privateKey = privateEd25519
+		publicKey = string(gossh.MarshalAuthorizedKey(sshPublicKey))
+	} else {
+		publicKeyData, err := os.ReadFile(plan.PublicKeyPath)
+		if err != nil {
+			return fmt.Errorf("public key not found: %s. Run vssh keygen to generate a key pair", plan.PublicKeyPath)
+		}
+		privateKey, err = loadPrivateKey(strings.TrimSuffix(plan.PublicKeyPath, ".pub"))
+		if err != nil {
+			return err
+		}
+		publicKey = string(publicKeyData)
+	}
+
+	if plan.KeyID, err = s.KeyID(target, plan); err != nil {
+		return err
+	}
+	signedCert, err := s.SignPublicKey(plan, publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign SSH key: %w", err)
+	}
+	parsed, _, _, _, err := gossh.ParseAuthorizedKey([]byte(signedCert))
+	if err != nil {
+		return fmt.Errorf("failed to parse signed certificate: %w", err)
+	}
+	cert, ok := parsed.(*gossh.Certificate)
+	if !ok {
+		return fmt.Errorf("Vault returned a public key instead of a certificate")
+	}
+	s.issued(target, plan, signedCert)
+	if err := s.checkLoginPrincipal(target, signedCert); err != nil {
+		return err
+	}
+
+	if err := addToKeyring(keyring, privateKey, cert, identity); err != nil {
+		return err
+	}
+	s.logger.Infof("SSH certificate added to ssh-agent: %s", identity)
+	s.pruneAgent(keyring)
+	return nil
+}
+
+// pruneAgent removes stale vssh certificates from the agent, logging rather
+// than failing the connection when that isn't possible
+func (s *Signer) pruneAgent(keyring agent.Agent) {
+	removed, err := PruneAgent(keyring)
+	if err != nil {
+		s.logger.Debugf("Failed to remove stale certificates from ssh-agent: %v", err)
+	}
+	for _, identity := range removed {
+		s.logger.Debugf("Removed stale certificate from ssh-agent: %s", identity)
+	}
+}
+
+// PruneAgent removes the certificates vssh added to the agent that have
+// expired or were superseded by a newer certificate for the same user, role
+// and engine, so they don't count against the server's authentication
+// attempts. It returns the comments of the removed identities.
+func PruneAgent(keyring agent.Agent) ([]string, error) {
+	keys, err := keyring.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ssh-agent identities: %w", err)
+	}
+
+	// The newest certificate of each identity is kept
+	newest := make(map[string]uint64)
+	for _, key := range keys {
+		if cert, ok := vsshCertificate(key); ok && cert.ValidBefore > newest[key.Comment] {
+			newest[key.Comment] = cert.ValidBefore
+		}
+	}
+
+	now := uint64(time.Now().Unix())
+	var removed []string
+	for _, key := range keys {
+		cert, ok := vsshCertificate(key)
+		if !ok {
+			continue
+		}
+		expired := cert.ValidBefore != gossh.CertTimeInfinity && now >= cert.ValidBefore
+		if !expired && cert.ValidBefore == newest[key.Comment] {
+			continue
+		}
+		if err := keyring.Remove(key); err != nil {
+			return removed, fmt.Errorf("failed to remove %s from ssh-agent: %w", key.Comment, err)
+		}
+		removed = append(removed, key.Comment)
+	}
+	return removed, nil
+}
+
+// vsshCertificate returns the certificate of an agent identity added by vssh
+func vsshCertificate(key *agent.Key) (*gossh.Certificate, bool) {
+	if !strings.HasPrefix(key.Comment, agentComment+" ") {
+		return nil, false
+	}
+	return parseAgentCertificate(key)
+}
+
+// parseAgentCertificate returns the certificate of an agent identity
+func parseAgentCertificate(key *agent.Key) (*gossh.Certificate, bool) {
+	publicKey, err := gossh.ParsePublicKey(key.Blob)
+	if err != nil {
+		return nil, false
+	}
+	cert, ok := publicKey.(*gossh.Certificate)
+	return cert, ok
+}
+
+// addToKeyring adds a certificate and its private key to the agent until the
+// certificate expires
+func addToKeyring(keyring agent.Agent, privateKey interface{}, cert *gossh.Certificate, comment string) error {
+	var lifetime uint32
+	if cert.ValidBefore != gossh.CertTimeInfinity {
+		remaining := time.Until(time.Unix(int64(cert.ValidBefore), 0))
+		if remaining <= 0 {
+			return fmt.Errorf("certificate %s has expired", comment)
+		}
+		lifetime = uint32(remaining.Seconds())
+	}
+
+	err := keyring.Add(agent.AddedKey{
+		PrivateKey:   privateKey,
+		Certificate:  cert,
+		Comment:      comment,
+		LifetimeSecs: lifetime,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add certificate to ssh-agent: %w", err)
+	}
+	return nil
+}
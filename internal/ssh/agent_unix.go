@@ -0,0 +1,19 @@
+//go:build !windows
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+// dialAgent connects to the agent socket named by SSH_AUTH_SOCK
+func dialAgent() (io.ReadWriteCloser, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("SSH_AUTH_SOCK is not set, is ssh-agent running?")
+	}
+	return net.Dial("unix", socket)
+}
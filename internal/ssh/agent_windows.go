@@ -0,0 +1,31 @@
+//go:build windows
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// windowsAgentPipe is the named pipe of the OpenSSH Authentication Agent service
+const windowsAgentPipe = `\\.\pipe\openssh-ssh-agent`
+
+// dialAgent opens the OpenSSH agent's named pipe. SSH_AUTH_SOCK is only used
+// when it names another pipe, since Windows OpenSSH doesn't listen on sockets.
+func dialAgent() (io.ReadWriteCloser, error) {
+	pipe := windowsAgentPipe
+	if socket := os.Getenv("SSH_AUTH_SOCK"); strings.HasPrefix(socket, `\\.\pipe\`) {
+		pipe = socket
+	}
+
+	conn, err := os.OpenFile(pipe, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s not found, is the OpenSSH Authentication Agent service running?", pipe)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,132 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpenSSH client options, split by whether they take an argument. Options
+// without a dedicated SSHOptions field are passed through to ssh unchanged.
+const (
+	sshFlagOptions  = "1246AaCfGgKkMNnqsTtVvXxYy"
+	sshValueOptions = "BbcDEeFIiJLlmOopQRSWw"
+)
+
+// ParseSSHArgs parses an ssh command line of the form
+//
+//	[options] destination [options] [command [argument ...]]
+//
+// Like OpenSSH, options are recognized both before the destination and
+// directly after it, and "--" ends option parsing. It returns the parsed
+// options and the remaining arguments: the destination followed by the remote
+// command. -d is accepted as vssh's debug flag.
+func ParseSSHArgs(args []string) (*SSHOptions, []string, error) {
+	options := &SSHOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// Options end at "--", or at the first argument after the destination
+		// that isn't an option, which starts the remote command
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			if len(positional) > 1 {
+				positional = append(positional, args[i+1:]...)
+				break
+			}
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			return nil, nil, fmt.Errorf("unknown option %s", arg)
+		}
+
+		// Options may be grouped (-4vA) and values attached (-p2222)
+		for j := 1; j < len(arg); j++ {
+			option := arg[j]
+
+			if option == 'd' || strings.IndexByte(sshFlagOptions, option) >= 0 {
+				options.setFlag(option)
+				continue
+			}
+			if strings.IndexByte(sshValueOptions, option) < 0 {
+				return nil, nil, fmt.Errorf("unknown option -%c", option)
+			}
+
+			value := arg[j+1:]
+			if value == "" {
+				if i+1 >= len(args) {
+					return nil, nil, fmt.Errorf("option -%c requires an argument", option)
+				}
+				i++
+				value = args[i]
+			}
+			if err := options.setValue(option, value); err != nil {
+				return nil, nil, err
+			}
+			break
+		}
+	}
+
+	return options, positional, nil
+}
+
+// setFlag records an option that takes no argument
+func (o *SSHOptions) setFlag(option byte) {
+	switch option {
+	case '4':
+		o.IPv4 = true
+	case '6':
+		o.IPv6 = true
+	case 'v':
+		o.Verbose++
+	case 'd':
+		o.Debug = true
+	case 'A', 'a':
+		forward := option == 'A'
+		o.ForwardAgent = &forward
+	case 'f':
+		o.Background = true
+	case 'N':
+		o.NoCommand = true
+	case 'C':
+		o.Compression = true
+	case 'q':
+		o.Quiet = true
+	case 't':
+		if o.TTY < 0 {
+			o.TTY = 0
+		}
+		o.TTY++
+	case 'T':
+		o.TTY = -1
+	case 'X', 'Y', 'x':
+		o.ForwardX11 = "-" + string(option)
+	default:
+		o.ExtraArgs = append(o.ExtraArgs, "-"+string(option))
+	}
+}
+
+// setValue records an option that takes an argument
+func (o *SSHOptions) setValue(option byte, value string) error {
+	switch option {
+	case 'p':
+		if !isPort(value) {
+			return fmt.Errorf("invalid port %q", value)
+		}
+		o.Port = value
+	case 'i':
+		o.IdentityFile = value
+	case 'l':
+		o.LoginName = value
+	case 'J':
+		o.ProxyJump = value
+	default:
+		o.ExtraArgs = append(o.ExtraArgs, "-"+string(option), value)
+	}
+	return nil
+}
@@ -1,11 +1,14 @@
 package ssh
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"vssh/pkg/types"
 
@@ -16,6 +19,7 @@ import (
 type Client struct {
 	config *types.Config
 	logger *logrus.Logger
+	hostCA *HostCA
 }
 
 // NewClient creates a new SSH client
@@ -31,21 +35,125 @@ type SSHOptions struct {
 	Port            string
 	IdentityFile    string
 	CertificateFile string
+	LoginName       string
 	IPv4            bool
 	IPv6            bool
-	Verbose         bool
+	Verbose         int // number of -v flags
 	Debug           bool
+	ForwardAgent    *bool  // -A or -a, nil to use the configured default
+	ForwardX11      string // -X, -Y or -x, "" to use the configured default
+	TTY             int    // number of -t flags forcing a pseudo-terminal, -1 for -T
+	Compression     bool
+	Quiet           bool
+	Background      bool   // -f: go to the background after authentication
+	NoCommand       bool   // -N: don't run a remote command, only forward ports
+	ProxyJump       string // -J jump host specification, overriding the configured one
+	Password        string // one-time password to log in with instead of a certificate
+	JumpHosts       []JumpHost
 	ExtraArgs       []string
 }
 
-// Connect executes SSH connection with the signed certificate
+// backgroundWaitDelay is how long to wait for output from ssh after it went
+// to the background
+const backgroundWaitDelay = time.Second
+
+// ExitError reports that ssh exited with a non-zero status, which is the
+// remote command's exit status or 255 if the connection failed
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	if e.Code == 255 {
+		return "SSH connection failed with exit code 255"
+	}
+	return fmt.Sprintf("remote command exited with code %d", e.Code)
+}
+
+// LaunchError reports that a program, such as ssh, couldn't be started
+type LaunchError struct {
+	Program string
+	Err     error
+}
+
+func (e *LaunchError) Error() string {
+	return fmt.Sprintf("failed to execute %s: %v", e.Program, e.Err)
+}
+
+func (e *LaunchError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit status vssh should exit with for an error from
+// Connect or Execute: the remote command's exit status, or 255 like ssh for
+// anything that kept the command from running
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 255
+}
+
+// Connect executes SSH connection with the signed certificate, attached to the
+// terminal. A non-zero exit status is returned as an *ExitError.
 func (c *Client) Connect(target *SSHTarget, certPath string, options *SSHOptions, command []string) error {
+	return c.Execute(target, certPath, options, command, os.Stdin, os.Stdout, os.Stderr)
+}
+
+// Execute runs ssh with the signed certificate using the given standard streams.
+// A nil stdin reads from the null device.
+func (c *Client) Execute(target *SSHTarget, certPath string, options *SSHOptions, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if c.useNative() {
+		return c.executeNative(target, certPath, options, command, stdin, stdout, stderr)
+	}
+	if err := c.probeHostCA(target, options); err != nil {
+		return err
+	}
+
+	cmd := c.Command(target, certPath, options, command)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	// With -f ssh forks into the background after authenticating, as does a
+	// new control master with ControlPersist, and the background process keeps
+	// any output pipes open. Stop waiting for them shortly after the foreground
+	// ssh process exits.
+	background := options.Background || multiplexingEnabled(c.config)
+	if background {
+		cmd.WaitDelay = backgroundWaitDelay
+	}
+
+	// Execute the command
+	if err := runForwardingSignals(cmd); err != nil {
+		if background && errors.Is(err, exec.ErrWaitDelay) {
+			return nil
+		}
+		if exitError, ok := err.(*exec.ExitError); ok {
+			// SSH command failed, return the exit code
+			return &ExitError{Code: exitError.ExitCode()}
+		}
+		return &LaunchError{Program: "ssh", Err: err}
+	}
+
+	return nil
+}
+
+// Command returns the ssh command that connects to the target with the signed
+// certificate, without starting it
+func (c *Client) Command(target *SSHTarget, certPath string, options *SSHOptions, command []string) *exec.Cmd {
 	// Build SSH command arguments
 	args := []string{}
 
-	// Add port if specified
+	// Add port if specified, falling back to the port resolved for the target
 	if options.Port != "" {
 		args = append(args, "-p", options.Port)
+	} else if target.Port != "" {
+		args = append(args, "-p", target.Port)
 	}
 
 	// Add certificate file
@@ -57,6 +165,7 @@ func (c *Client) Connect(target *SSHTarget, certPath string, options *SSHOptions
 	if options.IdentityFile != "" {
 		args = append(args, "-i", options.IdentityFile)
 	}
+	args = append(args, KeyProviderArgs(c.config)...)
 
 	// Add IP version flags
 	if options.IPv4 {
@@ -67,69 +176,215 @@ func (c *Client) Connect(target *SSHTarget, certPath string, options *SSHOptions
 	}
 
 	// Add verbose/debug flags
-	if options.Verbose {
-		args = append(args, "-v")
-	}
 	if options.Debug {
 		args = append(args, "-vvv")
+	} else if options.Verbose > 0 {
+		args = append(args, "-"+strings.Repeat("v", options.Verbose))
+	}
+
+	// Add compression and quiet flags
+	if options.Compression {
+		args = append(args, "-C")
+	}
+	if options.Quiet {
+		args = append(args, "-q")
+	}
+
+	// Add background and no-command flags
+	if options.Background {
+		args = append(args, "-f")
+	}
+	if options.NoCommand {
+		args = append(args, "-N")
+	}
+
+	// Force or disable pseudo-terminal allocation
+	if options.TTY < 0 {
+		args = append(args, "-T")
+	} else if options.TTY > 0 {
+		args = append(args, "-"+strings.Repeat("t", options.TTY))
+	}
+
+	// Forward the agent if requested with -A or configured, unless disabled with -a
+	if options.ForwardAgent != nil {
+		if *options.ForwardAgent {
+			args = append(args, "-A")
+		} else {
+			args = append(args, "-a")
+		}
+	} else if c.config.SSH.ForwardAgent {
+		args = append(args, "-A")
 	}
 
-	// Add extra SSH options for certificate-based authentication
-	args = append(args, "-o", "PreferredAuthentications=publickey")
-	args = append(args, "-o", "PubkeyAuthentication=yes")
+	// Forward X11 as requested with -X/-Y/-x or configured
+	if options.ForwardX11 != "" {
+		args = append(args, options.ForwardX11)
+	} else if c.config.SSH.ForwardX11 && c.config.SSH.ForwardX11Trusted {
+		args = append(args, "-Y")
+	} else if c.config.SSH.ForwardX11 {
+		args = append(args, "-X")
+	}
 
-	// Add any extra arguments
+	// Add any extra arguments before the options from the configuration:
+	// ssh uses the first value given for an option, so the user's win
 	args = append(args, options.ExtraArgs...)
 
-	// Add the target (user@hostname)
-	sshTarget := fmt.Sprintf("%s@%s", target.Username, target.Hostname)
-	args = append(args, sshTarget)
+	// Apply host key checking settings
+	args = append(args, c.hostKeyArgs(target)...)
+
+	// Share one connection per host through a control master
+	if multiplexingEnabled(c.config) {
+		muxArgs, err := multiplexingArgs(c.config)
+		if err != nil {
+			c.logger.Warnf("Connection multiplexing disabled: %v", err)
+		}
+		args = append(args, muxArgs...)
+	}
+
+	// Reach the target through jump hosts, using certificates on every hop
+	if len(options.JumpHosts) > 0 {
+		args = append(args, "-o", fmt.Sprintf("ProxyCommand=%s", ProxyCommand(options.JumpHosts)))
+	}
+
+	// Add extra SSH options for certificate-based authentication, or for the
+	// one-time password, which can only be tried once
+	if options.Password != "" {
+		args = append(args, "-o", "PreferredAuthentications=keyboard-interactive,password")
+		args = append(args, "-o", "PubkeyAuthentication=no")
+		args = append(args, "-o", "NumberOfPasswordPrompts=1")
+	} else {
+		args = append(args, "-o", "PreferredAuthentications=publickey")
+		args = append(args, "-o", "PubkeyAuthentication=yes")
+	}
+
+	// Add the target (user@hostname). "--" keeps ssh from parsing a command
+	// starting with "-" as options.
+	sshTarget := fmt.Sprintf("%s@%s", target.Username, target.SSHHost())
+	args = append(args, "--", sshTarget)
 
 	// Add command if specified
 	if len(command) > 0 {
-		args = append(args, command...)
+		args = append(args, RemoteCommand(command))
 	}
 
 	c.logger.Debugf("Executing SSH command: ssh %s", strings.Join(args, " "))
 
-	// Execute SSH command
 	cmd := exec.Command("ssh", args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
 
 	// Set environment variables if needed
 	cmd.Env = os.Environ()
 
-	// Execute the command
-	if err := cmd.Run(); err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			// SSH command failed, return the exit code
-			return fmt.Errorf("SSH connection failed with exit code %d", exitError.ExitCode())
+	// ssh reads the one-time password from vssh running as its askpass program
+	if options.Password != "" {
+		env, err := askpassEnv(options.Password)
+		if err != nil {
+			c.logger.Warnf("%v, ssh will ask for the password", err)
 		}
-		return fmt.Errorf("failed to execute SSH command: %w", err)
+		cmd.Env = append(cmd.Env, env...)
 	}
 
-	return nil
+	return cmd
+}
+
+// hostKeySettings returns the StrictHostKeyChecking value and known hosts file
+// for the target, preferring the settings for its host over the global ones.
+// Empty values leave ssh's defaults.
+func (c *Client) hostKeySettings(target *SSHTarget) (string, string) {
+	hostSettings := c.config.ResolveHost(target.Hostname)
+	strictHostKeyChecking := hostSettings.StrictHostKeyChecking
+	if strictHostKeyChecking == "" {
+		strictHostKeyChecking = c.config.SSH.StrictHostKeyChecking
+	}
+	knownHostsFile := hostSettings.KnownHostsFile
+	if knownHostsFile == "" {
+		knownHostsFile = c.config.SSH.KnownHostsFile
+	}
+	if knownHostsFile != "" {
+		knownHostsFile = expandTilde(knownHostsFile)
+	}
+	return strictHostKeyChecking, knownHostsFile
 }
 
-// ParseSSHArgs parses SSH command line arguments and extracts options
-func ParseSSHArgs(args []string) (*SSHOptions, []string, error) {
-	options := &SSHOptions{}
-	var command []string
+// hostKeyArgs returns the host key checking options for the target
+func (c *Client) hostKeyArgs(target *SSHTarget) []string {
+	var args []string
 
-	// Simple implementation - just find the target and treat everything else as command
-	if len(args) == 0 {
-		return options, command, fmt.Errorf("no arguments provided")
+	strictHostKeyChecking, knownHostsFile := c.hostKeySettings(target)
+	if strictHostKeyChecking != "" {
+		args = append(args, "-o", "StrictHostKeyChecking="+strictHostKeyChecking)
 	}
+	if knownHostsFile != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+knownHostsFile)
+	}
+	return args
+}
 
-	// For now, assume first argument is always the target
-	// and everything after is the command
-	if len(args) > 1 {
-		command = args[1:]
+// KeyProviderArgs returns the ssh options that load keys through a PKCS#11
+// token or select the FIDO middleware for security keys, as configured
+func KeyProviderArgs(config *types.Config) []string {
+	var args []string
+	if config.SSH.PKCS11Provider != "" {
+		args = append(args, "-o", "PKCS11Provider="+expandTilde(config.SSH.PKCS11Provider))
+	}
+	if config.SSH.SecurityKeyProvider != "" {
+		args = append(args, "-o", "SecurityKeyProvider="+expandTilde(config.SSH.SecurityKeyProvider))
 	}
+	return args
+}
 
-	return options, command, nil
+// ConnectionArgs returns the ssh -o options that authenticate to the target
+// with the signed certificate, for tools that run ssh themselves (scp, sftp,
+// rsync). They cover the certificate, identity, host key checking and jump
+// hosts, but not the port, which every tool sets differently.
+func (c *Client) ConnectionArgs(target *SSHTarget, certPath string, options *SSHOptions) []string {
+	var args []string
+	if certPath != "" {
+		args = append(args, "-o", "CertificateFile="+certPath)
+	}
+	if options.IdentityFile != "" {
+		args = append(args, "-o", "IdentityFile="+options.IdentityFile)
+	}
+	args = append(args, KeyProviderArgs(c.config)...)
+	args = append(args, c.hostKeyArgs(target)...)
+	if len(options.JumpHosts) > 0 {
+		args = append(args, "-o", "ProxyCommand="+ProxyCommand(options.JumpHosts))
+	}
+	return append(args, "-o", "PreferredAuthentications=publickey", "-o", "PubkeyAuthentication=yes")
+}
+
+// RunTool runs a program that uses ssh, such as scp, attached to the terminal.
+// A non-zero exit status is returned as an *ExitError.
+func (c *Client) RunTool(program string, args []string) error {
+	c.logger.Debugf("Executing: %s %s", program, strings.Join(args, " "))
+
+	cmd := exec.Command(program, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := runForwardingSignals(cmd); err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return &ExitError{Code: exitError.ExitCode()}
+		}
+		return &LaunchError{Program: program, Err: err}
+	}
+	return nil
+}
+
+// RemoteCommand joins command into the command line the remote shell runs. A
+// single argument is a shell command line and is passed on unchanged, so pipes,
+// globs and quotes in it work remotely. Several arguments are the words of a
+// command, each quoted so the remote shell sees exactly the same words.
+func RemoteCommand(command []string) string {
+	if len(command) == 1 {
+		return command[0]
+	}
+
+	words := make([]string, len(command))
+	for i, word := range command {
+		words[i] = ShellQuote(word)
+	}
+	return strings.Join(words, " ")
 }
 
 // GetPrivateKeyPath returns the private key path for the certificate
@@ -154,11 +409,15 @@ func (c *Client) GetPrivateKeyPath(username string) (string, error) {
 	return keyPath, nil
 }
 
-// ValidateSSHBinary checks if SSH binary is available
+// ValidateSSHBinary checks if SSH binary is available. Without it, or with
+// ssh.native set, connections use the built-in SSH client instead.
 func (c *Client) ValidateSSHBinary() error {
-	_, err := exec.LookPath("ssh")
-	if err != nil {
-		return fmt.Errorf("SSH binary not found in PATH. Please install OpenSSH client")
+	if c.config.SSH.Native {
+		c.logger.Debugf("Using the built-in SSH client (ssh.native)")
+		return nil
+	}
+	if _, err := exec.LookPath("ssh"); err != nil {
+		c.logger.Infof("SSH binary not found in PATH, using the built-in SSH client")
 	}
 	return nil
 }
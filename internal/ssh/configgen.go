@@ -0,0 +1,166 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vssh/pkg/types"
+)
+
+// GenerateSSHConfig returns an OpenSSH client configuration with a Host block
+// for every hosts entry and group in the vssh configuration. Each block points
+// ssh at the identity and certificate vssh signs for the hosts, and builds a
+// ProxyCommand for their jump hosts. Hosts without a configured user are
+// connected to as currentUser.
+func GenerateSSHConfig(config *types.Config, signer *Signer, currentUser string) (string, error) {
+	var b strings.Builder
+	b.WriteString("# Generated by vssh setup ssh-config. Regenerate it instead of editing it.\n")
+	b.WriteString("# Certificates are signed and renewed by vssh; run any vssh command for a\n")
+	b.WriteString("# host (e.g. vssh print-command user@host) to renew an expired one.\n")
+
+	for _, host := range config.Hosts {
+		// vssh patterns are comma-separated, ssh_config patterns space-separated
+		var patterns []string
+		for _, pattern := range strings.Split(host.Pattern, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+		if err := writeHostBlock(&b, config, signer, currentUser, patterns, host.HostSettings); err != nil {
+			return "", fmt.Errorf("hosts entry %s: %w", host.Pattern, err)
+		}
+	}
+
+	for _, name := range config.GroupNames() {
+		group := config.Groups[name]
+		if err := writeHostBlock(&b, config, signer, currentUser, group.Hosts, group.HostSettings); err != nil {
+			return "", fmt.Errorf("group %s: %w", name, err)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// writeHostBlock writes the Host block for hosts sharing settings
+func writeHostBlock(b *strings.Builder, config *types.Config, signer *Signer, currentUser string, patterns []string, settings types.HostSettings) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	username := settings.User
+	if username == "" {
+		username = currentUser
+	}
+	target := &SSHTarget{Username: username}
+
+	keyPath, err := signer.GetPrivateKeyPath(target)
+	if err != nil {
+		return err
+	}
+	certPath := signer.GetCertificatePath(username, signer.RoleFor(username, settings), signer.SigningEngineFor(settings), keyPath)
+
+	fmt.Fprintf(b, "\nHost %s\n", strings.Join(patterns, " "))
+	fmt.Fprintf(b, "    User %s\n", username)
+	if keyPath != "" {
+		fmt.Fprintf(b, "    IdentityFile %s\n", quoteConfigValue(keyPath))
+	}
+	if config.SSH.PKCS11Provider != "" {
+		fmt.Fprintf(b, "    PKCS11Provider %s\n", quoteConfigValue(expandTilde(config.SSH.PKCS11Provider)))
+	}
+	if config.SSH.SecurityKeyProvider != "" {
+		fmt.Fprintf(b, "    SecurityKeyProvider %s\n", quoteConfigValue(expandTilde(config.SSH.SecurityKeyProvider)))
+	}
+	fmt.Fprintf(b, "    CertificateFile %s\n", quoteConfigValue(certPath))
+
+	if settings.ProxyJump != "" {
+		hops, err := ParseProxyJump(config, settings.ProxyJump)
+		if err != nil {
+			return fmt.Errorf("invalid proxy_jump: %w", err)
+		}
+
+		var jumpHosts []JumpHost
+		for _, hop := range hops {
+			plan, err := signer.PlanCertificate(hop)
+			if err != nil {
+				return err
+			}
+			hopKey, err := signer.GetPrivateKeyPath(hop)
+			if err != nil {
+				return err
+			}
+			jumpHosts = append(jumpHosts, JumpHost{Target: hop, CertificateFile: plan.Path, IdentityFile: hopKey, KeyOptions: KeyProviderArgs(config)})
+		}
+		fmt.Fprintf(b, "    ProxyCommand %s\n", ProxyCommand(jumpHosts))
+	}
+
+	if settings.StrictHostKeyChecking != "" {
+		fmt.Fprintf(b, "    StrictHostKeyChecking %s\n", settings.StrictHostKeyChecking)
+	}
+	if settings.KnownHostsFile != "" {
+		fmt.Fprintf(b, "    UserKnownHostsFile %s\n", quoteConfigValue(expandTilde(settings.KnownHostsFile)))
+	}
+	return nil
+}
+
+// quoteConfigValue quotes an ssh_config value containing spaces
+func quoteConfigValue(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// WriteSSHConfig writes a generated configuration to path and makes the ssh
+// config at sshConfigPath include it, creating that file if needed. It
+// reports whether the Include directive was added.
+func WriteSSHConfig(path, sshConfigPath, content string) (bool, error) {
+	// ssh expands ~ in Include itself, so the directive keeps path as given
+	fullPath := expandTilde(path)
+	sshConfigPath = expandTilde(sshConfigPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0700); err != nil {
+		return false, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0600); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	existing, err := os.ReadFile(sshConfigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s: %w", sshConfigPath, err)
+	}
+	if includesFile(existing, fullPath) {
+		return false, nil
+	}
+
+	// Include must come before any Host block, or it would only apply to it
+	include := "Include " + quoteConfigValue(path) + "\n"
+	if len(existing) > 0 {
+		include += "\n"
+	}
+	if err := os.MkdirAll(filepath.Dir(sshConfigPath), 0700); err != nil {
+		return false, fmt.Errorf("failed to create directory for %s: %w", sshConfigPath, err)
+	}
+	if err := os.WriteFile(sshConfigPath, append([]byte(include), existing...), 0600); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", sshConfigPath, err)
+	}
+	return true, nil
+}
+
+// includesFile reports whether an ssh config has an Include directive for path
+func includesFile(sshConfig []byte, path string) bool {
+	for _, line := range strings.Split(string(sshConfig), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "Include") {
+			continue
+		}
+		for _, included := range fields[1:] {
+			if expandTilde(strings.Trim(included, `"`)) == path {
+				return true
+			}
+		}
+	}
+	return false
+}
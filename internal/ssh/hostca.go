@@ -0,0 +1,136 @@
+package ssh
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"vssh/pkg/types"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// hostCertAlgorithms are the host certificate algorithms requested from
+// servers covered by the host CA, ahead of any plain key algorithms
+var hostCertAlgorithms = []string{
+	gossh.CertAlgoED25519v01,
+	gossh.CertAlgoECDSA256v01,
+	gossh.CertAlgoECDSA384v01,
+	gossh.CertAlgoECDSA521v01,
+	gossh.CertAlgoRSASHA512v01,
+	gossh.CertAlgoRSASHA256v01,
+}
+
+// errProbeDone ends a probe handshake once the host key has been seen
+var errProbeDone = errors.New("host key received")
+
+// HostCA verifies that hosts in its domains present host certificates signed
+// by the Vault host CA
+type HostCA struct {
+	Key     gossh.PublicKey
+	Domains []string
+	Enforce bool // refuse mismatches instead of warning about them
+}
+
+// NewHostCA parses the host CA public key for ssh.host_ca
+func NewHostCA(config *types.HostCAConfig, caKey string) (*HostCA, error) {
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(caKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host CA public key: %w", err)
+	}
+	return &HostCA{Key: key, Domains: config.Domains, Enforce: config.Verify == "enforce"}, nil
+}
+
+// SetHostCA makes the client verify host certificates against ca
+func (c *Client) SetHostCA(ca *HostCA) {
+	c.hostCA = ca
+}
+
+// Covers reports whether hostname is in one of the CA's domains
+func (ca *HostCA) Covers(hostname string) bool {
+	for _, domain := range ca.Domains {
+		if types.MatchHostPattern(domain, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks that key is a host certificate for hostname, which may
+// include a port, signed by the CA and currently valid
+func (ca *HostCA) Verify(hostname string, remote net.Addr, key gossh.PublicKey) error {
+	if _, ok := key.(*gossh.Certificate); !ok {
+		return fmt.Errorf("%s presented a plain %s host key, not a certificate", hostname, key.Type())
+	}
+	if _, _, err := net.SplitHostPort(hostname); err != nil {
+		hostname = net.JoinHostPort(hostname, "22")
+	}
+	checker := &gossh.CertChecker{
+		IsHostAuthority: func(auth gossh.PublicKey, _ string) bool {
+			return bytes.Equal(auth.Marshal(), ca.Key.Marshal())
+		},
+	}
+	return checker.CheckHostKey(hostname, remote, key)
+}
+
+// verifyHostCA wraps a host key callback to verify host certificates against
+// the host CA first. A verified certificate is trusted without consulting the
+// known hosts file; on a mismatch the connection is refused when enforcing,
+// otherwise a warning is logged and callback decides.
+func (c *Client) verifyHostCA(hostname string, callback gossh.HostKeyCallback) gossh.HostKeyCallback {
+	if c.hostCA == nil || !c.hostCA.Covers(hostname) {
+		return callback
+	}
+	return func(address string, remote net.Addr, key gossh.PublicKey) error {
+		err := c.hostCA.Verify(address, remote, key)
+		if err == nil {
+			c.logger.Debugf("Host certificate of %s is signed by the Vault host CA", address)
+			return nil
+		}
+		if c.hostCA.Enforce {
+			return fmt.Errorf("host key verification against the Vault host CA failed: %w", err)
+		}
+		c.logger.Warnf("Host key verification against the Vault host CA failed: %v", err)
+		return callback(address, remote, key)
+	}
+}
+
+// probeHostCA connects to the target before ssh does to check its host key
+// against the host CA, since ssh itself only consults known_hosts. Targets
+// reached through jump hosts aren't probed.
+func (c *Client) probeHostCA(target *SSHTarget, options *SSHOptions) error {
+	if c.hostCA == nil || !c.hostCA.Covers(target.Hostname) || len(options.JumpHosts) > 0 || options.ProxyJump != "" {
+		return nil
+	}
+
+	address := net.JoinHostPort(target.Hostname, cmp.Or(options.Port, target.Port, "22"))
+	conn, err := net.DialTimeout("tcp", address, nativeConnectTimeout)
+	if err != nil {
+		// ssh reports connection errors itself
+		c.logger.Debugf("Could not probe the host key of %s: %v", address, err)
+		return nil
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(nativeConnectTimeout))
+
+	// The default host key algorithms ask for a certificate first
+	var hostKey gossh.PublicKey
+	config := &gossh.ClientConfig{
+		User: target.Username,
+		HostKeyCallback: func(_ string, _ net.Addr, key gossh.PublicKey) error {
+			hostKey = key
+			return errProbeDone
+		},
+	}
+	if _, _, _, err := gossh.NewClientConn(conn, address, config); hostKey == nil {
+		c.logger.Debugf("Could not probe the host key of %s: %v", address, err)
+		return nil
+	}
+
+	return c.verifyHostCA(target.Hostname, func(string, net.Addr, gossh.PublicKey) error {
+		return nil
+	})(address, conn.RemoteAddr(), hostKey)
+}
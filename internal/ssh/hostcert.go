@@ -0,0 +1,80 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"vssh/internal/utils"
+)
+
+// SignHostKey signs the host public key at publicKeyPath with a Vault role
+// for host certificates, valid for principals (the host's names)
+func (s *Signer) SignHostKey(engine, vaultRole, publicKeyPath string, principals []string, ttl time.Duration) (string, error) {
+	publicKey, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read public key %s: %w", publicKeyPath, err)
+	}
+	return s.signKey("host", engine, vaultRole, string(publicKey), principals, ttl)
+}
+
+// HostCertificatePath returns where sshd expects the certificate of a host
+// key: next to it, as <key>-cert.pub
+func HostCertificatePath(publicKeyPath string) string {
+	return strings.TrimSuffix(publicKeyPath, ".pub") + "-cert.pub"
+}
+
+// InstallHostCertificate writes a host certificate next to its key and adds a
+// HostCertificate directive for it to the sshd config at sshdConfigPath. It
+// returns the certificate path and whether the directive was added.
+func InstallHostCertificate(publicKeyPath, certificate, sshdConfigPath string) (string, bool, error) {
+	certPath := HostCertificatePath(publicKeyPath)
+	if err := utils.WriteFileAtomic(certPath, []byte(certificate), 0644); err != nil {
+		return "", false, fmt.Errorf("failed to write %s: %w", certPath, err)
+	}
+
+	existing, err := os.ReadFile(sshdConfigPath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s: %w", sshdConfigPath, err)
+	}
+	if hasHostCertificate(existing, certPath) {
+		return certPath, false, nil
+	}
+
+	// HostCertificate isn't allowed in Match blocks, so it goes before the first
+	lines := strings.SplitAfter(string(existing), "\n")
+	insertAt := len(lines)
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) > 0 && strings.EqualFold(fields[0], "Match") {
+			insertAt = i
+			break
+		}
+	}
+	if insertAt == len(lines) && len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		lines[len(lines)-1] += "\n"
+	}
+	directive := "HostCertificate " + certPath + "\n"
+	lines = append(lines[:insertAt], append([]string{directive}, lines[insertAt:]...)...)
+
+	info, err := os.Stat(sshdConfigPath)
+	if err != nil {
+		return "", false, err
+	}
+	if err := utils.WriteFileAtomic(sshdConfigPath, []byte(strings.Join(lines, "")), info.Mode().Perm()); err != nil {
+		return "", false, fmt.Errorf("failed to write %s: %w", sshdConfigPath, err)
+	}
+	return certPath, true, nil
+}
+
+// hasHostCertificate reports whether an sshd config has a HostCertificate
+// directive for certPath
+func hasHostCertificate(sshdConfig []byte, certPath string) bool {
+	for _, line := range strings.Split(string(sshdConfig), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.EqualFold(fields[0], "HostCertificate") && strings.Trim(fields[1], `"`) == certPath {
+			return true
+		}
+	}
+	return false
+}
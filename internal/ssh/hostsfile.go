@@ -0,0 +1,45 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ReadHostsFile reads targets from a file with one target per line. Blank lines
+// and text after # are ignored. A path of "-" reads from standard input.
+func ReadHostsFile(path string) ([]string, error) {
+	if path == "-" {
+		return parseHostsList(os.Stdin)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hosts file: %w", err)
+	}
+	defer file.Close()
+
+	hosts, err := parseHostsList(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hosts file %s: %w", path, err)
+	}
+	return hosts, nil
+}
+
+// parseHostsList reads one target per line, skipping blank lines and comments
+func parseHostsList(r io.Reader) ([]string, error) {
+	var hosts []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			hosts = append(hosts, line)
+		}
+	}
+	return hosts, scanner.Err()
+}
@@ -0,0 +1,133 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"vssh/pkg/types"
+)
+
+// JumpHost is a ProxyJump hop together with the credentials used to reach it
+type JumpHost struct {
+	Target          *SSHTarget
+	CertificateFile string
+	IdentityFile    string
+	KeyOptions      []string // ssh options loading the key, such as PKCS11Provider
+}
+
+// ParseProxyJump resolves a ProxyJump specification ("[user@]host[:port][,...]")
+// into hop targets, applying the same configuration defaults as connection targets
+func ParseProxyJump(config *types.Config, spec string) ([]*SSHTarget, error) {
+	var hops []*SSHTarget
+	for _, hop := range strings.Split(spec, ",") {
+		hop = strings.TrimSpace(hop)
+		if hop == "" {
+			continue
+		}
+
+		target, err := ResolveTarget(config, hop)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jump host %s: %w", hop, err)
+		}
+		// Hops are reached through the chain being built, not their own ProxyJump
+		target.ProxyJump = ""
+
+		hops = append(hops, target)
+	}
+
+	if len(hops) == 0 {
+		return nil, fmt.Errorf("empty ProxyJump specification")
+	}
+	return hops, nil
+}
+
+// ProxyCommand builds an ssh ProxyCommand that reaches the destination through
+// the given hops in order, authenticating to every hop with its certificate.
+// OpenSSH's own ProxyJump does not pass CertificateFile to the jump connection,
+// so the chain is built explicitly instead.
+func ProxyCommand(hops []JumpHost) string {
+	command := ""
+	for i, hop := range hops {
+		// The last hop forwards to the final destination, which ssh substitutes
+		// for %h:%p. Inner hops forward to the next hop explicitly.
+		destination := "%h:%p"
+		if i < len(hops)-1 {
+			next := hops[i+1].Target
+			port := next.Port
+			if port == "" {
+				port = "22"
+			}
+			destination = net.JoinHostPort(next.SSHHost(), port)
+		}
+
+		args := []string{"ssh"}
+		if hop.IdentityFile != "" {
+			args = append(args, "-i", ShellQuote(hop.IdentityFile))
+		}
+		if hop.CertificateFile != "" {
+			args = append(args, "-o", ShellQuote("CertificateFile="+hop.CertificateFile))
+		}
+		for _, option := range hop.KeyOptions {
+			args = append(args, ShellQuote(option))
+		}
+		if hop.Target.Port != "" {
+			args = append(args, "-p", ShellQuote(hop.Target.Port))
+		}
+		if command != "" {
+			args = append(args, "-o", ShellQuote("ProxyCommand="+command))
+		}
+		args = append(args, "-W", destination, ShellQuote(fmt.Sprintf("%s@%s", hop.Target.Username, hop.Target.SSHHost())))
+
+		command = strings.Join(args, " ")
+	}
+
+	// Tokens other than the final %h:%p must not be expanded by ssh
+	return escapeInnerTokens(command)
+}
+
+// escapeInnerTokens escapes % characters so ssh only expands the trailing %h:%p
+func escapeInnerTokens(command string) string {
+	const placeholder = "\x00"
+	command = strings.Replace(command, "-W %h:%p", "-W "+placeholder, 1)
+	command = strings.ReplaceAll(command, "%", "%%")
+	return strings.Replace(command, placeholder, "%h:%p", 1)
+}
+
+// ShellQuote quotes s for safe use as a single word in a POSIX shell command line
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, needsQuoting) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// needsQuoting reports whether r has special meaning to a POSIX shell
+func needsQuoting(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case strings.ContainsRune("@%_-+=:,./", r):
+		return false
+	default:
+		return true
+	}
+}
+
+// isPort reports whether s is a valid TCP port number
+func isPort(s string) bool {
+	if s == "" || len(s) > 5 {
+		return false
+	}
+	port := 0
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+		port = port*10 + int(r-'0')
+	}
+	return port > 0 && port <= 65535
+}
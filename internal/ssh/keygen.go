@@ -0,0 +1,80 @@
+package ssh
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"vssh/internal/utils"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// KeyTypes are the key types GenerateKeyPair can create
+var KeyTypes = []string{"ed25519", "ecdsa", "rsa"}
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 4096
+
+// GenerateKeyPair creates a key pair of the given type, writing the private
+// key in OpenSSH format to keyPath (mode 0600) and the public key to
+// keyPath.pub. Existing keys are only replaced with overwrite.
+func GenerateKeyPair(keyType, keyPath, comment string, overwrite bool) error {
+	keyPath = expandTilde(keyPath)
+	if !overwrite {
+		for _, path := range []string{keyPath, keyPath + ".pub"} {
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("%s already exists", path)
+			}
+		}
+	}
+
+	var privateKey crypto.Signer
+	var err error
+	switch keyType {
+	case "ed25519":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case "ecdsa":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "rsa":
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		return fmt.Errorf("unsupported key type %s (supported: ed25519, ecdsa, rsa)", keyType)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate %s key: %w", keyType, err)
+	}
+
+	block, err := gossh.MarshalPrivateKey(privateKey, comment)
+	if err != nil {
+		return fmt.Errorf("failed to encode private key: %w", err)
+	}
+	publicKey, err := gossh.NewPublicKey(privateKey.Public())
+	if err != nil {
+		return fmt.Errorf("failed to encode public key: %w", err)
+	}
+	authorizedKey := gossh.MarshalAuthorizedKey(publicKey)
+	if comment != "" {
+		authorizedKey = append(authorizedKey[:len(authorizedKey)-1], []byte(" "+comment+"\n")...)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	// The public key is written first, so a private key that exists always
+	// has its public key next to it
+	if err := utils.WriteFileAtomic(keyPath+".pub", authorizedKey, 0644); err != nil {
+		return fmt.Errorf("failed to write public key: %w", err)
+	}
+	if err := utils.WriteFileAtomic(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vssh/internal/utils"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// SyncCertAuthority adds or updates the @cert-authority line trusting a host
+// CA for domains in a known_hosts file. The line vssh manages for an engine is
+// marked with a vssh:<engine> comment, so other entries are left alone. It
+// reports whether the file changed.
+func SyncCertAuthority(path, engine, caKey string, domains []string) (bool, error) {
+	publicKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(caKey))
+	if err != nil {
+		return false, fmt.Errorf("invalid CA public key: %w", err)
+	}
+	if len(domains) == 0 {
+		return false, fmt.Errorf("no domains to trust the host CA for")
+	}
+	path = expandTilde(path)
+
+	marker := "vssh:" + engine
+	entry := fmt.Sprintf("@cert-authority %s %s %s", strings.Join(domains, ","),
+		strings.TrimSpace(string(gossh.MarshalAuthorizedKey(publicKey))), marker)
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	lines := strings.SplitAfter(string(existing), "\n")
+	found := false
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "@cert-authority" || fields[len(fields)-1] != marker {
+			continue
+		}
+		if strings.TrimSpace(line) == entry {
+			return false, nil
+		}
+		lines[i] = entry + "\n"
+		found = true
+		break
+	}
+	if !found {
+		if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+			lines[len(lines)-1] += "\n"
+		}
+		lines = append(lines, entry+"\n")
+	}
+
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return false, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := utils.WriteFileAtomic(path, []byte(strings.Join(lines, "")), perm); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"vssh/pkg/types"
+)
+
+// MuxSocket is an OpenSSH control master socket
+type MuxSocket struct {
+	Path string
+	Name string // file name, user@host:port with the default control path
+}
+
+// multiplexingEnabled reports whether connections should share a control
+// master. The Windows OpenSSH client doesn't support multiplexing.
+func multiplexingEnabled(config *types.Config) bool {
+	return config.SSH.Multiplexing.Enabled && config.SSH.Multiplexing.ControlPath != "" && runtime.GOOS != "windows"
+}
+
+// multiplexingArgs returns the ssh options that share one connection per host,
+// creating the control socket directory if needed
+func multiplexingArgs(config *types.Config) ([]string, error) {
+	controlPath := expandTilde(config.SSH.Multiplexing.ControlPath)
+	if err := os.MkdirAll(filepath.Dir(controlPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+
+	args := []string{"-o", "ControlMaster=auto", "-o", "ControlPath=" + controlPath}
+	if persist := config.SSH.Multiplexing.Persist; persist != "" {
+		args = append(args, "-o", "ControlPersist="+persist)
+	}
+	return args, nil
+}
+
+// MuxSockets lists the control sockets in the directory of the configured
+// control path
+func MuxSockets(config *types.Config) ([]MuxSocket, error) {
+	dir := filepath.Dir(expandTilde(config.SSH.Multiplexing.ControlPath))
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read control socket directory: %w", err)
+	}
+
+	var sockets []MuxSocket
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSocket == 0 {
+			continue
+		}
+		sockets = append(sockets, MuxSocket{Path: filepath.Join(dir, entry.Name()), Name: entry.Name()})
+	}
+	return sockets, nil
+}
+
+// MuxControl sends a control command ("check" or "exit") to a control master
+// and returns ssh's reply. With a target, ssh expands the configured control
+// path for it; without one, socket must be the path of a control socket.
+func MuxControl(config *types.Config, socket string, target *SSHTarget, command string) (string, error) {
+	if socket == "" {
+		socket = expandTilde(config.SSH.Multiplexing.ControlPath)
+	}
+
+	args := []string{"-o", "ControlPath=" + socket, "-O", command}
+	if target != nil {
+		if target.Port != "" {
+			args = append(args, "-p", target.Port)
+		}
+		args = append(args, fmt.Sprintf("%s@%s", target.Username, target.SSHHost()))
+	} else {
+		// A destination is required by ssh but unused with an explicit socket
+		args = append(args, "mux")
+	}
+
+	output, err := exec.Command("ssh", args...).CombinedOutput()
+	reply := strings.TrimSpace(string(output))
+	if err != nil {
+		if reply == "" {
+			reply = err.Error()
+		}
+		return "", fmt.Errorf("%s", reply)
+	}
+	return reply, nil
+}
@@ -0,0 +1,555 @@
+package ssh
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"vssh/internal/utils"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/term"
+)
+
+// nativeConnectTimeout limits how long the built-in client waits for a connection
+const nativeConnectTimeout = 30 * time.Second
+
+// useNative reports whether connections use the built-in client, because it
+// is configured or the ssh binary is missing
+func (c *Client) useNative() bool {
+	if c.config.SSH.Native {
+		return true
+	}
+	_, err := exec.LookPath("ssh")
+	return err != nil
+}
+
+// ExecuteNative runs a session like Execute, but always with the built-in
+// client, for commands driving several sessions from one process
+func (c *Client) ExecuteNative(target *SSHTarget, certPath string, options *SSHOptions, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return c.executeNative(target, certPath, options, command, stdin, stdout, stderr)
+}
+
+// UsesNative reports whether connections use the built-in client
+func (c *Client) UsesNative() bool {
+	return c.useNative()
+}
+
+// executeNative runs a session with the built-in client from
+// golang.org/x/crypto/ssh, authenticating with the signed certificate. It
+// supports commands, pseudo-terminals, jump hosts and agent forwarding but not
+// port or X11 forwarding or options passed through to ssh.
+func (c *Client) executeNative(target *SSHTarget, certPath string, options *SSHOptions, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if err := c.checkNativeOptions(options); err != nil {
+		return err
+	}
+
+	client, closeClient, err := c.dialNative(target, certPath, options)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	if c.forwardAgent(options) {
+		closeAgent, err := forwardNativeAgent(client, session)
+		if err != nil {
+			return err
+		}
+		defer closeAgent()
+	}
+
+	// The session waits for the stdin copy to finish, which an interactive
+	// stdin never does, so copy it separately
+	if stdin != nil {
+		stdinPipe, err := session.StdinPipe()
+		if err != nil {
+			return fmt.Errorf("failed to open session input: %w", err)
+		}
+		go func() {
+			io.Copy(stdinPipe, stdin)
+			stdinPipe.Close()
+		}()
+	}
+
+	if wantTTY(options, command, stdin) {
+		restore, err := requestPTY(session, stdin)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+
+	if len(command) > 0 {
+		err = session.Start(RemoteCommand(command))
+	} else {
+		err = session.Shell()
+	}
+	if err == nil {
+		stopSignals := signalSession(session)
+		err = session.Wait()
+		stopSignals()
+	}
+
+	var exitErr *gossh.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitStatus()}
+	}
+	if err != nil {
+		return fmt.Errorf("SSH session failed: %w", err)
+	}
+	return nil
+}
+
+// checkNativeOptions rejects options the built-in client can't honor
+func (c *Client) checkNativeOptions(options *SSHOptions) error {
+	switch {
+	case len(options.ExtraArgs) > 0:
+		return fmt.Errorf("ssh option %s is not supported by the built-in SSH client", options.ExtraArgs[0])
+	case options.ForwardX11 == "-X" || options.ForwardX11 == "-Y":
+		return fmt.Errorf("X11 forwarding is not supported by the built-in SSH client")
+	case options.Background || options.NoCommand:
+		return fmt.Errorf("-f and -N are not supported by the built-in SSH client")
+	case c.config.SSH.PKCS11Provider != "":
+		return fmt.Errorf("PKCS#11 tokens (ssh.pkcs11_provider) are not supported by the built-in SSH client")
+	}
+
+	if c.config.SSH.ForwardX11 {
+		c.logger.Warnf("The built-in SSH client doesn't forward X11, ignoring ssh.forward_x11")
+	}
+	if options.Compression {
+		c.logger.Debugf("The built-in SSH client doesn't support compression, ignoring -C")
+	}
+	return nil
+}
+
+// forwardAgent reports whether the agent should be forwarded: with -A, or by
+// default when configured and not disabled with -a
+func (c *Client) forwardAgent(options *SSHOptions) bool {
+	if options.ForwardAgent != nil {
+		return *options.ForwardAgent
+	}
+	return c.config.SSH.ForwardAgent
+}
+
+// forwardNativeAgent forwards the local ssh-agent over a session. The
+// returned function closes the agent connection.
+func forwardNativeAgent(client *gossh.Client, session *gossh.Session) (func() error, error) {
+	keyring, closeAgent, err := ConnectAgent()
+	if err != nil {
+		return nil, err
+	}
+	if err := agent.ForwardToAgent(client, keyring); err != nil {
+		closeAgent()
+		return nil, fmt.Errorf("failed to forward ssh-agent: %w", err)
+	}
+	if err := agent.RequestAgentForwarding(session); err != nil {
+		closeAgent()
+		return nil, fmt.Errorf("failed to request agent forwarding: %w", err)
+	}
+	return closeAgent, nil
+}
+
+// dialNative connects to the target through any jump hosts. The returned
+// function closes the connection and those to the jump hosts.
+func (c *Client) dialNative(target *SSHTarget, certPath string, options *SSHOptions) (*gossh.Client, func(), error) {
+	network := "tcp"
+	if options.IPv4 {
+		network = "tcp4"
+	} else if options.IPv6 {
+		network = "tcp6"
+	}
+
+	var clients []*gossh.Client
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
+		}
+	}
+
+	var via *gossh.Client
+	for _, hop := range options.JumpHosts {
+		client, err := c.dialHop(via, network, hop.Target, cmp.Or(hop.Target.Port, "22"), hop.CertificateFile, hop.IdentityFile, "")
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("jump host %s: %w", hop.Target.Hostname, err)
+		}
+		clients = append(clients, client)
+		via = client
+	}
+
+	client, err := c.dialHop(via, network, target, cmp.Or(options.Port, target.Port, "22"), certPath, options.IdentityFile, options.Password)
+	if err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+	clients = append(clients, client)
+	return client, closeAll, nil
+}
+
+// dialHop opens an SSH connection to target, directly or through another
+// connection, logging in with password when one is given
+func (c *Client) dialHop(via *gossh.Client, network string, target *SSHTarget, port, certPath, keyPath, password string) (*gossh.Client, error) {
+	var auth []gossh.AuthMethod
+	if password != "" {
+		// The vault-ssh-helper checks the password through PAM, which usually
+		// asks for it with keyboard-interactive authentication
+		auth = []gossh.AuthMethod{
+			gossh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}),
+			gossh.Password(password),
+		}
+	} else if certPath == "" {
+		// In agent mode the certificate is only in the agent
+		keyring, closeAgent, err := ConnectAgent()
+		if err != nil {
+			return nil, err
+		}
+		defer closeAgent()
+		auth = []gossh.AuthMethod{gossh.PublicKeysCallback(keyring.Signers)}
+	} else {
+		signer, err := loadCertSigner(keyPath, certPath)
+		if err != nil {
+			return nil, err
+		}
+		auth = []gossh.AuthMethod{gossh.PublicKeys(signer)}
+	}
+
+	address := net.JoinHostPort(target.Hostname, port)
+	hostKeyCallback, algorithms, err := c.hostKeyCallback(target, address)
+	if err != nil {
+		return nil, err
+	}
+	if c.hostCA != nil && c.hostCA.Covers(target.Hostname) {
+		hostKeyCallback = c.verifyHostCA(target.Hostname, hostKeyCallback)
+		if algorithms != nil {
+			algorithms = slices.Concat(hostCertAlgorithms, algorithms)
+		}
+	}
+
+	config := &gossh.ClientConfig{
+		User:              target.Username,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: algorithms,
+		Timeout:           nativeConnectTimeout,
+	}
+
+	c.logger.Debugf("Connecting to %s@%s with the built-in SSH client", target.Username, address)
+	if via == nil {
+		client, err := gossh.Dial(network, address, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+		}
+		return client, nil
+	}
+
+	conn, err := via.Dial(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	clientConn, channels, requests, err := gossh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	return gossh.NewClient(clientConn, channels, requests), nil
+}
+
+// loadCertSigner loads a private key and the certificate signed for it,
+// prompting for the passphrase of an encrypted key
+func loadCertSigner(keyPath, certPath string) (gossh.Signer, error) {
+	privateKey, err := loadPrivateKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := gossh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use private key %s: %w", keyPath, err)
+	}
+
+	cert, err := readCertificate(certPath)
+	if err != nil {
+		return nil, err
+	}
+	return gossh.NewCertSigner(cert, signer)
+}
+
+// loadPrivateKey reads a private key, prompting for the passphrase of an
+// encrypted key
+func loadPrivateKey(keyPath string) (interface{}, error) {
+	keyPath = expandTilde(keyPath)
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	// Signing with a security key needs the hardware, which only OpenSSH can use
+	if IsSecurityKey(keyPath + ".pub") {
+		return nil, fmt.Errorf("%s is a FIDO2 security key, which only the OpenSSH ssh client can use (not the built-in client or agent mode)", keyPath)
+	}
+
+	privateKey, err := gossh.ParseRawPrivateKey(keyData)
+	var passphraseMissing *gossh.PassphraseMissingError
+	if errors.As(err, &passphraseMissing) {
+		privateKey, err = parseEncryptedKey(keyPath, keyData)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", keyPath, err)
+	}
+	return privateKey, nil
+}
+
+// readCertificate reads a signed certificate
+func readCertificate(certPath string) (*gossh.Certificate, error) {
+	certData, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	publicKey, _, _, _, err := gossh.ParseAuthorizedKey(certData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", certPath, err)
+	}
+	cert, ok := publicKey.(*gossh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", certPath)
+	}
+	return cert, nil
+}
+
+// CertificateSerial returns the serial number of the certificate at certPath
+func CertificateSerial(certPath string) (uint64, error) {
+	cert, err := readCertificate(certPath)
+	if err != nil {
+		return 0, err
+	}
+	return cert.Serial, nil
+}
+
+// parseEncryptedKey asks for the passphrase of a private key on the terminal
+func parseEncryptedKey(keyPath string, keyData []byte) (interface{}, error) {
+	tty, err := utils.OpenTerminal()
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty.Out, "Enter passphrase for key '%s': ", keyPath)
+	passphrase, err := tty.ReadPassword()
+	if err != nil {
+		return nil, fmt.Errorf("error reading passphrase: %w", err)
+	}
+	return gossh.ParseRawPrivateKeyWithPassphrase(keyData, passphrase)
+}
+
+// hostKeyCallback verifies host keys against the known hosts file like
+// OpenSSH's StrictHostKeyChecking. It also returns the host key algorithms of
+// any known keys, so the server is asked for a key that can be verified.
+func (c *Client) hostKeyCallback(target *SSHTarget, address string) (gossh.HostKeyCallback, []string, error) {
+	strict, knownHostsFile := c.hostKeySettings(target)
+	if strict == "no" || strict == "off" {
+		return gossh.InsecureIgnoreHostKey(), nil, nil
+	}
+
+	if knownHostsFile == "" {
+		knownHostsFile = expandTilde("~/.ssh/known_hosts")
+	}
+	if err := ensureFile(knownHostsFile); err != nil {
+		return nil, nil, fmt.Errorf("failed to create known hosts file: %w", err)
+	}
+	known, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read known hosts file: %w", err)
+	}
+
+	callback := func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key for %s has changed (%s %s), it may be under attack: %w", hostname, key.Type(), gossh.FingerprintSHA256(key), err)
+		}
+
+		// The host is unknown
+		switch strict {
+		case "yes":
+			return fmt.Errorf("no host key is known for %s and strict host key checking is enabled", hostname)
+		case "accept-new":
+		default:
+			if err := confirmHostKey(hostname, key); err != nil {
+				return err
+			}
+		}
+		return addKnownHost(knownHostsFile, hostname, remote, key)
+	}
+
+	return callback, knownAlgorithms(known, address), nil
+}
+
+// knownAlgorithms returns the host key algorithms of the known keys for address
+func knownAlgorithms(known gossh.HostKeyCallback, address string) []string {
+	// Checking a key no host has reports every known key for the address
+	placeholder, err := gossh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(known(address, &net.TCPAddr{}, placeholder), &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, want := range keyErr.Want {
+		if want.Key.Type() == gossh.KeyAlgoRSA {
+			algorithms = append(algorithms, gossh.KeyAlgoRSASHA512, gossh.KeyAlgoRSASHA256)
+		}
+		algorithms = append(algorithms, want.Key.Type())
+	}
+	return algorithms
+}
+
+// confirmHostKey asks whether to trust the key of an unknown host
+func confirmHostKey(hostname string, key gossh.PublicKey) error {
+	tty, err := utils.OpenTerminal()
+	if err != nil {
+		return fmt.Errorf("no host key is known for %s: %w", hostname, err)
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty.Out, "The authenticity of host '%s' can't be established.\n", hostname)
+	fmt.Fprintf(tty.Out, "%s key fingerprint is %s.\n", key.Type(), gossh.FingerprintSHA256(key))
+	fmt.Fprint(tty.Out, "Are you sure you want to continue connecting (yes/no)? ")
+
+	answer, err := bufio.NewReader(tty.In).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("error reading answer: %w", err)
+	}
+	if strings.TrimSpace(answer) != "yes" {
+		return fmt.Errorf("host key verification failed")
+	}
+	return nil
+}
+
+// addKnownHost records a host key in the known hosts file
+func addKnownHost(path, hostname string, remote net.Addr, key gossh.PublicKey) error {
+	addresses := []string{knownhosts.Normalize(hostname)}
+	if tcpAddr, ok := remote.(*net.TCPAddr); ok && tcpAddr.IP != nil {
+		host, _, _ := net.SplitHostPort(hostname)
+		if ip := tcpAddr.IP.String(); ip != host {
+			addresses = append(addresses, knownhosts.Normalize(net.JoinHostPort(ip, fmt.Sprint(tcpAddr.Port))))
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to update known hosts file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintln(file, knownhosts.Line(addresses, key)); err != nil {
+		return fmt.Errorf("failed to update known hosts file: %w", err)
+	}
+	return nil
+}
+
+// ensureFile creates an empty file and its directory if it doesn't exist
+func ensureFile(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// wantTTY decides whether to request a pseudo-terminal like ssh: for
+// interactive shells, with -t when stdin is a terminal, always with -tt, and
+// never with -T
+func wantTTY(options *SSHOptions, command []string, stdin io.Reader) bool {
+	switch {
+	case options.TTY < 0:
+		return false
+	case options.TTY > 1:
+		return true
+	case options.TTY == 1 || len(command) == 0:
+		return isTerminal(stdin)
+	default:
+		return false
+	}
+}
+
+// requestPTY requests a pseudo-terminal sized like the local one and puts the
+// local terminal into raw mode. The returned function restores it.
+func requestPTY(session *gossh.Session, stdin io.Reader) (func(), error) {
+	termType := cmp.Or(os.Getenv("TERM"), "xterm-256color")
+	modes := gossh.TerminalModes{
+		gossh.ECHO:          1,
+		gossh.TTY_OP_ISPEED: 14400,
+		gossh.TTY_OP_OSPEED: 14400,
+	}
+
+	if !isTerminal(stdin) {
+		// Forced with -tt without a local terminal
+		if err := session.RequestPty(termType, 24, 80, modes); err != nil {
+			return nil, fmt.Errorf("failed to request pseudo-terminal: %w", err)
+		}
+		return func() {}, nil
+	}
+
+	fd := int(stdin.(*os.File).Fd())
+	width, height, err := term.GetSize(fd)
+	if err != nil {
+		width, height = 80, 24
+	}
+	if err := session.RequestPty(termType, height, width, modes); err != nil {
+		return nil, fmt.Errorf("failed to request pseudo-terminal: %w", err)
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set terminal to raw mode: %w", err)
+	}
+	stopWatching := watchWindowSize(fd, session)
+	return func() {
+		stopWatching()
+		term.Restore(fd, state)
+	}, nil
+}
+
+// isTerminal reports whether r is a terminal
+func isTerminal(r io.Reader) bool {
+	file, ok := r.(*os.File)
+	return ok && term.IsTerminal(int(file.Fd()))
+}
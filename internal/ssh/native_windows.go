@@ -0,0 +1,40 @@
+//go:build windows
+
+package ssh
+
+import (
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// How often the console size is checked, since Windows has no SIGWINCH
+const windowSizePollInterval = 250 * time.Millisecond
+
+// watchWindowSize forwards console size changes to the session until the
+// returned function is called
+func watchWindowSize(fd int, session *gossh.Session) func() {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(windowSizePollInterval)
+		defer ticker.Stop()
+
+		lastWidth, lastHeight, _ := term.GetSize(fd)
+		for {
+			select {
+			case <-ticker.C:
+				width, height, err := term.GetSize(fd)
+				if err == nil && (width != lastWidth || height != lastHeight) {
+					session.WindowChange(height, width)
+					lastWidth, lastHeight = width, height
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,124 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"vssh/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// askpassPasswordEnv passes the one-time password to vssh running as ssh's
+// SSH_ASKPASS program
+const askpassPasswordEnv = "VSSH_ASKPASS_PASSWORD"
+
+// ResolveMode returns how to log in to a target: its own mode (from --mode),
+// or the mode mapped to its host, or "ca" for a signed certificate
+func (s *Signer) ResolveMode(target *SSHTarget) string {
+	if target.Mode != "" {
+		return target.Mode
+	}
+	if mode := s.config.ResolveHost(target.Hostname).Mode; mode != "" {
+		return mode
+	}
+	return "ca"
+}
+
+// GenerateOTP generates a one-time password for the target from the OTP role
+// resolved for it, with the engine's creds endpoint. Vault needs the host's IP
+// address, which is looked up on network ("ip", "ip4" or "ip6").
+func (s *Signer) GenerateOTP(target *SSHTarget, network string) (string, error) {
+	engine := s.ResolveSigningEngine(target)
+	role := s.ResolveRole(target)
+
+	ip, err := lookupIP(network, target.Hostname)
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debugf("Generating a one-time password for %s@%s (%s) from %s/creds/%s", target.Username, target.Hostname, ip, engine, role)
+	spinner := utils.StartSpinner(fmt.Sprintf("Generating one-time password with Vault role %s...", role))
+	otp, err := s.vaultClient.SSHOTP(engine, role, target.Username, ip)
+	spinner.Stop()
+	if err != nil {
+		return "", err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"event": "otp_generated",
+		"user":  target.Username,
+		"host":  target.Hostname,
+		"role":  role,
+	}).Infof("Generated a one-time password for %s@%s with role %s", target.Username, target.Hostname, role)
+	return otp, nil
+}
+
+// lookupIP returns the first IP address of hostname, which may already be one
+func lookupIP(network, hostname string) (string, error) {
+	if ip := net.ParseIP(hostname); ip != nil {
+		return ip.String(), nil
+	}
+	ips, err := net.DefaultResolver.LookupIP(context.Background(), network, hostname)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the IP address of %s for a one-time password: %w", hostname, err)
+	}
+	return ips[0].String(), nil
+}
+
+// askpassEnv returns the environment variables that make ssh ask vssh itself
+// for passwords, which it answers with password
+func askpassEnv(password string) ([]string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the vssh executable for SSH_ASKPASS: %w", err)
+	}
+	return []string{
+		"SSH_ASKPASS=" + executable,
+		"SSH_ASKPASS_REQUIRE=force",
+		askpassPasswordEnv + "=" + password,
+	}, nil
+}
+
+// RunAskpass answers a prompt from ssh when vssh runs as its SSH_ASKPASS
+// program, and reports whether it did. Password prompts are answered with the
+// one-time password; anything else, such as confirming a new host key, is
+// asked on the terminal.
+func RunAskpass(args []string) bool {
+	password, ok := os.LookupEnv(askpassPasswordEnv)
+	if !ok {
+		return false
+	}
+
+	prompt := strings.Join(args, " ")
+	if strings.Contains(strings.ToLower(prompt), "password") {
+		fmt.Println(password)
+		return true
+	}
+
+	tty, err := utils.OpenTerminal()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vssh: can't answer %q: %v\n", prompt, err)
+		os.Exit(1)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty.Out, prompt)
+	var answer string
+	if strings.Contains(prompt, "(yes/no") {
+		answer, err = bufio.NewReader(tty.In).ReadString('\n')
+	} else {
+		var secret []byte
+		secret, err = tty.ReadPassword()
+		answer = string(secret)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vssh: error reading answer: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(strings.TrimSpace(answer))
+	return true
+}
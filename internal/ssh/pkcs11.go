@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// PKCS11PublicKey returns the public key of a key on a PKCS#11 token in
+// authorized_keys format, read with ssh-keygen -D. With a label the key whose
+// label (ssh-keygen's comment) matches is used, otherwise the first key.
+func PKCS11PublicKey(provider, label string) (string, error) {
+	cmd := exec.Command("ssh-keygen", "-D", expandTilde(provider))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("failed to read keys from PKCS#11 provider %s: %s", provider, message)
+		}
+		return "", fmt.Errorf("failed to read keys from PKCS#11 provider %s: %w", provider, err)
+	}
+
+	var labels []string
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		keyLabel := strings.Join(fields[2:], " ")
+		if label == "" || keyLabel == label {
+			return strings.Join(fields[:2], " ") + "\n", nil
+		}
+		labels = append(labels, keyLabel)
+	}
+
+	if len(labels) == 0 {
+		return "", fmt.Errorf("no keys found on the PKCS#11 token of %s", provider)
+	}
+	return "", fmt.Errorf("no key labelled %q on the PKCS#11 token of %s (found %s)", label, provider, strings.Join(labels, ", "))
+}
@@ -0,0 +1,94 @@
+package ssh
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"vssh/internal/vault"
+)
+
+// CheckRole checks that a Vault SSH role allows what a certificate plan
+// requests, so a mismatch is reported clearly instead of as a signing error
+// or a refused login. Templated allowed users can't be evaluated locally and
+// are not checked.
+func CheckRole(role *vault.SSHRole, plan *CertificatePlan) error {
+	if plan.CertType != "host" && !role.AllowedUsersTemplate && !slices.Contains(role.AllowedUsers, "*") {
+		for _, principal := range plan.Principals {
+			if !slices.Contains(role.AllowedUsers, principal) && principal != role.DefaultUser {
+				return fmt.Errorf("role %q does not allow principal %q (allowed users: %s)",
+					plan.Role, principal, strings.Join(roleUsers(role), ", "))
+			}
+		}
+	}
+
+	// An empty allowed_extensions allows none
+	if !slices.Contains(role.AllowedExtensions, "*") {
+		for _, extension := range slices.Sorted(maps.Keys(plan.Extensions)) {
+			if !slices.Contains(role.AllowedExtensions, extension) {
+				return fmt.Errorf("role %q does not allow extension %q (allowed extensions: %s)",
+					plan.Role, extension, strings.Join(roleList(role.AllowedExtensions), ", "))
+			}
+		}
+	}
+
+	// An empty allowed_critical_options allows any
+	if len(role.AllowedCriticalOptions) > 0 {
+		for _, option := range slices.Sorted(maps.Keys(plan.CriticalOptions)) {
+			if !slices.Contains(role.AllowedCriticalOptions, option) {
+				return fmt.Errorf("role %q does not allow critical option %q (allowed critical options: %s)",
+					plan.Role, option, strings.Join(role.AllowedCriticalOptions, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// roleUsers returns the principals a role signs for: its allowed users and
+// default user
+func roleUsers(role *vault.SSHRole) []string {
+	users := slices.Clone(role.AllowedUsers)
+	if role.DefaultUser != "" && !slices.Contains(users, role.DefaultUser) {
+		users = append(users, role.DefaultUser)
+	}
+	return roleList(users)
+}
+
+// roleList returns list, or "none" for an empty one
+func roleList(list []string) []string {
+	if len(list) == 0 {
+		return []string{"none"}
+	}
+	return list
+}
+
+// preflight checks a certificate plan against its Vault role before signing.
+// Roles are read once per engine; a role that can't be read, for lack of
+// permission to read it for example, isn't checked.
+func (s *Signer) preflight(plan *CertificatePlan) error {
+	if len(plan.Principals) == 0 && len(plan.Extensions) == 0 && len(plan.CriticalOptions) == 0 {
+		return nil
+	}
+
+	key := plan.Engine + "/" + plan.Role
+	s.rolesMu.Lock()
+	role, ok := s.roles[key]
+	if !ok {
+		var err error
+		if role, err = s.vaultClient.SSHRole(plan.Engine, plan.Role); err != nil {
+			s.logger.Debugf("Not checking role %s before signing: %v", plan.Role, err)
+		}
+		if s.roles == nil {
+			s.roles = make(map[string]*vault.SSHRole)
+		}
+		s.roles[key] = role
+	}
+	s.rolesMu.Unlock()
+
+	if role == nil {
+		return nil
+	}
+	return CheckRole(role, plan)
+}
@@ -0,0 +1,175 @@
+package ssh
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"vssh/internal/utils"
+	"vssh/pkg/types"
+)
+
+// RoleChoices remembers the Vault role chosen for each user@host whose role
+// isn't mapped in the configuration
+type RoleChoices struct {
+	path string
+
+	mu      sync.Mutex
+	choices map[string]string // loaded on first use
+}
+
+// NewRoleChoices creates a store of role choices backed by path
+func NewRoleChoices(path string) *RoleChoices {
+	return &RoleChoices{path: path}
+}
+
+// DefaultRoleChoicesPath returns the role choices file location inside stateDir
+func DefaultRoleChoicesPath(stateDir string) string {
+	return filepath.Join(stateDir, "roles.json")
+}
+
+// Get returns the role chosen for username on hostname, or ""
+func (r *RoleChoices) Get(username, hostname string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.load(); err != nil {
+		return ""
+	}
+	return r.choices[roleChoiceKey(username, hostname)]
+}
+
+// Set remembers the role chosen for username on hostname
+func (r *RoleChoices) Set(username, hostname, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.load(); err != nil {
+		return err
+	}
+	r.choices[roleChoiceKey(username, hostname)] = role
+
+	data, err := json.MarshalIndent(r.choices, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding role choices: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("error creating state directory: %w", err)
+	}
+	if err := utils.WriteFileAtomic(r.path, data, 0600); err != nil {
+		return fmt.Errorf("error writing role choices: %w", err)
+	}
+	return nil
+}
+
+// load reads the choices file once. A missing file has no choices.
+func (r *RoleChoices) load() error {
+	if r.choices != nil {
+		return nil
+	}
+	r.choices = make(map[string]string)
+
+	data, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading role choices: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.choices); err != nil {
+		return fmt.Errorf("error parsing role choices %s: %w", r.path, err)
+	}
+	return nil
+}
+
+// roleChoiceKey identifies a target in the choices file
+func roleChoiceKey(username, hostname string) string {
+	return username + "@" + strings.ToLower(hostname)
+}
+
+// UseRoleChoices makes the signer use the roles remembered in choices for
+// targets without a mapped role, and SelectRole record new choices there
+func (s *Signer) UseRoleChoices(choices *RoleChoices) {
+	s.roleChoices = choices
+}
+
+// hasRoleMapping reports whether the configuration names the Vault role for
+// username on hosts with the given settings
+func (s *Signer) hasRoleMapping(username string, hostSettings types.HostSettings) bool {
+	return hostSettings.Role != "" || s.config.Users[username].VaultRole != "" || s.config.Vault.Role != ""
+}
+
+// SelectRole asks which Vault role to sign with for a target whose role isn't
+// mapped in the configuration, when the signing engine has several roles, and
+// remembers the choice. An engine with a single role uses it without asking.
+// Nothing happens when the roles can't be listed or there is no terminal, so
+// the username is used as the role as before.
+func (s *Signer) SelectRole(target *SSHTarget) error {
+	if s.roleChoices == nil || s.hasRoleMapping(target.Username, s.config.ResolveHost(target.Hostname)) {
+		return nil
+	}
+	if s.roleChoices.Get(target.Username, target.Hostname) != "" {
+		return nil
+	}
+
+	engine := s.ResolveSigningEngine(target)
+	roles, err := s.vaultClient.ListSSHRoles(engine)
+	if err != nil || len(roles) == 0 {
+		s.logger.Debugf("Could not list the roles of %s, using role %s: %v", engine, target.Username, err)
+		return nil
+	}
+
+	role := roles[0]
+	if len(roles) > 1 {
+		tty, err := utils.OpenTerminal()
+		if err != nil {
+			s.logger.Debugf("Not asking for a Vault role: %v", err)
+			return nil
+		}
+		defer tty.Close()
+
+		defaultRole := roles[0]
+		if slices.Contains(roles, target.Username) {
+			defaultRole = target.Username
+		}
+		if role, err = promptRole(tty, target, engine, roles, defaultRole); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Infof("Using Vault role %s for %s@%s", role, target.Username, target.Hostname)
+	if err := s.roleChoices.Set(target.Username, target.Hostname, role); err != nil {
+		s.logger.Warnf("Failed to remember the Vault role: %v", err)
+	}
+	return nil
+}
+
+// promptRole asks on the terminal which of roles to use for target
+func promptRole(tty *utils.Terminal, target *SSHTarget, engine string, roles []string, defaultRole string) (string, error) {
+	fmt.Fprintf(tty.Out, "No Vault role is configured for %s@%s. Roles of %s:\n", target.Username, target.Hostname, engine)
+	for i, role := range roles {
+		fmt.Fprintf(tty.Out, "%d. %s\n", i+1, role)
+	}
+
+	reader := bufio.NewReader(tty.In)
+	for {
+		fmt.Fprintf(tty.Out, "Role to sign with (1-%d) [%s]: ", len(roles), defaultRole)
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("error reading answer: %w", err)
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			return defaultRole, nil
+		}
+		for i, role := range roles {
+			if answer == role || answer == fmt.Sprint(i+1) {
+				return role, nil
+			}
+		}
+		fmt.Fprintf(tty.Out, "Invalid choice: %s\n", answer)
+	}
+}
@@ -0,0 +1,65 @@
+package ssh
+
+import (
+	"strings"
+)
+
+// rsyncValueOptions are rsync's short options, other than -e, that take a value
+const rsyncValueOptions = "BfMT"
+
+// ParseRsyncRemote parses an rsync argument naming a path on a host reached
+// over ssh, [user@]host:path. Options, local paths and rsync daemon paths
+// (host::module and rsync:// URLs) are not remote paths.
+func ParseRsyncRemote(arg string) (*RemotePath, bool) {
+	if strings.HasPrefix(arg, "-") || strings.Contains(arg, "://") {
+		return nil, false
+	}
+
+	remote, ok := ParseRemotePath(arg)
+	if !ok || strings.HasPrefix(remote.Path, ":") {
+		return nil, false
+	}
+	return remote, true
+}
+
+// RsyncShell formats ssh and its arguments as the remote shell command for
+// rsync's -e option
+func RsyncShell(args []string) string {
+	words := []string{"ssh"}
+	for _, arg := range args {
+		words = append(words, ShellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// HasRsyncShellOption reports whether rsync arguments set the remote shell
+// with -e or --rsh
+func HasRsyncShellOption(args []string) bool {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return false
+		}
+		if arg == "--rsh" || strings.HasPrefix(arg, "--rsh=") {
+			return true
+		}
+		if strings.HasPrefix(arg, "--") || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		// -e may be grouped with other short options (-ave ssh), up to the
+		// first option that takes a value
+		for j, option := range arg[1:] {
+			if option == 'e' {
+				return true
+			}
+			if strings.ContainsRune(rsyncValueOptions, option) {
+				if j == len(arg)-2 {
+					i++ // the value is the next argument
+				}
+				break
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package ssh
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// scp and sftp options, split by whether they take an argument. -P, -i and -J
+// are used to resolve targets; the rest are passed through unchanged.
+const (
+	scpFlagOptions   = "346ABCOpqRrsTv"
+	scpValueOptions  = "cDFiJloPSX"
+	sftpFlagOptions  = "1246AaCfNpqrv"
+	sftpValueOptions = "BbcDFiJloPRSsX"
+)
+
+// RemotePath is a remote file in scp syntax, [user@]host:path
+type RemotePath struct {
+	Target string // [user@]host, or [user@]host:port for URIs
+	Path   string
+}
+
+// ParseSCPArgs parses an scp command line of the form
+//
+//	[options] source ... target
+//
+// It returns the options vssh uses to resolve targets (-P, -i and -J), with
+// every other option in ExtraArgs, and the paths.
+func ParseSCPArgs(args []string) (*SSHOptions, []string, error) {
+	return parseToolArgs(args, scpFlagOptions, scpValueOptions)
+}
+
+// ParseSFTPArgs parses an sftp command line of the form
+//
+//	[options] destination
+//
+// It returns the options vssh uses to resolve the target (-P, -i and -J), with
+// every other option in ExtraArgs, and the destination.
+func ParseSFTPArgs(args []string) (*SSHOptions, []string, error) {
+	return parseToolArgs(args, sftpFlagOptions, sftpValueOptions)
+}
+
+// parseToolArgs parses the options of scp or sftp, which end at the first
+// argument that isn't one
+func parseToolArgs(args []string, flagOptions, valueOptions string) (*SSHOptions, []string, error) {
+	options := &SSHOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return options, args[i+1:], nil
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return options, args[i:], nil
+		}
+		if strings.HasPrefix(arg, "--") {
+			return nil, nil, fmt.Errorf("unknown option %s", arg)
+		}
+
+		// Options may be grouped (-rp) and values attached (-P2222)
+		for j := 1; j < len(arg); j++ {
+			option := arg[j]
+
+			if strings.IndexByte(flagOptions, option) >= 0 {
+				options.ExtraArgs = append(options.ExtraArgs, "-"+string(option))
+				continue
+			}
+			if strings.IndexByte(valueOptions, option) < 0 {
+				return nil, nil, fmt.Errorf("unknown option -%c", option)
+			}
+
+			value := arg[j+1:]
+			if value == "" {
+				if i+1 >= len(args) {
+					return nil, nil, fmt.Errorf("option -%c requires an argument", option)
+				}
+				i++
+				value = args[i]
+			}
+
+			switch option {
+			case 'P':
+				if !isPort(value) {
+					return nil, nil, fmt.Errorf("invalid port %q", value)
+				}
+				options.Port = value
+			case 'i':
+				options.IdentityFile = value
+			case 'J':
+				options.ProxyJump = value
+			default:
+				options.ExtraArgs = append(options.ExtraArgs, "-"+string(option), value)
+			}
+			break
+		}
+	}
+
+	return options, nil, nil
+}
+
+// ParseRemotePath parses a remote scp path, [user@]host:path or a
+// scp://[user@]host[:port]/path or sftp:// URI. Like scp, a path is local
+// unless it has a colon before any slash. On Windows drive letters (C:\) are
+// local.
+func ParseRemotePath(spec string) (*RemotePath, bool) {
+	for _, scheme := range []string{"scp://", "sftp://"} {
+		if rest, ok := strings.CutPrefix(spec, scheme); ok {
+			target, path, _ := strings.Cut(rest, "/")
+			if target == "" {
+				return nil, false
+			}
+			return &RemotePath{Target: target, Path: path}, true
+		}
+	}
+
+	if runtime.GOOS == "windows" && len(spec) >= 2 && spec[1] == ':' && isDriveLetter(spec[0]) {
+		return nil, false
+	}
+
+	// Skip over a bracketed IPv6 address, whose colons don't end the host
+	start := 0
+	if at := strings.Index(spec, "@["); at >= 0 {
+		start = at + 1
+	}
+	if strings.HasPrefix(spec[start:], "[") {
+		end := strings.Index(spec[start:], "]")
+		if end < 0 {
+			return nil, false
+		}
+		start += end + 1
+	}
+
+	for i := start; i < len(spec); i++ {
+		switch spec[i] {
+		case '/':
+			return nil, false
+		case ':':
+			if i == 0 {
+				return nil, false
+			}
+			return &RemotePath{Target: spec[:i], Path: spec[i+1:]}, true
+		}
+	}
+	return nil, false
+}
+
+// FormatRemotePath formats a remote path on a resolved target in scp syntax,
+// bracketing IPv6 addresses
+func FormatRemotePath(target *SSHTarget, path string) string {
+	host := target.SSHHost()
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return fmt.Sprintf("%s@%s:%s", target.Username, host, path)
+}
+
+// isDriveLetter reports whether c is an ASCII letter
+func isDriveLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// interruptSignals end a session. While a session runs vssh passes them on
+// instead of dying, so ssh can clean up, the terminal is restored and vssh
+// reports how the session ended.
+var interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// runForwardingSignals runs cmd, passing interrupt signals vssh receives on
+// to it until it exits. On Windows, where only killing a process is
+// supported, the console sends Ctrl-C to ssh itself.
+func runForwardingSignals(cmd *exec.Cmd) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, interruptSignals...)
+	defer signal.Stop(signals)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return cmd.Wait()
+}
+
+// signalSession passes interrupt signals vssh receives on to the remote
+// command of a session until the returned function is called. Servers may
+// ignore them, so a second signal closes the session.
+func signalSession(session *gossh.Session) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, interruptSignals...)
+	done := make(chan struct{})
+
+	go func() {
+		received := 0
+		for {
+			select {
+			case sig := <-signals:
+				received++
+				if received > 1 {
+					session.Close()
+					continue
+				}
+				session.Signal(sessionSignal(sig))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// sessionSignal returns the SSH name of a signal
+func sessionSignal(sig os.Signal) gossh.Signal {
+	switch sig {
+	case syscall.SIGTERM:
+		return gossh.SIGTERM
+	case syscall.SIGHUP:
+		return gossh.SIGHUP
+	case syscall.SIGQUIT:
+		return gossh.SIGQUIT
+	default:
+		return gossh.SIGINT
+	}
+}
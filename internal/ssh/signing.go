@@ -1,12 +1,19 @@
 package ssh
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"vssh/internal/utils"
 	"vssh/internal/vault"
 	"vssh/pkg/types"
 
@@ -19,6 +26,29 @@ type Signer struct {
 	vaultClient *vault.Client
 	config      *types.Config
 	logger      *logrus.Logger
+
+	// vaultUser is looked up once, when a key ID template first needs it
+	vaultUserOnce sync.Once
+	vaultUser     string
+
+	// caKeys caches the CA public key of each signing engine, for verifying
+	// the certificates it signs
+	caKeysMu sync.Mutex
+	caKeys   map[string]ssh.PublicKey
+
+	// onIssued is called with every certificate Vault issues
+	onIssued func(target *SSHTarget, plan *CertificatePlan, cert *ssh.Certificate)
+
+	// onSigned is called with how long every signing request to Vault took
+	onSigned func(d time.Duration, err error)
+
+	// roleChoices are the roles chosen for targets without a mapped role
+	roleChoices *RoleChoices
+
+	// roles caches the Vault roles read for pre-flight checks, nil for a
+	// role that couldn't be read
+	rolesMu sync.Mutex
+	roles   map[string]*vault.SSHRole
 }
 
 // NewSigner creates a new SSH signer
@@ -30,95 +60,355 @@ func NewSigner(vaultClient *vault.Client, config *types.Config, logger *logrus.L
 	}
 }
 
-// SSHTarget represents a parsed SSH connection target
-type SSHTarget struct {
-	Username string
-	Hostname string
-	Port     string
+// OnIssued registers a function called with every certificate Vault issues,
+// once it has been verified. target is nil for keys signed with SignKey or
+// SignHostKey.
+func (s *Signer) OnIssued(hook func(target *SSHTarget, plan *CertificatePlan, cert *ssh.Certificate)) {
+	s.onIssued = hook
 }
 
-// ParseSSHTarget parses an SSH target string like "user@hostname" or "hostname"
-func ParseSSHTarget(target string) (*SSHTarget, error) {
-	sshTarget := &SSHTarget{}
+// OnSigned registers a function called after every signing request to Vault
+// with how long it took and its error, if any
+func (s *Signer) OnSigned(hook func(d time.Duration, err error)) {
+	s.onSigned = hook
+}
 
-	// Split on @ to separate user and host
-	parts := strings.Split(target, "@")
-	if len(parts) == 2 {
-		sshTarget.Username = parts[0]
-		sshTarget.Hostname = parts[1]
-	} else if len(parts) == 1 {
-		// No username specified, use current user
-		currentUser := os.Getenv("USER")
-		if currentUser == "" {
-			return nil, fmt.Errorf("no username specified and USER environment variable not set")
-		}
-		sshTarget.Username = currentUser
-		sshTarget.Hostname = parts[0]
-	} else {
-		return nil, fmt.Errorf("invalid SSH target format: %s", target)
+// GetPrivateKeyPath returns the private key path for a target's user, or ""
+// when the key is on a PKCS#11 token. When a key type is required for the
+// target, the first of the user's keys of that type is used.
+func (s *Signer) GetPrivateKeyPath(target *SSHTarget) (string, error) {
+	if s.config.SSH.PKCS11Provider != "" {
+		return "", nil
 	}
+	keyType := s.ResolveKeyType(target)
 
-	if sshTarget.Username == "" {
-		return nil, fmt.Errorf("username cannot be empty")
-	}
-	if sshTarget.Hostname == "" {
-		return nil, fmt.Errorf("hostname cannot be empty")
+	// Check if user has specific configuration
+	if userConfig, exists := s.config.Users[target.Username]; exists {
+		return selectKey(userConfig.PrivateKeys(), keyType, target.Username)
 	}
 
-	return sshTarget, nil
-}
-
-// GetPrivateKeyPath returns the private key path for a user
-func (s *Signer) GetPrivateKeyPath(username string) (string, error) {
-	// Check if user has specific configuration
-	if userConfig, exists := s.config.Users[username]; exists {
-		return userConfig.PrivateKey, nil
+	// Fall back to the IdentityFile from ssh_config
+	if target.IdentityFile != "" && (keyType == "" || privateKeyType(target.IdentityFile) == keyType) {
+		return target.IdentityFile, nil
 	}
 
 	// Use default key path
-	keyPath := filepath.Join(s.config.SSH.KeyDirectory, "id_rsa")
+	keyDirectory := s.config.SSH.KeyDirectory
 
 	// Expand tilde if present
-	if strings.HasPrefix(keyPath, "~") {
+	if strings.HasPrefix(keyDirectory, "~") {
 		home, err := os.UserHomeDir()
 		if err != nil {
 			return "", fmt.Errorf("error getting home directory: %w", err)
 		}
-		keyPath = filepath.Join(home, keyPath[1:])
+		keyDirectory = filepath.Join(home, keyDirectory[1:])
+	}
+
+	// A required key type uses ssh-keygen's default file name for it
+	if keyType != "" {
+		return filepath.Join(keyDirectory, "id_"+strings.ReplaceAll(keyType, "-", "_")), nil
+	}
+
+	// Without an id_rsa key, an id_ed25519 key is used, or generated if enabled
+	keyPath := filepath.Join(keyDirectory, "id_rsa")
+	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+		ed25519Path := filepath.Join(keyDirectory, "id_ed25519")
+		if _, err := os.Stat(ed25519Path); err == nil || s.config.SSH.AutoGenerateKey {
+			keyPath = ed25519Path
+		}
 	}
 
 	return keyPath, nil
 }
 
-// GetCertificatePath returns the path where the signed certificate should be stored
-func (s *Signer) GetCertificatePath(username string) string {
-	certName := fmt.Sprintf("vault_signed_%s.pub", username)
-	return filepath.Join(s.config.SSH.KeyDirectory, certName)
+// selectKey returns the first key of keyType, or the first key when any type will do
+func selectKey(keys []string, keyType, username string) (string, error) {
+	if len(keys) == 0 {
+		return "", fmt.Errorf("no private key configured for user %s", username)
+	}
+	if keyType == "" {
+		return keys[0], nil
+	}
+	for _, key := range keys {
+		if privateKeyType(key) == keyType {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("no %s key configured for user %s", keyType, username)
+}
+
+// ResolveKeyType returns the key type required for a target: the target's
+// own (from --key-type), or the key_type mapped to its host, or "" for any
+func (s *Signer) ResolveKeyType(target *SSHTarget) string {
+	if target.KeyType != "" {
+		return target.KeyType
+	}
+	return s.config.ResolveHost(target.Hostname).KeyType
+}
+
+// PublicKeyType returns the type of the key in a public key file, named as
+// in types.KeyTypes
+func PublicKeyType(publicKeyPath string) (string, error) {
+	data, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return "", err
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse public key %s: %w", publicKeyPath, err)
+	}
+
+	switch algorithm := publicKey.Type(); {
+	case algorithm == ssh.KeyAlgoED25519:
+		return "ed25519", nil
+	case algorithm == ssh.KeyAlgoRSA:
+		return "rsa", nil
+	case strings.HasPrefix(algorithm, "ecdsa-sha2-"):
+		return "ecdsa", nil
+	case algorithm == ssh.KeyAlgoSKED25519:
+		return "ed25519-sk", nil
+	case algorithm == ssh.KeyAlgoSKECDSA256:
+		return "ecdsa-sk", nil
+	default:
+		return algorithm, nil
+	}
+}
+
+// privateKeyType returns the type of a private key from its public key file,
+// or "" if it can't be read
+func privateKeyType(privateKeyPath string) string {
+	keyType, _ := PublicKeyType(expandTilde(privateKeyPath) + ".pub")
+	return keyType
+}
+
+// GetCertificatePath returns the path where the certificate signed for a
+// user's key should be stored. The name identifies the key, role, signing
+// engine and Vault server, so switching between them never reuses a
+// certificate issued for another: vault_signed_<user>_<role>_<id>.pub
+func (s *Signer) GetCertificatePath(username, role, engine, privateKeyPath string) string {
+	certName := fmt.Sprintf("vault_signed_%s_%s_%s", username, role, s.certificateID(engine, privateKeyPath))
+	return filepath.Join(s.certificateDirectory(), certName+".pub")
+}
+
+// certificateDirectory returns the directory signed certificates are cached
+// in, ssh.certificate_directory or else ssh.key_directory
+func (s *Signer) certificateDirectory() string {
+	if s.config.SSH.CertificateDirectory != "" {
+		return expandTilde(s.config.SSH.CertificateDirectory)
+	}
+	return expandTilde(s.config.SSH.KeyDirectory)
+}
+
+// certificateID returns a short hash of the Vault server, signing engine and
+// key a certificate is issued for. The key is identified by its public key,
+// or by its path while the public key doesn't exist yet.
+func (s *Signer) certificateID(engine, privateKeyPath string) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00", strings.TrimRight(s.config.Vault.Address, "/"), s.config.Vault.Namespace, engine)
+
+	publicKeyPath := s.publicKeyPath(privateKeyPath)
+	if data, err := os.ReadFile(expandTilde(publicKeyPath)); err == nil {
+		if publicKey, _, _, _, err := ssh.ParseAuthorizedKey(data); err == nil {
+			hash.Write(publicKey.Marshal())
+			return hex.EncodeToString(hash.Sum(nil))[:16]
+		}
+	}
+	hash.Write([]byte(publicKeyPath))
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// publicKeyPath returns the public key file of a private key, or a
+// description of the token key with ssh.pkcs11_provider
+func (s *Signer) publicKeyPath(privateKeyPath string) string {
+	if s.config.SSH.PKCS11Provider == "" {
+		return privateKeyPath + ".pub"
+	}
+	description := "pkcs11:" + s.config.SSH.PKCS11Provider
+	if s.config.SSH.PKCS11Label != "" {
+		description += " (" + s.config.SSH.PKCS11Label + ")"
+	}
+	return description
+}
+
+// ResolveSigningEngine returns the SSH secrets engine mount used for a target,
+// preferring an engine mapped to the target's host pattern
+func (s *Signer) ResolveSigningEngine(target *SSHTarget) string {
+	return s.SigningEngineFor(s.config.ResolveHost(target.Hostname))
+}
+
+// SigningEngineFor returns the SSH secrets engine mount used for hosts with
+// the given settings
+func (s *Signer) SigningEngineFor(hostSettings types.HostSettings) string {
+	if hostSettings.SigningEngine != "" {
+		return normalizeEngine(hostSettings.SigningEngine)
+	}
+	return normalizeEngine(s.config.SSH.SigningEngine)
+}
+
+// normalizeEngine strips surrounding slashes from an engine mount path
+func normalizeEngine(engine string) string {
+	return strings.Trim(engine, "/")
+}
+
+// ResolveRole returns the Vault role used to sign certificates for a target.
+// A role mapped to the target's host pattern takes precedence, followed by the
+// user's vault_role, the global vault.role, a role chosen for the target with
+// SelectRole, and finally the username itself.
+func (s *Signer) ResolveRole(target *SSHTarget) string {
+	hostSettings := s.config.ResolveHost(target.Hostname)
+	if s.roleChoices != nil && !s.hasRoleMapping(target.Username, hostSettings) {
+		if role := s.roleChoices.Get(target.Username, target.Hostname); role != "" {
+			return role
+		}
+	}
+	return s.RoleFor(target.Username, hostSettings)
+}
+
+// RoleFor returns the Vault role used to sign certificates for username on
+// hosts with the given settings
+func (s *Signer) RoleFor(username string, hostSettings types.HostSettings) string {
+	// Default to using the username as the role (matches Vault CLI pattern)
+	vaultRole := username
+
+	if hostSettings.Role != "" {
+		vaultRole = hostSettings.Role
+	} else if userConfig, exists := s.config.Users[username]; exists && userConfig.VaultRole != "" {
+		vaultRole = userConfig.VaultRole
+	} else if s.config.Vault.Role != "" {
+		// Fallback to global role if configured (for backward compatibility)
+		vaultRole = s.config.Vault.Role
+	}
+
+	return vaultRole
+}
+
+// ResolvePrincipals returns the principals requested in the certificate for a
+// target: those given with --principal, mapped to its host, or configured for
+// its user. Without any, the role's default principals are used.
+func (s *Signer) ResolvePrincipals(target *SSHTarget) []string {
+	if len(target.Principals) > 0 {
+		return target.Principals
+	}
+	if principals := s.config.ResolveHost(target.Hostname).Principals; len(principals) > 0 {
+		return principals
+	}
+	return s.config.Users[target.Username].Principals
+}
+
+// ResolvePermissions returns the extensions and critical options requested in
+// the certificate for a target: those configured for its Vault role, with the
+// ones mapped to its host taking precedence
+func (s *Signer) ResolvePermissions(target *SSHTarget, vaultRole string) (map[string]string, map[string]string) {
+	roleConfig := s.config.Roles[strings.ToLower(vaultRole)]
+	hostSettings := s.config.ResolveHost(target.Hostname)
+	return mergeOptions(roleConfig.Extensions, hostSettings.Extensions), mergeOptions(roleConfig.CriticalOptions, hostSettings.CriticalOptions)
+}
+
+// mergeOptions returns base with the entries of override added, or nil when
+// both are empty
+func mergeOptions(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	maps.Copy(merged, base)
+	maps.Copy(merged, override)
+	return merged
 }
 
-// IsCertificateValid checks if an existing certificate is still valid
-func (s *Signer) IsCertificateValid(certPath string) bool {
-	// Check if certificate file exists
-	certData, err := os.ReadFile(certPath)
+// IsCertificateValid checks if an existing certificate is still valid and,
+// when principals are given, valid for each of them
+func (s *Signer) IsCertificateValid(certPath string, principals ...string) bool {
+	cert, err := readCertificate(certPath)
 	if err != nil {
-		s.logger.Debugf("Certificate file not found: %s", certPath)
+		s.logger.Debugf("No usable certificate: %v", err)
 		return false
 	}
+	return s.certificateUsable(cert) && s.hasPrincipals(cert, principals)
+}
 
-	// Parse the certificate
-	pubKey, _, _, _, err := ssh.ParseAuthorizedKey(certData)
+// isPlanValid checks if the planned certificate exists, is still valid and
+// was signed as planned, so it can be reused
+func (s *Signer) isPlanValid(plan *CertificatePlan) bool {
+	cert, err := readCertificate(plan.Path)
 	if err != nil {
-		s.logger.Debugf("Failed to parse certificate: %v", err)
+		s.logger.Debugf("No usable certificate: %v", err)
 		return false
 	}
+	return s.certificateUsable(cert) && s.matchesPlan(cert, plan)
+}
 
-	// Check if it's actually a certificate
-	cert, ok := pubKey.(*ssh.Certificate)
-	if !ok {
-		s.logger.Debug("Public key is not a certificate")
+// matchesPlan checks that a certificate carries the principals, extensions
+// and critical options requested in plan
+func (s *Signer) matchesPlan(cert *ssh.Certificate, plan *CertificatePlan) bool {
+	if !s.hasPrincipals(cert, plan.Principals) {
 		return false
 	}
+	for name, value := range plan.Extensions {
+		if granted, ok := cert.Extensions[name]; !ok || granted != value {
+			s.logger.Debugf("Certificate lacks extension %s", name)
+			return false
+		}
+	}
+	for name, value := range plan.CriticalOptions {
+		if granted, ok := cert.CriticalOptions[name]; !ok || granted != value {
+			s.logger.Debugf("Certificate lacks critical option %s=%s", name, value)
+			return false
+		}
+	}
+	return true
+}
+
+// hasPrincipals checks that a certificate is valid for each of principals
+func (s *Signer) hasPrincipals(cert *ssh.Certificate, principals []string) bool {
+	for _, principal := range principals {
+		if !slices.Contains(cert.ValidPrincipals, principal) {
+			s.logger.Debugf("Certificate is not valid for principal %s", principal)
+			return false
+		}
+	}
+	return true
+}
+
+// checkLoginPrincipal warns when a newly signed certificate isn't valid for
+// the target's login user, which sshd would reject with an opaque "Permission
+// denied". With target.Strict it is an error instead.
+func (s *Signer) checkLoginPrincipal(target *SSHTarget, signedCert string) error {
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signedCert))
+	if err != nil {
+		return fmt.Errorf("failed to parse signed certificate: %w", err)
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	// A certificate without principals is valid for any user
+	if !ok || len(cert.ValidPrincipals) == 0 || slices.Contains(cert.ValidPrincipals, target.Username) {
+		return nil
+	}
+
+	err = fmt.Errorf("the certificate signed for %s@%s is valid for %s, not %s; check the allowed_users and default_user of Vault role %s or the configured principals",
+		target.Username, target.Hostname, strings.Join(cert.ValidPrincipals, ", "), target.Username, s.ResolveRole(target))
+	if target.Strict {
+		return err
+	}
+	s.logger.Warnf("%v", err)
+	return nil
+}
 
+// CertificateExpiry returns when the certificate at certPath expires, or the
+// zero time for a certificate that never does
+func CertificateExpiry(certPath string) (time.Time, error) {
+	cert, err := readCertificate(certPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if cert.ValidBefore == ssh.CertTimeInfinity {
+		return time.Time{}, nil
+	}
+	return time.Unix(int64(cert.ValidBefore), 0), nil
+}
+
+// certificateUsable checks that a certificate is valid now and will not be
+// renewed yet under the ssh.renew_before policy
+func (s *Signer) certificateUsable(cert *ssh.Certificate) bool {
 	// Check if certificate is still valid (not expired)
 	now := uint64(time.Now().Unix())
 	if cert.ValidBefore != 0 && now >= cert.ValidBefore {
@@ -132,11 +422,21 @@ func (s *Signer) IsCertificateValid(certPath string) bool {
 		return false
 	}
 
-	// Consider certificate valid if it has more than 5 minutes remaining
-	if cert.ValidBefore != 0 {
+	// Consider certificate valid until it is within the renewal margin of expiring
+	if cert.ValidBefore != 0 && cert.ValidBefore != ssh.CertTimeInfinity {
+		ttl := s.config.SSH.CertificateTTL
+		if cert.ValidAfter != 0 {
+			ttl = time.Duration(cert.ValidBefore-cert.ValidAfter) * time.Second
+		}
+		margin, err := s.config.SSH.RenewMargin(ttl)
+		if err != nil {
+			s.logger.Debugf("Invalid renewal policy: %v", err)
+			return false
+		}
+
 		remaining := time.Duration(cert.ValidBefore-now) * time.Second
-		if remaining < 5*time.Minute {
-			s.logger.Debugf("Certificate expires soon: %v remaining", remaining)
+		if remaining < margin {
+			s.logger.Debugf("Certificate expires soon: %v remaining, renewing %v before expiry", remaining, margin)
 			return false
 		}
 		s.logger.Debugf("Certificate is valid with %v remaining", remaining)
@@ -145,37 +445,56 @@ func (s *Signer) IsCertificateValid(certPath string) bool {
 	return true
 }
 
-// SignSSHKey signs an SSH public key using Vault
-func (s *Signer) SignSSHKey(username string, publicKeyPath string) (string, error) {
+// SignSSHKey signs the planned public key with the planned Vault SSH engine and role
+func (s *Signer) SignSSHKey(plan *CertificatePlan) (string, error) {
 	// Read the public key
-	pubKeyData, err := os.ReadFile(publicKeyPath)
+	pubKeyData, err := os.ReadFile(plan.PublicKeyPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read public key %s: %w", publicKeyPath, err)
+		return "", fmt.Errorf("failed to read public key %s: %w", plan.PublicKeyPath, err)
 	}
 
-	// Get the vault role for this user
-	// Default to using the username as the role (matches Vault CLI pattern)
-	vaultRole := username
+	s.logger.Debugf("Signing SSH key %s with %s role %s", plan.PublicKeyPath, plan.Engine, plan.Role)
+	return s.SignPublicKey(plan, string(pubKeyData))
+}
 
-	// Allow override from user configuration
-	if userConfig, exists := s.config.Users[username]; exists && userConfig.VaultRole != "" {
-		vaultRole = userConfig.VaultRole
-	} else if s.config.Vault.Role != "" {
-		// Fallback to global role if configured (for backward compatibility)
-		vaultRole = s.config.Vault.Role
+// SignPublicKey signs an SSH public key in authorized_keys format as planned,
+// returning the certificate. Vault clamps the TTL to the role's max_ttl.
+func (s *Signer) SignPublicKey(plan *CertificatePlan, publicKey string) (string, error) {
+	if err := s.preflight(plan); err != nil {
+		return "", err
 	}
 
-	s.logger.Debugf("Signing SSH key for user %s with role %s", username, vaultRole)
-
 	// Prepare signing request
-	path := fmt.Sprintf("%s/sign/%s", s.config.SSH.SigningEngine, vaultRole)
 	data := map[string]interface{}{
-		"public_key": string(pubKeyData),
-		"ttl":        s.config.SSH.CertificateTTL.String(),
+		"public_key": publicKey,
+	}
+	if plan.TTL > 0 {
+		data["ttl"] = plan.TTL.String()
+	}
+	if plan.CertType != "" {
+		data["cert_type"] = plan.CertType
+	}
+	if len(plan.Principals) > 0 {
+		data["valid_principals"] = strings.Join(plan.Principals, ",")
+	}
+	if plan.KeyID != "" {
+		data["key_id"] = plan.KeyID
+	}
+	if len(plan.Extensions) > 0 {
+		data["extensions"] = plan.Extensions
+	}
+	if len(plan.CriticalOptions) > 0 {
+		data["critical_options"] = plan.CriticalOptions
 	}
 
 	// Make the signing request to Vault
-	secret, err := s.vaultClient.GetClient().Logical().Write(path, data)
+	spinner := utils.StartSpinner(fmt.Sprintf("Signing certificate with Vault role %s...", plan.Role))
+	started := time.Now()
+	secret, err := s.vaultClient.GetClient().Logical().Write(plan.SignPath, data)
+	spinner.Stop()
+	if s.onSigned != nil {
+		s.onSigned(time.Since(started), err)
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to sign SSH key: %w", err)
 	}
@@ -189,56 +508,378 @@ func (s *Signer) SignSSHKey(username string, publicKeyPath string) (string, erro
 	if !ok {
 		return "", fmt.Errorf("signed_key not found in Vault response")
 	}
+	if err := s.verifySignedKey(plan.Engine, publicKey, signedKey); err != nil {
+		return "", fmt.Errorf("Vault returned an invalid certificate: %w", err)
+	}
 
-	s.logger.Debugf("Successfully signed SSH key for user %s", username)
+	s.logger.Debugf("Successfully signed SSH key with role %s", plan.Role)
 	return signedKey, nil
 }
 
-// EnsureSSHCertificate ensures a valid SSH certificate exists for the user
-func (s *Signer) EnsureSSHCertificate(username string) (string, error) {
-	certPath := s.GetCertificatePath(username)
+// verifySignedKey checks a certificate returned by Vault against the
+// submitted public key and the engine's CA, so a misconfigured engine's
+// response is never cached or used
+func (s *Signer) verifySignedKey(engine, publicKey, signedKey string) error {
+	caKey, err := s.caKey(engine)
+	if err != nil {
+		return err
+	}
+	return VerifySignedKey(signedKey, publicKey, caKey)
+}
+
+// VerifySignedKey checks that signedKey parses as a certificate for publicKey,
+// both in authorized_keys format, signed by caKey
+func VerifySignedKey(signedKey, publicKey string, caKey ssh.PublicKey) error {
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signedKey))
+	if err != nil {
+		return fmt.Errorf("failed to parse signed_key: %w", err)
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	if !ok {
+		return fmt.Errorf("signed_key is a %s public key, not a certificate", parsed.Type())
+	}
+
+	submitted, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return fmt.Errorf("failed to parse public key: %w", err)
+	}
+	if !bytes.Equal(cert.Key.Marshal(), submitted.Marshal()) {
+		return fmt.Errorf("certificate is for %s, not the submitted key %s",
+			ssh.FingerprintSHA256(cert.Key), ssh.FingerprintSHA256(submitted))
+	}
+	if !bytes.Equal(cert.SignatureKey.Marshal(), caKey.Marshal()) {
+		return fmt.Errorf("certificate is signed by %s, not the CA %s",
+			ssh.FingerprintSHA256(cert.SignatureKey), ssh.FingerprintSHA256(caKey))
+	}
+
+	// CheckCert verifies the signature. The validity period is checked at its
+	// start, so clock skew with Vault doesn't matter, and the principal and
+	// critical options are accepted as issued.
+	checker := &ssh.CertChecker{
+		Clock:                    func() time.Time { return time.Unix(int64(cert.ValidAfter), 0) },
+		SupportedCriticalOptions: slices.Collect(maps.Keys(cert.CriticalOptions)),
+	}
+	var principal string
+	if len(cert.ValidPrincipals) > 0 {
+		principal = cert.ValidPrincipals[0]
+	}
+	return checker.CheckCert(principal, cert)
+}
+
+// caKey returns the CA public key of an engine, reading it from Vault once
+func (s *Signer) caKey(engine string) (ssh.PublicKey, error) {
+	s.caKeysMu.Lock()
+	defer s.caKeysMu.Unlock()
+
+	if caKey, ok := s.caKeys[engine]; ok {
+		return caKey, nil
+	}
+	data, err := s.vaultClient.SSHCAPublicKey(engine)
+	if err != nil {
+		return nil, err
+	}
+	caKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA public key of %s: %w", engine, err)
+	}
+	if s.caKeys == nil {
+		s.caKeys = make(map[string]ssh.PublicKey)
+	}
+	s.caKeys[engine] = caKey
+	return caKey, nil
+}
+
+// SignKey signs any public key in authorized_keys format with a Vault role,
+// valid for principals, or the role's default principals when none are given.
+// A ttl of 0 leaves the validity to the role.
+func (s *Signer) SignKey(engine, vaultRole, publicKey string, principals []string, ttl time.Duration) (string, error) {
+	return s.signKey("", engine, vaultRole, publicKey, principals, ttl)
+}
+
+// signKey signs a public key as a certificate of certType, "host" or "" for a
+// user certificate
+func (s *Signer) signKey(certType, engine, vaultRole, publicKey string, principals []string, ttl time.Duration) (string, error) {
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey)); err != nil {
+		return "", fmt.Errorf("not an SSH public key: %w", err)
+	}
+
+	engine = normalizeEngine(engine)
+	plan := &CertificatePlan{
+		Role:       vaultRole,
+		Engine:     engine,
+		SignPath:   fmt.Sprintf("%s/sign/%s", engine, vaultRole),
+		TTL:        ttl,
+		CertType:   certType,
+		Principals: principals,
+	}
+	s.logger.Debugf("Signing public key with %s role %s", engine, vaultRole)
+	signedCert, err := s.SignPublicKey(plan, publicKey)
+	if err != nil {
+		return "", err
+	}
+	s.issued(nil, plan, signedCert)
+	return signedCert, nil
+}
+
+// issued logs the serial number and key ID of a certificate Vault issued, so
+// it can be matched to the server's auth log, and passes it to the OnIssued hook
+func (s *Signer) issued(target *SSHTarget, plan *CertificatePlan, signedCert string) {
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signedCert))
+	if err != nil {
+		return
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	if !ok {
+		return
+	}
+
+	fields := logrus.Fields{
+		"event":       "certificate_issued",
+		"role":        plan.Role,
+		"cert_serial": cert.Serial,
+		"key_id":      cert.KeyId,
+	}
+	if target != nil {
+		fields["user"] = target.Username
+		fields["host"] = target.Hostname
+		s.logger.WithFields(fields).Infof("Issued certificate serial %d, key ID %q for %s@%s", cert.Serial, cert.KeyId, target.Username, target.Hostname)
+	} else {
+		s.logger.WithFields(fields).Infof("Issued certificate serial %d, key ID %q", cert.Serial, cert.KeyId)
+	}
+	if s.onIssued != nil {
+		s.onIssued(target, plan, cert)
+	}
+}
+
+// KeyID returns the key ID to request for a target's certificate from
+// ssh.key_id_template, or "" to leave it to Vault
+func (s *Signer) KeyID(target *SSHTarget, plan *CertificatePlan) (string, error) {
+	if s.config.SSH.KeyIDTemplate == "" {
+		return "", nil
+	}
+	return s.config.SSH.KeyID(types.KeyIDFields{
+		VaultUser: s.tokenUser(),
+		Username:  target.Username,
+		Hostname:  target.Hostname,
+		Role:      plan.Role,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// tokenUser returns the user logged in to Vault, or "" when it can't be
+// looked up
+func (s *Signer) tokenUser() string {
+	s.vaultUserOnce.Do(func() {
+		if s.vaultClient == nil {
+			return
+		}
+		user, err := s.vaultClient.TokenUser()
+		if err != nil {
+			s.logger.Debugf("Could not look up the Vault user for the key ID: %v", err)
+			return
+		}
+		s.vaultUser = user
+	})
+	return s.vaultUser
+}
+
+// IsSecurityKey reports whether a public key file holds a FIDO2 security key
+// (sk-ssh-ed25519@openssh.com or sk-ecdsa-sha2-nistp256@openssh.com)
+func IsSecurityKey(publicKeyPath string) bool {
+	data, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimSpace(string(data)), "sk-")
+}
+
+// ensureKeyPair generates a key pair for the target's user when the key to
+// sign doesn't exist and ssh.auto_generate_key is set. The key has the type
+// required for the target, or is ed25519 when any type will do; security
+// keys and PKCS#11 tokens are never generated.
+func (s *Signer) ensureKeyPair(target *SSHTarget) error {
+	keyType := s.ResolveKeyType(target)
+	if keyType == "" {
+		keyType = "ed25519"
+	}
+	if !s.config.SSH.AutoGenerateKey || !slices.Contains(KeyTypes, keyType) {
+		return nil
+	}
+	privateKeyPath, err := s.GetPrivateKeyPath(target)
+	if err != nil || privateKeyPath == "" {
+		return err
+	}
+	privateKeyPath = expandTilde(privateKeyPath)
+	_, privateErr := os.Stat(privateKeyPath)
+	_, publicErr := os.Stat(privateKeyPath + ".pub")
+	if privateErr == nil && publicErr == nil {
+		return nil
+	}
+
+	// Only one vssh process generates the key, the others wait for it and
+	// sign the key it generated. The lock is kept with the certificates
+	// rather than in the key directory. An existing private key is never
+	// replaced, even without its public key.
+	lockDir := s.certificateDirectory()
+	if err := os.MkdirAll(lockDir, 0700); err != nil {
+		return fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+	unlock, err := utils.AcquireLock(filepath.Join(lockDir, filepath.Base(privateKeyPath)+".keygen.lock"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := os.Stat(privateKeyPath); !os.IsNotExist(err) {
+		return nil
+	}
+
+	comment := os.Getenv("USER")
+	if hostname, err := os.Hostname(); err == nil {
+		comment += "@" + hostname
+	}
+	if err := GenerateKeyPair(keyType, privateKeyPath, comment, false); err != nil {
+		return fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	s.logger.Infof("Generated new %s key pair: %s", keyType, privateKeyPath)
+	return nil
+}
+
+// CertificatePlan describes the certificate used for a target and how it is
+// signed when it is missing or expired
+type CertificatePlan struct {
+	Path          string
+	Role          string
+	Engine        string
+	SignPath      string // Vault path the public key is written to for signing
+	PublicKeyPath string
+	TTL           time.Duration // validity requested when signing, or 0 for the role's default
+	CertType      string        // "host" for a host certificate, or "" for a user certificate
+	Principals    []string      // principals requested when signing, or none for the role's default
+	KeyID         string        // key ID requested when signing, or "" for Vault's default
+
+	// Extensions and CriticalOptions are requested when signing, in addition
+	// to the role's defaults
+	Extensions      map[string]string
+	CriticalOptions map[string]string
+	Valid           bool // an existing certificate is still valid and is reused
+}
+
+// PlanCertificate works out the certificate for the target's user without
+// signing anything
+func (s *Signer) PlanCertificate(target *SSHTarget) (*CertificatePlan, error) {
+	vaultRole := s.ResolveRole(target)
+	engine := s.ResolveSigningEngine(target)
+
+	privateKeyPath, err := s.GetPrivateKeyPath(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get private key path: %w", err)
+	}
+
+	// A TTL given for the target is a one-off request, so it is always signed
+	ttl := s.config.SSH.CertificateTTL
+	if target.TTL > 0 {
+		ttl = target.TTL
+	}
+
+	publicKeyPath := s.publicKeyPath(privateKeyPath)
+	certPath := s.GetCertificatePath(target.Username, vaultRole, engine, privateKeyPath)
+	extensions, criticalOptions := s.ResolvePermissions(target, vaultRole)
+	plan := &CertificatePlan{
+		Path:            certPath,
+		Role:            vaultRole,
+		Engine:          engine,
+		SignPath:        fmt.Sprintf("%s/sign/%s", engine, vaultRole),
+		PublicKeyPath:   publicKeyPath,
+		TTL:             ttl,
+		Principals:      s.ResolvePrincipals(target),
+		Extensions:      extensions,
+		CriticalOptions: criticalOptions,
+	}
+	plan.Valid = target.TTL == 0 && s.isPlanValid(plan)
+	return plan, nil
+}
+
+// EnsureSSHCertificate ensures a valid SSH certificate exists for the target's user
+func (s *Signer) EnsureSSHCertificate(target *SSHTarget) (string, error) {
+	// The certificate path depends on the key, so generate it first
+	if err := s.ensureKeyPair(target); err != nil {
+		return "", err
+	}
+
+	plan, err := s.PlanCertificate(target)
+	if err != nil {
+		return "", err
+	}
+	certPath := plan.Path
 
 	// Check if we already have a valid certificate
-	if s.IsCertificateValid(certPath) {
+	if plan.Valid {
 		s.logger.Debugf("Using existing valid certificate: %s", certPath)
 		return certPath, nil
 	}
 
-	s.logger.Infof("Generating new SSH certificate for user: %s", username)
+	// Ensure the certificate directory exists
+	if err := os.MkdirAll(filepath.Dir(certPath), 0700); err != nil {
+		return "", fmt.Errorf("failed to create certificate directory: %w", err)
+	}
 
-	// Get the private key path
-	privateKeyPath, err := s.GetPrivateKeyPath(username)
+	// Only one vssh process signs a certificate at a time, the others wait
+	// for it and use the certificate it wrote
+	unlock, err := utils.AcquireLock(strings.TrimSuffix(certPath, ".pub") + ".lock")
 	if err != nil {
-		return "", fmt.Errorf("failed to get private key path: %w", err)
+		return "", err
+	}
+	defer unlock()
+	if target.TTL == 0 && s.isPlanValid(plan) {
+		s.logger.Debugf("Using certificate signed by another vssh process: %s", certPath)
+		return certPath, nil
 	}
 
-	// Generate public key path from private key path
-	publicKeyPath := privateKeyPath + ".pub"
+	s.logger.Infof("Generating new SSH certificate for user %s with role %s", target.Username, plan.Role)
 
-	// Check if private key exists
-	if _, err := os.Stat(privateKeyPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("private key not found: %s. Please generate an SSH key pair first", privateKeyPath)
+	if plan.KeyID, err = s.KeyID(target, plan); err != nil {
+		return "", err
 	}
 
-	// Check if public key exists
-	if _, err := os.Stat(publicKeyPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("public key not found: %s. Please generate an SSH key pair first", publicKeyPath)
-	}
+	var signedCert string
+	if s.config.SSH.PKCS11Provider != "" {
+		// The private key never leaves the token, only its public key is signed
+		publicKey, err := PKCS11PublicKey(s.config.SSH.PKCS11Provider, s.config.SSH.PKCS11Label)
+		if err != nil {
+			return "", err
+		}
+		signedCert, err = s.SignPublicKey(plan, publicKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign SSH key: %w", err)
+		}
+	} else {
+		publicKeyPath := plan.PublicKeyPath
+		privateKeyPath := strings.TrimSuffix(publicKeyPath, ".pub")
 
-	// Sign the SSH key
-	signedCert, err := s.SignSSHKey(username, publicKeyPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to sign SSH key: %w", err)
+		// Check if private key exists
+		if _, err := os.Stat(privateKeyPath); os.IsNotExist(err) {
+			return "", fmt.Errorf("private key not found: %s. Run vssh keygen to generate a key pair", privateKeyPath)
+		}
+
+		// Check if public key exists
+		if _, err := os.Stat(publicKeyPath); os.IsNotExist(err) {
+			return "", fmt.Errorf("public key not found: %s. Run vssh keygen to generate a key pair", publicKeyPath)
+		}
+
+		// Sign the SSH key
+		signedCert, err = s.SignSSHKey(plan)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign SSH key: %w", err)
+		}
 	}
 
-	// Ensure the SSH directory exists
-	sshDir := filepath.Dir(certPath)
-	if err := os.MkdirAll(sshDir, 0700); err != nil {
-		return "", fmt.Errorf("failed to create SSH directory: %w", err)
+	s.issued(target, plan, signedCert)
+	if err := s.checkLoginPrincipal(target, signedCert); err != nil {
+		return "", err
 	}
 
 	// Write the signed certificate to file
-	if err := os.WriteFile(certPath, []byte(signedCert), 0644); err != nil {
+	if err := utils.WriteFileAtomic(certPath, []byte(signedCert), 0644); err != nil {
 		return "", fmt.Errorf("failed to write certificate file: %w", err)
 	}
 
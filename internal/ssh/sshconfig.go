@@ -0,0 +1,203 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vssh/pkg/types"
+)
+
+// SSHConfigHost holds the ssh_config values that apply to a host alias
+type SSHConfigHost struct {
+	HostName     string
+	User         string
+	Port         string
+	IdentityFile string
+	ProxyJump    string
+}
+
+// sshConfigBlock is a Host block from an ssh_config file
+type sshConfigBlock struct {
+	patterns []string
+	options  map[string]string
+}
+
+// SSHConfig is a parsed OpenSSH client configuration file
+type SSHConfig struct {
+	blocks []sshConfigBlock
+}
+
+// LoadSSHConfig parses an OpenSSH client configuration file, following Include
+// directives. Match blocks are not evaluated and are skipped.
+func LoadSSHConfig(path string) (*SSHConfig, error) {
+	config := &SSHConfig{}
+	// Options before the first Host line apply to every host
+	config.blocks = append(config.blocks, sshConfigBlock{patterns: []string{"*"}, options: map[string]string{}})
+
+	if err := config.parseFile(path, 0); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadUserSSHConfig loads the ssh_config file named in the vssh configuration.
+// It returns nil without an error when the file is disabled or doesn't exist.
+func LoadUserSSHConfig(config *types.Config) (*SSHConfig, error) {
+	path := config.SSH.SSHConfigFile
+	if path == "" || path == "none" {
+		return nil, nil
+	}
+
+	sshConfig, err := LoadSSHConfig(expandTilde(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh config %s: %w", path, err)
+	}
+	return sshConfig, nil
+}
+
+// parseFile reads one ssh_config file into the configuration
+func (c *SSHConfig) parseFile(path string, depth int) error {
+	if depth > 8 {
+		return fmt.Errorf("too many nested Include directives in %s", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	current := &c.blocks[len(c.blocks)-1]
+	skipping := false
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		keyword, value := splitSSHConfigLine(scanner.Text())
+		if keyword == "" {
+			continue
+		}
+
+		switch keyword {
+		case "host":
+			c.blocks = append(c.blocks, sshConfigBlock{patterns: strings.Fields(value), options: map[string]string{}})
+			current = &c.blocks[len(c.blocks)-1]
+			skipping = false
+		case "match":
+			// Match criteria need runtime context we don't have; ignore the block
+			skipping = true
+		case "include":
+			for _, pattern := range strings.Fields(value) {
+				pattern = expandTilde(pattern)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(filepath.Dir(path), pattern)
+				}
+				matches, _ := filepath.Glob(pattern)
+				for _, match := range matches {
+					if err := c.parseFile(match, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+			current = &c.blocks[len(c.blocks)-1]
+		default:
+			// The first obtained value for each option is used
+			if _, exists := current.options[keyword]; !exists && !skipping {
+				current.options[keyword] = value
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// splitSSHConfigLine splits an ssh_config line into a lowercased keyword and its value
+func splitSSHConfigLine(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+
+	// Keywords and values are separated by whitespace and/or a single '='
+	index := strings.IndexAny(line, " \t=")
+	if index < 0 {
+		return strings.ToLower(line), ""
+	}
+
+	keyword := strings.ToLower(line[:index])
+	value := strings.TrimSpace(line[index:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	value = strings.Trim(value, `"`)
+	return keyword, value
+}
+
+// Lookup returns the options that apply to a host alias, following ssh's
+// first-match-wins semantics
+func (c *SSHConfig) Lookup(alias string) SSHConfigHost {
+	options := map[string]string{}
+	for _, block := range c.blocks {
+		if !matchSSHConfigPatterns(block.patterns, alias) {
+			continue
+		}
+		for keyword, value := range block.options {
+			if _, exists := options[keyword]; !exists {
+				options[keyword] = value
+			}
+		}
+	}
+
+	host := SSHConfigHost{
+		HostName:     options["hostname"],
+		User:         options["user"],
+		Port:         options["port"],
+		IdentityFile: options["identityfile"],
+		ProxyJump:    options["proxyjump"],
+	}
+
+	if strings.EqualFold(host.ProxyJump, "none") {
+		host.ProxyJump = ""
+	}
+
+	// %h in HostName refers to the alias given on the command line
+	host.HostName = strings.ReplaceAll(host.HostName, "%h", alias)
+	host.IdentityFile = expandTilde(host.IdentityFile)
+
+	return host
+}
+
+// Hosts returns the literal host aliases named on Host lines, skipping
+// patterns containing wildcards and negations
+func (c *SSHConfig) Hosts() []string {
+	var hosts []string
+	seen := map[string]bool{}
+	for _, block := range c.blocks {
+		for _, pattern := range block.patterns {
+			if strings.ContainsAny(pattern, "*?!") || seen[pattern] {
+				continue
+			}
+			seen[pattern] = true
+			hosts = append(hosts, pattern)
+		}
+	}
+	return hosts
+}
+
+// matchSSHConfigPatterns matches a Host line's patterns; negated patterns exclude the host
+func matchSSHConfigPatterns(patterns []string, alias string) bool {
+	return types.MatchHostPattern(strings.Join(patterns, ","), alias)
+}
+
+// expandTilde expands a leading ~ to the user's home directory
+func expandTilde(path string) string {
+	if strings.HasPrefix(path, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,164 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"vssh/pkg/types"
+)
+
+// SSHTarget represents a parsed SSH connection target
+type SSHTarget struct {
+	Username     string
+	Hostname     string
+	Port         string
+	Alias        string        // ssh_config Host alias the target was given as, if any
+	IdentityFile string        // IdentityFile from ssh_config, if any
+	ProxyJump    string        // ProxyJump specification from vssh or ssh_config, if any
+	KeyType      string        // key type to use for this target from --key-type, if any
+	TTL          time.Duration // certificate TTL for this target from --ttl, if any
+	Principals   []string      // principals requested for this target with --principal, if any
+	Strict       bool          // fail instead of warning when a new certificate doesn't allow Username (--strict)
+	Mode         string        // authentication mode for this target from --mode, if any
+}
+
+// SSHHost returns the host to pass to ssh. Aliases are passed through
+// unchanged so that the rest of the user's ssh_config still applies.
+func (t *SSHTarget) SSHHost() string {
+	if t.Alias != "" {
+		return t.Alias
+	}
+	return t.Hostname
+}
+
+// ParseSSHTarget parses an SSH target string like "user@hostname" or "hostname"
+func ParseSSHTarget(target string) (*SSHTarget, error) {
+	sshTarget, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if sshTarget.Username == "" {
+		if err := sshTarget.useCurrentUser(); err != nil {
+			return nil, err
+		}
+	}
+
+	return sshTarget, nil
+}
+
+// ResolveTarget parses an SSH target and applies configured defaults. Host
+// aliases are resolved through the user's ssh_config. When the target has no
+// user@ prefix, the user mapped to the host in the vssh configuration is used,
+// then the ssh_config User, before falling back to the current user.
+func ResolveTarget(config *types.Config, target string) (*SSHTarget, error) {
+	sshTarget, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var sshConfigHost SSHConfigHost
+	sshConfig, err := LoadUserSSHConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if sshConfig != nil {
+		sshConfigHost = sshConfig.Lookup(sshTarget.Hostname)
+	}
+
+	if sshConfigHost.HostName != "" && sshConfigHost.HostName != sshTarget.Hostname {
+		sshTarget.Alias = sshTarget.Hostname
+		sshTarget.Hostname = sshConfigHost.HostName
+	}
+	if sshTarget.Port == "" {
+		sshTarget.Port = sshConfigHost.Port
+	}
+	sshTarget.IdentityFile = sshConfigHost.IdentityFile
+
+	hostSettings := config.ResolveHost(sshTarget.Hostname)
+
+	sshTarget.ProxyJump = hostSettings.ProxyJump
+	if sshTarget.ProxyJump == "" {
+		sshTarget.ProxyJump = sshConfigHost.ProxyJump
+	}
+
+	if sshTarget.Username == "" {
+		sshTarget.Username = hostSettings.User
+	}
+	if sshTarget.Username == "" {
+		sshTarget.Username = sshConfigHost.User
+	}
+	if sshTarget.Username == "" {
+		if err := sshTarget.useCurrentUser(); err != nil {
+			return nil, err
+		}
+	}
+
+	return sshTarget, nil
+}
+
+// parseTarget splits a target of the form [user@]host[:port] into its parts,
+// leaving Username empty if none was given. IPv6 literals may be bracketed
+// ([2001:db8::1] or [::1]:2222) and are stored without brackets.
+func parseTarget(target string) (*SSHTarget, error) {
+	sshTarget := &SSHTarget{}
+
+	// Split on @ to separate user and host
+	parts := strings.Split(target, "@")
+	if len(parts) == 2 {
+		sshTarget.Username = parts[0]
+		sshTarget.Hostname = parts[1]
+		if sshTarget.Username == "" {
+			return nil, fmt.Errorf("username cannot be empty")
+		}
+	} else if len(parts) == 1 {
+		sshTarget.Hostname = parts[0]
+	} else {
+		return nil, fmt.Errorf("invalid SSH target format: %s", target)
+	}
+
+	if strings.HasPrefix(sshTarget.Hostname, "[") {
+		end := strings.Index(sshTarget.Hostname, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("missing closing bracket in SSH target %s", target)
+		}
+
+		rest := sshTarget.Hostname[end+1:]
+		sshTarget.Hostname = sshTarget.Hostname[1:end]
+
+		if rest != "" {
+			if !strings.HasPrefix(rest, ":") || !isPort(rest[1:]) {
+				return nil, fmt.Errorf("invalid port in SSH target %s: %q", target, strings.TrimPrefix(rest, ":"))
+			}
+			sshTarget.Port = rest[1:]
+		}
+	} else if index := strings.LastIndex(sshTarget.Hostname, ":"); index >= 0 && strings.Count(sshTarget.Hostname, ":") == 1 {
+		// An inline port follows the colon; unbracketed hostnames with more
+		// than one colon are IPv6 literals and left alone
+		port := sshTarget.Hostname[index+1:]
+		if !isPort(port) {
+			return nil, fmt.Errorf("invalid port in SSH target %s: %q", target, port)
+		}
+		sshTarget.Port = port
+		sshTarget.Hostname = sshTarget.Hostname[:index]
+	}
+
+	if sshTarget.Hostname == "" {
+		return nil, fmt.Errorf("hostname cannot be empty")
+	}
+
+	return sshTarget, nil
+}
+
+// useCurrentUser sets the target username to the current user
+func (t *SSHTarget) useCurrentUser() error {
+	// No username specified, use current user
+	currentUser := os.Getenv("USER")
+	if currentUser == "" {
+		return fmt.Errorf("no username specified and USER environment variable not set")
+	}
+	t.Username = currentUser
+	return nil
+}
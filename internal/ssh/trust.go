@@ -0,0 +1,48 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vssh/internal/utils"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// AddTrustedCAKey adds a CA public key to a TrustedUserCAKeys file, creating
+// the file if needed and keeping any other CA keys in it. It reports whether
+// the key was added.
+func AddTrustedCAKey(path, caKey string) (bool, error) {
+	publicKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(caKey))
+	if err != nil {
+		return false, fmt.Errorf("invalid CA public key: %w", err)
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	for _, line := range strings.Split(string(existing), "\n") {
+		trusted, _, _, _, err := gossh.ParseAuthorizedKey([]byte(line))
+		if err == nil && bytes.Equal(trusted.Marshal(), publicKey.Marshal()) {
+			return false, nil
+		}
+	}
+
+	content := string(existing)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += strings.TrimSpace(caKey) + "\n"
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := utils.WriteFileAtomic(path, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, nil
+}
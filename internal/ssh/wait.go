@@ -0,0 +1,70 @@
+package ssh
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// probeTimeout bounds each attempt to reach an SSH server while waiting
+const probeTimeout = 5 * time.Second
+
+// WaitForHost waits until the target's SSH server answers, trying every
+// interval until timeout, for hosts that are still booting or rebooting.
+// Hosts reached through jump hosts can't be checked and are connected to
+// right away.
+func (c *Client) WaitForHost(target *SSHTarget, options *SSHOptions, interval, timeout time.Duration) error {
+	if target.ProxyJump != "" || len(options.JumpHosts) > 0 || options.ProxyJump != "" {
+		c.logger.Warnf("Not waiting for %s, which is reached through a jump host", target.Hostname)
+		return nil
+	}
+
+	address := net.JoinHostPort(target.Hostname, cmp.Or(options.Port, target.Port, "22"))
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		err := probeSSH(address)
+		if err == nil {
+			if attempt > 1 {
+				c.logger.Infof("%s is up", address)
+			}
+			return nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("%s did not come up within %s: %w", address, timeout, err)
+		}
+
+		if attempt == 1 {
+			c.logger.Infof("Waiting for %s to accept SSH connections (up to %s)...", address, timeout)
+		}
+		c.logger.Debugf("Attempt %d to reach %s failed: %v", attempt, address, err)
+		time.Sleep(interval)
+	}
+}
+
+// probeSSH connects to address and reads the SSH server's version banner,
+// which a port that merely accepts connections, such as a load balancer's in
+// front of a booting instance, doesn't send
+func probeSSH(address string) error {
+	conn, err := net.DialTimeout("tcp", address, probeTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(probeTimeout))
+
+	// Servers may send other lines before the version (RFC 4253 4.2)
+	reader := bufio.NewReader(conn)
+	for range 10 {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, "SSH-") {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("no SSH server answered: %w", err)
+		}
+	}
+	return fmt.Errorf("no SSH server answered")
+}
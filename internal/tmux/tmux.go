@@ -0,0 +1,92 @@
+// Package tmux opens tmux windows with one pane per host.
+package tmux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Window is a tmux window with a pane running each command
+type Window struct {
+	Session string   // session to open the window in, created unless it exists
+	Name    string   // name of the window
+	Panes   []string // shell command run in each pane
+	Sync    bool     // send input typed in one pane to all of them
+}
+
+// Inside reports whether vssh runs in a tmux client, where windows are opened
+// in the current session instead of attaching to one
+func Inside() bool {
+	return os.Getenv("TMUX") != ""
+}
+
+// CreateArgs returns the tmux arguments creating the window with its first
+// pane, in a new session or in an existing one, and printing the window's ID
+func CreateArgs(w Window, existing bool) []string {
+	args := []string{"new-session", "-d", "-s", w.Session}
+	if existing {
+		args = []string{"new-window", "-d", "-t", "=" + w.Session + ":"}
+	}
+	return append(args, "-n", w.Name, "-P", "-F", "#{window_id}", w.Panes[0])
+}
+
+// LayoutArgs returns the tmux arguments splitting window id into a pane for
+// each remaining command, tiled, and synchronizing them if asked to. The
+// layout is tiled after each split so there is room for the next pane.
+func LayoutArgs(w Window, id string) []string {
+	var args []string
+	for _, pane := range w.Panes[1:] {
+		args = append(args, "split-window", "-t", id, pane, ";", "select-layout", "-t", id, "tiled", ";")
+	}
+	if w.Sync {
+		args = append(args, "set-window-option", "-t", id, "synchronize-panes", "on", ";")
+	}
+	return append(args, "select-pane", "-t", id+".0")
+}
+
+// Open opens the window, then attaches to its session or, inside tmux,
+// switches to it
+func Open(w Window) error {
+	if len(w.Panes) == 0 {
+		return errors.New("no panes to open")
+	}
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return fmt.Errorf("tmux not found in PATH: %w", err)
+	}
+
+	existing := exec.Command("tmux", "has-session", "-t", "="+w.Session).Run() == nil
+	output, err := tmux(CreateArgs(w, existing)...)
+	if err != nil {
+		return err
+	}
+	id := strings.TrimSpace(output)
+	if _, err := tmux(LayoutArgs(w, id)...); err != nil {
+		return err
+	}
+
+	if Inside() {
+		_, err = tmux("select-window", "-t", id, ";", "switch-client", "-t", "="+w.Session)
+		return err
+	}
+	attach := exec.Command("tmux", "attach-session", "-t", id)
+	attach.Stdin, attach.Stdout, attach.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return attach.Run()
+}
+
+// tmux runs a tmux command and returns its output
+func tmux(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("tmux", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("tmux %s failed: %s", args[0], message)
+		}
+		return "", fmt.Errorf("tmux %s failed: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
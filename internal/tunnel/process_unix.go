@@ -0,0 +1,28 @@
+//go:build !windows
+
+package tunnel
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// processRunning reports whether a process with the given ID exists
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// Terminate asks a process to exit
+func Terminate(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
+
+// Detach makes cmd run in its own session so it outlives vssh and its terminal
+func Detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,39 @@
+//go:build windows
+
+package tunnel
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const (
+	stillActive     = 259
+	detachedProcess = 0x00000008
+)
+
+// processRunning reports whether a process with the given ID exists
+func processRunning(pid int) bool {
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// Terminate stops a process. Windows has no termination signal to send.
+func Terminate(process *os.Process) error {
+	return process.Kill()
+}
+
+// Detach makes cmd run without a console so it outlives vssh and its terminal
+func Detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
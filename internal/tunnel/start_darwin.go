@@ -0,0 +1,17 @@
+package tunnel
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// processStart returns when a process started, or "" if it isn't running
+func processStart(pid int) string {
+	info, err := unix.SysctlKinfoProc("kern.proc.pid", pid)
+	if err != nil || info.Proc.P_pid != int32(pid) {
+		return ""
+	}
+	start := info.Proc.P_starttime
+	return fmt.Sprintf("%d.%06d", start.Sec, start.Usec)
+}
@@ -0,0 +1,26 @@
+package tunnel
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// processStart returns when a process started, as its start time in clock
+// ticks since boot and the ID of the boot, or "" if it isn't running
+func processStart(pid int) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return ""
+	}
+
+	// The command name in parentheses may contain spaces; the start time is
+	// the 22nd field, the 20th after the name
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 20 {
+		return ""
+	}
+	bootID, _ := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	return strings.TrimSpace(string(bootID)) + "/" + fields[19]
+}
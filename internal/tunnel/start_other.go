@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package tunnel
+
+// processStart can't tell when a process started on this system, so tunnels
+// are only identified by their process ID
+func processStart(pid int) string {
+	return ""
+}
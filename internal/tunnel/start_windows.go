@@ -0,0 +1,22 @@
+package tunnel
+
+import (
+	"strconv"
+
+	"golang.org/x/sys/windows"
+)
+
+// processStart returns when a process started, or "" if it isn't running
+func processStart(pid int) string {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return ""
+	}
+	defer windows.CloseHandle(handle)
+
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return ""
+	}
+	return strconv.FormatInt(creation.Nanoseconds(), 10)
+}
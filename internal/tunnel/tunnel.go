@@ -0,0 +1,240 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"vssh/internal/utils"
+)
+
+// Tunnel records a running forwarding-only ssh session
+type Tunnel struct {
+	Target     string    `json:"target"`
+	Forwards   []string  `json:"forwards"`
+	PID        int       `json:"pid"`
+	Background bool      `json:"background"`
+	Started    time.Time `json:"started"`
+
+	// ProcessStart is when the ssh process started, recorded by Add, so a
+	// process that gets its ID once the tunnel is gone isn't taken for it
+	ProcessStart string `json:"process_start,omitempty"`
+}
+
+// Running reports whether the tunnel's ssh process is still running
+func (t Tunnel) Running() bool {
+	return processRunning(t.PID) && processStart(t.PID) == t.ProcessStart
+}
+
+// Store is a JSON file of the tunnels started by vssh
+type Store struct {
+	path string
+}
+
+// NewStore creates a store backed by path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// DefaultPath returns the tunnel state file location inside stateDir
+func DefaultPath(stateDir string) string {
+	return filepath.Join(stateDir, "tunnels.json")
+}
+
+// List returns the tunnels whose ssh process is still running. Tunnels that
+// have exited are dropped from the store.
+func (s *Store) List() ([]Tunnel, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return s.running()
+}
+
+// Add records a started tunnel
+func (s *Store) Add(tunnel Tunnel) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tunnels, err := s.running()
+	if err != nil {
+		return err
+	}
+	tunnel.ProcessStart = processStart(tunnel.PID)
+	return s.save(append(tunnels, tunnel))
+}
+
+// Remove forgets the tunnel with the given ssh process ID
+func (s *Store) Remove(pid int) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tunnels, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	var kept []Tunnel
+	for _, tunnel := range tunnels {
+		if tunnel.PID != pid {
+			kept = append(kept, tunnel)
+		}
+	}
+	return s.save(kept)
+}
+
+// lock takes the lock on the state file, so concurrent vssh processes don't
+// lose each other's tunnels
+func (s *Store) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return nil, fmt.Errorf("error creating state directory: %w", err)
+	}
+	return utils.AcquireLock(s.path + ".lock")
+}
+
+// running returns the recorded tunnels still running, dropping the others
+// from the store
+func (s *Store) running() ([]Tunnel, error) {
+	tunnels, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var running []Tunnel
+	for _, tunnel := range tunnels {
+		if tunnel.Running() {
+			running = append(running, tunnel)
+		}
+	}
+
+	if len(running) != len(tunnels) {
+		if err := s.save(running); err != nil {
+			return nil, err
+		}
+	}
+	return running, nil
+}
+
+// load reads every recorded tunnel. A missing file means no tunnels.
+func (s *Store) load() ([]Tunnel, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading tunnel state: %w", err)
+	}
+
+	var tunnels []Tunnel
+	if err := json.Unmarshal(data, &tunnels); err != nil {
+		return nil, fmt.Errorf("error parsing tunnel state %s: %w", s.path, err)
+	}
+	return tunnels, nil
+}
+
+// save replaces the recorded tunnels
+func (s *Store) save(tunnels []Tunnel) error {
+	data, err := json.MarshalIndent(tunnels, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding tunnel state: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves truncated state
+	if err := utils.WriteFileAtomic(s.path, data, 0600); err != nil {
+		return fmt.Errorf("error writing tunnel state: %w", err)
+	}
+	return nil
+}
+
+// Stop terminates the ssh process of a recorded tunnel, unless it is no
+// longer running and its process ID may belong to another program
+func Stop(tunnel Tunnel) error {
+	if !tunnel.Running() {
+		return fmt.Errorf("tunnel is no longer running")
+	}
+	process, err := os.FindProcess(tunnel.PID)
+	if err != nil {
+		return err
+	}
+	return Terminate(process)
+}
+
+// ListenAddress returns the local address ssh listens on for a -L or -D
+// forwarding specification, or "" if it listens on a Unix socket
+func ListenAddress(option, spec string) (string, error) {
+	fields := splitForward(spec)
+	if strings.HasPrefix(fields[0], "/") {
+		return "", nil
+	}
+
+	var bind, port string
+	switch {
+	case option == "-D" && len(fields) == 1:
+		port = fields[0]
+	case option == "-D" && len(fields) == 2:
+		bind, port = fields[0], fields[1]
+	case option == "-L" && len(fields) == 2, option == "-L" && len(fields) == 3 && !strings.HasPrefix(fields[2], "/"):
+		// port:remote_socket or port:host:hostport
+		port = fields[0]
+	case option == "-L" && len(fields) == 3, option == "-L" && len(fields) == 4:
+		bind, port = fields[0], fields[1]
+	default:
+		return "", fmt.Errorf("invalid %s forwarding specification: %s", option, spec)
+	}
+
+	if port == "" || strings.Trim(port, "0123456789") != "" {
+		return "", fmt.Errorf("invalid port in %s forwarding specification: %s", option, spec)
+	}
+	if bind == "" || bind == "*" {
+		bind = "localhost"
+	}
+	return net.JoinHostPort(bind, port), nil
+}
+
+// splitForward splits a forwarding specification on colons outside brackets,
+// removing the brackets around IPv6 addresses
+func splitForward(spec string) []string {
+	var fields []string
+	var field strings.Builder
+	bracketed := false
+	for _, r := range spec {
+		switch {
+		case r == '[':
+			bracketed = true
+		case r == ']':
+			bracketed = false
+		case r == ':' && !bracketed:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	return append(fields, field.String())
+}
+
+// dialTimeout is how long Listening waits for a local connection
+const dialTimeout = time.Second
+
+// Listening reports whether something accepts connections on a local
+// address. It connects rather than binds the address, so it never holds a
+// port ssh is about to listen on.
+func Listening(address string) bool {
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package utils
+
+import "os"
+
+// enableANSI reports whether the terminal f understands ANSI escape sequences,
+// which every Unix terminal does
+func enableANSI(f *os.File) bool {
+	return true
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+)
+
+// AcquireLock takes an exclusive advisory lock on the file at path, creating
+// it if needed, and returns a function releasing the lock. Other vssh
+// processes block until the lock is released or its holder exits. The lock
+// file is left in place, since removing it would race with waiting processes.
+func AcquireLock(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
@@ -1,39 +1,131 @@
 package utils
 
 import (
+	"io"
 	"os"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 var Logger *logrus.Logger
 
-// InitLogger initializes the global logger
-func InitLogger(debug bool) {
-	Logger = logrus.New()
+// consoleLevel is the most verbose level printed on the console, which may be
+// less verbose than the logger's when a log file is written
+var consoleLevel = logrus.InfoLevel
+
+// consoleHook writes log entries to stderr
+var consoleHook *writerHook
 
-	// Set output to stderr (standard for CLI tools)
-	Logger.SetOutput(os.Stderr)
+// jsonFormat writes log entries as JSON objects, see SetJSONFormat
+var jsonFormat bool
+
+// InitLogger initializes the global logger, printing messages up to level on
+// stderr. Log lines are colored by level when color is set, see UseColor.
+func InitLogger(level logrus.Level, color bool) {
+	Logger = logrus.New()
+	Logger.SetLevel(level)
 
-	// Set log level based on debug flag
-	if debug {
-		Logger.SetLevel(logrus.DebugLevel)
-	} else {
-		Logger.SetLevel(logrus.InfoLevel)
+	// Entries are written by the hooks: the console's below and a log file's
+	// if one is added
+	Logger.SetOutput(io.Discard)
+	consoleLevel = level
+	colorOutput = color
+	jsonFormat = false
+	consoleHook = &writerHook{
+		out:       stderrWriter{},
+		levels:    levelsUpTo(level),
+		formatter: &Formatter{Color: color},
 	}
+	Logger.AddHook(consoleHook)
+}
 
-	// Use a simple formatter for CLI output
-	Logger.SetFormatter(&logrus.TextFormatter{
-		DisableTimestamp: false,
-		FullTimestamp:    true,
-		TimestampFormat:  "2006-01-02 15:04:05",
-	})
+// SetJSONFormat writes log entries on the console, and to log files added
+// afterwards, as JSON objects with their fields, for tools collecting logs
+func SetJSONFormat() {
+	GetLogger()
+	jsonFormat = true
+	consoleHook.formatter = &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano}
 }
 
 // GetLogger returns the global logger instance
 func GetLogger() *logrus.Logger {
 	if Logger == nil {
-		InitLogger(false)
+		InitLogger(logrus.InfoLevel, UseColor(os.Stderr))
 	}
 	return Logger
 }
+
+// AddLogFile also writes messages up to level to the log file at path,
+// whatever the console shows. The file is rotated once it grows past maxSize
+// bytes, keeping maxBackups old files.
+func AddLogFile(path string, level logrus.Level, maxSize int64, maxBackups int) error {
+	file, err := NewRotatingFile(path, maxSize, maxBackups)
+	if err != nil {
+		return err
+	}
+
+	logger := GetLogger()
+	logger.AddHook(&writerHook{
+		out:       file,
+		levels:    levelsUpTo(level),
+		formatter: fileFormatter(),
+	})
+	if level > logger.GetLevel() {
+		logger.SetLevel(level)
+	}
+	return nil
+}
+
+// fileFormatter returns the format of log files: JSON objects with
+// SetJSONFormat, otherwise timestamped key=value records
+func fileFormatter() logrus.Formatter {
+	if jsonFormat {
+		return &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano}
+	}
+	return &logrus.TextFormatter{
+		DisableColors:   true,
+		FullTimestamp:   true,
+		TimestampFormat: "2006-01-02 15:04:05.000",
+	}
+}
+
+// syslogFormatter returns the format of syslog messages: JSON objects with
+// SetJSONFormat, otherwise key=value records. Syslog adds the time itself.
+func syslogFormatter() logrus.Formatter {
+	if jsonFormat {
+		return &logrus.JSONFormatter{DisableTimestamp: true}
+	}
+	return &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true}
+}
+
+// writerHook writes log entries at some levels to a writer in its own format
+type writerHook struct {
+	out       io.Writer
+	levels    []logrus.Level
+	formatter logrus.Formatter
+}
+
+func (h *writerHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+func (h *writerHook) Fire(entry *logrus.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.out.Write(line)
+	return err
+}
+
+// levelsUpTo returns the levels as severe as level or more
+func levelsUpTo(level logrus.Level) []logrus.Level {
+	var levels []logrus.Level
+	for _, l := range logrus.AllLevels {
+		if l <= level {
+			levels = append(levels, l)
+		}
+	}
+	return levels
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// PrefixWriter writes complete lines to an underlying writer, prefixing each
+// one. Writers sharing a mutex never interleave partial lines.
+type PrefixWriter struct {
+	out    io.Writer
+	prefix []byte
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+// NewPrefixWriter creates a PrefixWriter that serializes writes to out with mu
+func NewPrefixWriter(out io.Writer, prefix string, mu *sync.Mutex) *PrefixWriter {
+	return &PrefixWriter{out: out, prefix: []byte(prefix), mu: mu}
+}
+
+// Write buffers p and writes every complete line it contains
+func (w *PrefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		index := bytes.IndexByte(w.buf, '\n')
+		if index < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.buf[:index+1]); err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[index+1:]
+	}
+}
+
+// Flush writes any buffered partial line, terminating it with a newline
+func (w *PrefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := append(w.buf, '\n')
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+// writeLine writes one prefixed line while holding the shared lock
+func (w *PrefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.out.Write(w.prefix); err != nil {
+		return err
+	}
+	_, err := w.out.Write(line)
+	return err
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Prompt asks for a line of input, returning defaultValue if the answer is empty
+func Prompt(reader *bufio.Reader, label, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Printf("%s [%s]: ", label, defaultValue)
+	} else {
+		fmt.Printf("%s: ", label)
+	}
+
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("error reading input: %w", err)
+	}
+
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return defaultValue, nil
+	}
+	return input, nil
+}
+
+// PromptChoice asks the user to pick one of the given options, by number or by name
+func PromptChoice(reader *bufio.Reader, label string, options []string, defaultValue string) (string, error) {
+	fmt.Println(label)
+	for i, option := range options {
+		fmt.Printf("%d. %s\n", i+1, option)
+	}
+
+	for {
+		answer, err := Prompt(reader, fmt.Sprintf("Enter your choice (1-%d)", len(options)), defaultValue)
+		if err != nil {
+			return "", err
+		}
+
+		for i, option := range options {
+			if answer == option || answer == fmt.Sprintf("%d", i+1) {
+				return option, nil
+			}
+		}
+		fmt.Printf("Invalid choice: %s\n", answer)
+	}
+}
+
+// Confirm asks a yes/no question, returning defaultYes if the answer is empty
+func Confirm(reader *bufio.Reader, question string, defaultYes bool) (bool, error) {
+	hint := "y/N"
+	if defaultYes {
+		hint = "Y/n"
+	}
+	fmt.Printf("%s [%s]: ", question, hint)
+
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("error reading input: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "":
+		return defaultYes, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,76 @@
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+	"log/syslog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// syslogFacilities maps facility names to their syslog priorities
+var syslogFacilities = map[string]syslog.Priority{
+	"user":     syslog.LOG_USER,
+	"auth":     syslog.LOG_AUTH,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// AddSyslog sends connection and signing events, the log entries with an
+// event field, to syslog with tag and facility. An empty network and address
+// use the local syslog daemon, otherwise the server at address is reached over
+// network ("udp" or "tcp"). Events are sent whatever the console shows.
+func AddSyslog(network, address, tag, facility string) error {
+	priority, ok := syslogFacilities[facility]
+	if !ok {
+		return fmt.Errorf("unknown syslog facility %s", facility)
+	}
+	writer, err := syslog.Dial(network, address, priority|syslog.LOG_INFO, tag)
+	if err != nil {
+		return fmt.Errorf("error connecting to syslog: %w", err)
+	}
+
+	logger := GetLogger()
+	logger.AddHook(&syslogHook{writer: writer, formatter: syslogFormatter()})
+	// Connection events are logged at debug level
+	logger.SetLevel(logrus.DebugLevel)
+	return nil
+}
+
+// syslogHook writes events to syslog at the severity of their level
+type syslogHook struct {
+	writer    *syslog.Writer
+	formatter logrus.Formatter
+}
+
+func (h *syslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *syslogHook) Fire(entry *logrus.Entry) error {
+	if _, ok := entry.Data["event"]; !ok {
+		return nil
+	}
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	message := string(line)
+	switch entry.Level {
+	case logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel:
+		return h.writer.Err(message)
+	case logrus.WarnLevel:
+		return h.writer.Warning(message)
+	default:
+		return h.writer.Info(message)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package utils
+
+import "fmt"
+
+// AddSyslog is not supported on Windows, which has no syslog
+func AddSyslog(network, address, tag, facility string) error {
+	return fmt.Errorf("syslog is not supported on Windows")
+}
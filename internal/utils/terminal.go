@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// Terminal is where interactive prompts are read from and written to
+type Terminal struct {
+	In  *os.File
+	Out *os.File
+
+	opened bool
+}
+
+// OpenTerminal returns the user's terminal for prompting. When stdin is piped
+// or redirected the controlling terminal is opened instead, so prompts never
+// consume the data meant for the remote command. Prompts are written to stdout
+// only if it is a terminal, keeping them out of redirected output.
+func OpenTerminal() (*Terminal, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		out := os.Stdout
+		if !term.IsTerminal(int(out.Fd())) {
+			out = os.Stderr
+		}
+		return &Terminal{In: os.Stdin, Out: out}, nil
+	}
+
+	in, err := os.Open(terminalInput)
+	if err != nil {
+		return nil, fmt.Errorf("stdin is not a terminal and no terminal is available for prompts: %w", err)
+	}
+	out, err := os.OpenFile(terminalOutput, os.O_WRONLY, 0)
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("stdin is not a terminal and no terminal is available for prompts: %w", err)
+	}
+	return &Terminal{In: in, Out: out, opened: true}, nil
+}
+
+// ReadPassword reads a line from the terminal without echoing it. If vssh is
+// interrupted meanwhile, echo is turned back on before it exits.
+func (t *Terminal) ReadPassword() ([]byte, error) {
+	fd := int(t.In.Fd())
+	if state, err := term.GetState(fd); err == nil {
+		defer restoreOnSignal(fd, state, t.Out)()
+	}
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(t.Out) // Add newline after hidden input
+	return password, err
+}
+
+// restoreOnSignal restores the terminal to state and exits like the shell
+// would (128 plus the signal number) if vssh is interrupted before the
+// returned function is called
+func restoreOnSignal(fd int, state *term.State, out *os.File) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-signals:
+			term.Restore(fd, state)
+			fmt.Fprintln(out)
+			code := 1
+			if s, ok := sig.(syscall.Signal); ok {
+				code = 128 + int(s)
+			}
+			os.Exit(code)
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// Close closes the terminal if OpenTerminal opened it
+func (t *Terminal) Close() {
+	if t.opened {
+		t.In.Close()
+		t.Out.Close()
+	}
+}
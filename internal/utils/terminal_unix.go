@@ -0,0 +1,9 @@
+//go:build !windows
+
+package utils
+
+// The controlling terminal
+const (
+	terminalInput  = "/dev/tty"
+	terminalOutput = "/dev/tty"
+)
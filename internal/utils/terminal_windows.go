@@ -0,0 +1,9 @@
+//go:build windows
+
+package utils
+
+// The console
+const (
+	terminalInput  = "CONIN$"
+	terminalOutput = "CONOUT$"
+)
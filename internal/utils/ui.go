@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// ANSI escape sequences for colored terminal output
+const (
+	ansiReset     = "\033[0m"
+	ansiBold      = "\033[1m"
+	ansiDim       = "\033[2m"
+	ansiRed       = "\033[31m"
+	ansiYellow    = "\033[33m"
+	ansiCyan      = "\033[36m"
+	ansiClearLine = "\r\033[K"
+)
+
+// spinnerFrames are drawn in turn while a spinner runs
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// spinnerDelay keeps fast operations from flashing a spinner
+const spinnerDelay = 150 * time.Millisecond
+
+var (
+	// colorOutput colors log lines and spinners, see InitLogger
+	colorOutput bool
+
+	// stderrMu serializes log lines and spinner frames on stderr
+	stderrMu sync.Mutex
+
+	// activeSpinner is the spinner currently running, if any
+	activeSpinner *Spinner
+
+	// spinnerDrawn is set while a spinner frame is on the current line
+	spinnerDrawn bool
+)
+
+// UseColor reports whether output to f can be colored: f must be a terminal
+// that understands ANSI colors, NO_COLOR (https://no-color.org) must not be set
+// and TERM must not be dumb
+func UseColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return term.IsTerminal(int(f.Fd())) && enableANSI(f)
+}
+
+// Formatter formats log entries as short status lines: errors and warnings
+// are labeled so they stand out, info messages are printed as they are and
+// debug messages carry a timestamp. With Color the labels are colored. Fields
+// are left to log files and JSON output, since messages already say the same
+// for people.
+type Formatter struct {
+	Color bool
+}
+
+// Format implements logrus.Formatter
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var b bytes.Buffer
+	switch entry.Level {
+	case logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel:
+		b.WriteString(f.paint(ansiBold+ansiRed, "error:") + " ")
+	case logrus.WarnLevel:
+		b.WriteString(f.paint(ansiBold+ansiYellow, "warning:") + " ")
+	case logrus.DebugLevel, logrus.TraceLevel:
+		b.WriteString(f.paint(ansiDim, entry.Time.Format("15:04:05.000")+" debug:") + " ")
+	}
+	b.WriteString(strings.TrimSuffix(entry.Message, "\n"))
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// paint colors s with an ANSI sequence when color is enabled
+func (f *Formatter) paint(sequence, s string) string {
+	if !f.Color {
+		return s
+	}
+	return sequence + s + ansiReset
+}
+
+// stderrWriter writes log lines to stderr, clearing a spinner frame first so
+// the two never share a line. The spinner redraws below on its next frame.
+type stderrWriter struct{}
+
+func (stderrWriter) Write(p []byte) (int, error) {
+	stderrMu.Lock()
+	defer stderrMu.Unlock()
+	if spinnerDrawn {
+		os.Stderr.WriteString(ansiClearLine)
+		spinnerDrawn = false
+	}
+	return os.Stderr.Write(p)
+}
+
+// PrintNotice writes a message for the user, such as a host banner, to
+// stderr. Unlike log messages it is shown regardless of the log level and
+// format and never ends up in the log file. With color it is shown in yellow.
+func PrintNotice(message string) {
+	message = strings.TrimSuffix(message, "\n")
+	if colorOutput {
+		message = ansiBold + ansiYellow + message + ansiReset
+	}
+	stderrWriter{}.Write([]byte(message + "\n"))
+}
+
+// Spinner animates a status line on stderr while a slow operation, such as a
+// Vault request, runs. It is only shown on a terminal when info messages are
+// logged as text, so it never ends up in redirected output or among debug
+// lines.
+type Spinner struct {
+	message string
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// StartSpinner starts a spinner with message, which is shown once the
+// operation has taken a moment. Only one spinner runs at a time; starting
+// another one while it runs does nothing.
+func StartSpinner(message string) *Spinner {
+	s := &Spinner{message: message}
+	if consoleLevel != logrus.InfoLevel || jsonFormat || !term.IsTerminal(int(os.Stderr.Fd())) || !enableANSI(os.Stderr) {
+		return s
+	}
+
+	stderrMu.Lock()
+	defer stderrMu.Unlock()
+	if activeSpinner != nil {
+		return s
+	}
+	activeSpinner = s
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.run()
+	return s
+}
+
+// run draws a frame every tick until the spinner is stopped
+func (s *Spinner) run() {
+	defer close(s.stopped)
+
+	select {
+	case <-s.done:
+		return
+	case <-time.After(spinnerDelay):
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for frame := 0; ; frame++ {
+		s.draw(spinnerFrames[frame%len(spinnerFrames)])
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// draw replaces the current spinner frame
+func (s *Spinner) draw(frame string) {
+	stderrMu.Lock()
+	defer stderrMu.Unlock()
+	if colorOutput {
+		frame = ansiCyan + frame + ansiReset
+	}
+	fmt.Fprintf(os.Stderr, "%s%s %s", ansiClearLine, frame, s.message)
+	spinnerDrawn = true
+}
+
+// Stop stops the spinner and clears its line
+func (s *Spinner) Stop() {
+	if s.done == nil {
+		return
+	}
+	close(s.done)
+	<-s.stopped
+
+	stderrMu.Lock()
+	defer stderrMu.Unlock()
+	if spinnerDrawn {
+		os.Stderr.WriteString(ansiClearLine)
+		spinnerDrawn = false
+	}
+	activeSpinner = nil
+	s.done = nil
+}
@@ -2,7 +2,9 @@ package vault
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -107,24 +109,83 @@ func (c *Client) IsTokenValid() bool {
 	return true
 }
 
-// LoadTokenFromFile loads a token from the configured token file
-func (c *Client) LoadTokenFromFile() error {
-	tokenPath := c.config.Token.TokenPath
-	if tokenPath == "" {
-		return fmt.Errorf("token path not configured")
+// TokenTTL looks up the current token and returns its remaining TTL, 0 for
+// a token that never expires, and whether it can be renewed
+func (c *Client) TokenTTL() (time.Duration, bool, error) {
+	if c.client.Token() == "" {
+		return 0, false, fmt.Errorf("no token")
 	}
 
-	// Expand tilde in path
-	if tokenPath[0] == '~' {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("error getting home directory: %w", err)
+	secret, err := c.client.Auth().Token().LookupSelf()
+	if err != nil {
+		return 0, false, fmt.Errorf("token lookup failed: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return 0, false, fmt.Errorf("token lookup returned no data")
+	}
+
+	ttl, err := secret.TokenTTL()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read token TTL: %w", err)
+	}
+	renewable, err := secret.TokenIsRenewable()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read token renewability: %w", err)
+	}
+	return ttl, renewable, nil
+}
+
+// TokenUser looks up the current token and returns the name of the user it
+// was issued to: the username for userpass and LDAP logins, otherwise the
+// token's display name
+func (c *Client) TokenUser() (string, error) {
+	if c.client.Token() == "" {
+		return "", fmt.Errorf("no token")
+	}
+
+	secret, err := c.client.Auth().Token().LookupSelf()
+	if err != nil {
+		return "", fmt.Errorf("token lookup failed: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return "", fmt.Errorf("token lookup returned no data")
+	}
+
+	if meta, ok := secret.Data["meta"].(map[string]interface{}); ok {
+		if username, ok := meta["username"].(string); ok && username != "" {
+			return username, nil
 		}
-		tokenPath = home + tokenPath[1:]
+	}
+	displayName, _ := secret.Data["display_name"].(string)
+	return displayName, nil
+}
+
+// RenewToken renews the current token and returns its new TTL
+func (c *Client) RenewToken() (time.Duration, error) {
+	secret, err := c.client.Auth().Token().RenewSelf(0)
+	if err != nil {
+		return 0, fmt.Errorf("token renewal failed: %w", err)
+	}
+	if secret == nil || secret.Auth == nil {
+		return 0, fmt.Errorf("token renewal returned no data")
+	}
+	return time.Duration(secret.Auth.LeaseDuration) * time.Second, nil
+}
+
+// LoadTokenFromFile loads a token from the configured token file
+func (c *Client) LoadTokenFromFile() error {
+	tokenPath, err := c.tokenPath()
+	if err != nil {
+		return err
 	}
 
 	// Read token from file
 	tokenBytes, err := os.ReadFile(tokenPath)
+	if errors.Is(err, fs.ErrNotExist) && c.config.Token.ImportPath != "" {
+		// Until vssh saves a token of its own, use one from `vault login`
+		tokenPath = c.config.Token.ImportPath
+		tokenBytes, err = os.ReadFile(tokenPath)
+	}
 	if err != nil {
 		return fmt.Errorf("error reading token file %s: %w", tokenPath, err)
 	}
@@ -151,18 +212,9 @@ func (c *Client) SaveTokenToFile() error {
 		return fmt.Errorf("no token to save")
 	}
 
-	tokenPath := c.config.Token.TokenPath
-	if tokenPath == "" {
-		return fmt.Errorf("token path not configured")
-	}
-
-	// Expand tilde in path
-	if tokenPath[0] == '~' {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("error getting home directory: %w", err)
-		}
-		tokenPath = home + tokenPath[1:]
+	tokenPath, err := c.tokenPath()
+	if err != nil {
+		return err
 	}
 
 	// Ensure directory exists
@@ -172,7 +224,7 @@ func (c *Client) SaveTokenToFile() error {
 	}
 
 	// Write token to file with secure permissions
-	err := os.WriteFile(tokenPath, []byte(token), 0600)
+	err = os.WriteFile(tokenPath, []byte(token), 0600)
 	if err != nil {
 		return fmt.Errorf("error writing token file: %w", err)
 	}
@@ -181,6 +233,38 @@ func (c *Client) SaveTokenToFile() error {
 	return nil
 }
 
+// LockToken takes a lock on the token file, so only one vssh process logs in
+// at a time and the others can use the token it saves. It returns a function
+// releasing the lock.
+func (c *Client) LockToken() (func(), error) {
+	tokenPath, err := c.tokenPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(tokenPath), 0700); err != nil {
+		return nil, fmt.Errorf("error creating token directory: %w", err)
+	}
+	return utils.AcquireLock(tokenPath + ".lock")
+}
+
+// tokenPath returns the configured token file with a leading ~ expanded
+func (c *Client) tokenPath() (string, error) {
+	tokenPath := c.config.Token.TokenPath
+	if tokenPath == "" {
+		return "", fmt.Errorf("token path not configured")
+	}
+
+	// Expand tilde in path
+	if tokenPath[0] == '~' {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error getting home directory: %w", err)
+		}
+		tokenPath = home + tokenPath[1:]
+	}
+	return tokenPath, nil
+}
+
 // GetClient returns the underlying Vault API client
 func (c *Client) GetClient() *api.Client {
 	return c.client
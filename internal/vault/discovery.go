@@ -0,0 +1,97 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AuthMount describes an enabled Vault auth method
+type AuthMount struct {
+	Path string
+	Type string
+}
+
+// SSHEngine describes an SSH secrets engine mount and its roles
+type SSHEngine struct {
+	Path  string
+	Roles []string
+}
+
+// Discovery holds the auth methods and SSH engines found on a Vault server
+type Discovery struct {
+	AuthMounts []AuthMount
+	SSHEngines []SSHEngine
+}
+
+// Discover lists enabled auth methods and SSH secrets engines along with their roles.
+// Requires a token allowed to read sys/auth and sys/mounts.
+func (c *Client) Discover() (*Discovery, error) {
+	discovery := &Discovery{}
+
+	authMounts, err := c.client.Sys().ListAuth()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list auth methods: %w", err)
+	}
+	for path, mount := range authMounts {
+		discovery.AuthMounts = append(discovery.AuthMounts, AuthMount{
+			Path: strings.TrimSuffix(path, "/"),
+			Type: mount.Type,
+		})
+	}
+	sort.Slice(discovery.AuthMounts, func(i, j int) bool {
+		return discovery.AuthMounts[i].Path < discovery.AuthMounts[j].Path
+	})
+
+	mounts, err := c.client.Sys().ListMounts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets engines: %w", err)
+	}
+	for path, mount := range mounts {
+		if mount.Type != "ssh" {
+			continue
+		}
+
+		enginePath := strings.TrimSuffix(path, "/")
+		roles, err := c.ListSSHRoles(enginePath)
+		if err != nil {
+			c.logger.Debugf("Could not list roles for %s: %v", enginePath, err)
+		}
+		discovery.SSHEngines = append(discovery.SSHEngines, SSHEngine{Path: enginePath, Roles: roles})
+	}
+	sort.Slice(discovery.SSHEngines, func(i, j int) bool {
+		return discovery.SSHEngines[i].Path < discovery.SSHEngines[j].Path
+	})
+
+	return discovery, nil
+}
+
+// ListSSHRoles lists the roles defined on an SSH secrets engine mount
+func (c *Client) ListSSHRoles(engine string) ([]string, error) {
+	return c.ListKeys(fmt.Sprintf("%s/roles", engine))
+}
+
+// ListKeys performs a LIST on path and returns the sorted keys
+func (c *Client) ListKeys(path string) ([]string, error) {
+	secret, err := c.client.Logical().List(path)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+
+	keys, ok := secret.Data["keys"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if k, ok := key.(string); ok {
+			result = append(result, k)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
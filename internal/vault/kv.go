@@ -0,0 +1,25 @@
+package vault
+
+import "fmt"
+
+// ReadKV reads a secret from a KV secrets engine and returns its data.
+// For KV version 2 the path must include the data/ segment
+// (e.g. secret/data/ssh/inventory); the version envelope is removed.
+func (c *Client) ReadKV(path string) (map[string]interface{}, error) {
+	secret, err := c.client.Logical().Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("no secret found at %s", path)
+	}
+
+	// KV version 2 wraps the secret in data alongside version metadata
+	if data, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, versioned := secret.Data["metadata"]; versioned {
+			return data, nil
+		}
+	}
+
+	return secret.Data, nil
+}
@@ -0,0 +1,183 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// caKeyTimeout bounds reading a CA public key, which the raw read API
+// doesn't limit by itself
+const caKeyTimeout = 30 * time.Second
+
+// SSHCAPublicKey reads the CA public key of an SSH secrets engine. The
+// public_key endpoint returns the key as plain text and needs no token.
+func (c *Client) SSHCAPublicKey(engine string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), caKeyTimeout)
+	defer cancel()
+
+	engine = strings.Trim(engine, "/")
+	resp, err := c.client.Logical().ReadRawWithContext(ctx, engine+"/public_key")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the CA public key of %s: %w", engine, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the CA public key of %s: %w", engine, err)
+	}
+	publicKey := strings.TrimSpace(string(data))
+	if publicKey == "" {
+		return "", fmt.Errorf("%s has no CA configured", engine)
+	}
+	return publicKey, nil
+}
+
+// SSHRole is the configuration of a role on an SSH secrets engine that
+// affects the certificates it signs
+type SSHRole struct {
+	KeyType                string
+	AllowUserCertificates  bool
+	AllowHostCertificates  bool
+	AllowedUsers           []string
+	AllowedUsersTemplate   bool
+	DefaultUser            string
+	AllowedDomains         []string
+	TTL                    time.Duration // 0 for the engine's default
+	MaxTTL                 time.Duration // 0 for the engine's maximum
+	AllowedUserKeyLengths  map[string][]int
+	AllowedExtensions      []string
+	DefaultExtensions      map[string]string
+	AllowedCriticalOptions []string
+	DefaultCriticalOptions map[string]string
+	AllowUserKeyIDs        bool
+	KeyIDFormat            string
+}
+
+// SSHRole reads a role of an SSH secrets engine
+func (c *Client) SSHRole(engine, name string) (*SSHRole, error) {
+	engine = strings.Trim(engine, "/")
+	secret, err := c.client.Logical().Read(fmt.Sprintf("%s/roles/%s", engine, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read role %s of %s: %w", name, engine, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("no role %s on %s", name, engine)
+	}
+
+	data := secret.Data
+	role := &SSHRole{
+		KeyType:                stringValue(data["key_type"]),
+		AllowUserCertificates:  data["allow_user_certificates"] == true,
+		AllowHostCertificates:  data["allow_host_certificates"] == true,
+		AllowedUsers:           listValue(data["allowed_users"]),
+		AllowedUsersTemplate:   data["allowed_users_template"] == true,
+		DefaultUser:            stringValue(data["default_user"]),
+		AllowedDomains:         listValue(data["allowed_domains"]),
+		TTL:                    durationValue(data["ttl"]),
+		MaxTTL:                 durationValue(data["max_ttl"]),
+		AllowedUserKeyLengths:  map[string][]int{},
+		AllowedExtensions:      listValue(data["allowed_extensions"]),
+		DefaultExtensions:      stringMapValue(data["default_extensions"]),
+		AllowedCriticalOptions: listValue(data["allowed_critical_options"]),
+		DefaultCriticalOptions: stringMapValue(data["default_critical_options"]),
+		AllowUserKeyIDs:        data["allow_user_key_ids"] == true,
+		KeyIDFormat:            stringValue(data["key_id_format"]),
+	}
+
+	// Each key type maps to one length or a list of them, 0 for any
+	lengths, _ := data["allowed_user_key_lengths"].(map[string]interface{})
+	for keyType, value := range lengths {
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			role.AllowedUserKeyLengths[keyType] = append(role.AllowedUserKeyLengths[keyType], int(intValue(v)))
+		}
+	}
+	return role, nil
+}
+
+// stringValue returns a string field of a Vault response, or ""
+func stringValue(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+// listValue returns a comma-separated string field of a Vault response as a list
+func listValue(value interface{}) []string {
+	var list []string
+	for _, item := range strings.Split(stringValue(value), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// stringMapValue returns an object field of a Vault response with string values
+func stringMapValue(value interface{}) map[string]string {
+	object, _ := value.(map[string]interface{})
+	result := make(map[string]string, len(object))
+	for key, v := range object {
+		result[key] = fmt.Sprint(v)
+	}
+	return result
+}
+
+// intValue returns a number field of a Vault response, or 0
+func intValue(value interface{}) int64 {
+	switch v := value.(type) {
+	case json.Number:
+		n, _ := v.Int64()
+		return n
+	case float64:
+		return int64(v)
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// durationValue returns a field of a Vault response given in seconds, or as
+// a duration string, as a duration
+func durationValue(value interface{}) time.Duration {
+	if s, ok := value.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d
+		}
+	}
+	return time.Duration(intValue(value)) * time.Second
+}
+
+// SSHOTP generates a one-time password for username on the host at ip from an
+// OTP role of an SSH secrets engine. The vault-ssh-helper on the host checks it
+// once, on login.
+func (c *Client) SSHOTP(engine, role, username, ip string) (string, error) {
+	engine = strings.Trim(engine, "/")
+	secret, err := c.client.Logical().Write(fmt.Sprintf("%s/creds/%s", engine, role), map[string]interface{}{
+		"username": username,
+		"ip":       ip,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate a one-time password with role %s of %s: %w", role, engine, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return "", fmt.Errorf("no one-time password returned by role %s of %s", role, engine)
+	}
+	if keyType := stringValue(secret.Data["key_type"]); keyType != "" && keyType != "otp" {
+		return "", fmt.Errorf("role %s of %s is a %s role, not an OTP role", role, engine, keyType)
+	}
+	otp := stringValue(secret.Data["key"])
+	if otp == "" {
+		return "", fmt.Errorf("no one-time password returned by role %s of %s", role, engine)
+	}
+	return otp, nil
+}
@@ -0,0 +1,85 @@
+// Package webhook posts connection events to a webhook as JSON, signed with
+// HMAC-SHA256 so the receiver can check they came from vssh.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 signature of the request body, as
+// sha256=<hex>
+const SignatureHeader = "X-Vssh-Signature"
+
+// Event types
+const (
+	SessionStart = "session_start"
+	SessionEnd   = "session_end"
+)
+
+// Event is a connection event posted to the webhook
+type Event struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Target     string    `json:"target"`
+	Host       string    `json:"host"`
+	User       string    `json:"user"`
+	Port       string    `json:"port"`
+	Role       string    `json:"role"`
+	CertSerial string    `json:"cert_serial,omitempty"`
+
+	// Reason is the reason given for connecting to a protected host
+	Reason string `json:"reason,omitempty"`
+
+	// ExitCode and Duration (in seconds) are set for session_end
+	ExitCode *int    `json:"exit_code,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+
+	// ClientHost and ClientUser identify the workstation that connected
+	ClientHost string `json:"client_host"`
+	ClientUser string `json:"client_user"`
+}
+
+// Sign returns the signature of body with secret, as sent in SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts event to url, signed with secret unless it is empty, giving up
+// after timeout
+func Send(url, secret string, timeout time.Duration, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding webhook event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting to webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -1,13 +1,33 @@
 package types
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
 
 // Config represents the main configuration structure
 type Config struct {
-	Vault VaultConfig `mapstructure:"vault" yaml:"vault"`
-	SSH   SSHConfig   `mapstructure:"ssh" yaml:"ssh"`
-	Users UserConfigs `mapstructure:"users" yaml:"users"`
-	Debug bool        `mapstructure:"debug" yaml:"debug"`
+	Vault     VaultConfig       `mapstructure:"vault" yaml:"vault"`
+	Vaults    VaultConfigs      `mapstructure:"vaults" yaml:"vaults,omitempty"`
+	SSH       SSHConfig         `mapstructure:"ssh" yaml:"ssh"`
+	Users     UserConfigs       `mapstructure:"users" yaml:"users"`
+	Hosts     []HostConfig      `mapstructure:"hosts" yaml:"hosts,omitempty"`
+	Groups    GroupConfigs      `mapstructure:"groups" yaml:"groups,omitempty"`
+	Bookmarks map[string]string `mapstructure:"bookmarks" yaml:"bookmarks,omitempty"`
+	Inventory InventoryConfig   `mapstructure:"inventory" yaml:"inventory,omitempty"`
+	History   HistoryConfig     `mapstructure:"history" yaml:"history,omitempty"`
+	Agentd    AgentdConfig      `mapstructure:"agentd" yaml:"agentd,omitempty"`
+	Roles     RoleConfigs       `mapstructure:"roles" yaml:"roles,omitempty"`
+	Logging   LoggingConfig     `mapstructure:"logging" yaml:"logging,omitempty"`
+	Recording RecordingConfig   `mapstructure:"recording" yaml:"recording,omitempty"`
+	Hooks     HooksConfig       `mapstructure:"hooks" yaml:"hooks,omitempty"`
+	Webhook   WebhookConfig     `mapstructure:"webhook" yaml:"webhook,omitempty"`
+	Metrics   MetricsConfig     `mapstructure:"metrics" yaml:"metrics,omitempty"`
+	UI        UIConfig          `mapstructure:"ui" yaml:"ui,omitempty"`
+	Debug     bool              `mapstructure:"debug" yaml:"debug"`
 }
 
 // VaultConfig contains Vault server configuration
@@ -17,6 +37,10 @@ type VaultConfig struct {
 	AuthMethod string `mapstructure:"auth_method" yaml:"auth_method"`
 	Namespace  string `mapstructure:"namespace" yaml:"namespace,omitempty"`
 
+	// SelectRole asks which of the signing engine's roles to use for a target
+	// without a mapped role, instead of using the username
+	SelectRole bool `mapstructure:"select_role" yaml:"select_role"`
+
 	// Auth method specific configurations
 	Token    TokenConfig    `mapstructure:"token" yaml:"token,omitempty"`
 	UserPass UserPassConfig `mapstructure:"userpass" yaml:"userpass,omitempty"`
@@ -24,9 +48,29 @@ type VaultConfig struct {
 	OIDC     OIDCConfig     `mapstructure:"oidc" yaml:"oidc,omitempty"`
 }
 
+// VaultConfigs is a map of name to the configuration of an additional Vault
+// cluster, which hosts select with their vault setting
+type VaultConfigs map[string]VaultConfig
+
+// VaultFor returns the name and configuration of the Vault cluster that signs
+// for hosts with the given settings: the named cluster, or "" and the vault
+// section
+func (c *Config) VaultFor(hostSettings HostSettings) (string, VaultConfig) {
+	name := strings.ToLower(hostSettings.Vault)
+	if vault, ok := c.Vaults[name]; ok {
+		return name, vault
+	}
+	return "", c.Vault
+}
+
 // TokenConfig for token-based authentication
 type TokenConfig struct {
 	TokenPath string `mapstructure:"token_path" yaml:"token_path,omitempty"`
+
+	// ImportPath is read while TokenPath doesn't exist, so a token from
+	// `vault login` is used until vssh saves one of its own. It isn't
+	// configurable.
+	ImportPath string `mapstructure:"-" yaml:"-"`
 }
 
 // UserPassConfig for username/password authentication
@@ -49,15 +93,257 @@ type OIDCConfig struct {
 
 // SSHConfig contains SSH-related configuration
 type SSHConfig struct {
-	KeyDirectory   string        `mapstructure:"key_directory" yaml:"key_directory"`
-	CertificateTTL time.Duration `mapstructure:"certificate_ttl" yaml:"certificate_ttl"`
-	SigningEngine  string        `mapstructure:"signing_engine" yaml:"signing_engine"`
+	KeyDirectory         string             `mapstructure:"key_directory" yaml:"key_directory"`
+	CertificateDirectory string             `mapstructure:"certificate_directory" yaml:"certificate_directory,omitempty"`
+	CertificateTTL       time.Duration      `mapstructure:"certificate_ttl" yaml:"certificate_ttl"`
+	RenewBefore          string             `mapstructure:"renew_before" yaml:"renew_before,omitempty"`
+	SigningEngine        string             `mapstructure:"signing_engine" yaml:"signing_engine"`
+	SSHConfigFile        string             `mapstructure:"ssh_config_file" yaml:"ssh_config_file,omitempty"`
+	ForwardAgent         bool               `mapstructure:"forward_agent" yaml:"forward_agent,omitempty"`
+	ForwardX11           bool               `mapstructure:"forward_x11" yaml:"forward_x11,omitempty"`
+	ForwardX11Trusted    bool               `mapstructure:"forward_x11_trusted" yaml:"forward_x11_trusted,omitempty"`
+	Multiplexing         MultiplexingConfig `mapstructure:"multiplexing" yaml:"multiplexing,omitempty"`
+
+	StrictHostKeyChecking string `mapstructure:"strict_host_key_checking" yaml:"strict_host_key_checking,omitempty"`
+	KnownHostsFile        string `mapstructure:"known_hosts_file" yaml:"known_hosts_file,omitempty"`
+
+	// AutoGenerateKey creates an ed25519 key pair when the key to sign is missing
+	AutoGenerateKey bool `mapstructure:"auto_generate_key" yaml:"auto_generate_key,omitempty"`
+
+	// PKCS11Provider makes the identity a key on a PKCS#11 token, such as a
+	// YubiKey PIV slot, selected by PKCS11Label
+	PKCS11Provider string `mapstructure:"pkcs11_provider" yaml:"pkcs11_provider,omitempty"`
+	PKCS11Label    string `mapstructure:"pkcs11_label" yaml:"pkcs11_label,omitempty"`
+
+	// SecurityKeyProvider is the FIDO middleware library ssh uses for sk-* keys
+	SecurityKeyProvider string `mapstructure:"security_key_provider" yaml:"security_key_provider,omitempty"`
+
+	// Native connects with the built-in SSH client instead of the ssh binary
+	Native bool `mapstructure:"native" yaml:"native,omitempty"`
+
+	// UseAgent loads signed certificates into the ssh-agent and connects through it
+	UseAgent bool `mapstructure:"use_agent" yaml:"use_agent,omitempty"`
+
+	// AgentOnly keeps signed certificates in the ssh-agent only, never on disk
+	AgentOnly bool `mapstructure:"agent_only" yaml:"agent_only,omitempty"`
+
+	// EphemeralKey signs a key generated in memory for each session in agent-only mode
+	EphemeralKey bool `mapstructure:"ephemeral_key" yaml:"ephemeral_key,omitempty"`
+
+	// KeyIDTemplate is a text/template for the key ID of signed certificates,
+	// executed with KeyIDFields. Vault's default key ID is used when empty.
+	KeyIDTemplate string `mapstructure:"key_id_template" yaml:"key_id_template,omitempty"`
+
+	// HostCA is the CA that signs host keys, trusted for the hosts in its domains
+	HostCA HostCAConfig `mapstructure:"host_ca" yaml:"host_ca,omitempty"`
+}
+
+// KeyIDFields are the fields available to ssh.key_id_template
+type KeyIDFields struct {
+	VaultUser string // user logged in to Vault
+	Username  string // remote login user
+	Hostname  string
+	Role      string
+	Timestamp string // signing time in UTC, as RFC 3339
+}
+
+// KeyID returns the key ID for a certificate from ssh.key_id_template, or ""
+// when no template is set
+func (c SSHConfig) KeyID(fields KeyIDFields) (string, error) {
+	if c.KeyIDTemplate == "" {
+		return "", nil
+	}
+
+	tmpl, err := template.New("key_id_template").Option("missingkey=error").Parse(c.KeyIDTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid ssh.key_id_template: %w", err)
+	}
+	var keyID strings.Builder
+	if err := tmpl.Execute(&keyID, fields); err != nil {
+		return "", fmt.Errorf("invalid ssh.key_id_template: %w", err)
+	}
+	return keyID.String(), nil
+}
+
+// DefaultRenewBefore is the renewal margin used when ssh.renew_before is not set
+const DefaultRenewBefore = "20%"
+
+// RenewMargin returns how long before it expires a certificate valid for ttl
+// is renewed. ssh.renew_before is either a duration ("30m") or a percentage
+// of the certificate's TTL ("20%").
+func (c SSHConfig) RenewMargin(ttl time.Duration) (time.Duration, error) {
+	value := c.RenewBefore
+	if value == "" {
+		value = DefaultRenewBefore
+	}
+
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		fraction, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if err != nil || fraction < 0 || fraction >= 100 {
+			return 0, fmt.Errorf("ssh.renew_before must be a duration or a percentage below 100%%, got %s", value)
+		}
+		return time.Duration(float64(ttl) * fraction / 100), nil
+	}
+
+	margin, err := time.ParseDuration(value)
+	if err != nil || margin < 0 {
+		return 0, fmt.Errorf("ssh.renew_before must be a duration or a percentage below 100%%, got %s", value)
+	}
+	return margin, nil
+}
+
+// MultiplexingConfig controls sharing one connection per host through an
+// OpenSSH control master
+type MultiplexingConfig struct {
+	Enabled     bool   `mapstructure:"enabled" yaml:"enabled"`
+	ControlPath string `mapstructure:"control_path" yaml:"control_path,omitempty"`
+	Persist     string `mapstructure:"persist" yaml:"persist,omitempty"`
+}
+
+// HostCAConfig configures the Vault CA that signs host keys
+type HostCAConfig struct {
+	SigningEngine string   `mapstructure:"signing_engine" yaml:"signing_engine,omitempty"`
+	Domains       []string `mapstructure:"domains" yaml:"domains,omitempty"`
+
+	// Verify checks that hosts in Domains present a certificate signed by the
+	// CA: "warn" logs mismatches, "enforce" refuses the connection
+	Verify string `mapstructure:"verify" yaml:"verify,omitempty"`
 }
 
+// InventoryConfig configures where host inventory is loaded from
+type InventoryConfig struct {
+	VaultPath string             `mapstructure:"vault_path" yaml:"vault_path,omitempty"`
+	EC2       EC2InventoryConfig `mapstructure:"ec2" yaml:"ec2,omitempty"`
+}
+
+// EC2InventoryConfig configures discovery of running AWS EC2 instances
+type EC2InventoryConfig struct {
+	Enabled     bool              `mapstructure:"enabled" yaml:"enabled"`
+	Region      string            `mapstructure:"region" yaml:"region,omitempty"`
+	Profile     string            `mapstructure:"profile" yaml:"profile,omitempty"`
+	Filters     map[string]string `mapstructure:"filters" yaml:"filters,omitempty"`
+	AddressType string            `mapstructure:"address_type" yaml:"address_type,omitempty"`
+	User        string            `mapstructure:"user" yaml:"user,omitempty"`
+	Role        string            `mapstructure:"role" yaml:"role,omitempty"`
+}
+
+// HistoryConfig controls the local connection history
+type HistoryConfig struct {
+	Enabled    bool `mapstructure:"enabled" yaml:"enabled"`
+	MaxEntries int  `mapstructure:"max_entries" yaml:"max_entries,omitempty"`
+
+	// RecordCertificates keeps the serial number and key ID of every issued certificate
+	RecordCertificates bool `mapstructure:"record_certificates" yaml:"record_certificates,omitempty"`
+}
+
+// LoggingConfig controls the log file, which is written independently of the
+// console's verbosity
+type LoggingConfig struct {
+	File       string `mapstructure:"file" yaml:"file,omitempty"`
+	Level      string `mapstructure:"level" yaml:"level,omitempty"`
+	Format     string `mapstructure:"format" yaml:"format,omitempty"`
+	MaxSize    int    `mapstructure:"max_size" yaml:"max_size,omitempty"`
+	MaxBackups int    `mapstructure:"max_backups" yaml:"max_backups,omitempty"`
+
+	// Syslog receives connection and signing events
+	Syslog SyslogConfig `mapstructure:"syslog" yaml:"syslog,omitempty"`
+}
+
+// SyslogConfig sends connection and signing events to the local syslog
+// daemon, or to a remote one at Address over Network
+type SyslogConfig struct {
+	Enabled  bool   `mapstructure:"enabled" yaml:"enabled"`
+	Network  string `mapstructure:"network" yaml:"network,omitempty"`
+	Address  string `mapstructure:"address" yaml:"address,omitempty"`
+	Tag      string `mapstructure:"tag" yaml:"tag,omitempty"`
+	Facility string `mapstructure:"facility" yaml:"facility,omitempty"`
+}
+
+// SyslogFacilities are the facilities events can be sent to syslog with
+var SyslogFacilities = []string{"user", "auth", "authpriv", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7"}
+
+// LogLevels are the levels a log file can be written at
+var LogLevels = []string{"error", "warn", "info", "debug"}
+
+// LogFormats are the formats log messages can be written in
+var LogFormats = []string{"text", "json"}
+
+// RecordingConfig controls the recording of terminal sessions
+type RecordingConfig struct {
+	// Enabled records every connection, as --record does
+	Enabled   bool   `mapstructure:"enabled" yaml:"enabled"`
+	Directory string `mapstructure:"directory" yaml:"directory,omitempty"`
+}
+
+// HooksConfig lists shell commands run around every connection
+type HooksConfig struct {
+	// PreConnect runs before connecting; a failing command stops the connection
+	PreConnect []string `mapstructure:"pre_connect" yaml:"pre_connect,omitempty"`
+
+	// PostDisconnect runs after the session ends
+	PostDisconnect []string `mapstructure:"post_disconnect" yaml:"post_disconnect,omitempty"`
+}
+
+// WebhookConfig posts session start and end events to a URL
+type WebhookConfig struct {
+	URL string `mapstructure:"url" yaml:"url,omitempty"`
+
+	// Secret signs the events with HMAC-SHA256
+	Secret  string        `mapstructure:"secret" yaml:"secret,omitempty"`
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty"`
+}
+
+// AgentdConfig controls the vssh agentd certificate refresh daemon
+type AgentdConfig struct {
+	Targets       []string      `mapstructure:"targets" yaml:"targets,omitempty"`
+	CheckInterval time.Duration `mapstructure:"check_interval" yaml:"check_interval,omitempty"`
+
+	// MetricsFile is written with the metrics in the Prometheus text format
+	// after every refresh, for node_exporter's textfile collector
+	MetricsFile string `mapstructure:"metrics_file" yaml:"metrics_file,omitempty"`
+
+	// MetricsListen is a host:port address the metrics are served on at
+	// /metrics for Prometheus to scrape
+	MetricsListen string `mapstructure:"metrics_listen" yaml:"metrics_listen,omitempty"`
+}
+
+// MetricsConfig controls the local metrics shown by vssh stats
+type MetricsConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+}
+
+// UIConfig controls what vssh prints around sessions
+type UIConfig struct {
+	// SessionSummary prints a line with the duration, certificate serial and
+	// exit code of every session when it ends
+	SessionSummary bool `mapstructure:"session_summary" yaml:"session_summary,omitempty"`
+}
+
+// RoleConfig holds settings for certificates signed with a Vault role
+type RoleConfig struct {
+	Extensions      map[string]string `mapstructure:"extensions" yaml:"extensions,omitempty"`
+	CriticalOptions map[string]string `mapstructure:"critical_options" yaml:"critical_options,omitempty"`
+}
+
+// RoleConfigs is a map of Vault role name to role configuration
+type RoleConfigs map[string]RoleConfig
+
 // UserConfig represents per-user configuration
 type UserConfig struct {
-	PrivateKey string `mapstructure:"private_key" yaml:"private_key"`
-	VaultRole  string `mapstructure:"vault_role" yaml:"vault_role,omitempty"`
+	PrivateKey string   `mapstructure:"private_key" yaml:"private_key,omitempty"`
+	Keys       []string `mapstructure:"keys" yaml:"keys,omitempty"`
+	VaultRole  string   `mapstructure:"vault_role" yaml:"vault_role,omitempty"`
+	Principals []string `mapstructure:"principals" yaml:"principals,omitempty"`
+}
+
+// PrivateKeys returns the user's private keys in order of preference:
+// private_key followed by keys
+func (u UserConfig) PrivateKeys() []string {
+	var keys []string
+	if u.PrivateKey != "" {
+		keys = append(keys, u.PrivateKey)
+	}
+	return append(keys, u.Keys...)
 }
 
 // UserConfigs is a map of username to user configuration
@@ -0,0 +1,172 @@
+package types
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// HostSettings are settings that can be applied to hosts by pattern
+type HostSettings struct {
+	Role          string   `mapstructure:"role" yaml:"role,omitempty"`
+	SigningEngine string   `mapstructure:"signing_engine" yaml:"signing_engine,omitempty"`
+	User          string   `mapstructure:"user" yaml:"user,omitempty"`
+	ProxyJump     string   `mapstructure:"proxy_jump" yaml:"proxy_jump,omitempty"`
+	KeyType       string   `mapstructure:"key_type" yaml:"key_type,omitempty"`
+	Principals    []string `mapstructure:"principals" yaml:"principals,omitempty"`
+	Mode          string   `mapstructure:"mode" yaml:"mode,omitempty"`
+	Vault         string   `mapstructure:"vault" yaml:"vault,omitempty"`
+
+	// Banner is shown before connecting to matching hosts, e.g. to announce
+	// a change freeze
+	Banner string `mapstructure:"banner" yaml:"banner,omitempty"`
+
+	// Protected hosts need the host name typed before connecting, and a
+	// reason too with RequireReason
+	Protected     bool `mapstructure:"protected" yaml:"protected,omitempty"`
+	RequireReason bool `mapstructure:"require_reason" yaml:"require_reason,omitempty"`
+
+	Extensions      map[string]string `mapstructure:"extensions" yaml:"extensions,omitempty"`
+	CriticalOptions map[string]string `mapstructure:"critical_options" yaml:"critical_options,omitempty"`
+
+	StrictHostKeyChecking string `mapstructure:"strict_host_key_checking" yaml:"strict_host_key_checking,omitempty"`
+	KnownHostsFile        string `mapstructure:"known_hosts_file" yaml:"known_hosts_file,omitempty"`
+}
+
+// KeyTypes are the key types that key_type and --key-type accept
+var KeyTypes = []string{"ed25519", "ecdsa", "rsa", "ed25519-sk", "ecdsa-sk"}
+
+// Modes are the authentication modes that mode and --mode accept: "ca" logs
+// in with a signed certificate, "otp" with a one-time password from Vault
+var Modes = []string{"ca", "otp"}
+
+// HostConfig applies settings to every host matching Pattern.
+// Pattern is a comma-separated list of globs (* and ?), and a leading !
+// negates a glob, as in ssh_config Host lines.
+type HostConfig struct {
+	Pattern      string `mapstructure:"pattern" yaml:"pattern"`
+	HostSettings `mapstructure:",squash" yaml:",inline"`
+}
+
+// GroupConfig is a named set of hosts sharing the same settings.
+// Each entry in Hosts is a hostname or a glob pattern.
+type GroupConfig struct {
+	Hosts        []string `mapstructure:"hosts" yaml:"hosts"`
+	HostSettings `mapstructure:",squash" yaml:",inline"`
+}
+
+// GroupConfigs is a map of group name to group configuration
+type GroupConfigs map[string]GroupConfig
+
+// ResolveHost returns the settings that apply to hostname. Entries in hosts
+// are evaluated in order, followed by the groups containing the host in name
+// order; the first value found for each setting wins.
+func (c *Config) ResolveHost(hostname string) HostSettings {
+	var settings HostSettings
+	for _, host := range c.Hosts {
+		if MatchHostPattern(host.Pattern, hostname) {
+			settings.merge(host.HostSettings)
+		}
+	}
+	for _, name := range c.GroupsForHost(hostname) {
+		settings.merge(c.Groups[name].HostSettings)
+	}
+	return settings
+}
+
+// GroupsForHost returns the sorted names of the groups containing hostname
+func (c *Config) GroupsForHost(hostname string) []string {
+	var names []string
+	for name, group := range c.Groups {
+		for _, host := range group.Hosts {
+			if MatchHostPattern(host, hostname) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GroupNames returns the sorted names of all configured groups
+func (c *Config) GroupNames() []string {
+	names := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// merge fills any unset fields of s from other
+func (s *HostSettings) merge(other HostSettings) {
+	dst := reflect.ValueOf(s).Elem()
+	src := reflect.ValueOf(other)
+	for i := 0; i < dst.NumField(); i++ {
+		if dst.Field(i).IsZero() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+// MatchHostPattern reports whether hostname matches a comma-separated list of
+// glob patterns. Matching is case-insensitive and any matching negated
+// pattern (prefixed with !) excludes the host.
+func MatchHostPattern(pattern, hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	matched := false
+
+	for _, p := range strings.Split(pattern, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+
+		if strings.HasPrefix(p, "!") {
+			if matchGlob(p[1:], hostname) {
+				return false
+			}
+			continue
+		}
+
+		if matchGlob(p, hostname) {
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+// matchGlob matches s against a glob supporting * (any run of characters) and ? (one character)
+func matchGlob(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// Collapse consecutive stars and try every possible split
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchGlob(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		s = s[1:]
+	}
+	return s == ""
+}
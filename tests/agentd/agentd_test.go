@@ -0,0 +1,121 @@
+package agentd_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vssh/internal/agentd"
+	"vssh/internal/ssh"
+	"vssh/internal/vault"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestRefresh_ValidCertificate(t *testing.T) {
+	keyDir := t.TempDir()
+	keyPath := filepath.Join(keyDir, "id_ed25519")
+	if err := ssh.GenerateKeyPair("ed25519", keyPath, "alice", false); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{
+		Vault:  types.VaultConfig{Address: "http://127.0.0.1:1"},
+		SSH:    types.SSHConfig{KeyDirectory: keyDir, SigningEngine: "ssh-client-signer", CertificateTTL: time.Hour},
+		Users:  types.UserConfigs{"alice": {PrivateKey: keyPath}},
+		Agentd: types.AgentdConfig{CheckInterval: time.Minute},
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	signer := ssh.NewSigner(nil, cfg, logger)
+	target := &ssh.SSHTarget{Username: "alice", Hostname: "db01"}
+
+	// A cached certificate that is still valid is kept without signing
+	plan, err := signer.PlanCertificate(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	writeCertificate(t, keyPath+".pub", plan.Path, expires)
+
+	vaultClient, err := vault.NewClient(&cfg.Vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := agentd.New(cfg, vaultClient, signer, logger, []*ssh.SSHTarget{target}).Refresh()
+
+	if status.TokenError == "" {
+		t.Errorf("Expected a token error without a Vault token")
+	}
+	if len(status.Certificates) != 1 {
+		t.Fatalf("Expected 1 certificate, got %d", len(status.Certificates))
+	}
+	cert := status.Certificates[0]
+	if cert.Error != "" || cert.Path != plan.Path || !cert.Expires.Equal(expires) {
+		t.Errorf("Expected %s valid until %v, got %+v", plan.Path, expires, cert)
+	}
+	if cert.Target != "alice@db01" {
+		t.Errorf("Expected target alice@db01, got %s", cert.Target)
+	}
+}
+
+func TestStatusFile(t *testing.T) {
+	path := agentd.DefaultStatusPath(t.TempDir())
+
+	status, err := agentd.ReadStatus(path)
+	if err != nil || status != nil {
+		t.Fatalf("Expected no status without a running daemon, got %v, %v", status, err)
+	}
+
+	written := agentd.Status{
+		PID:          42,
+		Started:      time.Now().Truncate(time.Second),
+		Certificates: []agentd.CertificateStatus{{Target: "alice@db01", Path: "/certs/alice.pub"}},
+	}
+	if err := agentd.WriteStatus(path, written); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	status, err = agentd.ReadStatus(path)
+	if err != nil || status == nil {
+		t.Fatalf("Expected the written status, got %v, %v", status, err)
+	}
+	if status.PID != 42 || !status.Started.Equal(written.Started) || len(status.Certificates) != 1 {
+		t.Errorf("Expected %+v, got %+v", written, *status)
+	}
+}
+
+// writeCertificate signs the public key at publicKeyPath with a throwaway CA
+// and writes the certificate to certPath
+func writeCertificate(t *testing.T, publicKeyPath, certPath string, expires time.Time) {
+	t.Helper()
+	data, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _, _, _, err := gossh.ParseAuthorizedKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := gossh.NewSignerFromKey(caKey)
+	cert := &gossh.Certificate{
+		Key:         publicKey,
+		CertType:    gossh.UserCert,
+		ValidAfter:  uint64(time.Now().Add(-time.Minute).Unix()),
+		ValidBefore: uint64(expires.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certPath, gossh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+package agentd_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"vssh/internal/agentd"
+	"vssh/internal/ssh"
+	"vssh/internal/vault"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAPI_Certificate(t *testing.T) {
+	keyDir := t.TempDir()
+	keyPath := filepath.Join(keyDir, "id_ed25519")
+	if err := ssh.GenerateKeyPair("ed25519", keyPath, "alice", false); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{
+		Vault:  types.VaultConfig{Address: "http://127.0.0.1:1"},
+		SSH:    types.SSHConfig{KeyDirectory: keyDir, SigningEngine: "ssh-client-signer", CertificateTTL: time.Hour},
+		Users:  types.UserConfigs{"alice": {PrivateKey: keyPath}},
+		Agentd: types.AgentdConfig{CheckInterval: time.Minute},
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	signer := ssh.NewSigner(nil, cfg, logger)
+
+	plan, err := signer.PlanCertificate(&ssh.SSHTarget{Username: "alice", Hostname: "db02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	writeCertificate(t, keyPath+".pub", plan.Path, expires)
+
+	vaultClient, err := vault.NewClient(&cfg.Vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon := agentd.New(cfg, vaultClient, signer, logger, nil)
+
+	socketPath := agentd.DefaultSocketPath(t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- daemon.Serve(ctx, socketPath) }()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("Expected no error when stopping, got %v", err)
+		}
+	}()
+
+	client := agentd.NewClient(socketPath)
+	var cert *agentd.CertificateResponse
+	// Wait for the daemon to listen
+	for i := 0; i < 50; i++ {
+		if cert, err = client.Certificate("alice@db02"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if cert.Path != plan.Path || cert.PrivateKeyPath != keyPath || !cert.Expires.Equal(expires) {
+		t.Errorf("Expected %s for %s valid until %v, got %+v", plan.Path, keyPath, expires, cert)
+	}
+	if !strings.Contains(cert.Certificate, "ssh-ed25519-cert-v01@openssh.com") {
+		t.Errorf("Expected the certificate content, got %q", cert.Certificate)
+	}
+
+	// Requested targets are kept fresh from then on
+	status := daemon.Refresh()
+	if len(status.Certificates) != 1 || status.Certificates[0].Target != "alice@db02" {
+		t.Errorf("Expected alice@db02 to be refreshed, got %+v", status.Certificates)
+	}
+
+	remote, err := client.Status()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if remote.PID != status.PID || len(remote.Certificates) != 1 {
+		t.Errorf("Expected %+v, got %+v", status, *remote)
+	}
+}
+
+func TestAPI_InvalidRequest(t *testing.T) {
+	cfg := &types.Config{Agentd: types.AgentdConfig{CheckInterval: time.Minute}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	daemon := agentd.New(cfg, nil, ssh.NewSigner(nil, cfg, logger), logger, nil)
+
+	recorder := httptest.NewRecorder()
+	daemon.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/certificate", strings.NewReader(`{}`)))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), `"error"`) {
+		t.Errorf("Expected an error body, got %s", recorder.Body.String())
+	}
+}
@@ -3,6 +3,8 @@ package config_test
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +17,8 @@ import (
 func TestLoadConfig_WithDefaults(t *testing.T) {
 	// Reset viper for clean test
 	viper.Reset()
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
 
 	// Load config without any config file (should use defaults)
 	cfg, err := config.LoadConfig()
@@ -38,6 +42,10 @@ func TestLoadConfig_WithDefaults(t *testing.T) {
 	if cfg.SSH.CertificateTTL != 4*time.Hour {
 		t.Errorf("Expected default certificate TTL 4h, got %v", cfg.SSH.CertificateTTL)
 	}
+
+	if expected := filepath.Join(cacheDir, "vssh", "certs"); cfg.SSH.CertificateDirectory != expected {
+		t.Errorf("Expected default certificate directory %s, got %s", expected, cfg.SSH.CertificateDirectory)
+	}
 }
 
 func TestLoadConfig_WithCustomConfig(t *testing.T) {
@@ -224,3 +232,444 @@ func containsAt(s, substr string) bool {
 	}
 	return false
 }
+
+func TestLoadConfig_EnvironmentOverrides(t *testing.T) {
+	viper.Reset()
+	t.Setenv("VSSH_VAULT_ADDRESS", "https://env-vault.example.com")
+	t.Setenv("VSSH_SSH_CERTIFICATE_TTL", "15m")
+	t.Setenv("VSSH_VAULT_OIDC_ROLE", "env-role")
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if cfg.Vault.Address != "https://env-vault.example.com" {
+		t.Errorf("Expected vault address from environment, got %s", cfg.Vault.Address)
+	}
+
+	if cfg.SSH.CertificateTTL != 15*time.Minute {
+		t.Errorf("Expected certificate TTL 15m from environment, got %v", cfg.SSH.CertificateTTL)
+	}
+
+	if cfg.Vault.OIDC.Role != "env-role" {
+		t.Errorf("Expected OIDC role from environment, got %s", cfg.Vault.OIDC.Role)
+	}
+}
+
+func TestLoadConfig_InvalidStrictHostKeyChecking(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+vault:
+  address: "https://vault.example.com:8200"
+  role: "test-role"
+  auth_method: "token"
+
+hosts:
+  - pattern: "*.compute.internal"
+    strict_host_key_checking: "sometimes"
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+
+	if _, err := config.LoadConfig(); err == nil {
+		t.Errorf("Expected validation error for strict_host_key_checking, got nil")
+	}
+}
+
+func TestLoadConfig_EphemeralKeyRequiresAgentOnly(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+vault:
+  address: "https://vault.example.com:8200"
+  auth_method: "token"
+
+ssh:
+  ephemeral_key: true
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+
+	if _, err := config.LoadConfig(); err == nil || !strings.Contains(err.Error(), "agent_only") {
+		t.Errorf("Expected ssh.ephemeral_key without ssh.agent_only to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfig_UserKeys(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+vault:
+  address: "https://vault.example.com:8200"
+  auth_method: "token"
+
+users:
+  alice:
+    keys:
+      - "~/.ssh/id_ed25519"
+      - "/keys/alice_rsa"
+
+hosts:
+  - pattern: "legacy-*"
+    key_type: "rsa"
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	home, _ := os.UserHomeDir()
+	expected := []string{filepath.Join(home, ".ssh/id_ed25519"), "/keys/alice_rsa"}
+	if keys := cfg.Users["alice"].PrivateKeys(); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected keys %v, got %v", expected, keys)
+	}
+	if keyType := cfg.ResolveHost("legacy-db01").KeyType; keyType != "rsa" {
+		t.Errorf("Expected key type rsa, got %s", keyType)
+	}
+
+	viper.Reset()
+	invalid := strings.Replace(configContent, `key_type: "rsa"`, `key_type: "dsa"`, 1)
+	if err := os.WriteFile(configFile, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+	viper.SetConfigFile(configFile)
+
+	if _, err := config.LoadConfig(); err == nil || !strings.Contains(err.Error(), "key_type") {
+		t.Errorf("Expected an invalid key_type to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfig_Principals(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+vault:
+  address: "https://vault.example.com:8200"
+  auth_method: "token"
+
+users:
+  alice:
+    private_key: "/keys/alice"
+    principals: ["alice", "deploy"]
+
+groups:
+  prod:
+    hosts: ["*.prod.example.com"]
+    principals: ["prod-admin"]
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if principals := cfg.Users["alice"].Principals; !reflect.DeepEqual(principals, []string{"alice", "deploy"}) {
+		t.Errorf("Expected principals [alice deploy], got %v", principals)
+	}
+	if principals := cfg.ResolveHost("db01.prod.example.com").Principals; !reflect.DeepEqual(principals, []string{"prod-admin"}) {
+		t.Errorf("Expected principals [prod-admin], got %v", principals)
+	}
+
+	viper.Reset()
+	invalid := strings.Replace(configContent, `["prod-admin"]`, `["prod-admin,root"]`, 1)
+	if err := os.WriteFile(configFile, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+	viper.SetConfigFile(configFile)
+
+	if _, err := config.LoadConfig(); err == nil || !strings.Contains(err.Error(), "principals") {
+		t.Errorf("Expected a principal with a comma to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfig_Roles(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+vault:
+  address: "https://vault.example.com:8200"
+  auth_method: "token"
+
+roles:
+  deploy:
+    extensions:
+      permit-pty: ""
+    critical_options:
+      force-command: "/usr/local/bin/deploy"
+
+hosts:
+  - pattern: "ci-*"
+    extensions:
+      permit-port-forwarding: ""
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	role := cfg.Roles["deploy"]
+	if _, ok := role.Extensions["permit-pty"]; !ok {
+		t.Errorf("Expected the permit-pty extension, got %v", role.Extensions)
+	}
+	if command := role.CriticalOptions["force-command"]; command != "/usr/local/bin/deploy" {
+		t.Errorf("Expected force-command /usr/local/bin/deploy, got %q", command)
+	}
+	if _, ok := cfg.ResolveHost("ci-runner01").Extensions["permit-port-forwarding"]; !ok {
+		t.Errorf("Expected the permit-port-forwarding extension for ci-runner01")
+	}
+}
+
+func TestLoadConfig_VaultClusters(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.yaml")
+	t.Setenv("XDG_STATE_HOME", tempDir)
+
+	configContent := `
+vault:
+  address: "https://vault.corp.example.com:8200"
+  auth_method: "token"
+
+vaults:
+  prod:
+    address: "https://vault.prod.example.com:8200"
+    namespace: "ops"
+
+hosts:
+  - pattern: "*.prod.example.com"
+    vault: "prod"
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	name, vault := cfg.VaultFor(cfg.ResolveHost("db01.prod.example.com"))
+	if name != "prod" || vault.Address != "https://vault.prod.example.com:8200" || vault.Namespace != "ops" {
+		t.Errorf("Expected the prod cluster for db01.prod.example.com, got %s %+v", name, vault)
+	}
+	if vault.AuthMethod != "token" {
+		t.Errorf("Expected auth method token by default, got %s", vault.AuthMethod)
+	}
+	if expected := filepath.Join(tempDir, "vssh", "tokens", "prod"); vault.Token.TokenPath != expected {
+		t.Errorf("Expected token path %s, got %s", expected, vault.Token.TokenPath)
+	}
+	if name, vault := cfg.VaultFor(cfg.ResolveHost("web01.corp.example.com")); name != "" || vault.Address != cfg.Vault.Address {
+		t.Errorf("Expected the vault section for web01.corp.example.com, got %s %+v", name, vault)
+	}
+
+	viper.Reset()
+	invalid := strings.Replace(configContent, `vault: "prod"`, `vault: "staging"`, 1)
+	if err := os.WriteFile(configFile, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+	viper.SetConfigFile(configFile)
+
+	if _, err := config.LoadConfig(); err == nil || !strings.Contains(err.Error(), "unknown Vault cluster staging") {
+		t.Errorf("Expected an unknown Vault cluster to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfig_Logging(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.yaml")
+	t.Setenv("HOME", tempDir)
+
+	configContent := `
+vault:
+  address: "https://vault.example.com:8200"
+
+logging:
+  file: "~/.local/state/vssh/vssh.log"
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if expected := filepath.Join(tempDir, ".local", "state", "vssh", "vssh.log"); cfg.Logging.File != expected {
+		t.Errorf("Expected log file %s, got %s", expected, cfg.Logging.File)
+	}
+	if cfg.Logging.Level != "debug" || cfg.Logging.Format != "text" || cfg.Logging.MaxSize != 10 || cfg.Logging.MaxBackups != 3 {
+		t.Errorf("Expected level debug, format text, max_size 10 and max_backups 3 by default, got %+v", cfg.Logging)
+	}
+
+	viper.Reset()
+	invalid := configContent + "  level: \"verbose\"\n"
+	if err := os.WriteFile(configFile, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+	viper.SetConfigFile(configFile)
+
+	if _, err := config.LoadConfig(); err == nil || !strings.Contains(err.Error(), "logging.level") {
+		t.Errorf("Expected an invalid logging.level to be rejected, got %v", err)
+	}
+
+	viper.Reset()
+	invalid = configContent + "  format: \"xml\"\n"
+	if err := os.WriteFile(configFile, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+	viper.SetConfigFile(configFile)
+
+	if _, err := config.LoadConfig(); err == nil || !strings.Contains(err.Error(), "logging.format") {
+		t.Errorf("Expected an invalid logging.format to be rejected, got %v", err)
+	}
+
+	viper.Reset()
+	invalid = configContent + "  syslog:\n    enabled: true\n    network: \"udp\"\n"
+	if err := os.WriteFile(configFile, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+	viper.SetConfigFile(configFile)
+
+	if _, err := config.LoadConfig(); err == nil || !strings.Contains(err.Error(), "logging.syslog.address") {
+		t.Errorf("Expected a syslog network without an address to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfig_Metrics(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.yaml")
+	t.Setenv("HOME", tempDir)
+
+	configContent := `
+vault:
+  address: "https://vault.example.com:8200"
+
+agentd:
+  metrics_file: "~/textfiles/vssh.prom"
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !cfg.Metrics.Enabled {
+		t.Errorf("Expected metrics to be enabled by default")
+	}
+	if expected := filepath.Join(tempDir, "textfiles", "vssh.prom"); cfg.Agentd.MetricsFile != expected {
+		t.Errorf("Expected metrics file %s, got %s", expected, cfg.Agentd.MetricsFile)
+	}
+
+	viper.Reset()
+	invalid := configContent + "  metrics_listen: \"9782\"\n"
+	if err := os.WriteFile(configFile, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+	viper.SetConfigFile(configFile)
+
+	if _, err := config.LoadConfig(); err == nil || !strings.Contains(err.Error(), "agentd.metrics_listen") {
+		t.Errorf("Expected an agentd.metrics_listen without a port to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfig_TokenPath(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.yaml")
+	t.Setenv("XDG_STATE_HOME", tempDir)
+	t.Setenv("HOME", tempDir)
+
+	load := func(content string) *types.Config {
+		t.Helper()
+		if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test config file: %v", err)
+		}
+		viper.Reset()
+		viper.SetConfigFile(configFile)
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return cfg
+	}
+
+	corp := load("vault:\n  address: \"https://vault.corp.example.com\"\n").Vault.Token
+	if dir := filepath.Join(tempDir, "vssh", "tokens"); filepath.Dir(corp.TokenPath) != dir {
+		t.Errorf("Expected the token in %s, got %s", dir, corp.TokenPath)
+	}
+	if expected := filepath.Join(tempDir, ".vault-token"); corp.ImportPath != expected {
+		t.Errorf("Expected a token from vault login to be imported from %s, got %q", expected, corp.ImportPath)
+	}
+
+	// Each cluster and namespace keeps its own token
+	prod := load("vault:\n  address: \"https://vault.prod.example.com\"\n").Vault.Token
+	namespaced := load("vault:\n  address: \"https://vault.corp.example.com/\"\n  namespace: \"ops\"\n").Vault.Token
+	if prod.TokenPath == corp.TokenPath || namespaced.TokenPath == corp.TokenPath {
+		t.Errorf("Expected different token files per cluster and namespace, got %s, %s and %s", corp.TokenPath, prod.TokenPath, namespaced.TokenPath)
+	}
+
+	// The Vault client uses VAULT_NAMESPACE without a configured namespace
+	t.Setenv("VAULT_NAMESPACE", "ops")
+	fromEnv := load("vault:\n  address: \"https://vault.corp.example.com\"\n")
+	if fromEnv.Vault.Namespace != "ops" || fromEnv.Vault.Token.TokenPath != namespaced.TokenPath {
+		t.Errorf("Expected namespace ops and token path %s from VAULT_NAMESPACE, got %q and %s", namespaced.TokenPath, fromEnv.Vault.Namespace, fromEnv.Vault.Token.TokenPath)
+	}
+
+	explicit := load("vault:\n  address: \"https://vault.corp.example.com\"\n  token:\n    token_path: \"/opt/vault/token\"\n").Vault.Token
+	if explicit.TokenPath != "/opt/vault/token" || explicit.ImportPath != "" {
+		t.Errorf("Expected the configured token path alone, got %+v", explicit)
+	}
+}
@@ -0,0 +1,151 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"vssh/internal/config"
+	"vssh/pkg/types"
+)
+
+func TestParseValue_Types(t *testing.T) {
+	testCases := []struct {
+		key     string
+		raw     string
+		want    interface{}
+		wantErr bool
+	}{
+		{"vault.address", "https://vault.test:8200", "https://vault.test:8200", false},
+		{"ssh.certificate_ttl", "30m", 30 * time.Minute, false},
+		{"ssh.certificate_ttl", "forever", nil, true},
+		{"debug", "true", true, false},
+		{"debug", "maybe", nil, true},
+		{"users.alice.private_key", "/keys/alice", "/keys/alice", false},
+		{"vault.unknown", "x", nil, true},
+		{"vault", "x", nil, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := config.ParseValue(tc.key, tc.raw)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Expected error for %s=%s, got %v", tc.key, tc.raw, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error for %s=%s, got %v", tc.key, tc.raw, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Expected %v for %s, got %v", tc.want, tc.key, got)
+		}
+	}
+}
+
+func TestGetValue(t *testing.T) {
+	cfg := &types.Config{
+		Vault: types.VaultConfig{Address: "https://vault.test"},
+		SSH:   types.SSHConfig{CertificateTTL: 2 * time.Hour},
+		Users: types.UserConfigs{"alice": {PrivateKey: "/keys/alice"}},
+	}
+
+	value, err := config.GetValue(cfg, "vault.address")
+	if err != nil || value != "https://vault.test" {
+		t.Errorf("Expected vault address, got %v (err %v)", value, err)
+	}
+
+	value, err = config.GetValue(cfg, "ssh.certificate_ttl")
+	if err != nil || config.FormatValue(value) != "2h0m0s" {
+		t.Errorf("Expected 2h0m0s, got %v (err %v)", value, err)
+	}
+
+	value, err = config.GetValue(cfg, "users.alice.private_key")
+	if err != nil || value != "/keys/alice" {
+		t.Errorf("Expected alice private key, got %v (err %v)", value, err)
+	}
+
+	if _, err := config.GetValue(cfg, "users.bob.private_key"); err == nil {
+		t.Errorf("Expected error for unset user")
+	}
+}
+
+func TestSetValue_PreservesComments(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `# top comment
+vault:
+  address: "https://old.example.com" # the vault
+  auth_method: "token"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	if err := config.SetValue(configPath, "vault.address", "https://new.example.com"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := config.SetValue(configPath, "ssh.certificate_ttl", time.Hour); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+
+	result := string(data)
+	for _, expected := range []string{"# top comment", "# the vault", "https://new.example.com", "certificate_ttl: 1h0m0s"} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("Expected config file to contain %q, got:\n%s", expected, result)
+		}
+	}
+}
+
+func TestSetValue_RejectsInvalidConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "vault:\n  address: \"https://vault.example.com\"\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	if err := config.SetValue(configPath, "vault.auth_method", "kerberos"); err == nil {
+		t.Errorf("Expected validation error for invalid auth method")
+	}
+
+	data, _ := os.ReadFile(configPath)
+	if string(data) != content {
+		t.Errorf("Expected config file to be unchanged, got:\n%s", data)
+	}
+}
+
+func TestUnsetValue(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `vault:
+  address: "https://vault.example.com"
+bookmarks:
+  db: "admin@db01.example.com"
+  web: "web01.example.com"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	if err := config.UnsetValue(configPath, "bookmarks.db"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	if strings.Contains(string(data), "db01") || !strings.Contains(string(data), "web01") {
+		t.Errorf("Expected only the db bookmark to be removed, got:\n%s", data)
+	}
+
+	if err := config.UnsetValue(configPath, "bookmarks.db"); err == nil {
+		t.Errorf("Expected error removing a key that is not set")
+	}
+}
@@ -0,0 +1,489 @@
+//go:build e2e && !windows
+
+// Package e2e_test runs the vssh binary against a mock Vault and an SSH
+// server trusting its CA, covering login, signing and connecting together:
+//
+//	go test -tags e2e ./tests/e2e/
+package e2e_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// vsshBinary is the vssh binary built for the tests
+var vsshBinary string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "vssh-e2e")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create build directory: %v\n", err)
+		os.Exit(1)
+	}
+	vsshBinary = filepath.Join(dir, "vssh")
+	build := exec.Command("go", "build", "-o", vsshBinary, "vssh")
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to build vssh: %v\n", err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testToken is the Vault token the mock Vault accepts
+const testToken = "hvs.e2e-token"
+
+// environment is a home directory with a vssh configuration, a Vault token
+// and a known_hosts file for an SSH server
+type environment struct {
+	home string
+
+	// stdin is the input of the vssh commands run
+	stdin string
+}
+
+// newEnvironment configures vssh to sign with vault, using token, and to
+// connect to sshd with the built-in SSH client
+func newEnvironment(t *testing.T, vault *mockVault, sshd *testSSHD, token string) *environment {
+	t.Helper()
+	e := &environment{home: t.TempDir()}
+
+	knownHosts := filepath.Join(e.home, ".ssh", "known_hosts")
+	config := fmt.Sprintf(`vault:
+  address: %q
+  auth_method: "token"
+  select_role: false
+ssh:
+  native: true
+  auto_generate_key: true
+  strict_host_key_checking: "yes"
+  known_hosts_file: %q
+`, vault.server.URL, knownHosts)
+
+	e.write(t, ".config/vssh/config.yaml", config)
+	e.write(t, ".vault-token", token)
+	e.write(t, ".ssh/known_hosts", knownhosts.Line([]string{knownhosts.Normalize(sshd.Addr())}, sshd.hostKey.PublicKey())+"\n")
+	return e
+}
+
+// write creates a file in the home directory
+func (e *environment) write(t *testing.T, name, content string) {
+	t.Helper()
+	path := filepath.Join(e.home, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// configure adds yaml to the vssh configuration
+func (e *environment) configure(t *testing.T, yaml string) {
+	t.Helper()
+	path := filepath.Join(e.home, ".config", "vssh", "config.yaml")
+	config, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	e.write(t, ".config/vssh/config.yaml", string(config)+yaml)
+}
+
+// read returns the contents of a file in the home directory, or "" if it
+// doesn't exist
+func (e *environment) read(name string) string {
+	data, _ := os.ReadFile(filepath.Join(e.home, name))
+	return string(data)
+}
+
+// vssh runs vssh with args and returns its output and exit code
+func (e *environment) vssh(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := e.command(args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("Failed to run vssh: %v", err)
+	}
+	return stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()
+}
+
+// command returns a vssh command with args in the environment. It runs in a
+// new session without a controlling terminal, so it can never prompt.
+func (e *environment) command(args ...string) *exec.Cmd {
+	cmd := exec.Command(vsshBinary, args...)
+	cmd.Env = []string{
+		"HOME=" + e.home,
+		"PATH=" + os.Getenv("PATH"),
+		"XDG_CACHE_HOME=" + filepath.Join(e.home, ".cache"),
+		"XDG_STATE_HOME=" + filepath.Join(e.home, ".local", "state"),
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Stdin = strings.NewReader(e.stdin)
+	return cmd
+}
+
+func TestConnect_SignsAndReusesCertificate(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	stdout, stderr, code := env.vssh(t, "alice@"+sshd.Addr(), "whoami")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	if stdout != "alice\n" {
+		t.Errorf("Expected output %q, got %q", "alice\n", stdout)
+	}
+
+	issued := vault.Issued()
+	if len(issued) != 1 {
+		t.Fatalf("Expected 1 certificate to be signed, got %d", len(issued))
+	}
+	if principals := issued[0].ValidPrincipals; len(principals) != 1 || principals[0] != "alice" {
+		t.Errorf("Expected the certificate to be valid for alice, got %v", principals)
+	}
+	if _, err := os.Stat(filepath.Join(env.home, ".ssh", "id_ed25519")); err != nil {
+		t.Errorf("Expected a key to be generated: %v", err)
+	}
+
+	// The cached certificate is used for the next connection
+	stdout, stderr, code = env.vssh(t, "alice@"+sshd.Addr(), "echo", "hello")
+	if code != 0 || stdout != "hello\n" {
+		t.Fatalf("Expected exit code 0 and output %q, got %d and %q: %s", "hello\n", code, stdout, stderr)
+	}
+	if n := len(vault.Issued()); n != 1 {
+		t.Errorf("Expected the certificate to be reused, got %d signed", n)
+	}
+}
+
+func TestConnect_ExitStatus(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	_, stderr, code := env.vssh(t, "alice@"+sshd.Addr(), "exit", "3")
+	if code != 3 {
+		t.Errorf("Expected the remote exit status 3, got %d: %s", code, stderr)
+	}
+}
+
+func TestConnect_InvalidToken(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, "hvs.expired")
+
+	_, stderr, code := env.vssh(t, "alice@"+sshd.Addr(), "whoami")
+	if code != 3 {
+		t.Errorf("Expected exit code 3 for a failed login, got %d: %s", code, stderr)
+	}
+	if n := len(vault.Issued()); n != 0 {
+		t.Errorf("Expected nothing to be signed, got %d certificates", n)
+	}
+}
+
+func TestConnect_SigningRefused(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	_, stderr, code := env.vssh(t, "mallory@"+sshd.Addr(), "whoami")
+	if code != 4 {
+		t.Errorf("Expected exit code 4 for a refused signing request, got %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "unknown role: mallory") {
+		t.Errorf("Expected Vault's error to be reported, got %q", stderr)
+	}
+}
+
+func TestConnect_UntrustedCA(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	other := newMockVault(t, testToken)
+	sshd := newSSHD(t, other.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	_, stderr, code := env.vssh(t, "alice@"+sshd.Addr(), "whoami")
+	if code != 255 {
+		t.Errorf("Expected exit code 255 when the server doesn't trust the CA, got %d: %s", code, stderr)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+	env.configure(t, "vault: [\n")
+
+	for _, args := range [][]string{
+		{"alice@" + sshd.Addr(), "whoami"},
+		{"config", "get", "vault.address"},
+		{"history"},
+		{"group", "list"},
+	} {
+		_, stderr, code := env.vssh(t, args...)
+		if code != 2 {
+			t.Errorf("Expected exit code 2 for %v, got %d: %s", args, code, stderr)
+		}
+	}
+}
+
+func TestConnect_Banner(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+	env.configure(t, `hosts:
+  - pattern: "127.0.0.1"
+    banner: "Change freeze in effect"
+logging:
+  level: "error"
+  format: "json"
+`)
+
+	_, stderr, code := env.vssh(t, "--quiet", "alice@"+sshd.Addr(), "whoami")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "\nChange freeze in effect\n") && !strings.HasPrefix(stderr, "Change freeze in effect\n") {
+		t.Errorf("Expected the banner on a line of its own, got %q", stderr)
+	}
+}
+
+func TestConnect_Reconnect(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	stdout, stderr, code := env.vssh(t, "--reconnect", "--retry-interval", "100ms", "alice@"+sshd.Addr(), "drop")
+	if code != 0 || stdout != "connected\n" {
+		t.Fatalf("Expected exit code 0 and output %q, got %d and %q: %s", "connected\n", code, stdout, stderr)
+	}
+	if !strings.Contains(stderr, "reconnecting in 100ms (attempt 1 of 3)") {
+		t.Errorf("Expected the reconnection to be reported, got %q", stderr)
+	}
+	if n := len(vault.Issued()); n != 1 {
+		t.Errorf("Expected the certificate to be reused, got %d signed", n)
+	}
+}
+
+func TestConnect_DropWithoutReconnect(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	_, stderr, code := env.vssh(t, "alice@"+sshd.Addr(), "drop")
+	if code != 255 {
+		t.Errorf("Expected exit code 255, got %d: %s", code, stderr)
+	}
+}
+
+func TestCluster_BroadcastsInput(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice", "bob")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	env.stdin = "whoami\nexit 2\n"
+	stdout, stderr, code := env.vssh(t, "cluster", "--hosts", "alice@"+sshd.Addr()+",bob@"+sshd.Addr())
+	if code != 2 {
+		t.Errorf("Expected the shells' exit status 2, got %d: %s", code, stderr)
+	}
+	for _, line := range []string{"alice@" + sshd.Addr() + ": alice\n", "bob@" + sshd.Addr() + ": bob\n"} {
+		if !strings.Contains(stdout, line) {
+			t.Errorf("Expected output line %q, got %q", line, stdout)
+		}
+	}
+}
+
+func TestGitSSH(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	// git checks that its ssh command takes OpenSSH's options first
+	if _, stderr, code := env.vssh(t, "git-ssh", "-G", "alice@"+sshd.Addr()); code != 0 {
+		t.Fatalf("Expected exit code 0 for -G, got %d: %s", code, stderr)
+	}
+
+	host, port, _ := strings.Cut(sshd.Addr(), ":")
+	stdout, stderr, code := env.vssh(t, "git-ssh", "-o", "SendEnv=GIT_PROTOCOL", "-p", port, "alice@"+host, "echo git-upload-pack")
+	if code != 0 || stdout != "git-upload-pack\n" {
+		t.Fatalf("Expected exit code 0 and output %q, got %d and %q: %s", "git-upload-pack\n", code, stdout, stderr)
+	}
+	if stderr != "" {
+		t.Errorf("Expected no messages besides git's, got %q", stderr)
+	}
+	if n := len(vault.Issued()); n != 1 {
+		t.Errorf("Expected a certificate to be signed, got %d", n)
+	}
+}
+
+func TestConnect_ConcurrentSigning(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	// Like an editor opening several channels at once, before any key or
+	// certificate exists
+	const processes = 8
+	outputs := make(chan string, processes)
+	for range processes {
+		go func() {
+			output, err := env.command("alice@"+sshd.Addr(), "whoami").Output()
+			if err != nil {
+				output = fmt.Appendf(output, "%v", err)
+			}
+			outputs <- string(output)
+		}()
+	}
+	for range processes {
+		if output := <-outputs; output != "alice\n" {
+			t.Errorf("Expected output %q, got %q", "alice\n", output)
+		}
+	}
+
+	if n := len(vault.Issued()); n != 1 {
+		t.Errorf("Expected one process to sign the certificate for all, got %d signed", n)
+	}
+}
+
+// hooksConfig records the target of each pre_connect hook and the exit code
+// of each post_disconnect hook in the home directory. pre_connect comes last,
+// so a test can add a hook to it.
+const hooksConfig = `hooks:
+  post_disconnect:
+    - 'echo "$VSSH_EXIT_CODE" >> "$HOME/post_disconnect"'
+  pre_connect:
+    - 'echo "$VSSH_TARGET" >> "$HOME/pre_connect"'
+`
+
+func TestRun_Hooks(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+	env.configure(t, hooksConfig)
+
+	target := "alice@" + sshd.Addr()
+	if _, stderr, code := env.vssh(t, "run", "--hosts", target, "--", "whoami"); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	if pre, post := env.read("pre_connect"), env.read("post_disconnect"); pre != target+"\n" || post != "0\n" {
+		t.Errorf("Expected the hooks to run for %s, got pre_connect %q and post_disconnect %q", target, pre, post)
+	}
+
+	// A failing pre_connect hook keeps vssh run from connecting
+	env.configure(t, "    - 'exit 1'\n")
+	stdout, stderr, code := env.vssh(t, "run", "--hosts", target, "--", "whoami")
+	if code != 1 || strings.Contains(stdout, "alice") {
+		t.Errorf("Expected exit code 1 without connecting, got %d and %q: %s", code, stdout, stderr)
+	}
+}
+
+func TestSCP_Hooks(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+	env.configure(t, hooksConfig+"    - 'exit 1'\n")
+	env.write(t, "report.txt", "report\n")
+
+	// The hooks run before scp, so the copy is refused without scp installed
+	host, port, _ := strings.Cut(sshd.Addr(), ":")
+	_, stderr, code := env.vssh(t, "scp", "-P", port, filepath.Join(env.home, "report.txt"), "alice@"+host+":/tmp/")
+	if code != 1 || !strings.Contains(stderr, "pre_connect") {
+		t.Errorf("Expected exit code 1 from the pre_connect hook, got %d: %s", code, stderr)
+	}
+	if pre := env.read("pre_connect"); pre != "alice@"+host+"\n" {
+		t.Errorf("Expected the pre_connect hook to run for alice@%s, got %q", host, pre)
+	}
+	if post := env.read("post_disconnect"); post != "" {
+		t.Errorf("Expected no post_disconnect hook without a connection, got %q", post)
+	}
+}
+
+func TestRun_Webhook(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice", "bob")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	var mu sync.Mutex
+	var events []string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event struct{ Event, Target string }
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode webhook event: %v", err)
+		}
+		mu.Lock()
+		events = append(events, event.Event+" "+event.Target)
+		mu.Unlock()
+	}))
+	t.Cleanup(webhook.Close)
+	env.configure(t, fmt.Sprintf("webhook:\n  url: %q\n", webhook.URL))
+
+	alice, bob := "alice@"+sshd.Addr(), "bob@"+sshd.Addr()
+	if _, stderr, code := env.vssh(t, "run", "--hosts", alice+","+bob, "--", "whoami"); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	slices.Sort(events)
+	expected := []string{"session_end " + alice, "session_end " + bob, "session_start " + alice, "session_start " + bob}
+	if !slices.Equal(events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+}
+
+func TestConnect_HistoryForEachTarget(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice", "bob")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	alice, bob := "alice@"+sshd.Addr(), "bob@"+sshd.Addr()
+	if _, stderr, code := env.vssh(t, alice, bob, "--", "whoami"); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+
+	var entries []struct{ Target string }
+	if err := json.Unmarshal([]byte(env.read(".local/state/vssh/history.json")), &entries); err != nil {
+		t.Fatalf("Failed to parse the history: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Target != alice || entries[1].Target != bob {
+		t.Errorf("Expected history entries for %s and %s, got %+v", alice, bob, entries)
+	}
+}
+
+func TestRun_DiffShowsFailingHosts(t *testing.T) {
+	vault := newMockVault(t, testToken, "alice", "bob")
+	sshd := newSSHD(t, vault.ca.PublicKey())
+	env := newEnvironment(t, vault, sshd, testToken)
+
+	// Nothing listens on port 1, so bob's host can't be reached and has no
+	// output to compare
+	alice, unreachable := "alice@"+sshd.Addr(), "bob@127.0.0.1:1"
+	stdout, stderr, code := env.vssh(t, "run", "--hosts", alice+","+unreachable, "--diff", "--", "false")
+	if code != 255 {
+		t.Errorf("Expected exit code 255, got %d: %s", code, stderr)
+	}
+	if expected := "==> " + alice + " [exit 1] <==\n"; stdout != expected {
+		t.Errorf("Expected output %q, got %q", expected, stdout)
+	}
+}
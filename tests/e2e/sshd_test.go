@@ -0,0 +1,172 @@
+//go:build e2e && !windows
+
+package e2e_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// testSSHD is an SSH server that only accepts user certificates signed by a
+// CA, like sshd with TrustedUserCAKeys, and runs a few built-in commands
+type testSSHD struct {
+	listener net.Listener
+	hostKey  gossh.Signer
+	config   *gossh.ServerConfig
+
+	// drops counts the drop commands run, the first of which drops the connection
+	drops atomic.Int32
+}
+
+// newSSHD starts an SSH server on localhost trusting certificates from ca
+func newSSHD(t *testing.T, ca gossh.PublicKey) *testSSHD {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate host key: %v", err)
+	}
+	hostKey, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("Failed to create host key signer: %v", err)
+	}
+
+	checker := &gossh.CertChecker{
+		IsUserAuthority: func(auth gossh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), ca.Marshal())
+		},
+	}
+	config := &gossh.ServerConfig{PublicKeyCallback: checker.Authenticate}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	d := &testSSHD{listener: listener, hostKey: hostKey, config: config}
+	go d.serve()
+	return d
+}
+
+// Addr returns the host:port the server listens on
+func (d *testSSHD) Addr() string {
+	return d.listener.Addr().String()
+}
+
+// serve accepts connections until the listener is closed
+func (d *testSSHD) serve() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+// handle runs the sessions of one connection
+func (d *testSSHD) handle(conn net.Conn) {
+	defer conn.Close()
+	serverConn, channels, requests, err := gossh.NewServerConn(conn, d.config)
+	if err != nil {
+		return
+	}
+	go gossh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(gossh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go d.session(serverConn, channel, channelRequests)
+	}
+}
+
+// session runs the command of an exec request and reports its exit status.
+// The first drop command drops the connection instead, like a network blip.
+func (d *testSSHD) session(conn *gossh.ServerConn, channel gossh.Channel, requests <-chan *gossh.Request) {
+	defer channel.Close()
+	for request := range requests {
+		if request.Type == "shell" {
+			request.Reply(true, nil)
+			status := shell(conn.User(), channel)
+			channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+			return
+		}
+		if request.Type != "exec" {
+			// Accept pseudo-terminals and environment variables, which the
+			// commands don't use
+			request.Reply(request.Type == "pty-req" || request.Type == "env", nil)
+			continue
+		}
+		request.Reply(true, nil)
+
+		var command string
+		if len(request.Payload) >= 4 {
+			command = string(request.Payload[4:])
+		}
+		if command == "drop" && d.drops.Add(1) == 1 {
+			conn.Close()
+			return
+		}
+		status := run(conn.User(), command, channel, channel.Stderr())
+		channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+		return
+	}
+}
+
+// shell runs each line of input as a built-in command until input ends, and
+// returns the exit status of the last one
+func shell(user string, channel gossh.Channel) uint32 {
+	var status uint32
+	scanner := bufio.NewScanner(channel)
+	for scanner.Scan() {
+		status = run(user, scanner.Text(), channel, channel.Stderr())
+	}
+	return status
+}
+
+// run runs one of the built-in commands: whoami, echo, exit, false and drop, which
+// reports that it connected once the connection hasn't been dropped
+func run(user, command string, stdout, stderr interface{ Write([]byte) (int, error) }) uint32 {
+	name, args, _ := strings.Cut(command, " ")
+	switch name {
+	case "drop":
+		fmt.Fprintln(stdout, "connected")
+		return 0
+	case "whoami":
+		fmt.Fprintln(stdout, user)
+		return 0
+	case "echo":
+		fmt.Fprintln(stdout, args)
+		return 0
+	case "false":
+		return 1
+	case "exit":
+		status, err := strconv.ParseUint(args, 10, 8)
+		if err != nil {
+			fmt.Fprintf(stderr, "exit: %s: numeric argument required\n", args)
+			return 2
+		}
+		return uint32(status)
+	default:
+		fmt.Fprintf(stderr, "%s: command not found\n", name)
+		return 127
+	}
+}
@@ -0,0 +1,163 @@
+//go:build e2e && !windows
+
+package e2e_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// mockVault implements the Vault endpoints vssh uses to check its token and
+// sign user certificates on the ssh-client-signer engine, with a CA of its own
+type mockVault struct {
+	server *httptest.Server
+	ca     gossh.Signer
+	token  string
+	roles  map[string]bool
+
+	mu     sync.Mutex
+	issued []*gossh.Certificate
+}
+
+// newMockVault starts a mock Vault accepting token and signing with roles
+func newMockVault(t *testing.T, token string, roles ...string) *mockVault {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	ca, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("Failed to create CA signer: %v", err)
+	}
+
+	v := &mockVault{ca: ca, token: token, roles: map[string]bool{}}
+	for _, role := range roles {
+		v.roles[role] = true
+	}
+	v.server = httptest.NewServer(v)
+	t.Cleanup(v.server.Close)
+	return v
+}
+
+// Issued returns the certificates signed so far
+func (v *mockVault) Issued() []*gossh.Certificate {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]*gossh.Certificate(nil), v.issued...)
+}
+
+func (v *mockVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+
+	// The CA public key is readable without a token
+	if path == "ssh-client-signer/public_key" && r.Method == http.MethodGet {
+		w.Write(gossh.MarshalAuthorizedKey(v.ca.PublicKey()))
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != v.token {
+		vaultError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	switch {
+	case path == "auth/token/lookup-self" && r.Method == http.MethodGet:
+		writeJSON(w, map[string]interface{}{
+			"data": map[string]interface{}{"ttl": 3600, "renewable": false, "display_name": "token-e2e"},
+		})
+	case strings.HasPrefix(path, "ssh-client-signer/sign/") && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		v.sign(w, r, strings.TrimPrefix(path, "ssh-client-signer/sign/"))
+	default:
+		vaultError(w, http.StatusNotFound)
+	}
+}
+
+// sign signs the public key in a sign request like Vault's SSH engine
+func (v *mockVault) sign(w http.ResponseWriter, r *http.Request, role string) {
+	if !v.roles[role] {
+		vaultError(w, http.StatusBadRequest, "unknown role: "+role)
+		return
+	}
+
+	var request struct {
+		PublicKey       string `json:"public_key"`
+		TTL             string `json:"ttl"`
+		ValidPrincipals string `json:"valid_principals"`
+		KeyID           string `json:"key_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		vaultError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	publicKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(request.PublicKey))
+	if err != nil {
+		vaultError(w, http.StatusBadRequest, "failed to parse public_key: "+err.Error())
+		return
+	}
+
+	ttl := time.Hour
+	if request.TTL != "" {
+		if ttl, err = time.ParseDuration(request.TTL); err != nil {
+			vaultError(w, http.StatusBadRequest, "invalid ttl: "+err.Error())
+			return
+		}
+	}
+	principals := []string{role}
+	if request.ValidPrincipals != "" {
+		principals = strings.Split(request.ValidPrincipals, ",")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := time.Now()
+	cert := &gossh.Certificate{
+		Key:             publicKey,
+		Serial:          uint64(len(v.issued) + 1),
+		CertType:        gossh.UserCert,
+		KeyId:           request.KeyID,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: gossh.Permissions{
+			Extensions: map[string]string{"permit-pty": ""},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, v.ca); err != nil {
+		vaultError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	v.issued = append(v.issued, cert)
+
+	writeJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{
+			"serial_number": cert.Serial,
+			"signed_key":    string(gossh.MarshalAuthorizedKey(cert)),
+		},
+	})
+}
+
+// writeJSON writes a Vault response
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// vaultError writes a Vault error response
+func vaultError(w http.ResponseWriter, status int, errors ...string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if errors == nil {
+		errors = []string{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": errors})
+}
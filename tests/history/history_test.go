@@ -0,0 +1,164 @@
+package history_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"vssh/internal/history"
+)
+
+func TestStore_AddAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history.json")
+	store := history.NewStore(path, 2)
+
+	entries, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() on missing file error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Load() on missing file = %v, want empty", entries)
+	}
+
+	now := time.Now()
+	for _, target := range []string{"web1", "alice@web2", "db1:2222"} {
+		if err := store.Add(history.Entry{Target: target, Time: now}); err != nil {
+			t.Fatalf("Add(%s) error = %v", target, err)
+		}
+	}
+
+	entries, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Load() returned %d entries, want 2", len(entries))
+	}
+	if entries[0].Target != "alice@web2" || entries[1].Target != "db1:2222" {
+		t.Errorf("Load() = %v, want oldest entry dropped", entries)
+	}
+
+	entry, err := store.Get(2)
+	if err != nil {
+		t.Fatalf("Get(2) error = %v", err)
+	}
+	if entry.Target != "db1:2222" {
+		t.Errorf("Get(2).Target = %s, want db1:2222", entry.Target)
+	}
+
+	if _, err := store.Get(3); err == nil {
+		t.Error("Get(3) expected error")
+	}
+}
+
+func TestStore_ConcurrentAdd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+
+	// Each store stands for a vssh process adding to the same file
+	const adds = 20
+	var wg sync.WaitGroup
+	for i := range adds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := history.NewStore(path, 0).Add(history.Entry{Target: fmt.Sprintf("web%d", i)}); err != nil {
+				t.Errorf("Add() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := history.NewStore(path, 0).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(entries) != adds {
+		t.Errorf("Load() returned %d entries, want %d", len(entries), adds)
+	}
+}
+
+func TestStore_SessionSummary(t *testing.T) {
+	store := history.NewStore(filepath.Join(t.TempDir(), "history.json"), 0)
+
+	entry := history.Entry{Target: "db", Time: time.Now(), Duration: time.Minute, ExitCode: 130, CertSerial: "4711"}
+	if err := store.Add(entry); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	got, err := store.Get(1)
+	if err != nil {
+		t.Fatalf("Get(1) error = %v", err)
+	}
+	if got.ExitCode != 130 || got.CertSerial != "4711" || got.Duration != time.Minute {
+		t.Errorf("Get(1) = %+v, want exit code 130, serial 4711 and a minute", got)
+	}
+}
+
+func TestStore_ProtectedHostReason(t *testing.T) {
+	store := history.NewStore(filepath.Join(t.TempDir(), "history.json"), 0)
+
+	entry := history.Entry{Target: "db01.prod", Time: time.Now(), ExitCode: 255, Protected: true, Reason: "INC-4711 disk full"}
+	if err := store.Add(entry); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	got, err := store.Get(1)
+	if err != nil {
+		t.Fatalf("Get(1) error = %v", err)
+	}
+	if !got.Protected || got.Reason != "INC-4711 disk full" {
+		t.Errorf("Get(1) = %+v, want the confirmation and its reason", got)
+	}
+}
+
+func TestCertificateStore_AddAndLoad(t *testing.T) {
+	path := history.DefaultCertificatesPath(filepath.Join(t.TempDir(), "state"))
+	store := history.NewCertificateStore(path, 2)
+
+	issued := time.Now().Truncate(time.Second)
+	for serial := uint64(1); serial <= 3; serial++ {
+		cert := history.Certificate{Serial: serial, KeyID: "alice", User: "alice", Host: "web1", Role: "alice", Engine: "ssh-client-signer", Issued: issued}
+		if err := store.Add(cert); err != nil {
+			t.Fatalf("Add(%d) error = %v", serial, err)
+		}
+	}
+
+	certs, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(certs) != 2 || certs[0].Serial != 2 || certs[1].Serial != 3 {
+		t.Fatalf("Load() = %v, want serials 2 and 3", certs)
+	}
+	if !certs[1].Issued.Equal(issued) || certs[1].KeyID != "alice" || !certs[1].Expires.IsZero() {
+		t.Errorf("Load() = %+v, want the recorded fields", certs[1])
+	}
+}
+
+func TestCertificateStore_ConcurrentAdd(t *testing.T) {
+	path := history.DefaultCertificatesPath(t.TempDir())
+
+	// Each store stands for a vssh process recording the certificate it signed
+	const signings = 20
+	var wg sync.WaitGroup
+	for serial := range uint64(signings) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := history.NewCertificateStore(path, 0).Add(history.Certificate{Serial: serial + 1}); err != nil {
+				t.Errorf("Add(%d) error = %v", serial+1, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	certs, err := history.NewCertificateStore(path, 0).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(certs) != signings {
+		t.Errorf("Load() returned %d certificates, want %d", len(certs), signings)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package hooks_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"vssh/internal/hooks"
+)
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	commands := []string{`echo "pre $VSSH_USER@$VSSH_HOST"`, `echo serial=$VSSH_CERT_SERIAL`}
+	env := []string{"VSSH_HOST=db01", "VSSH_USER=alice", "VSSH_CERT_SERIAL=42"}
+
+	if err := hooks.Run(commands, env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := "pre alice@db01\nserial=42\n"; out.String() != expected {
+		t.Errorf("Expected output %q, got %q", expected, out.String())
+	}
+}
+
+func TestRun_StopsAtFailure(t *testing.T) {
+	var out bytes.Buffer
+	commands := []string{"echo first", "exit 3", "echo never"}
+
+	err := hooks.Run(commands, nil, &out)
+	if err == nil || !strings.Contains(err.Error(), `hook "exit 3" failed`) {
+		t.Errorf("Expected the failing hook in the error, got %v", err)
+	}
+	if out.String() != "first\n" {
+		t.Errorf("Expected only the first hook to run before the failure, got %q", out.String())
+	}
+}
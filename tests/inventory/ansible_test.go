@@ -0,0 +1,45 @@
+package inventory_test
+
+import (
+	"reflect"
+	"testing"
+
+	"vssh/internal/inventory"
+)
+
+func TestAnsible(t *testing.T) {
+	hosts := []inventory.AnsibleHost{
+		{Name: "web02", Groups: []string{"web", "tag_env_prod"}, Vars: map[string]interface{}{"ansible_user": "deploy"}},
+		{Name: "web01", Groups: []string{"web"}, Vars: map[string]interface{}{"ansible_port": "2222"}},
+		{Name: "db01", Groups: []string{"db-prod"}},
+	}
+
+	result := inventory.Ansible(hosts)
+
+	all := result["all"].(map[string]interface{})["hosts"]
+	if !reflect.DeepEqual(all, []string{"db01", "web01", "web02"}) {
+		t.Errorf("all hosts = %v", all)
+	}
+
+	web := result["web"].(map[string]interface{})["hosts"]
+	if !reflect.DeepEqual(web, []string{"web01", "web02"}) {
+		t.Errorf("web hosts = %v", web)
+	}
+
+	if _, exists := result["db_prod"]; !exists {
+		t.Errorf("expected group db-prod to be renamed db_prod, got %v", result)
+	}
+
+	hostvars := result["_meta"].(map[string]interface{})["hostvars"].(map[string]interface{})
+	if vars := hostvars["web02"].(map[string]interface{}); vars["ansible_user"] != "deploy" {
+		t.Errorf("web02 hostvars = %v", vars)
+	}
+}
+
+func TestTagGroups(t *testing.T) {
+	groups := inventory.TagGroups(map[string]string{"Environment": "staging", "Team": "data-eng"})
+	want := []string{"tag_Environment_staging", "tag_Team_data_eng"}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("TagGroups() = %v, want %v", groups, want)
+	}
+}
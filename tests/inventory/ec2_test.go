@@ -0,0 +1,54 @@
+package inventory_test
+
+import (
+	"testing"
+
+	"vssh/internal/inventory"
+)
+
+const describeInstancesOutput = `{
+  "Reservations": [
+    {
+      "Instances": [
+        {
+          "InstanceId": "i-0abc",
+          "PrivateIpAddress": "10.0.0.5",
+          "PublicIpAddress": "203.0.113.5",
+          "Tags": [{"Key": "Name", "Value": "web-1"}, {"Key": "Environment", "Value": "staging"}]
+        },
+        {
+          "InstanceId": "i-0def",
+          "PrivateIpAddress": "10.0.0.6"
+        }
+      ]
+    }
+  ]
+}`
+
+func TestParseEC2Instances(t *testing.T) {
+	hosts, err := inventory.ParseEC2Instances([]byte(describeInstancesOutput), "private")
+	if err != nil {
+		t.Fatalf("ParseEC2Instances() error = %v", err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("ParseEC2Instances() returned %d hosts, want 2", len(hosts))
+	}
+
+	if hosts[0].Name != "web-1" || hosts[0].Address != "10.0.0.5" || hosts[0].Tags["Environment"] != "staging" {
+		t.Errorf("hosts[0] = %+v", hosts[0])
+	}
+	if hosts[1].Name != "i-0def" {
+		t.Errorf("hosts[1].Name = %s, want instance ID when there is no Name tag", hosts[1].Name)
+	}
+}
+
+func TestParseEC2Instances_Public(t *testing.T) {
+	hosts, err := inventory.ParseEC2Instances([]byte(describeInstancesOutput), "public")
+	if err != nil {
+		t.Fatalf("ParseEC2Instances() error = %v", err)
+	}
+	// The second instance has no public address and is skipped
+	if len(hosts) != 1 || hosts[0].Address != "203.0.113.5" {
+		t.Errorf("ParseEC2Instances() = %+v, want only the public address", hosts)
+	}
+}
@@ -0,0 +1,76 @@
+package inventory_test
+
+import (
+	"testing"
+
+	"vssh/internal/inventory"
+)
+
+func TestDecodeHosts(t *testing.T) {
+	data := map[string]interface{}{
+		"web01": map[string]interface{}{
+			"address": "10.0.0.11",
+			"user":    "deploy",
+			"role":    "web-ssh",
+			"tags":    map[string]interface{}{"env": "prod", "tier": "web"},
+		},
+		"db01": `{"address": "10.0.1.5", "tags": {"env": "staging"}}`,
+		"bare": map[string]interface{}{},
+	}
+
+	hosts, err := inventory.DecodeHosts(data)
+	if err != nil {
+		t.Fatalf("DecodeHosts() error = %v", err)
+	}
+	if len(hosts) != 3 {
+		t.Fatalf("DecodeHosts() returned %d hosts, want 3", len(hosts))
+	}
+
+	inv := &inventory.Inventory{Hosts: hosts}
+
+	web := inv.Find("WEB01")
+	if web == nil || web.Address != "10.0.0.11" || web.User != "deploy" || web.Role != "web-ssh" {
+		t.Errorf("Find(WEB01) = %+v", web)
+	}
+	if db := inv.Find("db01"); db == nil || db.Address != "10.0.1.5" {
+		t.Errorf("Find(db01) = %+v, want address decoded from JSON string", db)
+	}
+	if bare := inv.Find("bare"); bare == nil || bare.Address != "bare" {
+		t.Errorf("Find(bare) = %+v, want address defaulting to the name", bare)
+	}
+
+	configs := inv.HostConfigs()
+	if len(configs) != 1 || configs[0].Pattern != "10.0.0.11" || configs[0].Role != "web-ssh" {
+		t.Errorf("HostConfigs() = %+v", configs)
+	}
+}
+
+func TestDecodeHosts_Invalid(t *testing.T) {
+	if _, err := inventory.DecodeHosts(map[string]interface{}{"web01": "not json"}); err == nil {
+		t.Error("DecodeHosts() expected error for invalid entry")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	inv := &inventory.Inventory{Hosts: []inventory.Host{
+		{Name: "web01", Tags: map[string]string{"Environment": "prod", "Role": "web"}},
+		{Name: "web02", Tags: map[string]string{"Environment": "staging", "Role": "web"}},
+		{Name: "db01", Tags: map[string]string{"Environment": "prod", "Role": "db"}},
+	}}
+
+	selector, err := inventory.ParseTagSelector("environment=prod, Role=web")
+	if err != nil {
+		t.Fatalf("ParseTagSelector() error = %v", err)
+	}
+
+	hosts := inv.Select(selector)
+	if len(hosts) != 1 || hosts[0].Name != "web01" {
+		t.Errorf("Select() = %+v, want only web01", hosts)
+	}
+
+	for _, invalid := range []string{"", "env", "=prod"} {
+		if _, err := inventory.ParseTagSelector(invalid); err == nil {
+			t.Errorf("ParseTagSelector(%q) expected error", invalid)
+		}
+	}
+}
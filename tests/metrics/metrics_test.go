@@ -0,0 +1,129 @@
+package metrics_test
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"vssh/internal/metrics"
+)
+
+func TestLatency_Observe(t *testing.T) {
+	var l metrics.Latency
+	l.Observe(20*time.Millisecond, nil)
+	l.Observe(300*time.Millisecond, errors.New("permission denied"))
+	l.Observe(time.Minute, nil)
+
+	if l.Count != 3 || l.Failures != 1 {
+		t.Errorf("Expected 3 requests with 1 failure, got %d with %d", l.Count, l.Failures)
+	}
+	if l.Max != 60 {
+		t.Errorf("Expected a maximum of 60s, got %v", l.Max)
+	}
+	if got := l.Average().Round(10 * time.Millisecond); got != 20110*time.Millisecond {
+		t.Errorf("Expected an average of 20.11s, got %s", got)
+	}
+
+	// 20ms is in the first bucket, 300ms in the 0.5s one and a minute in none
+	expected := []uint64{1, 0, 0, 1, 0, 0, 0, 0}
+	for i := range expected {
+		if l.Buckets[i] != expected[i] {
+			t.Errorf("Expected buckets %v, got %v", expected, l.Buckets)
+			break
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := metrics.NewStore(filepath.Join(t.TempDir(), "state", "metrics.json"))
+
+	m, err := store.Load()
+	if err != nil {
+		t.Fatalf("Expected no error loading missing metrics, got %v", err)
+	}
+	if m.Connections.Count != 0 || m.Since.IsZero() {
+		t.Errorf("Expected empty metrics starting now, got %+v", m)
+	}
+
+	for _, err := range []error{nil, nil, errors.New("connection refused")} {
+		if err := store.Update(func(m *metrics.Metrics) { m.Connections.Add(err) }); err != nil {
+			t.Fatalf("Expected no error updating metrics, got %v", err)
+		}
+	}
+
+	m, err = store.Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if m.Connections.Count != 3 || m.Connections.Failures != 1 {
+		t.Errorf("Expected 3 connections with 1 failure, got %+v", m.Connections)
+	}
+
+	if err := store.Reset(); err != nil {
+		t.Fatalf("Expected no error resetting metrics, got %v", err)
+	}
+	if m, _ := store.Load(); m.Connections.Count != 0 {
+		t.Errorf("Expected no connections after a reset, got %d", m.Connections.Count)
+	}
+}
+
+func TestStore_ConcurrentUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	// Each store stands for a vssh process counting its connection
+	const connections = 20
+	var wg sync.WaitGroup
+	for range connections {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := metrics.NewStore(path).Update(func(m *metrics.Metrics) { m.Connections.Add(nil) }); err != nil {
+				t.Errorf("Expected no error updating metrics, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	m, err := metrics.NewStore(path).Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if m.Connections.Count != connections {
+		t.Errorf("Expected %d connections, got %d", connections, m.Connections.Count)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	m := &metrics.Metrics{Since: time.Unix(1700000000, 0)}
+	m.Signing.Observe(80*time.Millisecond, nil)
+	m.Signing.Observe(2*time.Second, errors.New("timeout"))
+	m.Connections.Add(nil)
+
+	var b bytes.Buffer
+	if err := metrics.WritePrometheus(&b, m); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	output := b.String()
+
+	for _, line := range []string{
+		"# TYPE vssh_signing_duration_seconds histogram",
+		`vssh_signing_duration_seconds_bucket{le="0.05"} 0`,
+		`vssh_signing_duration_seconds_bucket{le="0.1"} 1`,
+		`vssh_signing_duration_seconds_bucket{le="2.5"} 2`,
+		`vssh_signing_duration_seconds_bucket{le="+Inf"} 2`,
+		"vssh_signing_duration_seconds_sum 2.08",
+		"vssh_signing_duration_seconds_count 2",
+		"vssh_signing_failures_total 1",
+		"vssh_auth_duration_seconds_count 0",
+		"vssh_connections_total 1",
+		"vssh_metrics_since_seconds 1700000000",
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package recording_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"vssh/internal/recording"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recordings", "session.cast")
+	recorder, err := recording.Create(path, 120, 40, "alice@db01")
+	if err != nil {
+		t.Fatalf("Failed to create recording: %v", err)
+	}
+
+	recorder.Write([]byte("$ echo caf"))
+	// "é" split across two writes is recorded once complete
+	recorder.Write([]byte{0xc3})
+	recorder.Write([]byte{0xa9, '\r', '\n'})
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Failed to close recording: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open recording: %v", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+
+	scanner.Scan()
+	var header struct {
+		Version int    `json:"version"`
+		Width   int    `json:"width"`
+		Height  int    `json:"height"`
+		Title   string `json:"title"`
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatalf("Expected a JSON header, got %q: %v", scanner.Text(), err)
+	}
+	if header.Version != 2 || header.Width != 120 || header.Height != 40 || header.Title != "alice@db01" {
+		t.Errorf("Expected a version 2 header for 120x40 titled alice@db01, got %+v", header)
+	}
+
+	var output []string
+	for scanner.Scan() {
+		var event []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Expected a JSON event, got %q: %v", scanner.Text(), err)
+		}
+		if len(event) != 3 || event[1] != "o" {
+			t.Fatalf("Expected an output event, got %v", event)
+		}
+		output = append(output, event[2].(string))
+	}
+	expected := []string{"$ echo caf", "é\r\n"}
+	if len(output) != len(expected) || output[0] != expected[0] || output[1] != expected[1] {
+		t.Errorf("Expected output events %q, got %q", expected, output)
+	}
+
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		t.Errorf("Expected the recording to be private, got mode %v", info.Mode().Perm())
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	started := time.Date(2025, 1, 13, 10, 30, 5, 0, time.Local)
+	path := recording.DefaultPath("/recordings", "alice", "db01", started)
+	if expected := filepath.Join("/recordings", "20250113-103005-alice@db01.cast"); path != expected {
+		t.Errorf("Expected %s, got %s", expected, path)
+	}
+}
@@ -0,0 +1,215 @@
+//go:build !windows
+
+package ssh_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startTestAgent serves an in-memory keyring on SSH_AUTH_SOCK for the test
+func startTestAgent(t *testing.T) agent.Agent {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	keyring := agent.NewKeyring()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	return keyring
+}
+
+func TestConnectAgent(t *testing.T) {
+	keyring := startTestAgent(t)
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key, Comment: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, closeAgent, err := ssh.ConnectAgent()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer closeAgent()
+
+	keys, err := client.List()
+	if err != nil {
+		t.Fatalf("Expected no error listing keys, got %v", err)
+	}
+	if len(keys) != 1 || keys[0].Comment != "test" {
+		t.Errorf("Expected the key in the agent, got %v", keys)
+	}
+}
+
+func TestConnectAgent_NotRunning(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if _, _, err := ssh.ConnectAgent(); err == nil {
+		t.Errorf("Expected an error without SSH_AUTH_SOCK")
+	}
+}
+
+func TestAddCertificateToAgent(t *testing.T) {
+	keyring := startTestAgent(t)
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := gossh.NewSignerFromKey(caKey)
+	keyPath, certPath := writeSignedKey(t, t.TempDir(), ca, "alice")
+
+	// Adding the same certificate twice keeps a single identity
+	for i := 0; i < 2; i++ {
+		if err := ssh.AddCertificateToAgent(keyPath, certPath); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	keys, _ := keyring.List()
+	if len(keys) != 1 {
+		t.Fatalf("Expected 1 identity in the agent, got %d", len(keys))
+	}
+	if !strings.HasPrefix(keys[0].Type(), "ssh-ed25519-cert") || !strings.HasPrefix(keys[0].Comment, "vssh") {
+		t.Errorf("Expected the vssh certificate in the agent, got %s %q", keys[0].Type(), keys[0].Comment)
+	}
+}
+
+func TestExecute_NativeAgent(t *testing.T) {
+	startTestAgent(t)
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := gossh.NewSignerFromKey(caKey)
+	address := startTestServer(t, ca.PublicKey())
+	host, port, _ := net.SplitHostPort(address)
+
+	keyPath, certPath := writeSignedKey(t, t.TempDir(), ca, "alice")
+	if err := ssh.AddCertificateToAgent(keyPath, certPath); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cfg := &types.Config{SSH: types.SSHConfig{Native: true, StrictHostKeyChecking: "no"}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	target := &ssh.SSHTarget{Username: "alice", Hostname: host, Port: port}
+
+	// Without a certificate file the built-in client authenticates through the agent
+	var stdout bytes.Buffer
+	err := ssh.NewClient(cfg, logger).Execute(target, "", &ssh.SSHOptions{}, []string{"true"}, nil, &stdout, io.Discard)
+	if ssh.ExitCode(err) != 3 || stdout.String() != "ran: true\n" {
+		t.Errorf("Expected the command to run, got %v with output %q", err, stdout.String())
+	}
+}
+
+func TestEnsureAgentCertificate_Reuse(t *testing.T) {
+	keyring := startTestAgent(t)
+
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := gossh.NewSignerFromKey(caKey)
+	userPublic, userPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	publicKey, _ := gossh.NewPublicKey(userPublic)
+	cert := &gossh.Certificate{
+		Key:         publicKey,
+		CertType:    gossh.UserCert,
+		ValidBefore: gossh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{SSH: types.SSHConfig{KeyDirectory: t.TempDir(), SigningEngine: "ssh-client-signer", AgentOnly: true}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	signer := ssh.NewSigner(nil, cfg, logger)
+	target := &ssh.SSHTarget{Username: "alice", Hostname: "db01"}
+
+	plan, err := signer.PlanCertificate(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := "vssh " + strings.TrimSuffix(filepath.Base(plan.Path), ".pub")
+	if err := keyring.Add(agent.AddedKey{PrivateKey: userPrivate, Certificate: cert, Comment: comment}); err != nil {
+		t.Fatal(err)
+	}
+
+	// No Vault client is needed since the certificate in the agent is reused
+	if err := signer.EnsureAgentCertificate(target); err != nil {
+		t.Fatalf("Expected the certificate in the agent to be reused, got %v", err)
+	}
+
+	keys, _ := keyring.List()
+	if len(keys) != 1 {
+		t.Errorf("Expected 1 identity in the agent, got %d", len(keys))
+	}
+}
+
+func TestPruneAgent(t *testing.T) {
+	keyring := agent.NewKeyring()
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := gossh.NewSignerFromKey(caKey)
+
+	addCert := func(comment string, validBefore time.Time) {
+		t.Helper()
+		userPublic, userPrivate, _ := ed25519.GenerateKey(rand.Reader)
+		publicKey, _ := gossh.NewPublicKey(userPublic)
+		cert := &gossh.Certificate{Key: publicKey, CertType: gossh.UserCert, ValidBefore: uint64(validBefore.Unix())}
+		if err := cert.SignCert(rand.Reader, ca); err != nil {
+			t.Fatal(err)
+		}
+		if err := keyring.Add(agent.AddedKey{PrivateKey: userPrivate, Certificate: cert, Comment: comment}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	addCert("vssh vault_signed_alice", now.Add(time.Hour))
+	addCert("vssh vault_signed_alice", now.Add(4*time.Hour))
+	addCert("vssh vault_signed_bob", now.Add(-time.Minute))
+	addCert("vssh vault_signed_carol", now.Add(time.Hour))
+	addCert("someone else", now.Add(-time.Minute))
+
+	removed, err := ssh.PruneAgent(keyring)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := []string{"vssh vault_signed_alice", "vssh vault_signed_bob"}; !reflect.DeepEqual(removed, expected) {
+		t.Errorf("Expected removed identities %v, got %v", expected, removed)
+	}
+
+	keys, _ := keyring.List()
+	if len(keys) != 3 {
+		t.Fatalf("Expected 3 identities left, got %d", len(keys))
+	}
+	for _, key := range keys {
+		if key.Comment != "vssh vault_signed_alice" {
+			continue
+		}
+		publicKey, _ := gossh.ParsePublicKey(key.Blob)
+		if cert := publicKey.(*gossh.Certificate); cert.ValidBefore != uint64(now.Add(4*time.Hour).Unix()) {
+			t.Errorf("Expected the newest certificate for alice to be kept")
+		}
+	}
+}
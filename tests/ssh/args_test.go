@@ -0,0 +1,139 @@
+package ssh_test
+
+import (
+	"reflect"
+	"testing"
+
+	"vssh/internal/ssh"
+)
+
+func TestParseSSHArgs(t *testing.T) {
+	options, args, err := ssh.ParseSSHArgs([]string{"-p", "2222", "-4", "-i", "~/.ssh/id_ed25519", "alice@db01", "ls", "-la"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if options.Port != "2222" || !options.IPv4 || options.IdentityFile != "~/.ssh/id_ed25519" {
+		t.Errorf("Unexpected options: %+v", options)
+	}
+	if expected := []string{"alice@db01", "ls", "-la"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected arguments %v, got %v", expected, args)
+	}
+}
+
+func TestParseSSHArgs_OptionsAfterDestination(t *testing.T) {
+	options, args, err := ssh.ParseSSHArgs([]string{"db01", "-6", "-lbob", "-o", "ServerAliveInterval=30", "uptime"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !options.IPv6 || options.LoginName != "bob" {
+		t.Errorf("Unexpected options: %+v", options)
+	}
+	if expected := []string{"-o", "ServerAliveInterval=30"}; !reflect.DeepEqual(options.ExtraArgs, expected) {
+		t.Errorf("Expected extra arguments %v, got %v", expected, options.ExtraArgs)
+	}
+	if expected := []string{"db01", "uptime"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected arguments %v, got %v", expected, args)
+	}
+}
+
+func TestParseSSHArgs_GroupedFlags(t *testing.T) {
+	options, args, err := ssh.ParseSSHArgs([]string{"-vvAp2200", "-L", "8080:localhost:80", "web1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if options.Verbose != 2 || options.Port != "2200" {
+		t.Errorf("Unexpected options: %+v", options)
+	}
+	if expected := []string{"-L", "8080:localhost:80"}; options.ForwardAgent == nil || !reflect.DeepEqual(options.ExtraArgs, expected) {
+		t.Errorf("Expected extra arguments %v, got %v", expected, options.ExtraArgs)
+	}
+	if expected := []string{"web1"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected arguments %v, got %v", expected, args)
+	}
+}
+
+func TestParseSSHArgs_DoubleDash(t *testing.T) {
+	options, args, err := ssh.ParseSSHArgs([]string{"-4", "--", "db01", "-v"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if options.Verbose != 0 {
+		t.Errorf("Expected -v after -- to be part of the command, got %+v", options)
+	}
+	if expected := []string{"db01", "-v"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected arguments %v, got %v", expected, args)
+	}
+}
+
+func TestParseSSHArgs_Errors(t *testing.T) {
+	testCases := [][]string{
+		{"-p"},
+		{"-p", "notaport", "db01"},
+		{"-Z", "db01"},
+		{"--port", "22", "db01"},
+	}
+
+	for _, args := range testCases {
+		if _, _, err := ssh.ParseSSHArgs(args); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
+
+func TestParseSSHArgs_ProxyJump(t *testing.T) {
+	options, _, err := ssh.ParseSSHArgs([]string{"-J", "jump@bastion:2222", "db01"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if options.ProxyJump != "jump@bastion:2222" || len(options.ExtraArgs) != 0 {
+		t.Errorf("Expected -J to set ProxyJump only, got %+v", options)
+	}
+}
+
+func TestParseSSHArgs_ForwardAgent(t *testing.T) {
+	options, _, err := ssh.ParseSSHArgs([]string{"-A", "db01"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if options.ForwardAgent == nil || !*options.ForwardAgent {
+		t.Errorf("Expected -A to enable agent forwarding, got %v", options.ForwardAgent)
+	}
+
+	options, _, _ = ssh.ParseSSHArgs([]string{"-A", "-a", "db01"})
+	if options.ForwardAgent == nil || *options.ForwardAgent {
+		t.Errorf("Expected the last of -A/-a to win, got %v", options.ForwardAgent)
+	}
+}
+
+func TestParseSSHArgs_TTY(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"db01"}, 0},
+		{[]string{"-t", "db01", "sudo", "-i"}, 1},
+		{[]string{"-tt", "db01"}, 2},
+		{[]string{"-T", "db01"}, -1},
+		{[]string{"-T", "-t", "db01"}, 1},
+	}
+
+	for _, tc := range testCases {
+		options, _, err := ssh.ParseSSHArgs(tc.args)
+		if err != nil {
+			t.Fatalf("Expected no error for %v, got %v", tc.args, err)
+		}
+		if options.TTY != tc.expected {
+			t.Errorf("Expected TTY %d for %v, got %d", tc.expected, tc.args, options.TTY)
+		}
+	}
+}
+
+func TestParseSSHArgs_CompressionAndQuiet(t *testing.T) {
+	options, _, err := ssh.ParseSSHArgs([]string{"-Cq", "db01"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !options.Compression || !options.Quiet || len(options.ExtraArgs) != 0 {
+		t.Errorf("Expected -C and -q to set Compression and Quiet, got %+v", options)
+	}
+}
@@ -0,0 +1,340 @@
+package ssh_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// commandArgs returns the ssh arguments a client builds for options
+func commandArgs(cfg *types.Config, options *ssh.SSHOptions) []string {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	target := &ssh.SSHTarget{Username: "alice", Hostname: "db01"}
+	return ssh.NewClient(cfg, logger).Command(target, "/certs/alice.pub", options, nil).Args[1:]
+}
+
+func TestCommand_ForwardAgent(t *testing.T) {
+	forward, noForward := true, false
+
+	testCases := []struct {
+		configured bool
+		option     *bool
+		expected   string
+	}{
+		{false, nil, ""},
+		{true, nil, "-A"},
+		{false, &forward, "-A"},
+		{true, &noForward, "-a"},
+	}
+
+	for _, tc := range testCases {
+		cfg := &types.Config{SSH: types.SSHConfig{ForwardAgent: tc.configured}}
+		args := commandArgs(cfg, &ssh.SSHOptions{ForwardAgent: tc.option})
+
+		hasA, hasNoA := slices.Contains(args, "-A"), slices.Contains(args, "-a")
+		switch tc.expected {
+		case "":
+			if hasA || hasNoA {
+				t.Errorf("Expected no agent flag with forward_agent=%v, got %v", tc.configured, args)
+			}
+		case "-A":
+			if !hasA || hasNoA {
+				t.Errorf("Expected -A with forward_agent=%v, got %v", tc.configured, args)
+			}
+		case "-a":
+			if !hasNoA || hasA {
+				t.Errorf("Expected -a with forward_agent=%v, got %v", tc.configured, args)
+			}
+		}
+	}
+}
+
+func TestCommand_ForwardX11(t *testing.T) {
+	testCases := []struct {
+		sshConfig types.SSHConfig
+		option    string
+		expected  string
+	}{
+		{types.SSHConfig{}, "", ""},
+		{types.SSHConfig{ForwardX11: true}, "", "-X"},
+		{types.SSHConfig{ForwardX11: true, ForwardX11Trusted: true}, "", "-Y"},
+		{types.SSHConfig{ForwardX11: true}, "-x", "-x"},
+		{types.SSHConfig{}, "-Y", "-Y"},
+	}
+
+	for _, tc := range testCases {
+		args := commandArgs(&types.Config{SSH: tc.sshConfig}, &ssh.SSHOptions{ForwardX11: tc.option})
+
+		var found []string
+		for _, flag := range []string{"-X", "-Y", "-x"} {
+			if slices.Contains(args, flag) {
+				found = append(found, flag)
+			}
+		}
+		if tc.expected == "" && len(found) != 0 || tc.expected != "" && !slices.Equal(found, []string{tc.expected}) {
+			t.Errorf("Expected X11 flag %q for %+v and option %q, got %v", tc.expected, tc.sshConfig, tc.option, found)
+		}
+	}
+}
+
+func TestExecute_BackgroundDoesNotWaitForOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of ssh")
+	}
+
+	// A stand-in for ssh -f: exit right away, leaving a process holding stdout open
+	dir := t.TempDir()
+	script := "#!/bin/sh\nsleep 10 &\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake ssh: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := ssh.NewClient(&types.Config{}, logger)
+	target := &ssh.SSHTarget{Username: "alice", Hostname: "db01"}
+
+	var output bytes.Buffer
+	started := time.Now()
+	err := client.Execute(target, "", &ssh.SSHOptions{Background: true, NoCommand: true}, nil, nil, &output, &output)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Expected Execute to return once ssh went to the background, took %v", elapsed)
+	}
+}
+
+func TestCommand_Multiplexing(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("multiplexing is not supported on Windows")
+	}
+
+	controlPath := filepath.Join(t.TempDir(), "mux", "%r@%h:%p")
+	cfg := &types.Config{SSH: types.SSHConfig{Multiplexing: types.MultiplexingConfig{
+		Enabled:     true,
+		ControlPath: controlPath,
+		Persist:     "10m",
+	}}}
+
+	args := commandArgs(cfg, &ssh.SSHOptions{})
+	for _, expected := range []string{"ControlMaster=auto", "ControlPath=" + controlPath, "ControlPersist=10m"} {
+		if !slices.Contains(args, expected) {
+			t.Errorf("Expected %s in %v", expected, args)
+		}
+	}
+	if _, err := os.Stat(filepath.Dir(controlPath)); err != nil {
+		t.Errorf("Expected the control socket directory to be created: %v", err)
+	}
+
+	// ssh uses the first value given, so -o ControlMaster=no turns
+	// multiplexing off for one connection
+	args = commandArgs(cfg, &ssh.SSHOptions{ExtraArgs: []string{"-o", "ControlMaster=no"}})
+	user, configured := slices.Index(args, "ControlMaster=no"), slices.Index(args, "ControlMaster=auto")
+	if user < 0 || configured < 0 || user > configured {
+		t.Errorf("Expected the user's ControlMaster before the configured one, got %v", args)
+	}
+
+	cfg.SSH.Multiplexing.Enabled = false
+	if args := commandArgs(cfg, &ssh.SSHOptions{}); slices.Contains(args, "ControlMaster=auto") {
+		t.Errorf("Expected no multiplexing options when disabled, got %v", args)
+	}
+}
+
+func TestCommand_SecurityKeyProvider(t *testing.T) {
+	args := commandArgs(&types.Config{}, &ssh.SSHOptions{IdentityFile: "/keys/id_ed25519_sk"})
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "SecurityKeyProvider=") {
+			t.Errorf("Expected no SecurityKeyProvider without one configured, got %v", args)
+		}
+	}
+
+	cfg := &types.Config{SSH: types.SSHConfig{SecurityKeyProvider: "/usr/lib/libsk-libfido2.so"}}
+	args = commandArgs(cfg, &ssh.SSHOptions{IdentityFile: "/keys/id_ed25519_sk"})
+	if !slices.Contains(args, "SecurityKeyProvider=/usr/lib/libsk-libfido2.so") || !slices.Contains(args, "/keys/id_ed25519_sk") {
+		t.Errorf("Expected the identity and security key provider, got %v", args)
+	}
+}
+
+func TestCommand_HostKeyChecking(t *testing.T) {
+	cfg := &types.Config{
+		SSH: types.SSHConfig{StrictHostKeyChecking: "yes"},
+		Hosts: []types.HostConfig{{
+			Pattern: "*.compute.internal",
+			HostSettings: types.HostSettings{
+				StrictHostKeyChecking: "accept-new",
+				KnownHostsFile:        "/tmp/known_hosts_ephemeral",
+			},
+		}},
+	}
+
+	args := commandArgs(cfg, &ssh.SSHOptions{})
+	if !slices.Contains(args, "StrictHostKeyChecking=yes") || slices.Contains(args, "UserKnownHostsFile=/tmp/known_hosts_ephemeral") {
+		t.Errorf("Expected the global setting for db01, got %v", args)
+	}
+
+	// ssh uses the first value given, so the user's options must come first
+	args = commandArgs(cfg, &ssh.SSHOptions{ExtraArgs: []string{"-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null"}})
+	user, configured := slices.Index(args, "StrictHostKeyChecking=no"), slices.Index(args, "StrictHostKeyChecking=yes")
+	if user < 0 || configured < 0 || user > configured {
+		t.Errorf("Expected the user's StrictHostKeyChecking before the configured one, got %v", args)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	target := &ssh.SSHTarget{Username: "ec2-user", Hostname: "ip-10-0-0-1.compute.internal"}
+	args = ssh.NewClient(cfg, logger).Command(target, "", &ssh.SSHOptions{}, nil).Args
+	if !slices.Contains(args, "StrictHostKeyChecking=accept-new") || !slices.Contains(args, "UserKnownHostsFile=/tmp/known_hosts_ephemeral") {
+		t.Errorf("Expected the host settings for %s, got %v", target.Hostname, args)
+	}
+}
+
+func TestExecute_ExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of ssh")
+	}
+
+	// A stand-in for ssh that exits with the status of the remote command
+	dir := t.TempDir()
+	script := "#!/bin/sh\nexit 3\n"
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake ssh: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := ssh.NewClient(&types.Config{}, logger)
+	target := &ssh.SSHTarget{Username: "alice", Hostname: "db01"}
+
+	err := client.Execute(target, "", &ssh.SSHOptions{}, []string{"false"}, nil, io.Discard, io.Discard)
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Errorf("Expected exit code 3, got %v", err)
+	}
+}
+
+func TestRunTool_LaunchError(t *testing.T) {
+	// No rsync to start
+	t.Setenv("PATH", t.TempDir())
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := ssh.NewClient(&types.Config{}, logger)
+
+	err := client.RunTool("rsync", []string{"a", "db01:b"})
+	var launchErr *ssh.LaunchError
+	if !errors.As(err, &launchErr) || launchErr.Program != "rsync" {
+		t.Errorf("Expected a launch error for rsync, got %v", err)
+	}
+	if code := ssh.ExitCode(err); code != 255 {
+		t.Errorf("Expected exit code 255, got %d", code)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected int
+	}{
+		{nil, 0},
+		{&ssh.ExitError{Code: 2}, 2},
+		{fmt.Errorf("db01: %w", &ssh.ExitError{Code: 130}), 130},
+		{errors.New("failed to sign certificate"), 255},
+	}
+
+	for _, tc := range testCases {
+		if code := ssh.ExitCode(tc.err); code != tc.expected {
+			t.Errorf("Expected exit code %d for %v, got %d", tc.expected, tc.err, code)
+		}
+	}
+}
+
+func TestRemoteCommand(t *testing.T) {
+	testCases := []struct {
+		command  []string
+		expected string
+	}{
+		{[]string{"grep 'foo bar' /var/log/syslog | tail"}, "grep 'foo bar' /var/log/syslog | tail"},
+		{[]string{"ls", "-la"}, "ls -la"},
+		{[]string{"grep", "foo bar", "/var/log/syslog"}, "grep 'foo bar' /var/log/syslog"},
+		{[]string{"echo", "it's", "*.log"}, `echo 'it'\''s' '*.log'`},
+		{[]string{"touch", ""}, "touch ''"},
+	}
+
+	for _, tc := range testCases {
+		if got := ssh.RemoteCommand(tc.command); got != tc.expected {
+			t.Errorf("Expected %q for %q, got %q", tc.expected, tc.command, got)
+		}
+	}
+}
+
+func TestCommand_RemoteCommandAfterDestination(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	target := &ssh.SSHTarget{Username: "alice", Hostname: "db01"}
+	args := ssh.NewClient(&types.Config{}, logger).Command(target, "", &ssh.SSHOptions{}, []string{"-n", "foo bar"}).Args
+
+	expected := []string{"--", "alice@db01", "-n 'foo bar'"}
+	if tail := args[len(args)-3:]; !slices.Equal(tail, expected) {
+		t.Errorf("Expected the arguments to end with %q, got %q", expected, tail)
+	}
+}
+
+func TestConnectionArgs(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := &types.Config{SSH: types.SSHConfig{StrictHostKeyChecking: "accept-new"}}
+	target := &ssh.SSHTarget{Username: "alice", Hostname: "db01", Port: "2222"}
+	args := ssh.NewClient(cfg, logger).ConnectionArgs(target, "/certs/alice.pub", &ssh.SSHOptions{IdentityFile: "/keys/id_rsa"})
+
+	for _, expected := range []string{"CertificateFile=/certs/alice.pub", "IdentityFile=/keys/id_rsa", "StrictHostKeyChecking=accept-new", "PreferredAuthentications=publickey"} {
+		if !slices.Contains(args, expected) {
+			t.Errorf("Expected %s in %v", expected, args)
+		}
+	}
+	if slices.Contains(args, "-p") || slices.Contains(args, "2222") {
+		t.Errorf("Expected no port in %v", args)
+	}
+}
+
+func TestCommand_OneTimePassword(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	target := &ssh.SSHTarget{Username: "alice", Hostname: "legacy01"}
+	cmd := ssh.NewClient(&types.Config{}, logger).Command(target, "", &ssh.SSHOptions{Password: "f2e1b4c3"}, nil)
+	args := strings.Join(cmd.Args, " ")
+
+	for _, expected := range []string{"PreferredAuthentications=keyboard-interactive,password", "PubkeyAuthentication=no", "NumberOfPasswordPrompts=1"} {
+		if !strings.Contains(args, expected) {
+			t.Errorf("Expected %s in %s", expected, args)
+		}
+	}
+	if strings.Contains(args, "f2e1b4c3") {
+		t.Errorf("Expected the password not to be in the arguments, got %s", args)
+	}
+	for _, expected := range []string{"SSH_ASKPASS_REQUIRE=force", "VSSH_ASKPASS_PASSWORD=f2e1b4c3"} {
+		if !slices.Contains(cmd.Env, expected) {
+			t.Errorf("Expected %s in the environment", expected)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package ssh_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestGenerateSSHConfig(t *testing.T) {
+	keyDir := t.TempDir()
+	cfg := &types.Config{
+		SSH: types.SSHConfig{KeyDirectory: keyDir, SigningEngine: "ssh-client-signer"},
+		Hosts: []types.HostConfig{
+			{Pattern: "bastion", HostSettings: types.HostSettings{User: "jump"}},
+			{
+				Pattern:      "*.prod.example.com,!web9.prod.example.com",
+				HostSettings: types.HostSettings{User: "admin", Role: "prod", ProxyJump: "bastion", StrictHostKeyChecking: "yes"},
+			},
+		},
+		Groups: map[string]types.GroupConfig{
+			"db": {Hosts: []string{"db01", "db02"}},
+		},
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	signer := ssh.NewSigner(nil, cfg, logger)
+	generated, err := ssh.GenerateSSHConfig(cfg, signer, "alice")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	keyPath := filepath.Join(keyDir, "id_rsa")
+	jumpCert := signer.GetCertificatePath("jump", "jump", "ssh-client-signer", keyPath)
+	expected := []string{
+		"Host bastion\n    User jump\n    IdentityFile " + keyPath + "\n    CertificateFile " + jumpCert + "\n",
+		"Host *.prod.example.com !web9.prod.example.com\n    User admin\n",
+		"    CertificateFile " + signer.GetCertificatePath("admin", "prod", "ssh-client-signer", keyPath) + "\n",
+		"    ProxyCommand ssh -i " + keyPath + " -o CertificateFile=" + jumpCert + " -W %h:%p jump@bastion\n",
+		"    StrictHostKeyChecking yes\n",
+		"Host db01 db02\n    User alice\n",
+	}
+	for _, block := range expected {
+		if !strings.Contains(generated, block) {
+			t.Errorf("Expected generated config to contain %q, got:\n%s", block, generated)
+		}
+	}
+}
+
+func TestWriteSSHConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vssh.conf")
+	sshConfigPath := filepath.Join(dir, "config")
+	if err := os.WriteFile(sshConfigPath, []byte("Host foo\n    User bar\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := ssh.WriteSSHConfig(path, sshConfigPath, "Host db01\n")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !added {
+		t.Errorf("Expected the Include to be added")
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "Host db01\n" {
+		t.Errorf("Expected the generated config to be written, got %q", data)
+	}
+
+	expected := "Include " + path + "\n\nHost foo\n    User bar\n"
+	data, _ = os.ReadFile(sshConfigPath)
+	if string(data) != expected {
+		t.Errorf("Expected ssh config %q, got %q", expected, data)
+	}
+
+	// Writing again keeps a single Include
+	added, err = ssh.WriteSSHConfig(path, sshConfigPath, "Host db02\n")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data, _ = os.ReadFile(sshConfigPath)
+	if added || string(data) != expected {
+		t.Errorf("Expected the ssh config to be unchanged, got %q", data)
+	}
+}
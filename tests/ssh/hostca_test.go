@@ -0,0 +1,76 @@
+package ssh_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestHostCA_Verify(t *testing.T) {
+	newSigner := func() gossh.Signer {
+		_, private, _ := ed25519.GenerateKey(rand.Reader)
+		signer, _ := gossh.NewSignerFromKey(private)
+		return signer
+	}
+	signHostKey := func(ca gossh.Signer, hostKey gossh.PublicKey, principal string) *gossh.Certificate {
+		cert := &gossh.Certificate{
+			Key:             hostKey,
+			CertType:        gossh.HostCert,
+			ValidPrincipals: []string{principal},
+			ValidAfter:      uint64(time.Now().Add(-time.Minute).Unix()),
+			ValidBefore:     uint64(time.Now().Add(time.Hour).Unix()),
+		}
+		if err := cert.SignCert(rand.Reader, ca); err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	vaultCA, otherCA, hostKey := newSigner(), newSigner(), newSigner().PublicKey()
+	caKey := string(gossh.MarshalAuthorizedKey(vaultCA.PublicKey()))
+	hostCA, err := ssh.NewHostCA(&types.HostCAConfig{Domains: []string{"*.example.com"}, Verify: "enforce"}, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hostCA.Enforce {
+		t.Errorf("Expected verify: enforce to refuse mismatches")
+	}
+
+	if !hostCA.Covers("web01.example.com") || hostCA.Covers("web01.example.org") {
+		t.Errorf("Expected the CA to cover only *.example.com")
+	}
+
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 22}
+	if err := hostCA.Verify("web01.example.com:22", remote, signHostKey(vaultCA, hostKey, "web01.example.com")); err != nil {
+		t.Errorf("Expected a certificate signed by the CA to verify, got %v", err)
+	}
+	if err := hostCA.Verify("web01.example.com", remote, signHostKey(vaultCA, hostKey, "web01.example.com")); err != nil {
+		t.Errorf("Expected a hostname without a port to verify, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  gossh.PublicKey
+	}{
+		{"other CA", signHostKey(otherCA, hostKey, "web01.example.com")},
+		{"other host", signHostKey(vaultCA, hostKey, "web02.example.com")},
+		{"plain key", hostKey},
+	}
+	for _, tt := range tests {
+		if err := hostCA.Verify("web01.example.com:22", remote, tt.key); err == nil {
+			t.Errorf("%s: Expected verification to fail", tt.name)
+		}
+	}
+
+	if _, err := ssh.NewHostCA(&types.HostCAConfig{}, strings.Repeat("x", 10)); err == nil {
+		t.Errorf("Expected an invalid CA key to be rejected")
+	}
+}
@@ -0,0 +1,50 @@
+package ssh_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vssh/internal/ssh"
+)
+
+func TestInstallHostCertificate(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "ssh_host_ed25519_key.pub")
+	sshdConfig := filepath.Join(dir, "sshd_config")
+	original := "PermitRootLogin no\n\nMatch User backup\n    ForceCommand internal-sftp\n"
+	if err := os.WriteFile(sshdConfig, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	certPath, added, err := ssh.InstallHostCertificate(keyPath, "ssh-ed25519-cert-v01@openssh.com AAAA\n", sshdConfig)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := filepath.Join(dir, "ssh_host_ed25519_key-cert.pub"); certPath != expected || !added {
+		t.Errorf("Expected %s to be added, got %s (added %v)", expected, certPath, added)
+	}
+	if data, err := os.ReadFile(certPath); err != nil || string(data) != "ssh-ed25519-cert-v01@openssh.com AAAA\n" {
+		t.Errorf("Expected the certificate to be written, got %q, %v", data, err)
+	}
+
+	// The directive goes before the Match block, and only once
+	if _, added, err := ssh.InstallHostCertificate(keyPath, "ssh-ed25519-cert-v01@openssh.com BBBB\n", sshdConfig); err != nil || added {
+		t.Errorf("Expected the directive not to be added again, got added %v, %v", added, err)
+	}
+	data, err := os.ReadFile(sshdConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "PermitRootLogin no\n\nHostCertificate " + certPath + "\nMatch User backup\n    ForceCommand internal-sftp\n"
+	if string(data) != expected {
+		t.Errorf("Expected sshd config %q, got %q", expected, data)
+	}
+	info, err := os.Stat(sshdConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the sshd config mode to be kept, got %v", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,39 @@
+package ssh_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"vssh/internal/ssh"
+)
+
+func TestReadHostsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.txt")
+	content := `# production web tier
+web1.example.com
+admin@web2.example.com:2222   # maintenance user
+
+@databases
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := ssh.ReadHostsFile(path)
+	if err != nil {
+		t.Fatalf("ReadHostsFile() error = %v", err)
+	}
+
+	want := []string{"web1.example.com", "admin@web2.example.com:2222", "@databases"}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("ReadHostsFile() = %v, want %v", hosts, want)
+	}
+}
+
+func TestReadHostsFile_Missing(t *testing.T) {
+	if _, err := ssh.ReadHostsFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("ReadHostsFile() expected error for missing file")
+	}
+}
@@ -0,0 +1,72 @@
+package ssh_test
+
+import (
+	"testing"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+)
+
+func TestParseProxyJump(t *testing.T) {
+	t.Setenv("USER", "localuser")
+
+	cfg := &types.Config{
+		Hosts: []types.HostConfig{
+			{Pattern: "bastion*", HostSettings: types.HostSettings{User: "jump"}},
+		},
+	}
+
+	hops, err := ssh.ParseProxyJump(cfg, "bastion1:2222,admin@bastion2")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(hops) != 2 {
+		t.Fatalf("Expected 2 hops, got %d", len(hops))
+	}
+	if hops[0].Username != "jump" || hops[0].Hostname != "bastion1" || hops[0].Port != "2222" {
+		t.Errorf("Unexpected first hop: %+v", hops[0])
+	}
+	if hops[1].Username != "admin" || hops[1].Hostname != "bastion2" {
+		t.Errorf("Unexpected second hop: %+v", hops[1])
+	}
+}
+
+func TestProxyCommand(t *testing.T) {
+	single := ssh.ProxyCommand([]ssh.JumpHost{{
+		Target:          &ssh.SSHTarget{Username: "jump", Hostname: "bastion"},
+		CertificateFile: "/certs/jump.pub",
+		IdentityFile:    "/keys/id_ed25519",
+	}})
+
+	expected := "ssh -i /keys/id_ed25519 -o CertificateFile=/certs/jump.pub -W %h:%p jump@bastion"
+	if single != expected {
+		t.Errorf("Expected %q, got %q", expected, single)
+	}
+
+	chained := ssh.ProxyCommand([]ssh.JumpHost{
+		{Target: &ssh.SSHTarget{Username: "a", Hostname: "hop1"}, CertificateFile: "/certs/a.pub"},
+		{Target: &ssh.SSHTarget{Username: "b", Hostname: "hop2", Port: "2222"}, CertificateFile: "/certs/b.pub"},
+	})
+
+	expected = "ssh -o CertificateFile=/certs/b.pub -p 2222 -o 'ProxyCommand=ssh -o CertificateFile=/certs/a.pub -W hop2:2222 a@hop1' -W %h:%p b@hop2"
+	if chained != expected {
+		t.Errorf("Expected %q, got %q", expected, chained)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	testCases := map[string]string{
+		"simple":        "simple",
+		"":              "''",
+		"with space":    "'with space'",
+		"it's":          `'it'\''s'`,
+		"/path/to/file": "/path/to/file",
+		"$HOME":         "'$HOME'",
+	}
+
+	for input, expected := range testCases {
+		if got := ssh.ShellQuote(input); got != expected {
+			t.Errorf("ShellQuote(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
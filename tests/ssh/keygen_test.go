@@ -0,0 +1,76 @@
+package ssh_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"vssh/internal/ssh"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestGenerateKeyPair(t *testing.T) {
+	dir := t.TempDir()
+	expectedTypes := map[string]string{
+		"ed25519": gossh.KeyAlgoED25519,
+		"ecdsa":   gossh.KeyAlgoECDSA256,
+		"rsa":     gossh.KeyAlgoRSA,
+	}
+
+	for keyType, algorithm := range expectedTypes {
+		keyPath := filepath.Join(dir, "keys", "id_"+keyType)
+		if err := ssh.GenerateKeyPair(keyType, keyPath, "alice@laptop", false); err != nil {
+			t.Fatalf("Expected no error for %s, got %v", keyType, err)
+		}
+
+		keyData, _ := os.ReadFile(keyPath)
+		signer, err := gossh.ParsePrivateKey(keyData)
+		if err != nil {
+			t.Fatalf("Expected a valid %s private key, got %v", keyType, err)
+		}
+
+		publicData, _ := os.ReadFile(keyPath + ".pub")
+		publicKey, comment, _, _, err := gossh.ParseAuthorizedKey(publicData)
+		if err != nil {
+			t.Fatalf("Expected a valid %s public key, got %v", keyType, err)
+		}
+		if publicKey.Type() != algorithm || comment != "alice@laptop" {
+			t.Errorf("Expected %s key with comment alice@laptop, got %s %q", algorithm, publicKey.Type(), comment)
+		}
+		if string(publicKey.Marshal()) != string(signer.PublicKey().Marshal()) {
+			t.Errorf("Expected the %s public key to match the private key", keyType)
+		}
+
+		if info, _ := os.Stat(keyPath); runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("Expected private key mode 0600, got %v", info.Mode().Perm())
+		}
+	}
+}
+
+func TestGenerateKeyPair_Existing(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := ssh.GenerateKeyPair("ed25519", keyPath, "", false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	original, _ := os.ReadFile(keyPath)
+
+	if err := ssh.GenerateKeyPair("ed25519", keyPath, "", false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected an existing key not to be replaced, got %v", err)
+	}
+
+	if err := ssh.GenerateKeyPair("ed25519", keyPath, "", true); err != nil {
+		t.Fatalf("Expected no error with overwrite, got %v", err)
+	}
+	if replaced, _ := os.ReadFile(keyPath); string(replaced) == string(original) {
+		t.Errorf("Expected the key to be replaced with overwrite")
+	}
+}
+
+func TestGenerateKeyPair_UnsupportedType(t *testing.T) {
+	if err := ssh.GenerateKeyPair("dsa", filepath.Join(t.TempDir(), "id_dsa"), "", false); err == nil {
+		t.Errorf("Expected an error for an unsupported key type")
+	}
+}
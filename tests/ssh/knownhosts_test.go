@@ -0,0 +1,54 @@
+package ssh_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vssh/internal/ssh"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestSyncCertAuthority(t *testing.T) {
+	newCAKey := func() string {
+		public, _, _ := ed25519.GenerateKey(rand.Reader)
+		publicKey, _ := gossh.NewPublicKey(public)
+		return strings.TrimSpace(string(gossh.MarshalAuthorizedKey(publicKey)))
+	}
+	oldCA, newCA := newCAKey(), newCAKey()
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	hostLine := "web01.example.com " + newCAKey()
+	if err := os.WriteFile(path, []byte(hostLine+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := ssh.SyncCertAuthority(path, "ssh-host-signer", oldCA, []string{"*.example.com"})
+	if err != nil || !changed {
+		t.Fatalf("Expected the CA line to be added, got %v, %v", changed, err)
+	}
+	if changed, err := ssh.SyncCertAuthority(path, "ssh-host-signer", oldCA, []string{"*.example.com"}); err != nil || changed {
+		t.Errorf("Expected an unchanged CA line not to be rewritten, got %v, %v", changed, err)
+	}
+
+	// A rotated CA key replaces the line for the engine
+	if _, err := ssh.SyncCertAuthority(path, "ssh-host-signer", newCA, []string{"*.example.com", "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := hostLine + "\n@cert-authority *.example.com,example.com " + newCA + " vssh:ssh-host-signer\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+
+	if _, err := ssh.SyncCertAuthority(path, "ssh-host-signer", newCA, nil); err == nil {
+		t.Errorf("Expected no domains to be rejected")
+	}
+}
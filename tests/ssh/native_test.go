@@ -0,0 +1,202 @@
+package ssh_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// startTestServer starts an SSH server that trusts user certificates signed by
+// ca. It echoes exec requests back and exits with status 3.
+func startTestServer(t *testing.T, ca gossh.PublicKey) string {
+	t.Helper()
+
+	_, hostKey, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := gossh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("Failed to create host key: %v", err)
+	}
+
+	checker := &gossh.CertChecker{
+		IsUserAuthority: func(auth gossh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), ca.Marshal())
+		},
+	}
+	config := &gossh.ServerConfig{PublicKeyCallback: checker.Authenticate}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestConn(conn, config)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveTestConn(conn net.Conn, config *gossh.ServerConfig) {
+	_, channels, requests, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go gossh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for request := range channelRequests {
+				if request.Type != "exec" {
+					request.Reply(false, nil)
+					continue
+				}
+				request.Reply(true, nil)
+
+				command := string(request.Payload[4:])
+				io.WriteString(channel, "ran: "+command+"\n")
+
+				status := make([]byte, 4)
+				binary.BigEndian.PutUint32(status, 3)
+				channel.SendRequest("exit-status", false, status)
+				return
+			}
+		}()
+	}
+}
+
+// writeSignedKey writes a user key and a certificate for it signed by ca
+func writeSignedKey(t *testing.T, dir string, ca gossh.Signer, principal string) (string, string) {
+	t.Helper()
+
+	userPublic, userPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := gossh.MarshalPrivateKey(userPrivate, "")
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	publicKey, _ := gossh.NewPublicKey(userPublic)
+	cert := &gossh.Certificate{
+		Key:             publicKey,
+		CertType:        gossh.UserCert,
+		ValidPrincipals: []string{principal},
+		ValidBefore:     gossh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("Failed to sign certificate: %v", err)
+	}
+	certPath := filepath.Join(dir, "id_ed25519-cert.pub")
+	if err := os.WriteFile(certPath, gossh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	return keyPath, certPath
+}
+
+func TestExecute_Native(t *testing.T) {
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := gossh.NewSignerFromKey(caKey)
+	address := startTestServer(t, ca.PublicKey())
+	host, port, _ := net.SplitHostPort(address)
+
+	dir := t.TempDir()
+	keyPath, certPath := writeSignedKey(t, dir, ca, "alice")
+	knownHosts := filepath.Join(dir, "known_hosts")
+
+	cfg := &types.Config{SSH: types.SSHConfig{
+		Native:                true,
+		StrictHostKeyChecking: "accept-new",
+		KnownHostsFile:        knownHosts,
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := ssh.NewClient(cfg, logger)
+	target := &ssh.SSHTarget{Username: "alice", Hostname: host, Port: port}
+
+	var stdout bytes.Buffer
+	err := client.Execute(target, certPath, &ssh.SSHOptions{IdentityFile: keyPath}, []string{"echo", "a b"}, nil, &stdout, io.Discard)
+
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Errorf("Expected exit code 3, got %v", err)
+	}
+	if expected := "ran: echo 'a b'\n"; stdout.String() != expected {
+		t.Errorf("Expected output %q, got %q", expected, stdout.String())
+	}
+
+	data, err := os.ReadFile(knownHosts)
+	if err != nil || !strings.Contains(string(data), "ssh-ed25519") {
+		t.Errorf("Expected the host key to be recorded, got %q (%v)", data, err)
+	}
+
+	// The recorded key is now required
+	cfg.SSH.StrictHostKeyChecking = "yes"
+	if err := client.Execute(target, certPath, &ssh.SSHOptions{IdentityFile: keyPath}, []string{"true"}, nil, io.Discard, io.Discard); ssh.ExitCode(err) != 3 {
+		t.Errorf("Expected the known host to be accepted, got %v", err)
+	}
+}
+
+func TestExecute_NativeUnknownHost(t *testing.T) {
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := gossh.NewSignerFromKey(caKey)
+	address := startTestServer(t, ca.PublicKey())
+	host, port, _ := net.SplitHostPort(address)
+
+	dir := t.TempDir()
+	keyPath, certPath := writeSignedKey(t, dir, ca, "alice")
+
+	cfg := &types.Config{SSH: types.SSHConfig{
+		Native:                true,
+		StrictHostKeyChecking: "yes",
+		KnownHostsFile:        filepath.Join(dir, "known_hosts"),
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	target := &ssh.SSHTarget{Username: "alice", Hostname: host, Port: port}
+
+	err := ssh.NewClient(cfg, logger).Execute(target, certPath, &ssh.SSHOptions{IdentityFile: keyPath}, []string{"true"}, nil, io.Discard, io.Discard)
+	if ssh.ExitCode(err) != 255 {
+		t.Errorf("Expected an unknown host to be rejected, got %v", err)
+	}
+}
+
+func TestExecute_NativeUnsupportedOption(t *testing.T) {
+	cfg := &types.Config{SSH: types.SSHConfig{Native: true}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	target := &ssh.SSHTarget{Username: "alice", Hostname: "db01"}
+
+	err := ssh.NewClient(cfg, logger).Execute(target, "", &ssh.SSHOptions{ExtraArgs: []string{"-L", "8080:localhost:80"}}, nil, nil, io.Discard, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "-L") {
+		t.Errorf("Expected an error for -L, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package ssh_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeSSHKeygen puts a stand-in for ssh-keygen -D on PATH that prints the
+// keys on a PIV token
+func fakeSSHKeygen(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of ssh-keygen")
+	}
+
+	dir := t.TempDir()
+	script := `#!/bin/sh
+[ "$1" = "-D" ] && [ "$2" = "/usr/lib/opensc-pkcs11.so" ] || exit 1
+echo "ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAA1 PIV AUTH pubkey"
+echo "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAB2 SIGN pubkey"
+`
+	if err := os.WriteFile(filepath.Join(dir, "ssh-keygen"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake ssh-keygen: %v", err)
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestPKCS11PublicKey(t *testing.T) {
+	fakeSSHKeygen(t)
+
+	testCases := map[string]string{
+		"":            "ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAA1\n",
+		"SIGN pubkey": "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAB2\n",
+	}
+	for label, expected := range testCases {
+		publicKey, err := ssh.PKCS11PublicKey("/usr/lib/opensc-pkcs11.so", label)
+		if err != nil {
+			t.Fatalf("Expected no error for label %q, got %v", label, err)
+		}
+		if publicKey != expected {
+			t.Errorf("Expected public key %q for label %q, got %q", expected, label, publicKey)
+		}
+	}
+
+	if _, err := ssh.PKCS11PublicKey("/usr/lib/opensc-pkcs11.so", "KEY MAN pubkey"); err == nil || !strings.Contains(err.Error(), "PIV AUTH pubkey") {
+		t.Errorf("Expected an error listing the available labels, got %v", err)
+	}
+	if _, err := ssh.PKCS11PublicKey("/usr/lib/missing.so", ""); err == nil {
+		t.Errorf("Expected an error for a provider ssh-keygen can't load")
+	}
+}
+
+func TestCommand_PKCS11Provider(t *testing.T) {
+	cfg := &types.Config{SSH: types.SSHConfig{KeyDirectory: t.TempDir(), PKCS11Provider: "/usr/lib/opensc-pkcs11.so"}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	keyPath, err := ssh.NewSigner(nil, cfg, logger).GetPrivateKeyPath(&ssh.SSHTarget{Username: "alice"})
+	if err != nil || keyPath != "" {
+		t.Errorf("Expected no private key file with a PKCS#11 token, got %q (%v)", keyPath, err)
+	}
+
+	hop := &ssh.SSHTarget{Username: "jump", Hostname: "bastion"}
+	args := commandArgs(cfg, &ssh.SSHOptions{JumpHosts: []ssh.JumpHost{{Target: hop, CertificateFile: "/certs/jump.pub", KeyOptions: ssh.KeyProviderArgs(cfg)}}})
+	for _, arg := range args {
+		if arg == "-i" {
+			t.Errorf("Expected no -i with a PKCS#11 token, got %v", args)
+		}
+	}
+
+	expected := "PKCS11Provider=/usr/lib/opensc-pkcs11.so"
+	proxyCommand := ""
+	found := false
+	for _, arg := range args {
+		if arg == expected {
+			found = true
+		}
+		if strings.HasPrefix(arg, "ProxyCommand=") {
+			proxyCommand = arg
+		}
+	}
+	if !found || !strings.Contains(proxyCommand, "-o "+expected) {
+		t.Errorf("Expected %s for the target and the jump host, got %v", expected, args)
+	}
+}
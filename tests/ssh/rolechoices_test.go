@@ -0,0 +1,50 @@
+package ssh_test
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRoleChoices(t *testing.T) {
+	path := ssh.DefaultRoleChoicesPath(filepath.Join(t.TempDir(), "state"))
+	if err := ssh.NewRoleChoices(path).Set("alice", "Web01.example.com", "web-admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new store reads the choice back from the file
+	choices := ssh.NewRoleChoices(path)
+	if role := choices.Get("alice", "web01.example.com"); role != "web-admin" {
+		t.Errorf("Expected web-admin, got %q", role)
+	}
+	if role := choices.Get("bob", "web01.example.com"); role != "" {
+		t.Errorf("Expected no role for another user, got %q", role)
+	}
+
+	cfg := &types.Config{
+		Hosts: []types.HostConfig{{Pattern: "db*", HostSettings: types.HostSettings{Role: "dba"}}},
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	signer := ssh.NewSigner(nil, cfg, logger)
+	signer.UseRoleChoices(choices)
+
+	tests := []struct {
+		target   ssh.SSHTarget
+		expected string
+	}{
+		{ssh.SSHTarget{Username: "alice", Hostname: "web01.example.com"}, "web-admin"},
+		{ssh.SSHTarget{Username: "bob", Hostname: "web01.example.com"}, "bob"},
+		{ssh.SSHTarget{Username: "alice", Hostname: "db01"}, "dba"},
+	}
+	for _, tt := range tests {
+		if role := signer.ResolveRole(&tt.target); role != tt.expected {
+			t.Errorf("%s@%s: Expected role %s, got %s", tt.target.Username, tt.target.Hostname, tt.expected, role)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ssh_test
+
+import (
+	"testing"
+
+	"vssh/internal/ssh"
+)
+
+func TestParseRsyncRemote(t *testing.T) {
+	testCases := map[string]string{
+		"admin@web1:/srv/www/":       "admin@web1",
+		"db01:backups":               "db01",
+		"./site/":                    "",
+		"--exclude=*.tmp":            "",
+		"backup.example.com::module": "",
+		"rsync://backup/module":      "",
+	}
+
+	for arg, expected := range testCases {
+		remote, ok := ssh.ParseRsyncRemote(arg)
+		if expected == "" {
+			if ok {
+				t.Errorf("Expected %q not to be a remote path, got %+v", arg, remote)
+			}
+			continue
+		}
+		if !ok || remote.Target != expected {
+			t.Errorf("Expected target %q for %q, got %+v", expected, arg, remote)
+		}
+	}
+}
+
+func TestHasRsyncShellOption(t *testing.T) {
+	testCases := map[string]struct {
+		args     []string
+		expected bool
+	}{
+		"none":       {[]string{"-av", "src", "host:dst"}, false},
+		"short":      {[]string{"-e", "ssh", "src", "host:dst"}, true},
+		"grouped":    {[]string{"-ave", "ssh", "src", "host:dst"}, true},
+		"long":       {[]string{"--rsh=ssh -p 2222", "src", "host:dst"}, true},
+		"filter":     {[]string{"-f", "- exclude", "src", "host:dst"}, false},
+		"filter arg": {[]string{"-f-exclude", "src", "host:dst"}, false},
+	}
+
+	for name, tc := range testCases {
+		if got := ssh.HasRsyncShellOption(tc.args); got != tc.expected {
+			t.Errorf("%s: expected %v for %v, got %v", name, tc.expected, tc.args, got)
+		}
+	}
+}
+
+func TestRsyncShell(t *testing.T) {
+	got := ssh.RsyncShell([]string{"-o", "CertificateFile=/home/a b/cert.pub", "-p", "2222"})
+	expected := "ssh -o 'CertificateFile=/home/a b/cert.pub' -p 2222"
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
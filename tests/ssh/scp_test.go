@@ -0,0 +1,89 @@
+package ssh_test
+
+import (
+	"reflect"
+	"testing"
+
+	"vssh/internal/ssh"
+)
+
+func TestParseRemotePath(t *testing.T) {
+	testCases := []struct {
+		spec   string
+		remote *ssh.RemotePath
+	}{
+		{"admin@db01:/srv/backups/", &ssh.RemotePath{Target: "admin@db01", Path: "/srv/backups/"}},
+		{"web1:", &ssh.RemotePath{Target: "web1", Path: ""}},
+		{"root@[2001:db8::1]:/etc/hosts", &ssh.RemotePath{Target: "root@[2001:db8::1]", Path: "/etc/hosts"}},
+		{"scp://alice@files.example.com:2222/reports/q3.pdf", &ssh.RemotePath{Target: "alice@files.example.com:2222", Path: "reports/q3.pdf"}},
+		{"sftp://alice@files.example.com", &ssh.RemotePath{Target: "alice@files.example.com", Path: ""}},
+		{"backup.tar.gz", nil},
+		{"./logs/a:b", nil},
+		{"/tmp/a:b", nil},
+		{":local", nil},
+	}
+
+	for _, tc := range testCases {
+		remote, ok := ssh.ParseRemotePath(tc.spec)
+		if ok != (tc.remote != nil) {
+			t.Errorf("Expected remote=%v for %q, got %v", tc.remote != nil, tc.spec, ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(remote, tc.remote) {
+			t.Errorf("Expected %+v for %q, got %+v", tc.remote, tc.spec, remote)
+		}
+	}
+}
+
+func TestParseSCPArgs(t *testing.T) {
+	options, paths, err := ssh.ParseSCPArgs([]string{"-rp", "-P2222", "-i", "~/.ssh/id_ed25519", "-l", "1000", "src", "db01:/tmp"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if options.Port != "2222" || options.IdentityFile != "~/.ssh/id_ed25519" {
+		t.Errorf("Unexpected options: %+v", options)
+	}
+	if expected := []string{"-r", "-p", "-l", "1000"}; !reflect.DeepEqual(options.ExtraArgs, expected) {
+		t.Errorf("Expected extra arguments %v, got %v", expected, options.ExtraArgs)
+	}
+	if expected := []string{"src", "db01:/tmp"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v, got %v", expected, paths)
+	}
+
+	if _, _, err := ssh.ParseSCPArgs([]string{"-P", "ssh", "src", "db01:"}); err == nil {
+		t.Errorf("Expected error for an invalid port")
+	}
+}
+
+func TestFormatRemotePath(t *testing.T) {
+	testCases := []struct {
+		target   *ssh.SSHTarget
+		expected string
+	}{
+		{&ssh.SSHTarget{Username: "admin", Hostname: "db01"}, "admin@db01:/srv"},
+		{&ssh.SSHTarget{Username: "root", Hostname: "2001:db8::1"}, "root@[2001:db8::1]:/srv"},
+		{&ssh.SSHTarget{Username: "admin", Hostname: "10.0.0.5", Alias: "prod-db"}, "admin@prod-db:/srv"},
+	}
+
+	for _, tc := range testCases {
+		if got := ssh.FormatRemotePath(tc.target, "/srv"); got != tc.expected {
+			t.Errorf("Expected %q, got %q", tc.expected, got)
+		}
+	}
+}
+
+func TestParseSFTPArgs(t *testing.T) {
+	options, destination, err := ssh.ParseSFTPArgs([]string{"-P", "2222", "-b", "batch.txt", "-C", "alice@files:/uploads"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if options.Port != "2222" {
+		t.Errorf("Expected port 2222, got %q", options.Port)
+	}
+	if expected := []string{"-b", "batch.txt", "-C"}; !reflect.DeepEqual(options.ExtraArgs, expected) {
+		t.Errorf("Expected extra arguments %v, got %v", expected, options.ExtraArgs)
+	}
+	if expected := []string{"alice@files:/uploads"}; !reflect.DeepEqual(destination, expected) {
+		t.Errorf("Expected destination %v, got %v", expected, destination)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package ssh_test
+
+import (
+	"io"
+	"testing"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRunTool_ForwardsSignals(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := ssh.NewClient(&types.Config{}, logger)
+
+	// The tool sends vssh a SIGTERM, which vssh survives and passes on
+	script := `trap "exit 7" TERM; kill -TERM $PPID; sleep 5 & wait`
+	err := client.RunTool("sh", []string{"-c", script})
+	if code := ssh.ExitCode(err); code != 7 {
+		t.Errorf("Expected the tool to exit with 7 from its TERM trap, got %d (%v)", code, err)
+	}
+}
@@ -0,0 +1,117 @@
+package ssh_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+)
+
+func writeSSHConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write ssh config: %v", err)
+	}
+	return path
+}
+
+func TestLoadSSHConfig_Lookup(t *testing.T) {
+	path := writeSSHConfig(t, `
+# Global defaults come first
+Port 22
+
+Host db
+    HostName db01.prod.example.com
+    User dbadmin
+    Port 2222
+    IdentityFile /keys/db_ed25519
+
+Host *.lab
+    HostName %h.example.com
+    User = labuser
+
+Match host foo
+    User ignored
+
+Host *
+    User fallback
+`)
+
+	sshConfig, err := ssh.LoadSSHConfig(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	db := sshConfig.Lookup("db")
+	if db.HostName != "db01.prod.example.com" || db.User != "dbadmin" || db.IdentityFile != "/keys/db_ed25519" {
+		t.Errorf("Unexpected values for db: %+v", db)
+	}
+	// The global Port comes before the Host block, so it wins
+	if db.Port != "22" {
+		t.Errorf("Expected first obtained port 22, got %s", db.Port)
+	}
+
+	lab := sshConfig.Lookup("web.lab")
+	if lab.HostName != "web.lab.example.com" || lab.User != "labuser" {
+		t.Errorf("Unexpected values for web.lab: %+v", lab)
+	}
+
+	other := sshConfig.Lookup("other")
+	if other.HostName != "" || other.User != "fallback" {
+		t.Errorf("Unexpected values for other: %+v", other)
+	}
+}
+
+func TestResolveTarget_SSHConfigAlias(t *testing.T) {
+	t.Setenv("USER", "localuser")
+
+	path := writeSSHConfig(t, `
+Host myalias
+    HostName real.example.com
+    User aliasuser
+    Port 2200
+`)
+
+	cfg := &types.Config{SSH: types.SSHConfig{SSHConfigFile: path}}
+
+	target, err := ssh.ResolveTarget(cfg, "myalias")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if target.Hostname != "real.example.com" || target.Alias != "myalias" {
+		t.Errorf("Expected alias to resolve to real.example.com, got %+v", target)
+	}
+	if target.Username != "aliasuser" || target.Port != "2200" {
+		t.Errorf("Expected user and port from ssh config, got %+v", target)
+	}
+	if target.SSHHost() != "myalias" {
+		t.Errorf("Expected ssh to be invoked with the alias, got %s", target.SSHHost())
+	}
+}
+
+func TestSSHConfig_Hosts(t *testing.T) {
+	path := writeSSHConfig(t, `
+Host db web
+    User admin
+
+Host *.lab !skip.lab
+    User labuser
+
+Host web
+    Port 2222
+`)
+
+	sshConfig, err := ssh.LoadSSHConfig(path)
+	if err != nil {
+		t.Fatalf("LoadSSHConfig() error = %v", err)
+	}
+
+	hosts := sshConfig.Hosts()
+	if len(hosts) != 2 || hosts[0] != "db" || hosts[1] != "web" {
+		t.Errorf("Hosts() = %v, want [db web]", hosts)
+	}
+}
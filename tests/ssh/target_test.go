@@ -0,0 +1,105 @@
+package ssh_test
+
+import (
+	"testing"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+)
+
+func TestParseSSHTarget(t *testing.T) {
+	t.Setenv("USER", "localuser")
+
+	testCases := []struct {
+		input    string
+		username string
+		hostname string
+		wantErr  bool
+	}{
+		{"alice@server.example.com", "alice", "server.example.com", false},
+		{"server.example.com", "localuser", "server.example.com", false},
+		{"@server.example.com", "", "", true},
+		{"alice@", "", "", true},
+		{"a@b@c", "", "", true},
+		{"admin@db01:2222", "admin", "db01", false},
+		{"db01:22", "localuser", "db01", false},
+		{"db01:notaport", "", "", true},
+		{"db01:70000", "", "", true},
+		{"root@[2001:db8::1]", "root", "2001:db8::1", false},
+		{"root@2001:db8::1", "root", "2001:db8::1", false},
+		{"[::1]:2222", "localuser", "::1", false},
+		{"root@[::1", "", "", true},
+		{"root@[::1]x", "", "", true},
+	}
+
+	for _, tc := range testCases {
+		target, err := ssh.ParseSSHTarget(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Expected error for %q, got %+v", tc.input, target)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error for %q, got %v", tc.input, err)
+			continue
+		}
+		if target.Username != tc.username || target.Hostname != tc.hostname {
+			t.Errorf("Expected %s@%s for %q, got %s@%s", tc.username, tc.hostname, tc.input, target.Username, target.Hostname)
+		}
+	}
+}
+
+func TestParseSSHTarget_InlinePort(t *testing.T) {
+	target, err := ssh.ParseSSHTarget("admin@db01.example.com:2222")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if target.Port != "2222" || target.Hostname != "db01.example.com" {
+		t.Errorf("Expected db01.example.com port 2222, got %s port %s", target.Hostname, target.Port)
+	}
+}
+
+func TestParseSSHTarget_IPv6Port(t *testing.T) {
+	target, err := ssh.ParseSSHTarget("admin@[fe80::1]:2200")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if target.Hostname != "fe80::1" || target.Port != "2200" {
+		t.Errorf("Expected fe80::1 port 2200, got %s port %s", target.Hostname, target.Port)
+	}
+}
+
+func TestResolveTarget_HostUser(t *testing.T) {
+	t.Setenv("USER", "localuser")
+
+	cfg := &types.Config{
+		Hosts: []types.HostConfig{
+			{Pattern: "*.compute.amazonaws.com", HostSettings: types.HostSettings{User: "ubuntu"}},
+		},
+	}
+
+	target, err := ssh.ResolveTarget(cfg, "ec2-1-2-3-4.compute.amazonaws.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if target.Username != "ubuntu" {
+		t.Errorf("Expected mapped user ubuntu, got %s", target.Username)
+	}
+
+	target, err = ssh.ResolveTarget(cfg, "alice@ec2-1-2-3-4.compute.amazonaws.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if target.Username != "alice" {
+		t.Errorf("Expected explicit user alice, got %s", target.Username)
+	}
+
+	target, err = ssh.ResolveTarget(cfg, "other.example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if target.Username != "localuser" {
+		t.Errorf("Expected current user fallback, got %s", target.Username)
+	}
+}
@@ -0,0 +1,49 @@
+package ssh_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vssh/internal/ssh"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestAddTrustedCAKey(t *testing.T) {
+	newCAKey := func() string {
+		public, _, _ := ed25519.GenerateKey(rand.Reader)
+		publicKey, _ := gossh.NewPublicKey(public)
+		return strings.TrimSpace(string(gossh.MarshalAuthorizedKey(publicKey)))
+	}
+	otherCA, vaultCA := newCAKey(), newCAKey()
+
+	path := filepath.Join(t.TempDir(), "trusted-user-ca-keys.pem")
+	if err := os.WriteFile(path, []byte(otherCA), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := ssh.AddTrustedCAKey(path, vaultCA+"\n")
+	if err != nil || !added {
+		t.Fatalf("Expected the CA key to be added, got %v, %v", added, err)
+	}
+	// A key already trusted, even with a comment, is not added again
+	if added, err := ssh.AddTrustedCAKey(path, vaultCA+" vault-ca"); err != nil || added {
+		t.Errorf("Expected the CA key not to be added again, got %v, %v", added, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := otherCA + "\n" + vaultCA + "\n"; string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+
+	if _, err := ssh.AddTrustedCAKey(path, "not a key"); err == nil {
+		t.Errorf("Expected an invalid CA key to be rejected")
+	}
+}
@@ -0,0 +1,85 @@
+package ssh_test
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"vssh/internal/ssh"
+	"vssh/pkg/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// waitClient returns a client logging nowhere
+func waitClient() *ssh.Client {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return ssh.NewClient(&types.Config{}, logger)
+}
+
+// listenTarget returns a target for a local listener
+func listenTarget(t *testing.T, listener net.Listener) *ssh.SSHTarget {
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to split listener address: %v", err)
+	}
+	return &ssh.SSHTarget{Username: "alice", Hostname: host, Port: port}
+}
+
+func TestWaitForHost_WaitsForBanner(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	// Like a port in front of a booting host: accept connections but close
+	// them until the SSH server is up
+	up := time.Now().Add(300 * time.Millisecond)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if time.Now().After(up) {
+				io.WriteString(conn, "Welcome\r\nSSH-2.0-OpenSSH_9.6\r\n")
+			}
+			conn.Close()
+		}
+	}()
+
+	started := time.Now()
+	err = waitClient().WaitForHost(listenTarget(t, listener), &ssh.SSHOptions{}, 50*time.Millisecond, 5*time.Second)
+	if err != nil {
+		t.Errorf("Expected the host to come up, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 300*time.Millisecond {
+		t.Errorf("Expected to wait for the SSH banner, returned after %v", elapsed)
+	}
+}
+
+func TestWaitForHost_Timeout(t *testing.T) {
+	// A port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	target := listenTarget(t, listener)
+	listener.Close()
+
+	err = waitClient().WaitForHost(target, &ssh.SSHOptions{}, 50*time.Millisecond, 200*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "did not come up within 200ms") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+}
+
+func TestWaitForHost_JumpHost(t *testing.T) {
+	target := &ssh.SSHTarget{Username: "alice", Hostname: "db01.internal", ProxyJump: "bastion"}
+	if err := waitClient().WaitForHost(target, &ssh.SSHOptions{}, time.Second, time.Second); err != nil {
+		t.Errorf("Expected hosts behind jump hosts not to be waited for, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package tmux_test
+
+import (
+	"slices"
+	"testing"
+
+	"vssh/internal/tmux"
+)
+
+func TestCreateArgs(t *testing.T) {
+	w := tmux.Window{Session: "vssh", Name: "web", Panes: []string{"vssh web1", "vssh web2"}}
+
+	args := tmux.CreateArgs(w, false)
+	expected := []string{"new-session", "-d", "-s", "vssh", "-n", "web", "-P", "-F", "#{window_id}", "vssh web1"}
+	if !slices.Equal(args, expected) {
+		t.Errorf("Expected %q, got %q", expected, args)
+	}
+
+	args = tmux.CreateArgs(w, true)
+	expected = []string{"new-window", "-d", "-t", "=vssh:", "-n", "web", "-P", "-F", "#{window_id}", "vssh web1"}
+	if !slices.Equal(args, expected) {
+		t.Errorf("Expected %q, got %q", expected, args)
+	}
+}
+
+func TestLayoutArgs(t *testing.T) {
+	w := tmux.Window{Session: "vssh", Name: "web", Panes: []string{"vssh web1", "vssh web2", "vssh web3"}}
+
+	args := tmux.LayoutArgs(w, "@3")
+	expected := []string{
+		"split-window", "-t", "@3", "vssh web2", ";", "select-layout", "-t", "@3", "tiled", ";",
+		"split-window", "-t", "@3", "vssh web3", ";", "select-layout", "-t", "@3", "tiled", ";",
+		"select-pane", "-t", "@3.0",
+	}
+	if !slices.Equal(args, expected) {
+		t.Errorf("Expected %q, got %q", expected, args)
+	}
+
+	w.Sync = true
+	args = tmux.LayoutArgs(w, "@3")
+	if !slices.Contains(args, "synchronize-panes") {
+		t.Errorf("Expected the panes to be synchronized, got %q", args)
+	}
+}
+
+func TestLayoutArgs_OnePane(t *testing.T) {
+	w := tmux.Window{Session: "vssh", Name: "db", Panes: []string{"vssh db1"}}
+
+	args := tmux.LayoutArgs(w, "@1")
+	expected := []string{"select-pane", "-t", "@1.0"}
+	if !slices.Equal(args, expected) {
+		t.Errorf("Expected %q, got %q", expected, args)
+	}
+}
@@ -0,0 +1,123 @@
+package tunnel_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"vssh/internal/tunnel"
+)
+
+func TestListenAddress(t *testing.T) {
+	testCases := []struct {
+		option   string
+		spec     string
+		expected string
+		wantErr  bool
+	}{
+		{"-L", "5432:db:5432", "localhost:5432", false},
+		{"-L", "0.0.0.0:8080:intranet:80", "0.0.0.0:8080", false},
+		{"-L", "*:8080:intranet:80", "localhost:8080", false},
+		{"-L", "[::1]:8080:[2001:db8::1]:80", "[::1]:8080", false},
+		{"-L", "9000:/var/run/app.sock", "localhost:9000", false},
+		{"-L", "/tmp/local.sock:db:5432", "", false},
+		{"-D", "1080", "localhost:1080", false},
+		{"-D", "127.0.0.1:1080", "127.0.0.1:1080", false},
+		{"-L", "db:5432", "", true},
+		{"-D", "socks", "", true},
+	}
+
+	for _, tc := range testCases {
+		address, err := tunnel.ListenAddress(tc.option, tc.spec)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Expected error for %s %s, got %q", tc.option, tc.spec, address)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error for %s %s, got %v", tc.option, tc.spec, err)
+			continue
+		}
+		if address != tc.expected {
+			t.Errorf("Expected %q for %s %s, got %q", tc.expected, tc.option, tc.spec, address)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := tunnel.NewStore(filepath.Join(t.TempDir(), "tunnels.json"))
+
+	running := tunnel.Tunnel{Target: "db01", Forwards: []string{"-L 5432:db:5432"}, PID: os.Getpid(), Started: time.Now()}
+	if err := store.Add(running); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A process ID that can't exist stands in for a tunnel that has exited
+	if err := store.Add(tunnel.Tunnel{Target: "gone", PID: 1 << 30}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tunnels, err := store.List()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(tunnels) != 1 || tunnels[0].Target != "db01" {
+		t.Fatalf("Expected only the running tunnel, got %+v", tunnels)
+	}
+
+	if err := store.Remove(os.Getpid()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tunnels, _ := store.List(); len(tunnels) != 0 {
+		t.Errorf("Expected no tunnels after removal, got %+v", tunnels)
+	}
+}
+
+func TestStore_ReusedProcessID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tunnels.json")
+
+	// A tunnel recorded with this process's ID but another start time stands
+	// in for one that exited, its ID now used by an unrelated program
+	stale := `[{"target": "db01", "pid": ` + strconv.Itoa(os.Getpid()) + `, "process_start": "earlier"}]`
+	if err := os.WriteFile(path, []byte(stale), 0600); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tunnels, err := tunnel.NewStore(path).List()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(tunnels) != 0 {
+		t.Errorf("Expected the tunnel with a reused process ID to be dropped, got %+v", tunnels)
+	}
+	if err := tunnel.Stop(tunnel.Tunnel{Target: "db01", PID: os.Getpid(), ProcessStart: "earlier"}); err == nil {
+		t.Error("Expected an error stopping a tunnel whose process ID was reused")
+	}
+}
+
+func TestListening(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	address := listener.Addr().String()
+	if !tunnel.Listening(address) {
+		t.Errorf("Expected %s to be listening", address)
+	}
+
+	listener.Close()
+	if tunnel.Listening(address) {
+		t.Errorf("Expected %s not to be listening once closed", address)
+	}
+
+	// Checking must leave the port free for ssh to bind
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("Expected %s to be free after checking it, got %v", address, err)
+	}
+	listener.Close()
+}
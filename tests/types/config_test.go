@@ -0,0 +1,66 @@
+package types_test
+
+import (
+	"testing"
+	"time"
+
+	"vssh/pkg/types"
+)
+
+func TestRenewMargin(t *testing.T) {
+	testCases := []struct {
+		renewBefore string
+		ttl         time.Duration
+		expected    time.Duration
+	}{
+		{"", 15 * time.Minute, 3 * time.Minute},
+		{"20%", 4 * time.Hour, 48 * time.Minute},
+		{"50%", 30 * time.Minute, 15 * time.Minute},
+		{"30m", 4 * time.Hour, 30 * time.Minute},
+		{"0s", time.Hour, 0},
+	}
+
+	for _, tc := range testCases {
+		margin, err := types.SSHConfig{RenewBefore: tc.renewBefore}.RenewMargin(tc.ttl)
+		if err != nil {
+			t.Fatalf("Expected no error for %q, got %v", tc.renewBefore, err)
+		}
+		if margin != tc.expected {
+			t.Errorf("Expected margin %v for %q with TTL %v, got %v", tc.expected, tc.renewBefore, tc.ttl, margin)
+		}
+	}
+
+	for _, invalid := range []string{"100%", "-5%", "soon", "-1m", "%"} {
+		if _, err := (types.SSHConfig{RenewBefore: invalid}).RenewMargin(time.Hour); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestKeyID(t *testing.T) {
+	fields := types.KeyIDFields{
+		VaultUser: "alice",
+		Username:  "deploy",
+		Hostname:  "db01",
+		Role:      "prod-admin",
+		Timestamp: "2026-01-16T10:30:00Z",
+	}
+
+	keyID, err := types.SSHConfig{KeyIDTemplate: "{{.VaultUser}}@{{.Hostname}}/{{.Timestamp}}"}.KeyID(fields)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := "alice@db01/2026-01-16T10:30:00Z"; keyID != expected {
+		t.Errorf("Expected key ID %s, got %s", expected, keyID)
+	}
+
+	if keyID, err := (types.SSHConfig{}).KeyID(fields); err != nil || keyID != "" {
+		t.Errorf("Expected no key ID without a template, got %q, %v", keyID, err)
+	}
+
+	for _, invalid := range []string{"{{.VaultUser", "{{.Email}}"} {
+		if _, err := (types.SSHConfig{KeyIDTemplate: invalid}).KeyID(fields); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package types_test
+
+import (
+	"testing"
+
+	"vssh/pkg/types"
+)
+
+func TestMatchHostPattern(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		hostname string
+		match    bool
+	}{
+		{"*.prod.example.com", "db01.prod.example.com", true},
+		{"*.prod.example.com", "db01.lab.example.com", false},
+		{"*.prod.example.com", "prod.example.com", false},
+		{"web-??.example.com", "web-01.example.com", true},
+		{"web-??.example.com", "web-1.example.com", false},
+		{"*.example.com,!bastion.example.com", "bastion.example.com", false},
+		{"*.example.com,!bastion.example.com", "app.example.com", true},
+		{"*.EXAMPLE.com", "App.example.COM", true},
+		{"10.0.*", "10.0.3.4", true},
+		{"*", "anything", true},
+		{"", "anything", false},
+	}
+
+	for _, tc := range testCases {
+		if got := types.MatchHostPattern(tc.pattern, tc.hostname); got != tc.match {
+			t.Errorf("MatchHostPattern(%q, %q) = %v, expected %v", tc.pattern, tc.hostname, got, tc.match)
+		}
+	}
+}
+
+func TestResolveHost_FirstMatchWins(t *testing.T) {
+	cfg := &types.Config{
+		Hosts: []types.HostConfig{
+			{Pattern: "db*.prod.example.com", HostSettings: types.HostSettings{Role: "prod-db"}},
+			{Pattern: "*.prod.example.com", HostSettings: types.HostSettings{Role: "prod-ssh"}},
+			{Pattern: "*.lab.example.com", HostSettings: types.HostSettings{Role: "lab-ssh"}},
+		},
+	}
+
+	testCases := map[string]string{
+		"db01.prod.example.com":  "prod-db",
+		"web01.prod.example.com": "prod-ssh",
+		"web01.lab.example.com":  "lab-ssh",
+		"other.example.org":      "",
+	}
+
+	for hostname, expected := range testCases {
+		if got := cfg.ResolveHost(hostname).Role; got != expected {
+			t.Errorf("Expected role %q for %s, got %q", expected, hostname, got)
+		}
+	}
+}
+
+func TestResolveHost_Groups(t *testing.T) {
+	cfg := &types.Config{
+		Hosts: []types.HostConfig{
+			{Pattern: "web01.example.com", HostSettings: types.HostSettings{Role: "web01-only"}},
+		},
+		Groups: types.GroupConfigs{
+			"web": {
+				Hosts:        []string{"web01.example.com", "web02.example.com"},
+				HostSettings: types.HostSettings{Role: "web-ssh", SigningEngine: "ssh-web"},
+			},
+			"db": {
+				Hosts:        []string{"db*.example.com"},
+				HostSettings: types.HostSettings{Role: "db-ssh"},
+			},
+		},
+	}
+
+	settings := cfg.ResolveHost("web01.example.com")
+	if settings.Role != "web01-only" || settings.SigningEngine != "ssh-web" {
+		t.Errorf("Expected host entry role and group engine, got %+v", settings)
+	}
+
+	settings = cfg.ResolveHost("web02.example.com")
+	if settings.Role != "web-ssh" {
+		t.Errorf("Expected group role web-ssh, got %q", settings.Role)
+	}
+
+	if got := cfg.GroupsForHost("db07.example.com"); len(got) != 1 || got[0] != "db" {
+		t.Errorf("Expected db07 to be in group db, got %v", got)
+	}
+}
+
+func TestResolveHost_Protected(t *testing.T) {
+	cfg := &types.Config{
+		Hosts: []types.HostConfig{
+			{Pattern: "db*.prod.example.com", HostSettings: types.HostSettings{RequireReason: true}},
+			{Pattern: "*.prod.example.com", HostSettings: types.HostSettings{Role: "prod-ssh"}},
+		},
+		Groups: types.GroupConfigs{
+			"prod": {
+				Hosts:        []string{"*.prod.example.com"},
+				HostSettings: types.HostSettings{Protected: true},
+			},
+		},
+	}
+
+	settings := cfg.ResolveHost("db01.prod.example.com")
+	if !settings.Protected || !settings.RequireReason {
+		t.Errorf("Expected db01 to be protected and require a reason, got %+v", settings)
+	}
+
+	settings = cfg.ResolveHost("web01.prod.example.com")
+	if !settings.Protected || settings.RequireReason {
+		t.Errorf("Expected web01 to be protected without a reason, got %+v", settings)
+	}
+
+	if cfg.ResolveHost("web01.lab.example.com").Protected {
+		t.Errorf("Expected web01.lab not to be protected")
+	}
+}
+
+func TestResolveHost_Banner(t *testing.T) {
+	cfg := &types.Config{
+		Hosts: []types.HostConfig{
+			{Pattern: "db01.prod.example.com", HostSettings: types.HostSettings{Banner: "db01 is being migrated"}},
+		},
+		Groups: types.GroupConfigs{
+			"prod": {
+				Hosts:        []string{"*.prod.example.com"},
+				HostSettings: types.HostSettings{Banner: "Change freeze in effect"},
+			},
+		},
+	}
+
+	testCases := map[string]string{
+		"db01.prod.example.com":  "db01 is being migrated",
+		"web01.prod.example.com": "Change freeze in effect",
+		"web01.lab.example.com":  "",
+	}
+
+	for hostname, expected := range testCases {
+		if got := cfg.ResolveHost(hostname).Banner; got != expected {
+			t.Errorf("Expected banner %q for %s, got %q", expected, hostname, got)
+		}
+	}
+}
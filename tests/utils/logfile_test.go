@@ -0,0 +1,115 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vssh/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "vssh.log")
+	file, err := utils.NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+
+	// Each line takes the file past 10 bytes, so each rotates the last one
+	expected := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for name, content := range expected {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Errorf("Expected %s to exist: %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("Expected %q in %s, got %q", content, name, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 old files, got %s.3", path)
+	}
+}
+
+func TestAddLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vssh.log")
+	utils.InitLogger(logrus.WarnLevel, false)
+	defer utils.InitLogger(logrus.InfoLevel, false)
+
+	if err := utils.AddLogFile(path, logrus.DebugLevel, 1<<20, 1); err != nil {
+		t.Fatalf("Failed to add log file: %v", err)
+	}
+	logger := utils.GetLogger()
+	logger.Debugf("Signing certificate for alice@db01")
+	logger.Errorf("Signing failed")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	log := string(data)
+	if !strings.Contains(log, `level=debug msg="Signing certificate for alice@db01"`) {
+		t.Errorf("Expected the debug message in the log file, got %q", log)
+	}
+	if !strings.Contains(log, `level=error msg="Signing failed"`) {
+		t.Errorf("Expected the error message in the log file, got %q", log)
+	}
+}
+
+func TestSetJSONFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vssh.log")
+	utils.InitLogger(logrus.WarnLevel, false)
+	defer utils.InitLogger(logrus.InfoLevel, false)
+
+	utils.SetJSONFormat()
+	if err := utils.AddLogFile(path, logrus.InfoLevel, 1<<20, 1); err != nil {
+		t.Fatalf("Failed to add log file: %v", err)
+	}
+	utils.GetLogger().WithFields(logrus.Fields{
+		"host":        "db01",
+		"user":        "alice",
+		"role":        "dev",
+		"cert_serial": uint64(42),
+	}).Info("Issued certificate")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("Expected a JSON object, got %q: %v", data, err)
+	}
+	expected := map[string]interface{}{
+		"msg":         "Issued certificate",
+		"level":       "info",
+		"host":        "db01",
+		"user":        "alice",
+		"role":        "dev",
+		"cert_serial": float64(42),
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Expected %s %v, got %v", key, value, entry[key])
+		}
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Errorf("Expected a time field, got %v", entry)
+	}
+}
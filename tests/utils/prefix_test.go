@@ -0,0 +1,29 @@
+package utils_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"vssh/internal/utils"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := utils.NewPrefixWriter(&out, "web1: ", &mu)
+
+	w.Write([]byte("first li"))
+	w.Write([]byte("ne\nsecond line\npartial"))
+
+	if got, want := out.String(), "web1: first line\nweb1: second line\n"; got != want {
+		t.Errorf("before flush got %q, want %q", got, want)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got, want := out.String(), "web1: first line\nweb1: second line\nweb1: partial\n"; got != want {
+		t.Errorf("after flush got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+
+package utils_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"vssh/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAddSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	utils.InitLogger(logrus.WarnLevel, false)
+	defer utils.InitLogger(logrus.InfoLevel, false)
+
+	if err := utils.AddSyslog("udp", conn.LocalAddr().String(), "vssh", "auth"); err != nil {
+		t.Fatalf("Failed to add syslog: %v", err)
+	}
+	logger := utils.GetLogger()
+	logger.Info("Using certificate: /certs/alice.pub")
+	logger.WithFields(logrus.Fields{
+		"event":     "connection_closed",
+		"host":      "db01",
+		"user":      "alice",
+		"exit_code": 0,
+	}).Debug("Connection to alice@db01 closed after 5s")
+
+	// Only the event is sent, although the console shows no debug messages
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Expected a syslog message: %v", err)
+	}
+	message := string(buf[:n])
+	for _, expected := range []string{"vssh[", "event=connection_closed", "host=db01", "user=alice"} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected %q in the syslog message, got %q", expected, message)
+		}
+	}
+	// auth (4) at info (6)
+	if !strings.HasPrefix(message, "<38>") {
+		t.Errorf("Expected priority <38>, got %q", message)
+	}
+
+	if err := utils.AddSyslog("", "", "vssh", "kern"); err == nil {
+		t.Errorf("Expected an unknown facility to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package utils_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vssh/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFormatter(t *testing.T) {
+	testCases := []struct {
+		level    logrus.Level
+		color    bool
+		fields   logrus.Fields
+		expected string
+	}{
+		{logrus.InfoLevel, false, nil, "Certificate signed\n"},
+		{logrus.WarnLevel, false, nil, "warning: Certificate signed\n"},
+		{logrus.ErrorLevel, false, nil, "error: Certificate signed\n"},
+		{logrus.DebugLevel, false, nil, "10:30:05.000 debug: Certificate signed\n"},
+		{logrus.InfoLevel, false, logrus.Fields{"role": "dev", "host": "db01"}, "Certificate signed\n"},
+		{logrus.ErrorLevel, true, nil, "\033[1m\033[31merror:\033[0m Certificate signed\n"},
+		{logrus.InfoLevel, true, nil, "Certificate signed\n"},
+	}
+
+	for _, tc := range testCases {
+		entry := &logrus.Entry{
+			Level:   tc.level,
+			Time:    time.Date(2025, 1, 13, 10, 30, 5, 0, time.UTC),
+			Message: "Certificate signed",
+			Data:    tc.fields,
+		}
+		formatter := &utils.Formatter{Color: tc.color}
+		line, err := formatter.Format(entry)
+		if err != nil {
+			t.Fatalf("Format failed: %v", err)
+		}
+		if string(line) != tc.expected {
+			t.Errorf("Expected %q for level %s (color %v), got %q", tc.expected, tc.level, tc.color, line)
+		}
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer file.Close()
+
+	if utils.UseColor(file) {
+		t.Errorf("Expected no color for a file")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if utils.UseColor(os.Stderr) {
+		t.Errorf("Expected no color with NO_COLOR set")
+	}
+}
+
+func TestSpinner_NotATerminal(t *testing.T) {
+	// Under go test stderr isn't a terminal, so the spinner draws nothing and
+	// stopping it returns at once
+	spinner := utils.StartSpinner("Signing certificate...")
+	spinner.Stop()
+	spinner.Stop()
+}
@@ -0,0 +1,86 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"vssh/internal/webhook"
+)
+
+func TestSend(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(webhook.SignatureHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	code := 0
+	event := &webhook.Event{
+		Event:      webhook.SessionEnd,
+		Time:       time.Date(2025, 1, 13, 10, 30, 5, 0, time.UTC),
+		Target:     "db",
+		Host:       "db01",
+		User:       "alice",
+		Port:       "22",
+		Role:       "dev",
+		CertSerial: "42",
+		ExitCode:   &code,
+		Duration:   61.5,
+	}
+	if err := webhook.Send(server.URL, "s3cret", time.Second, event); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if expected := webhook.Sign("s3cret", body); signature != expected {
+		t.Errorf("Expected signature %s, got %s", expected, signature)
+	}
+	var received map[string]interface{}
+	if err := json.Unmarshal(body, &received); err != nil {
+		t.Fatalf("Expected a JSON body, got %q: %v", body, err)
+	}
+	if received["event"] != "session_end" || received["host"] != "db01" || received["cert_serial"] != "42" || received["exit_code"] != float64(0) || received["duration"] != 61.5 {
+		t.Errorf("Unexpected event %v", received)
+	}
+}
+
+func TestSend_Unsigned(t *testing.T) {
+	signed := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, signed = r.Header[webhook.SignatureHeader]
+	}))
+	defer server.Close()
+
+	event := &webhook.Event{Event: webhook.SessionStart}
+	if err := webhook.Send(server.URL, "", time.Second, event); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if signed {
+		t.Errorf("Expected no signature without a secret")
+	}
+}
+
+func TestSend_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if err := webhook.Send(server.URL, "", time.Second, &webhook.Event{}); err == nil {
+		t.Errorf("Expected an error for status 403")
+	}
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 of "hello" with key "key"
+	expected := "sha256=9307b3b915efb5171ff14d8cb55fbcc798c6c0ef1456d66ded1a6aa723a58b7b"
+	if signature := webhook.Sign("key", []byte("hello")); signature != expected {
+		t.Errorf("Expected %s, got %s", expected, signature)
+	}
+}